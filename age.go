@@ -0,0 +1,42 @@
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// returns the moon's age at now in days, the time since the last New Moon at or before now in
+// recentData, to the minute the API gives. a New Moon at now itself is age 0, so a moment just
+// before one is close to a whole cycle old and one just after close to 0, never flipping between them
+func GetMoonAge(now time.Time, recentData []MoonPhase) (float64, error) {
+	var lastNewMoon time.Time
+	for _, phase := range recentData {
+		instant, err := GetPhaseInstant(phase)
+		if err != nil {
+			return 0, err
+		}
+		if (Phase(phase.Phase) == NewMoon && !instant.After(now)) {
+			lastNewMoon = instant
+		}
+	}
+	if (lastNewMoon.IsZero()) {
+		return 0, fmt.Errorf("date range of recent data doesn't have a New Moon before %s", now.Format(time.RFC3339))
+	}
+	return now.Sub(lastNewMoon).Hours() / 24, nil
+}
+
+// Get the moon's age at t in days, see GetMoonAge
+func (c *Client) GetAge(ctx context.Context, t time.Time) (float64, error) {
+	// four consecutive primary phases hold one of each
+	recentData, err := c.getMoonDataAround(ctx, t, FetchStrategy{Past: 4})
+	if err != nil {
+		return 0, err
+	}
+	return GetMoonAge(t, recentData)
+}
+
+// Get the moon's age at t in days using DefaultClient, see GetMoonAge
+func Age(ctx context.Context, t time.Time) (float64, error) {
+	return DefaultClient.GetAge(ctx, t)
+}
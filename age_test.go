@@ -0,0 +1,128 @@
+package moonphase_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// the recorded New Moon of 2024-03-10 09:00 UT, 29 days 10 hours and 1 minute after the one of
+// February 9th
+var ageNewMoon = time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+
+// a moment before the New Moon is nearly a whole lunation old, the New Moon itself and anything
+// after it count from 0
+func TestAgeEitherSideOfNewMoon(t *testing.T) {
+	lunation := 29 + 601.0 / 1440
+	tests := []struct {
+		offset time.Duration
+		want   float64
+	}{
+		{-6 * time.Hour, lunation - 0.25},
+		{-time.Hour, lunation - 1.0 / 24},
+		{-time.Minute, lunation - 1.0 / 1440},
+		{0, 0},
+		{time.Minute, 1.0 / 1440},
+		{time.Hour, 1.0 / 24},
+		{6 * time.Hour, 0.25},
+	}
+	phases := moonphasetest.MoonPhases(moonphasetest.ReferenceEvents()...)
+	for _, test := range tests {
+		got, err := moonphase.GetMoonAge(ageNewMoon.Add(test.offset), phases)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (math.Abs(got - test.want) > 1e-9) {
+			t.Errorf("%s from the New Moon: got %.5f days, want %.5f", test.offset, got, test.want)
+		}
+	}
+}
+
+// every minute from twelve hours before the New Moon to twelve after, seen from any zone, the age
+// rises by the minute and drops to 0 once, at the New Moon, the same on every lookup
+func TestAgeNeverFlips(t *testing.T) {
+	client := newReferenceClient()
+	ctx := context.Background()
+	start := ageNewMoon.Add(-12 * time.Hour)
+	end := ageNewMoon.Add(12 * time.Hour)
+	for _, zone := range []string{"UTC", "America/Los_Angeles", "Asia/Tokyo", "Pacific/Kiritimati"} {
+		location := loadLocation(t, zone)
+		previous := -1.0
+		for instant := start; !instant.After(end); instant = instant.Add(time.Minute) {
+			local := instant.In(location)
+			got, err := client.GetAge(ctx, local)
+			if err != nil {
+				t.Fatalf("%s %s: %v", zone, local.Format(time.RFC3339), err)
+			}
+			again, err := client.GetAge(ctx, local)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (again != got) {
+				t.Fatalf("%s %s: got %.5f days, then %.5f", zone, local.Format(time.RFC3339), got, again)
+			}
+			if (instant.Before(ageNewMoon)) {
+				if (got < 28.5 || got <= previous) {
+					t.Fatalf("%s %s: got %.5f days after %.5f, want nearly a whole lunation and rising", zone, local.Format(time.RFC3339), got, previous)
+				}
+			} else if (got > 0.5 || (instant.After(ageNewMoon) && got <= previous)) {
+				t.Fatalf("%s %s: got %.5f days after %.5f, want under half a day and rising", zone, local.Format(time.RFC3339), got, previous)
+			}
+			previous = got
+		}
+	}
+}
+
+// offline the age counts from the mean New Moon the same way, whole cycle just before and 0 at it
+func TestOfflineAgeEitherSideOfNewMoon(t *testing.T) {
+	var meanNewMoon time.Time
+	for _, phase := range moonphase.MeanPhases(ageNewMoon) {
+		instant, err := moonphase.GetPhaseInstant(phase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (moonphase.Phase(phase.Phase) == moonphase.NewMoon && math.Abs(instant.Sub(ageNewMoon).Hours()) < 24) {
+			meanNewMoon = instant
+		}
+	}
+	if (meanNewMoon.IsZero()) {
+		t.Fatalf("no mean New Moon within a day of %s", ageNewMoon)
+	}
+	cycle := moonphase.MeanSynodicMonth.Hours() / 24
+	for _, test := range []struct {
+		offset time.Duration
+		want   float64
+	}{
+		{-time.Minute, cycle},
+		{0, 0},
+		{time.Minute, 0},
+	} {
+		instant := meanNewMoon.Add(test.offset)
+		got, err := moonphase.GetMoonAge(instant, moonphase.MeanPhases(instant))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the mean phases are rounded to the minute
+		if (math.Abs(got - test.want) > 2.0 / 1440) {
+			t.Errorf("%s from the mean New Moon: got %.5f days, want %.5f", test.offset, got, test.want)
+		}
+	}
+}
+
+// data without a New Moon at or before the moment is an error rather than an age from the next one
+func TestAgeWithoutNewMoon(t *testing.T) {
+	phases := moonphasetest.MoonPhases(
+		moonphasetest.Event(moonphase.FirstQuarter, "2024-03-17 04:11"),
+		moonphasetest.Event(moonphase.FullMoon, "2024-03-25 07:00"),
+		moonphasetest.Event(moonphase.NewMoon, "2024-04-08 18:21"),
+	)
+	_, err := moonphase.GetMoonAge(time.Date(2024, time.April, 8, 18, 20, 0, 0, time.UTC), phases)
+	if (err == nil || !strings.Contains(err.Error(), "doesn't have a New Moon")) {
+		t.Errorf("got %v, want an error with \"doesn't have a New Moon\"", err)
+	}
+}
@@ -0,0 +1,127 @@
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// a primary phase event relative to the anchor of an around query.
+// durations are whole seconds for machines, with the human form alongside in a _human field
+type AroundEvent struct {
+	Phase         Phase   `json:"phase"`
+	PhaseID       string  `json:"phase_id"`
+	Instant       Instant `json:"instant"`
+	LocalDate     DayKey  `json:"local_date"`
+	OffsetDays    int     `json:"offset_days"`
+	OffsetSeconds int64   `json:"offset_seconds"`
+	OffsetHuman   string  `json:"offset_human"`
+}
+
+// formats a day offset like -9d, 0d or +4d
+func formatDayOffset(days int) string {
+	if (days == 0) {
+		return "0d"
+	}
+	return fmt.Sprintf("%+dd", days)
+}
+
+// returns the most events either side GetPhasesAround can list, limited by how many phases
+// the API returns in one request
+func MaxAroundEvents() int {
+	n := 1
+	for {
+		_, numPhases := FetchWindow(time.Time{}, FetchStrategy{Past: n + 1, Future: n + 1})
+		if (numPhases > maxNumPhases) {
+			return n
+		}
+		n++
+	}
+}
+
+// returns the n primary phase events either side of anchor, events at the anchor instant count as after it.
+// local dates and day offsets are in anchor's location
+func (c *Client) GetPhasesAround(ctx context.Context, anchor time.Time, n int) ([]AroundEvent, error) {
+	if (n < 1) {
+		return nil, fmt.Errorf("need at least 1 event either side, got %d", n)
+	}
+	if (n > MaxAroundEvents()) {
+		return nil, fmt.Errorf("%d events either side needs more phases than the API returns in one request, the most is %d", n, MaxAroundEvents())
+	}
+	phases, err := c.getMoonDataAround(ctx, anchor, FetchStrategy{Past: n, Future: n, ExactTimes: true})
+	if err != nil {
+		return nil, err
+	}
+	anchorDay := NewDayKey(anchor)
+	var before []AroundEvent
+	var after []AroundEvent
+	for _, phase := range phases {
+		instant := phaseInstant(phase)
+		localDay := NewDayKey(instant.In(anchor.Location()))
+		// offsets count calendar days, so the narrative "four days after" matches the calendar
+		offsetDays := int(localDay.Time(time.UTC).Sub(anchorDay.Time(time.UTC)).Hours() / 24)
+		event := AroundEvent{
+			Phase:         Phase(phase.Phase),
+			PhaseID:       PhaseID(phase.Phase),
+			Instant:       Instant{instant},
+			LocalDate:     localDay,
+			OffsetDays:    offsetDays,
+			OffsetSeconds: int64(instant.Sub(anchor) / time.Second),
+			OffsetHuman:   formatDayOffset(offsetDays),
+		}
+		if (instant.Before(anchor)) {
+			before = append(before, event)
+		} else if (len(after) < n) {
+			after = append(after, event)
+		}
+	}
+	if (len(before) < n || len(after) < n) {
+		return nil, fmt.Errorf("date range of recent data doesn't have enough phases around the date")
+	}
+	return append(before[len(before) - n:], after...), nil
+}
+
+// returns the instant of the first primary phase named phase strictly after after
+func (c *Client) NextPhase(ctx context.Context, after time.Time, phase Phase) (time.Time, error) {
+	// four consecutive primary phases hold one of each
+	phases, err := c.getMoonDataAround(ctx, after, FetchStrategy{Past: 1, Future: 4, ExactTimes: true})
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, candidate := range phases {
+		instant := phaseInstant(candidate)
+		if (Phase(candidate.Phase) == phase && instant.After(after)) {
+			return instant, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no %s found after %s", phase, after.Format(time.RFC3339))
+}
+
+// returns the instant of the last primary phase named phase strictly before before
+func (c *Client) PreviousPhase(ctx context.Context, before time.Time, phase Phase) (time.Time, error) {
+	phases, err := c.getMoonDataAround(ctx, before, FetchStrategy{Past: 4, Future: 1, ExactTimes: true})
+	if err != nil {
+		return time.Time{}, err
+	}
+	var previous time.Time
+	for _, candidate := range phases {
+		instant := phaseInstant(candidate)
+		if (Phase(candidate.Phase) == phase && instant.Before(before)) {
+			previous = instant
+		}
+	}
+	if (previous.IsZero()) {
+		return time.Time{}, fmt.Errorf("no %s found before %s", phase, before.Format(time.RFC3339))
+	}
+	return previous, nil
+}
+
+// returns the instant of the next primary phase named phase after after, using DefaultClient
+func NextPhase(ctx context.Context, after time.Time, phase Phase) (time.Time, error) {
+	return DefaultClient.NextPhase(ctx, after, phase)
+}
+
+// returns the instant of the last primary phase named phase before before, using DefaultClient
+func PreviousPhase(ctx context.Context, before time.Time, phase Phase) (time.Time, error) {
+	return DefaultClient.PreviousPhase(ctx, before, phase)
+}
@@ -0,0 +1,152 @@
+package moonphase_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// returns the instants of the reference events of phase
+func referenceInstants(t *testing.T, phase moonphase.Phase) []time.Time {
+	t.Helper()
+	var instants []time.Time
+	for _, event := range moonphasetest.MoonPhases(moonphasetest.ReferenceEvents()...) {
+		if (moonphase.Phase(event.Phase) != phase) {
+			continue
+		}
+		instant, err := moonphase.GetPhaseInstant(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instants = append(instants, instant)
+	}
+	return instants
+}
+
+// a phase at the moment itself is neither the previous nor the next one, both are strictly
+// either side of it
+func TestPreviousAndNextPhaseAtTheInstant(t *testing.T) {
+	client := newReferenceClient()
+	ctx := context.Background()
+	fullMoon := time.Date(2024, time.March, 25, 7, 0, 0, 0, time.UTC)
+	tests := []struct {
+		from     time.Time
+		phase    moonphase.Phase
+		previous string
+		next     string
+	}{
+		{fullMoon, moonphase.FullMoon, "2024-02-24T12:30:00Z", "2024-04-23T23:49:00Z"},
+		{fullMoon.Add(time.Minute), moonphase.FullMoon, "2024-03-25T07:00:00Z", "2024-04-23T23:49:00Z"},
+		{fullMoon.Add(-time.Minute), moonphase.FullMoon, "2024-02-24T12:30:00Z", "2024-03-25T07:00:00Z"},
+		{fullMoon, moonphase.NewMoon, "2024-03-10T09:00:00Z", "2024-04-08T18:21:00Z"},
+		{fullMoon, moonphase.FirstQuarter, "2024-03-17T04:11:00Z", "2024-04-15T19:13:00Z"},
+		{fullMoon, moonphase.LastQuarter, "2024-03-03T15:23:00Z", "2024-04-02T03:15:00Z"},
+	}
+	for _, test := range tests {
+		previous, err := client.PreviousPhase(ctx, test.from, test.phase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := client.NextPhase(ctx, test.from, test.phase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (previous.Format(time.RFC3339) != test.previous || next.Format(time.RFC3339) != test.next) {
+			t.Errorf("%s from %s: got %s and %s, want %s and %s", test.phase, test.from.Format(time.RFC3339), previous.Format(time.RFC3339), next.Format(time.RFC3339), test.previous, test.next)
+		}
+	}
+}
+
+// from every six hours of the first half of 2024, each primary phase's previous and next
+// instances are the reference events either side, with none of that phase between them
+func TestPreviousAndNextPhaseSweep(t *testing.T) {
+	client := newReferenceClient()
+	ctx := context.Background()
+	for _, phase := range []moonphase.Phase{moonphase.NewMoon, moonphase.FirstQuarter, moonphase.FullMoon, moonphase.LastQuarter} {
+		instants := referenceInstants(t, phase)
+		for from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC); from.Year() == 2024 && from.Month() <= time.June; from = from.Add(6 * time.Hour) {
+			var wantPrevious, wantNext time.Time
+			for _, instant := range instants {
+				if (instant.Before(from)) {
+					wantPrevious = instant
+				} else if (instant.After(from) && wantNext.IsZero()) {
+					wantNext = instant
+				}
+			}
+			previous, err := client.PreviousPhase(ctx, from, phase)
+			if err != nil {
+				t.Fatalf("%s before %s: %v", phase, from.Format(time.RFC3339), err)
+			}
+			next, err := client.NextPhase(ctx, from, phase)
+			if err != nil {
+				t.Fatalf("%s after %s: %v", phase, from.Format(time.RFC3339), err)
+			}
+			if (!previous.Equal(wantPrevious) || !next.Equal(wantNext)) {
+				t.Fatalf("%s from %s: got %s and %s, want %s and %s", phase, from.Format(time.RFC3339), previous, next, wantPrevious, wantNext)
+			}
+		}
+	}
+}
+
+// formats events as "new-moon 2024-03-10 0d 0", their phase, local date and offsets
+func formatAroundEvents(events []moonphase.AroundEvent) string {
+	var formatted []string
+	for _, event := range events {
+		formatted = append(formatted, fmt.Sprintf("%s %s %s %d", event.PhaseID, event.LocalDate, event.OffsetHuman, event.OffsetSeconds))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// either side of the New Moon of 2024-03-10 09:00 UT, a phase at the anchor itself is the first
+// after it and one a minute earlier the last before, with the local dates and day offsets in the
+// anchor's zone, where the New Moon can be on the next day
+func TestGetPhasesAroundLunationEdges(t *testing.T) {
+	client := newReferenceClient()
+	newMoon := time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		anchor time.Time
+		n      int
+		want   string
+	}{
+		{"at the New Moon", newMoon, 1, "last-quarter 2024-03-03 -7d -581820, new-moon 2024-03-10 0d 0"},
+		{"a minute before", newMoon.Add(-time.Minute), 1, "last-quarter 2024-03-03 -7d -581760, new-moon 2024-03-10 0d 60"},
+		{"a minute after", newMoon.Add(time.Minute), 1, "new-moon 2024-03-10 0d -60, first-quarter 2024-03-17 +7d 587400"},
+		{"the evening before in Tokyo", time.Date(2024, time.March, 9, 23, 30, 0, 0, loadLocation(t, "Asia/Tokyo")), 1, "last-quarter 2024-03-04 -5d -515220, new-moon 2024-03-10 +1d 66600"},
+		{"two either side of the Full Moon", time.Date(2024, time.March, 25, 7, 0, 0, 0, time.UTC), 2, "new-moon 2024-03-10 -15d -1288800, first-quarter 2024-03-17 -8d -701340, full-moon 2024-03-25 0d 0, last-quarter 2024-04-02 +8d 677700"},
+	}
+	for _, test := range tests {
+		events, err := client.GetPhasesAround(context.Background(), test.anchor, test.n)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := formatAroundEvents(events); (got != test.want) {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// no events either side, more than one request can hold, or an anchor at the edge of the data are errors
+func TestGetPhasesAroundErrors(t *testing.T) {
+	client := newReferenceClient()
+	tests := []struct {
+		name   string
+		anchor time.Time
+		n      int
+	}{
+		{"no events", time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), 0},
+		{"past one request", time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), moonphase.MaxAroundEvents() + 1},
+		// the reference events start with the Last Quarter of 2023-12-05
+		{"before the data", time.Date(2023, time.December, 6, 0, 0, 0, 0, time.UTC), 2},
+	}
+	for _, test := range tests {
+		if events, err := client.GetPhasesAround(context.Background(), test.anchor, test.n); (err == nil) {
+			t.Errorf("%s: got %s, want an error", test.name, formatAroundEvents(events))
+		}
+	}
+}
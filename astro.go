@@ -0,0 +1,22 @@
+package moonphase
+
+import (
+	"time"
+)
+
+// Astronomical constants shared by everything that reasons about the lunar cycle.
+// Keep them here rather than repeating the literals where they're used.
+
+// the mean length of a lunation, new moon to new moon, 29.530588853 days
+// source: Meeus, Astronomical Algorithms (2nd ed.), chapter 49
+const MeanSynodicMonth time.Duration = 29 * 24 * time.Hour + 12 * time.Hour + 44 * time.Minute + 2876899200 * time.Nanosecond
+
+// the interval between consecutive primary phases varies from about 6.5 to 8.3 days
+// around a quarter of MeanSynodicMonth because the moon's orbit is eccentric, these bound it in whole days
+const minPhaseIntervalDays int = 6
+const maxPhaseIntervalDays int = 9
+
+// the first New Moon of 2000, 2000-01-06 18:14 UT, as published by the USNO
+// https://aa.usno.navy.mil/api/moon/phases/date?date=2000-01-01&nump=4
+var ReferenceNewMoon = time.Date(2000, time.January, 6, 18, 14, 0, 0, time.UTC)
+
@@ -0,0 +1,110 @@
+package moonphase_test
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the length of a lunation written out in days or seconds, as it would be if the constant were
+// repeated instead of used
+var synodicMonthPattern = regexp.MustCompile(`29\.5[0-9]|255144[0-9]`)
+
+// no Go file in the module but astro.go, and this guard, spells out the synodic month, in code or
+// in a comment, as 29.53 days, 2551443 seconds or a float near them, everything else goes through
+// MeanSynodicMonth
+func TestNoSynodicMonthLiterals(t *testing.T) {
+	files := token.NewFileSet()
+	checked := 0
+	err := filepath.WalkDir(".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if (entry.IsDir() && (entry.Name() == ".git" || entry.Name() == "testdata")) {
+			return filepath.SkipDir
+		}
+		if (entry.IsDir() || !strings.HasSuffix(path, ".go") || path == "astro.go" || path == "astro_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(files, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		checked++
+		for _, group := range file.Comments {
+			if (synodicMonthPattern.MatchString(group.Text())) {
+				t.Errorf("%s: a comment spells out the synodic month, refer to MeanSynodicMonth", files.Position(group.Pos()))
+			}
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			literal, isLiteral := node.(*ast.BasicLit)
+			if (!isLiteral || (literal.Kind != token.FLOAT && literal.Kind != token.INT)) {
+				return true
+			}
+			value, err := strconv.ParseFloat(strings.ReplaceAll(literal.Value, "_", ""), 64)
+			near := err == nil && ((value >= 29.5 && value < 29.6) || (value >= 2551000 && value < 2552000))
+			if (near || synodicMonthPattern.MatchString(literal.Value)) {
+				t.Errorf("%s: %s is the synodic month, use MeanSynodicMonth", files.Position(literal.Pos()), literal.Value)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the package, the command and the test helpers
+	if (checked < 40) {
+		t.Errorf("checked %d files, want the whole module", checked)
+	}
+}
+
+// counting lunations from ReferenceNewMoon in whole MeanSynodicMonths numbers the recorded New
+// Moons one after another with none skipped or counted twice, each within a day of the mean one,
+// and the recorded lunations average to the constant within an hour
+func TestMeanSynodicMonthMatchesUSNO(t *testing.T) {
+	recorded, err := moonphase.FileProvider{Path: "moonphasetest/testdata/usno-2024.json"}.GetMoonData(context.Background(), moonphase.DayKey{Year: 2023, Month: time.January, Day: 1}, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var newMoons []time.Time
+	for _, phase := range recorded {
+		if (moonphase.Phase(phase.Phase) == moonphase.NewMoon) {
+			instant, err := moonphase.GetPhaseInstant(phase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newMoons = append(newMoons, instant)
+		}
+	}
+	if (len(newMoons) < 13) {
+		t.Fatalf("the fixture has %d New Moons, want a year of them", len(newMoons))
+	}
+	first := int(math.Round(float64(newMoons[0].Sub(moonphase.ReferenceNewMoon)) / float64(moonphase.MeanSynodicMonth)))
+	for i, instant := range newMoons {
+		lunation := int(math.Round(float64(instant.Sub(moonphase.ReferenceNewMoon)) / float64(moonphase.MeanSynodicMonth)))
+		if (lunation != first + i) {
+			t.Errorf("the New Moon of %s is lunation %d, want %d", instant.Format(time.RFC3339), lunation, first + i)
+		}
+		mean := moonphase.ReferenceNewMoon.Add(time.Duration(lunation) * moonphase.MeanSynodicMonth)
+		if (absDuration(instant.Sub(mean)) > 24 * time.Hour) {
+			t.Errorf("the New Moon of %s is %s from the mean one", instant.Format(time.RFC3339), instant.Sub(mean))
+		}
+	}
+	average := newMoons[len(newMoons) - 1].Sub(newMoons[0]) / time.Duration(len(newMoons) - 1)
+	// a single lunation runs up to about 7 hours either side of the mean, a year of them averages out
+	if (absDuration(average - moonphase.MeanSynodicMonth) > time.Hour) {
+		t.Errorf("the recorded lunations average %s, MeanSynodicMonth is %s", average, moonphase.MeanSynodicMonth)
+	}
+}
@@ -0,0 +1,590 @@
+package moonphase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how long a cached API response is trusted before it's fetched again
+const cacheMaxAge time.Duration = 30 * 24 * time.Hour
+
+// a day either side of the anchor a cached range has to reach past, so a phase later on the
+// anchor's own local day isn't mistaken for the next one. FetchWindow always reaches further
+const cacheMarginDays int = 1
+
+// past this total size, saving a response purges the older half of the cache
+const cacheMaxBytes int64 = 8 << 20
+
+// RefreshPolicy is when the predicted instants in a cached response are too old to report. the
+// USNO API sometimes revises its predictions by a minute or two, which doesn't move a phase to
+// another day but does change the time a lookup like NextPhase reports
+type RefreshPolicy struct {
+	// a response holding a phase more than this far ahead of when it was fetched...
+	Ahead time.Duration
+	// ...is fetched again once it's older than this, never when negative
+	After time.Duration
+}
+
+// DefaultRefreshPolicy refreshes a response a week old for phases predicted over a month ahead
+var DefaultRefreshPolicy = RefreshPolicy{Ahead: 30 * 24 * time.Hour, After: 7 * 24 * time.Hour}
+
+// reports whether phases, fetched at fetched, are due to be fetched again for their exact instants
+func (p RefreshPolicy) due(phases []MoonPhase, fetched time.Time) bool {
+	if (p.After < 0 || time.Since(fetched) <= p.After) {
+		return false
+	}
+	for _, phase := range phases {
+		if (phaseInstant(phase).Sub(fetched) > p.Ahead) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns c.Refresh, or DefaultRefreshPolicy when it's zero
+func (c *Client) refreshPolicy() RefreshPolicy {
+	if (c.Refresh == RefreshPolicy{}) {
+		return DefaultRefreshPolicy
+	}
+	return c.Refresh
+}
+
+// what a Client's cache did for its lookups
+type CacheStats struct {
+	// lookups answered from the cache
+	Hits int
+	// lookups the cache had nothing for
+	Misses int
+	// lookups whose cached response was fetched again, by the RefreshPolicy or ForceRefresh
+	Refreshes int
+	// lookups failed with an ErrCachedFailure, without asking the API
+	Failures int
+}
+
+// returns what c's cache has done for its lookups so far
+func (c *Client) CacheStats() CacheStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.cacheStats
+}
+
+// the requests a Client sent the USNO API
+type UpstreamStats struct {
+	// requests sent, each retry counting as another
+	Requests int
+	// requests that got no response, or a 4xx or 5xx status
+	Errors int
+}
+
+// returns the requests c has sent the API so far
+func (c *Client) UpstreamStats() UpstreamStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.upstreamStats
+}
+
+// counts a lookup in c's CacheStats
+func (c *Client) countCacheLookup(count func(stats *CacheStats)) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	count(&c.cacheStats)
+}
+
+// returns the name of the cache file for a date query
+func cacheFileName(startDay DayKey, numPhases int) string {
+	return fmt.Sprintf("%s-n%d.json", startDay, numPhases)
+}
+
+// the end of the names of cached failures, which sit beside the responses in their partitions
+const cacheErrorSuffix string = ".error.json"
+
+// returns the name of the file caching a date query's permanent failure
+func cacheErrorFileName(startDay DayKey, numPhases int) string {
+	return fmt.Sprintf("%s-n%d%s", startDay, numPhases, cacheErrorSuffix)
+}
+
+// returns the directory in c.CacheDir holding the responses for date queries starting in year.
+// partitioning by year means a lookup only reads the couple of years that could cover it
+func (c *Client) cachePartition(year int) string {
+	return filepath.Join(c.CacheDir, fmt.Sprintf("%04d", year))
+}
+
+// a cached response as found in c.CacheDir
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+	// a cached failure rather than phases
+	failure bool
+}
+
+// lists the cached responses in the partitions for years, along with the size of each. partitions
+// that don't exist are skipped
+func (c *Client) cacheEntries(years []int) []cacheEntry {
+	var entries []cacheEntry
+	for _, year := range years {
+		dirEntries, err := os.ReadDir(c.cachePartition(year))
+		if err != nil {
+			continue
+		}
+		for _, dirEntry := range dirEntries {
+			if (!strings.HasSuffix(dirEntry.Name(), ".json")) {
+				continue
+			}
+			info, err := dirEntry.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(c.cachePartition(year), dirEntry.Name())
+			entries = append(entries, cacheEntry{path: path, size: info.Size(), modTime: info.ModTime(), failure: strings.HasSuffix(dirEntry.Name(), cacheErrorSuffix)})
+		}
+	}
+	return entries
+}
+
+// returns the years of every partition in c.CacheDir, in order
+func (c *Client) cacheYears() ([]int, error) {
+	dirEntries, err := os.ReadDir(c.CacheDir)
+	if err != nil {
+		if (os.IsNotExist(err)) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var years []int
+	for _, dirEntry := range dirEntries {
+		year, err := strconv.Atoi(dirEntry.Name())
+		if (dirEntry.IsDir() && err == nil) {
+			years = append(years, year)
+		}
+	}
+	return years, nil
+}
+
+// reads the phases of a cached response
+func readCacheEntry(path string) ([]MoonPhase, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var phases []MoonPhase
+	if err := json.Unmarshal(content, &phases); err != nil {
+		return nil, err
+	}
+	return phases, nil
+}
+
+// reports whether phases, consecutive as the API returns them, has strategy.Past phases before
+// anchor and strategy.Future after it, with cacheMarginDays to spare
+func coversAnchor(phases []MoonPhase, anchor time.Time, strategy FetchStrategy) bool {
+	before := 0
+	after := 0
+	for _, phase := range phases {
+		instant, err := GetPhaseInstant(phase)
+		if err != nil {
+			return false
+		}
+		if (instant.Before(anchor.AddDate(0, 0, -cacheMarginDays))) {
+			before++
+		}
+		if (instant.After(anchor.AddDate(0, 0, cacheMarginDays))) {
+			after++
+		}
+	}
+	past := strategy.Past
+	if (past < 1) {
+		past = 1
+	}
+	future := strategy.Future
+	if (future < 1) {
+		future = 1
+	}
+	return before >= past && after >= future
+}
+
+// looks through c.CacheDir for a cached response that covers anchor, removing expired ones on the way.
+// FetchWindow never reaches back more than a year, so only the partitions for the anchor's year
+// and the one before can hold a response covering it. the second result is false when there's
+// none, and the third true when one covers it but is due a refresh for strategy.ExactTimes
+func (c *Client) loadCachedMoonData(anchor time.Time, strategy FetchStrategy) ([]MoonPhase, bool, bool) {
+	anchorYear := anchor.UTC().Year()
+	refresh := false
+	for _, entry := range c.cacheEntries([]int{anchorYear - 1, anchorYear}) {
+		if (time.Since(entry.modTime) > cacheMaxAge) {
+			os.Remove(entry.path)
+			continue
+		}
+		if (entry.failure) {
+			continue
+		}
+		phases, err := readCacheEntry(entry.path)
+		// a corrupt file is a miss, the fetch replaces it
+		if err != nil {
+			os.Remove(entry.path)
+			continue
+		}
+		if (!coversAnchor(phases, anchor, strategy)) {
+			continue
+		}
+		// another response may be fresher
+		if (strategy.ExactTimes && c.refreshPolicy().due(phases, entry.modTime)) {
+			refresh = true
+			continue
+		}
+		return phases, true, false
+	}
+	return nil, false, refresh
+}
+
+// writes a fetched response to c.CacheDir. the cache is only an optimisation, so failing to
+// write it isn't an error
+func (c *Client) saveCachedMoonData(startDay DayKey, numPhases int, phases []MoonPhase) {
+	content, err := json.Marshal(phases)
+	if err != nil {
+		return
+	}
+	partition := c.cachePartition(startDay.Year)
+	if err := os.MkdirAll(partition, 0755); err != nil {
+		return
+	}
+	file, err := os.CreateTemp(partition, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); (err == nil) {
+		err = closeErr
+	}
+	if (err == nil) {
+		err = os.Rename(file.Name(), filepath.Join(partition, cacheFileName(startDay, numPhases)))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return
+	}
+	// years of daily use would otherwise grow the cache without bound
+	if (c.cacheSize() > cacheMaxBytes) {
+		c.PurgeCache(cacheMaxAge / 2)
+	}
+}
+
+// returns the total size of the cached responses in c.CacheDir
+func (c *Client) cacheSize() int64 {
+	years, err := c.cacheYears()
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, entry := range c.cacheEntries(years) {
+		size += entry.size
+	}
+	return size
+}
+
+// PurgeCache removes the cached responses in c.CacheDir fetched more than olderThan ago, along
+// with any partitions left empty, and returns how many responses it removed
+func (c *Client) PurgeCache(olderThan time.Duration) (int, error) {
+	if (c.CacheDir == "") {
+		return 0, nil
+	}
+	years, err := c.cacheYears()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range c.cacheEntries(years) {
+		if (time.Since(entry.modTime) > olderThan) {
+			if err := os.Remove(entry.path); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	c.removeEmptyPartitions(years)
+	return removed, nil
+}
+
+// what CompactCache did to c.CacheDir
+type CacheCompaction struct {
+	// expired or unreadable responses removed
+	Expired int
+	// responses removed because another response in the same partition holds all their phases
+	Redundant int
+	// responses from before the cache was partitioned by year, removed
+	Unpartitioned int
+	// responses left
+	Kept int
+}
+
+// CompactCache rewrites c.CacheDir down to the responses lookups can still use. expired and
+// unreadable responses are removed, as is any response whose phases all lie within another
+// one in the same partition, and the files left at the top level by older versions
+func (c *Client) CompactCache() (CacheCompaction, error) {
+	var compaction CacheCompaction
+	if (c.CacheDir == "") {
+		return compaction, nil
+	}
+	years, err := c.cacheYears()
+	if err != nil {
+		return compaction, err
+	}
+	dirEntries, err := os.ReadDir(c.CacheDir)
+	if err != nil {
+		return compaction, err
+	}
+	for _, dirEntry := range dirEntries {
+		if (!dirEntry.IsDir() && strings.HasSuffix(dirEntry.Name(), ".json")) {
+			if err := os.Remove(filepath.Join(c.CacheDir, dirEntry.Name())); err != nil {
+				return compaction, err
+			}
+			compaction.Unpartitioned++
+		}
+	}
+	for _, year := range years {
+		// the first and last phase instants of each usable response
+		type span struct {
+			path        string
+			first, last time.Time
+		}
+		var spans []span
+		for _, entry := range c.cacheEntries([]int{year}) {
+			if (entry.failure) {
+				if (time.Since(entry.modTime) > cacheMaxAge) {
+					if err := os.Remove(entry.path); err != nil {
+						return compaction, err
+					}
+					compaction.Expired++
+				} else {
+					compaction.Kept++
+				}
+				continue
+			}
+			phases, err := readCacheEntry(entry.path)
+			if (err != nil || len(phases) == 0 || time.Since(entry.modTime) > cacheMaxAge) {
+				if err := os.Remove(entry.path); err != nil {
+					return compaction, err
+				}
+				compaction.Expired++
+				continue
+			}
+			first, firstErr := GetPhaseInstant(phases[0])
+			last, lastErr := GetPhaseInstant(phases[len(phases) - 1])
+			if (firstErr != nil || lastErr != nil) {
+				if err := os.Remove(entry.path); err != nil {
+					return compaction, err
+				}
+				compaction.Expired++
+				continue
+			}
+			spans = append(spans, span{entry.path, first, last})
+		}
+		// widest first, so a response is only ever dropped in favour of one that's kept
+		sort.Slice(spans, func(i, j int) bool {
+			return spans[i].last.Sub(spans[i].first) > spans[j].last.Sub(spans[j].first)
+		})
+		var kept []span
+		for _, candidate := range spans {
+			redundant := false
+			for _, keeper := range kept {
+				if (!candidate.first.Before(keeper.first) && !candidate.last.After(keeper.last)) {
+					redundant = true
+					break
+				}
+			}
+			if (!redundant) {
+				kept = append(kept, candidate)
+				continue
+			}
+			if err := os.Remove(candidate.path); err != nil {
+				return compaction, err
+			}
+			compaction.Redundant++
+		}
+		compaction.Kept += len(kept)
+	}
+	c.removeEmptyPartitions(years)
+	return compaction, nil
+}
+
+// removes the partitions for years that no longer hold any responses
+func (c *Client) removeEmptyPartitions(years []int) {
+	for _, year := range years {
+		// only succeeds for an empty directory
+		os.Remove(c.cachePartition(year))
+	}
+}
+
+// returned in place of a permanent failure of the API that c.CacheDir recorded, so a lookup that's
+// bound to fail again doesn't ask. it wraps the original error, which errors.As still finds
+type ErrCachedFailure struct {
+	// when the API failed
+	Fetched time.Time
+	Err     error
+}
+
+func (e *ErrCachedFailure) Error() string {
+	return fmt.Sprintf("%v (cached-error, the API failed the same way at %s)", e.Err, e.Fetched.UTC().Format(time.RFC3339))
+}
+
+func (e *ErrCachedFailure) Unwrap() error {
+	return e.Err
+}
+
+// a permanent failure as the cache keeps it
+type cachedFailure struct {
+	// rejected, status or outside-coverage, for the error type it was
+	Kind    string  `json:"kind"`
+	Status  int     `json:"status,omitempty"`
+	Message string  `json:"message,omitempty"`
+	// the day an outside-coverage failure asked for
+	Day     *DayKey `json:"day,omitempty"`
+}
+
+// returns err as the cache keeps it, and false when it's not a failure the API will answer the
+// same way next time. timeouts, 5xx and 429 responses are transient, as is anything that isn't
+// the API's own answer, like a failure of every provider in a chain. a date the API doesn't cover
+// is only recorded when the API was asked, since the check before asking costs nothing
+func permanentFailure(err error, startDay DayKey) (cachedFailure, bool) {
+	var rejected *ErrUpstreamRejected
+	var status *ErrUpstreamStatus
+	var coverage *ErrOutsideCoverage
+	transient := func(code int) bool {
+		return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+	}
+	switch {
+	case errors.As(err, &rejected) && !transient(rejected.Status):
+		return cachedFailure{Kind: "rejected", Status: rejected.Status, Message: rejected.Message}, true
+	case errors.As(err, &status) && status.Status >= 400 && !transient(status.Status):
+		return cachedFailure{Kind: "status", Status: status.Status}, true
+	case errors.As(err, &coverage) && USNOCovers(startDay):
+		return cachedFailure{Kind: "outside-coverage", Day: &coverage.Day}, true
+	}
+	return cachedFailure{}, false
+}
+
+// returns the error of a failure as it was before the cache kept it
+func (f cachedFailure) err() error {
+	switch f.Kind {
+	case "rejected":
+		return &ErrUpstreamRejected{Status: f.Status, Message: f.Message}
+	case "status":
+		return &ErrUpstreamStatus{Status: f.Status}
+	}
+	if (f.Day == nil) {
+		return &ErrOutsideCoverage{}
+	}
+	return &ErrOutsideCoverage{Day: *f.Day}
+}
+
+// returns the cached failure of the date query, as an ErrCachedFailure, or nil when there isn't
+// one. an expired or unreadable one is removed
+func (c *Client) loadCachedFailure(startDay DayKey, numPhases int) error {
+	path := filepath.Join(c.cachePartition(startDay.Year), cacheErrorFileName(startDay, numPhases))
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	content, err := ioutil.ReadFile(path)
+	var failure cachedFailure
+	if (err == nil) {
+		err = json.Unmarshal(content, &failure)
+	}
+	if (err != nil || time.Since(info.ModTime()) > cacheMaxAge) {
+		os.Remove(path)
+		return nil
+	}
+	return &ErrCachedFailure{Fetched: info.ModTime(), Err: failure.err()}
+}
+
+// records a permanent failure of the date query in c.CacheDir, failing quietly like
+// saveCachedMoonData
+func (c *Client) saveCachedFailure(startDay DayKey, numPhases int, failure cachedFailure) {
+	content, err := json.Marshal(failure)
+	if err != nil {
+		return
+	}
+	partition := c.cachePartition(startDay.Year)
+	if err := os.MkdirAll(partition, 0755); err != nil {
+		return
+	}
+	ioutil.WriteFile(filepath.Join(partition, cacheErrorFileName(startDay, numPhases)), content, 0644)
+}
+
+// returns phase data holding strategy.Past phases before anchor and strategy.Future after it,
+// from c.CacheDir when a cached response covers it, otherwise from the provider chain
+func (c *Client) getMoonDataAround(ctx context.Context, anchor time.Time, strategy FetchStrategy) ([]MoonPhase, error) {
+	// the cache only ever holds API responses
+	useCache := c.CacheDir != "" && c.Source == nil
+	startDay, numPhases := FetchWindow(anchor, strategy)
+	if (useCache && c.ForceRefresh) {
+		_, span := c.startSpan(ctx, SpanCacheLookup)
+		os.Remove(filepath.Join(c.cachePartition(startDay.Year), cacheErrorFileName(startDay, numPhases)))
+		c.countCacheLookup(func(stats *CacheStats) { stats.Refreshes++ })
+		span.SetAttribute("cache.result", "refresh")
+		span.End(nil)
+	} else if (useCache) {
+		_, span := c.startSpan(ctx, SpanCacheLookup)
+		phases, found, refresh := c.loadCachedMoonData(anchor, strategy)
+		if (found) {
+			c.countCacheLookup(func(stats *CacheStats) { stats.Hits++ })
+			span.SetAttribute("cache.result", "hit")
+			span.End(nil)
+			return phases, nil
+		}
+		if err := c.loadCachedFailure(startDay, numPhases); err != nil {
+			c.countCacheLookup(func(stats *CacheStats) { stats.Failures++ })
+			span.SetAttribute("cache.result", "failure")
+			span.End(err)
+			return nil, err
+		}
+		c.countCacheLookup(func(stats *CacheStats) {
+			if (refresh) {
+				stats.Refreshes++
+			} else {
+				stats.Misses++
+			}
+		})
+		if (refresh) {
+			span.SetAttribute("cache.result", "refresh")
+		} else {
+			span.SetAttribute("cache.result", "miss")
+		}
+		span.End(nil)
+	}
+	phases, answered, err := c.getMoonDataFromChain(ctx, startDay, numPhases)
+	if err != nil {
+		if failure, permanent := permanentFailure(err, startDay); (useCache && permanent) {
+			c.saveCachedFailure(startDay, numPhases, failure)
+		}
+		return nil, err
+	}
+	// the USNO API always brackets the anchor with a FetchWindow, but a registered provider can
+	// return less than it was asked for. ask once more for a wider window before handing back
+	// data that can't answer the lookup, the classifier reports it if that falls short too
+	if (!coversAnchor(phases, anchor, strategy)) {
+		widerStartDay, widerNumPhases := FetchWindow(anchor, FetchStrategy{Past: strategy.Past + 2, Future: strategy.Future + 2})
+		if (widerNumPhases <= maxNumPhases) {
+			widerPhases, widerAnswered, err := c.getMoonDataFromChain(ctx, widerStartDay, widerNumPhases)
+			if (err == nil) {
+				startDay, numPhases, phases, answered = widerStartDay, widerNumPhases, widerPhases, widerAnswered
+			}
+		}
+	}
+	// a cached response is only ever used for lookups it covers, and only the API's own answers
+	// are kept. one from a fallback like the OfflineProvider would otherwise be served in place of
+	// the API's for 30 days after the API came back
+	if (useCache && answered.usno && coversAnchor(phases, anchor, strategy)) {
+		c.saveCachedMoonData(startDay, numPhases, phases)
+	}
+	return phases, nil
+}
@@ -0,0 +1,541 @@
+package moonphase_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// returns a client of server's API caching in a new temp dir, without retries
+func newCachingClient(t *testing.T, serverURL string) *moonphase.Client {
+	t.Helper()
+	client := moonphase.NewClient()
+	client.BaseURL = serverURL
+	client.CacheDir = t.TempDir()
+	client.Retries = 0
+	return client
+}
+
+// returns the cached responses in dir
+func cachedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// a fallback's answer is never cached, so the API answers once it's back
+func TestCacheOnlyKeepsAPIAnswers(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	client := newCachingClient(t, down.URL)
+	client.RegisterProvider("offline", moonphase.OfflineProvider{}, moonphase.USNOPriority - 1, nil)
+	date := time.Date(2024, time.August, 19, 0, 0, 0, 0, time.UTC)
+	if _, err := client.GetPhaseForDate(context.Background(), date, moonphase.SnapStrategy); err != nil {
+		t.Fatal(err)
+	}
+	if files := cachedFiles(t, client.CacheDir); (len(files) != 0) {
+		t.Fatalf("the offline answer was cached in %v", files)
+	}
+
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client.BaseURL = server.URL
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPhaseForDate(context.Background(), date, moonphase.SnapStrategy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if (server.Requests() != 1) {
+		t.Errorf("made %d requests once the API was back, want 1", server.Requests())
+	}
+	if files := cachedFiles(t, client.CacheDir); (len(files) != 1) {
+		t.Errorf("cached %v, want the API's answer", files)
+	}
+}
+
+// a provider registered above the API answers without its data reaching the cache
+func TestCacheSkipsHigherProviders(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client := newCachingClient(t, server.URL)
+	client.RegisterProvider("fixture", moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...), moonphase.USNOPriority + 1, nil)
+	if _, err := client.GetPhaseForDate(context.Background(), time.Date(2024, time.August, 19, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy); err != nil {
+		t.Fatal(err)
+	}
+	if files := cachedFiles(t, client.CacheDir); (len(files) != 0) {
+		t.Errorf("the fixture's answer was cached in %v", files)
+	}
+	if entries, err := os.ReadDir(client.CacheDir); (err != nil || len(entries) != 0) {
+		t.Errorf("cache dir holds %v (%v)", entries, err)
+	}
+}
+
+// writes phases into client's cache as the response to a date query from startDay
+func writeCacheEntry(t *testing.T, client *moonphase.Client, startDay string, phases []moonphase.MoonPhase) string {
+	t.Helper()
+	content, err := json.Marshal(phases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(client.CacheDir, startDay[:4], startDay + "-n4.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// a response for one day answers the days after it it still brackets, a day far from it is fetched
+func TestCacheHitAndMiss(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client := newCachingClient(t, server.URL)
+	tests := []struct {
+		day      time.Time
+		want     moonphase.Phase
+		requests int
+	}{
+		{time.Date(2024, time.August, 19, 0, 0, 0, 0, time.UTC), moonphase.FullMoon, 1},
+		// the same response brackets the next few days
+		{time.Date(2024, time.August, 20, 0, 0, 0, 0, time.UTC), moonphase.FullMoon, 1},
+		{time.Date(2024, time.August, 22, 0, 0, 0, 0, time.UTC), moonphase.WaningGibbous, 1},
+		{time.Date(2024, time.August, 19, 0, 0, 0, 0, time.UTC), moonphase.FullMoon, 1},
+		// months later nothing cached covers it
+		{time.Date(2024, time.November, 15, 0, 0, 0, 0, time.UTC), moonphase.FullMoon, 2},
+	}
+	for _, test := range tests {
+		got, err := client.GetPhaseForDate(context.Background(), test.day, moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got != test.want) {
+			t.Errorf("%s: got %s, want %s", test.day.Format("2006-01-02"), got, test.want)
+		}
+		if (server.Requests() != test.requests) {
+			t.Errorf("%s: %d requests so far, want %d", test.day.Format("2006-01-02"), server.Requests(), test.requests)
+		}
+	}
+	if files := cachedFiles(t, client.CacheDir); (len(files) != 2) {
+		t.Errorf("cached %v, want the two responses", files)
+	}
+}
+
+// a cached range is only used for days it brackets with a phase to spare either side, a day at
+// its end is fetched rather than answered from the phases that happen to be there
+func TestCachePartialCoverage(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client := newCachingClient(t, server.URL)
+	// Last Quarter on March 3rd to Full Moon on March 25th
+	writeCacheEntry(t, client, "2024-03-01", lunationFixture[:4])
+
+	got, err := client.GetPhaseForDate(context.Background(), time.Date(2024, time.March, 20, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (got != moonphase.WaxingGibbous || server.Requests() != 0) {
+		t.Errorf("inside the cached range: got %s after %d requests, want %s from the cache", got, server.Requests(), moonphase.WaxingGibbous)
+	}
+	// nothing cached comes after the Full Moon, so the day of it needs the API
+	got, err = client.GetPhaseForDate(context.Background(), time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (got != moonphase.FullMoon || server.Requests() != 1) {
+		t.Errorf("at the end of the cached range: got %s after %d requests, want %s from the API", got, server.Requests(), moonphase.FullMoon)
+	}
+	// and before the first cached phase
+	if _, err := client.GetPhaseForDate(context.Background(), time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy); err != nil {
+		t.Fatal(err)
+	}
+	if (server.Requests() != 2) {
+		t.Errorf("before the cached range: %d requests, want 2", server.Requests())
+	}
+}
+
+// a response past its age, or one that doesn't decode, is removed and fetched again
+func TestCacheExpiry(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client := newCachingClient(t, server.URL)
+	expired := writeCacheEntry(t, client, "2024-03-01", lunationFixture[:4])
+	old := time.Now().Add(-31 * 24 * time.Hour)
+	if err := os.Chtimes(expired, old, old); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(client.CacheDir, "2024", "2024-02-20-n4.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetPhaseForDate(context.Background(), time.Date(2024, time.March, 20, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy); err != nil {
+		t.Fatal(err)
+	}
+	if (server.Requests() != 1) {
+		t.Errorf("made %d requests, want 1", server.Requests())
+	}
+	for _, path := range []string{expired, corrupt} {
+		if _, err := os.Stat(path); (!os.IsNotExist(err)) {
+			t.Errorf("%s is still cached (%v)", filepath.Base(path), err)
+		}
+	}
+	if files := cachedFiles(t, client.CacheDir); (len(files) != 1) {
+		t.Errorf("cached %v, want the fresh response", files)
+	}
+}
+
+// many lookups at once through clients sharing a cache dir, as shells rendering their prompts
+// together do, all get the right answer and leave only whole responses behind
+func TestCacheConcurrentLookups(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	dir := t.TempDir()
+	reference := newReferenceClient()
+	days := make([]time.Time, 60)
+	want := make([]moonphase.Phase, len(days))
+	for i := range days {
+		days[i] = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i * 5)
+		phase, err := reference.GetPhaseForDate(context.Background(), days[i], moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[i] = phase
+	}
+	var wait sync.WaitGroup
+	errs := make(chan error, 32 * len(days))
+	for worker := 0; worker < 32; worker++ {
+		wait.Add(1)
+		go func(worker int) {
+			defer wait.Done()
+			// a client each, like separate processes
+			client := moonphase.NewClient()
+			client.BaseURL = server.URL
+			client.CacheDir = dir
+			client.Retries = 0
+			for j := range days {
+				i := (j + worker * 7) % len(days)
+				phase, err := client.GetPhaseForDate(context.Background(), days[i], moonphase.SnapStrategy)
+				if err != nil {
+					errs <- err
+				} else if (phase != want[i]) {
+					errs <- fmt.Errorf("%s: got %s, want %s", days[i].Format("2006-01-02"), phase, want[i])
+				}
+			}
+		}(worker)
+	}
+	wait.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	files := cachedFiles(t, dir)
+	if (len(files) == 0) {
+		t.Fatal("nothing was cached")
+	}
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var phases []moonphase.MoonPhase
+		if err := json.Unmarshal(content, &phases); (err != nil || len(phases) == 0) {
+			t.Errorf("%s is torn: %v", filepath.Base(path), err)
+		}
+	}
+	// nothing half written is left behind
+	if temps, _ := filepath.Glob(filepath.Join(dir, "*", ".tmp-*")); (len(temps) != 0) {
+		t.Errorf("left %v", temps)
+	}
+	if (server.Requests() >= 32 * len(days)) {
+		t.Errorf("made %d requests for %d lookups, the cache answered none", server.Requests(), 32 * len(days))
+	}
+}
+
+// a torn response, as a crashed writer without the rename could leave, is a miss rather than
+// a panic or an error
+func TestCacheTornResponse(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client := newCachingClient(t, server.URL)
+	path := writeCacheEntry(t, client, "2024-03-01", lunationFixture[:4])
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content[:len(content) / 2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetPhaseForDate(context.Background(), time.Date(2024, time.March, 20, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (got != moonphase.WaxingGibbous || server.Requests() != 1) {
+		t.Errorf("got %s after %d requests, want %s from the API", got, server.Requests(), moonphase.WaxingGibbous)
+	}
+}
+
+// sets the modification time of a cached response to age ago
+func ageCacheEntry(t *testing.T, path string, age time.Duration) {
+	t.Helper()
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compaction keeps the widest response and any reaching past it, removes the expired, unreadable,
+// empty, covered and unpartitioned ones and the partitions they leave empty, and a second run has
+// nothing left to do
+func TestCompactCache(t *testing.T) {
+	client := newCachingClient(t, "")
+	wide := writeCacheEntry(t, client, "2024-03-01", lunationFixture[:6])
+	later := writeCacheEntry(t, client, "2024-03-30", lunationFixture[4:])
+	covered := writeCacheEntry(t, client, "2024-03-08", lunationFixture[1:4])
+	expired := writeCacheEntry(t, client, "2024-03-15", lunationFixture[2:])
+	ageCacheEntry(t, expired, 31 * 24 * time.Hour)
+	empty := writeCacheEntry(t, client, "2024-03-20", nil)
+	emptyPartition := writeCacheEntry(t, client, "2023-12-01", lunationFixture[:2])
+	ageCacheEntry(t, emptyPartition, 45 * 24 * time.Hour)
+	corrupt := filepath.Join(client.CacheDir, "2024", "2024-02-20-n4.json")
+	unpartitioned := filepath.Join(client.CacheDir, "2024-03-01-n4.json")
+	for _, path := range []string{corrupt, unpartitioned} {
+		if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compaction, err := client.CompactCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := moonphase.CacheCompaction{Expired: 4, Redundant: 1, Unpartitioned: 1, Kept: 2}
+	if (compaction != want) {
+		t.Errorf("got %+v, want %+v", compaction, want)
+	}
+	for _, path := range []string{wide, later} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed (%v)", filepath.Base(path), err)
+		}
+	}
+	for _, path := range []string{covered, expired, empty, emptyPartition, corrupt, unpartitioned, filepath.Dir(emptyPartition)} {
+		if _, err := os.Stat(path); (!os.IsNotExist(err)) {
+			t.Errorf("%s is still in the cache (%v)", filepath.Base(path), err)
+		}
+	}
+
+	compaction, err = client.CompactCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (moonphase.CacheCompaction{Kept: 2}); (compaction != want) {
+		t.Errorf("compacting again: got %+v, want %+v", compaction, want)
+	}
+}
+
+// purging removes the responses fetched longer ago than asked and the partitions left empty, and
+// a client without a cache, or whose cache dir was never made, has nothing to purge
+func TestPurgeCache(t *testing.T) {
+	client := newCachingClient(t, "")
+	old := writeCacheEntry(t, client, "2023-12-01", lunationFixture[:4])
+	ageCacheEntry(t, old, 10 * 24 * time.Hour)
+	recent := writeCacheEntry(t, client, "2024-03-01", lunationFixture[:4])
+	ageCacheEntry(t, recent, 3 * 24 * time.Hour)
+	fresh := writeCacheEntry(t, client, "2024-03-30", lunationFixture[4:])
+
+	removed, err := client.PurgeCache(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (removed != 1) {
+		t.Errorf("removed %d responses, want 1", removed)
+	}
+	for _, path := range []string{old, filepath.Dir(old)} {
+		if _, err := os.Stat(path); (!os.IsNotExist(err)) {
+			t.Errorf("%s is still in the cache (%v)", filepath.Base(path), err)
+		}
+	}
+	if files := cachedFiles(t, client.CacheDir); (len(files) != 2) {
+		t.Errorf("left %v, want %s and %s", files, filepath.Base(recent), filepath.Base(fresh))
+	}
+
+	removed, err = client.PurgeCache(0)
+	if (err != nil || removed != 2) {
+		t.Errorf("purging everything: removed %d responses (%v), want 2", removed, err)
+	}
+	if entries, err := os.ReadDir(client.CacheDir); (err != nil || len(entries) != 0) {
+		t.Errorf("left %v in the cache dir (%v)", entries, err)
+	}
+
+	for _, cacheDir := range []string{"", filepath.Join(t.TempDir(), "never-made")} {
+		client.CacheDir = cacheDir
+		if removed, err := client.PurgeCache(0); (removed != 0 || err != nil) {
+			t.Errorf("cache dir %q: removed %d responses (%v), want none", cacheDir, removed, err)
+		}
+	}
+}
+
+// a week old response predicting phases months ahead is fetched again when a lookup reports the
+// time of one, which picks up the API's revision, while classifying days goes on using it.
+// ForceRefresh fetches every lookup again, and CacheStats counts what happened
+func TestRefreshRevisedInstants(t *testing.T) {
+	now := time.Now().UTC()
+	anchor := now.AddDate(0, 6, 0)
+	events := moonphasetest.Events(moonphase.MeanPhasesBetween(now.AddDate(0, 1, 0), now.AddDate(1, 1, 0))...)
+	revised := append([]moonphasetest.PhaseEvent{}, events...)
+	var original time.Time
+	for i, event := range revised {
+		if (event.Phase == moonphase.FullMoon && event.Instant.After(anchor)) {
+			original = event.Instant
+			revised[i].Instant = event.Instant.Add(2 * time.Minute)
+			break
+		}
+	}
+	before := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, events...))
+	after := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, revised...))
+	client := newCachingClient(t, before.URL)
+	next, err := client.NextPhase(context.Background(), anchor, moonphase.FullMoon)
+	if (err != nil || !next.Equal(original)) {
+		t.Fatalf("got %s (%v), want %s", next, err, original)
+	}
+	phase, err := client.GetPhaseForDate(context.Background(), anchor, moonphase.SnapStrategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a week and a day later the API has revised the Full Moon
+	week := now.Add(-8 * 24 * time.Hour)
+	for _, file := range cachedFiles(t, client.CacheDir) {
+		if err := os.Chtimes(file, week, week); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.BaseURL = after.URL
+	if got, err := client.GetPhaseForDate(context.Background(), anchor, moonphase.SnapStrategy); (err != nil || got != phase || after.Requests() != 0) {
+		t.Errorf("classifying got %s (%v) with %d requests, want %s from the cache", got, err, after.Requests(), phase)
+	}
+	if next, err := client.NextPhase(context.Background(), anchor, moonphase.FullMoon); (err != nil || !next.Equal(original.Add(2 * time.Minute)) || after.Requests() != 1) {
+		t.Errorf("got %s (%v) with %d requests, want the revised %s", next, err, after.Requests(), original.Add(2 * time.Minute))
+	}
+	// the refreshed response is fresh
+	if _, err := client.NextPhase(context.Background(), anchor, moonphase.FullMoon); (err != nil || after.Requests() != 1) {
+		t.Errorf("%d requests (%v), want the refreshed response to be used", after.Requests(), err)
+	}
+	if got, want := client.CacheStats(), (moonphase.CacheStats{Hits: 3, Misses: 1, Refreshes: 1}); (got != want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	client.ForceRefresh = true
+	if _, err := client.GetPhaseForDate(context.Background(), anchor, moonphase.SnapStrategy); (err != nil || after.Requests() != 2) {
+		t.Errorf("%d requests (%v), want ForceRefresh to fetch again", after.Requests(), err)
+	}
+	if got := client.CacheStats().Refreshes; (got != 2) {
+		t.Errorf("%d refreshes, want 2", got)
+	}
+
+	// a negative After never refreshes
+	client = newCachingClient(t, before.URL)
+	client.Refresh = moonphase.RefreshPolicy{After: -1}
+	if _, err := client.NextPhase(context.Background(), anchor, moonphase.FullMoon); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range cachedFiles(t, client.CacheDir) {
+		if err := os.Chtimes(file, week, week); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.BaseURL = after.URL
+	if next, err := client.NextPhase(context.Background(), anchor, moonphase.FullMoon); (err != nil || !next.Equal(original) || after.Requests() != 2) {
+		t.Errorf("got %s (%v) with %d requests, want the cached %s", next, err, after.Requests(), original)
+	}
+}
+
+// a permanent failure of the API is cached, so a second run fails with the same error without
+// asking it, until -refresh asks again. transient failures are always asked again
+func TestCachedFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		permanent bool
+		check     func(err error) bool
+	}{
+		{"rejected", http.StatusBadRequest, `{"error": "invalid date"}`, true, func(err error) bool {
+			var rejected *moonphase.ErrUpstreamRejected
+			return errors.As(err, &rejected) && rejected.Status == http.StatusBadRequest && rejected.Message == "invalid date"
+		}},
+		{"rejected with a 200", http.StatusOK, `{"error": "invalid date"}`, true, nil},
+		{"not found", http.StatusNotFound, "", true, func(err error) bool {
+			var status *moonphase.ErrUpstreamStatus
+			return errors.As(err, &status) && status.Status == http.StatusNotFound
+		}},
+		{"no phases", http.StatusOK, `{"apiversion": "4.0.1", "numphases": 0, "phasedata": []}`, true, func(err error) bool {
+			var coverage *moonphase.ErrOutsideCoverage
+			return errors.As(err, &coverage)
+		}},
+		{"server error", http.StatusInternalServerError, "", false, nil},
+		{"rejected with a 503", http.StatusServiceUnavailable, `{"error": "maintenance"}`, false, nil},
+		{"too many requests", http.StatusTooManyRequests, "", false, nil},
+	}
+	date := time.Date(2024, time.August, 19, 0, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+			cacheDir := t.TempDir()
+			run := func(forceRefresh bool) (error, moonphase.CacheStats) {
+				client := newCachingClient(t, server.URL)
+				client.CacheDir = cacheDir
+				client.ForceRefresh = forceRefresh
+				_, err := client.GetPhaseForDate(context.Background(), date, moonphase.SnapStrategy)
+				if (err == nil) {
+					t.Fatal("got no error")
+				}
+				return err, client.CacheStats()
+			}
+			first, _ := run(false)
+			second, stats := run(false)
+			var cached *moonphase.ErrCachedFailure
+			want := 2
+			if (test.permanent) {
+				want = 1
+				if (!errors.As(second, &cached) || !strings.Contains(second.Error(), "cached-error") || stats.Failures != 1) {
+					t.Errorf("the second run got %v (%+v), want an ErrCachedFailure", second, stats)
+				}
+				if (cached != nil && cached.Err.Error() != first.Error()) {
+					t.Errorf("cached %q, the API failed with %q", cached.Err, first)
+				}
+			} else if (errors.As(second, &cached)) {
+				t.Errorf("a transient failure was cached: %v", second)
+			}
+			if (test.check != nil && !test.check(second)) {
+				t.Errorf("got %v", second)
+			}
+			if (requests != want) {
+				t.Errorf("%d requests across two runs, want %d", requests, want)
+			}
+			refreshed, _ := run(true)
+			if (requests != want + 1 || errors.As(refreshed, &cached)) {
+				t.Errorf("-refresh made %d requests in all and got %v, want it to ask again", requests, refreshed)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package moonphase_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// a lunation of fixed phases, from the Last Quarter before a New Moon to the New Moon after it
+var lunationFixture = moonphasetest.MoonPhases(
+	moonphasetest.Event(moonphase.LastQuarter, "2024-03-03 15:23"),
+	moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:00"),
+	moonphasetest.Event(moonphase.FirstQuarter, "2024-03-17 04:11"),
+	moonphasetest.Event(moonphase.FullMoon, "2024-03-25 07:00"),
+	moonphasetest.Event(moonphase.LastQuarter, "2024-04-02 03:15"),
+	moonphasetest.Event(moonphase.NewMoon, "2024-04-08 18:21"),
+	moonphasetest.Event(moonphase.FirstQuarter, "2024-04-15 19:13"),
+)
+
+// each of the eight phases, with the days either side of a primary phase snapping to it and the
+// days between two primary phases taking the intermediate one between them
+func TestGetCurrentPhaseEightPhases(t *testing.T) {
+	tests := []struct {
+		day  string
+		want moonphase.Phase
+	}{
+		{"2024-03-09", moonphase.NewMoon},
+		{"2024-03-10", moonphase.NewMoon},
+		{"2024-03-11", moonphase.NewMoon},
+		{"2024-03-12", moonphase.WaxingCrescent},
+		{"2024-03-15", moonphase.WaxingCrescent},
+		{"2024-03-16", moonphase.FirstQuarter},
+		{"2024-03-17", moonphase.FirstQuarter},
+		{"2024-03-18", moonphase.FirstQuarter},
+		// 9 to 13 days after the New Moon, once reported as a Waxing Crescent
+		{"2024-03-19", moonphase.WaxingGibbous},
+		{"2024-03-21", moonphase.WaxingGibbous},
+		{"2024-03-23", moonphase.WaxingGibbous},
+		{"2024-03-24", moonphase.FullMoon},
+		{"2024-03-25", moonphase.FullMoon},
+		{"2024-03-26", moonphase.FullMoon},
+		{"2024-03-27", moonphase.WaningGibbous},
+		{"2024-03-31", moonphase.WaningGibbous},
+		{"2024-04-01", moonphase.LastQuarter},
+		{"2024-04-02", moonphase.LastQuarter},
+		{"2024-04-03", moonphase.LastQuarter},
+		{"2024-04-04", moonphase.WaningCrescent},
+		{"2024-04-06", moonphase.WaningCrescent},
+		{"2024-04-07", moonphase.NewMoon},
+	}
+	for _, test := range tests {
+		day, err := moonphase.ParseDayKey(test.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := moonphase.GetCurrentPhase(day.Time(time.UTC), lunationFixture, moonphase.SnapStrategy)
+		if err != nil {
+			t.Errorf("%s: %v", test.day, err)
+		} else if (got != test.want) {
+			t.Errorf("%s: got %s %s, want %s %s", test.day, got.Emoji(), got, test.want.Emoji(), test.want)
+		}
+	}
+}
+
+// the intermediate phase between two primary phases is the one between them in Phases, so the
+// classifier and the symbol tables agree on the order of the cycle
+func TestIntermediatePhasesFollowTheCycle(t *testing.T) {
+	for i := 1; (i + 1 < len(lunationFixture)); i++ {
+		previous := moonphase.Phase(lunationFixture[i].Phase)
+		// the middle of the interval, well outside either snap window
+		start, _ := moonphase.GetPhaseInstant(lunationFixture[i])
+		end, _ := moonphase.GetPhaseInstant(lunationFixture[i + 1])
+		middle := start.Add(end.Sub(start) / 2)
+		got, err := moonphase.GetCurrentPhase(middle, lunationFixture, moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want moonphase.Phase
+		for j, phase := range moonphase.Phases {
+			if (phase == previous) {
+				want = moonphase.Phases[(j + 1) % len(moonphase.Phases)]
+			}
+		}
+		if (got != want) {
+			t.Errorf("after %s: got %s, want %s", previous, got, want)
+		}
+		if (got.Emoji() == "" || got.Emoji() == previous.Emoji()) {
+			t.Errorf("after %s: %s has the emoji %q", previous, got, got.Emoji())
+		}
+	}
+}
+
+// PrimaryOnlyStrategy reports the last primary phase for every day, never an intermediate one
+func TestGetCurrentPhasePrimaryOnly(t *testing.T) {
+	tests := []struct {
+		day  string
+		want moonphase.Phase
+	}{
+		{"2024-03-09", moonphase.LastQuarter},
+		{"2024-03-10", moonphase.NewMoon},
+		{"2024-03-16", moonphase.NewMoon},
+		{"2024-03-21", moonphase.FirstQuarter},
+		{"2024-03-25", moonphase.FullMoon},
+		{"2024-04-01", moonphase.FullMoon},
+		{"2024-04-05", moonphase.LastQuarter},
+	}
+	for _, test := range tests {
+		day, err := moonphase.ParseDayKey(test.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := moonphase.GetCurrentPhase(day.Time(time.UTC), lunationFixture, moonphase.PrimaryOnlyStrategy)
+		if err != nil {
+			t.Errorf("%s: %v", test.day, err)
+		} else if (got != test.want) {
+			t.Errorf("%s: got %s, want %s", test.day, got, test.want)
+		}
+	}
+}
+
+// data that doesn't bracket the day, or that the API couldn't have sent, is an error rather than
+// a guess
+func TestGetCurrentPhaseErrors(t *testing.T) {
+	unknown := moonphasetest.MoonPhases(moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:00"), moonphasetest.Event(moonphase.FirstQuarter, "2024-03-17 04:11"))
+	unknown[1].Phase = "Half Moon"
+	badTime := moonphasetest.MoonPhases(moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:00"), moonphasetest.Event(moonphase.FirstQuarter, "2024-03-17 04:11"))
+	badTime[0].Time = "9am"
+	outOfOrder := moonphasetest.MoonPhases(moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:00"), moonphasetest.Event(moonphase.FullMoon, "2024-03-25 07:00"))
+	tests := []struct {
+		name string
+		day  time.Time
+		data []moonphase.MoonPhase
+		want string
+	}{
+		{"no history", time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC), lunationFixture, "enough history"},
+		{"no phase after", time.Date(2024, time.April, 20, 0, 0, 0, 0, time.UTC), lunationFixture, "a phase after"},
+		{"unknown phase", time.Date(2024, time.March, 13, 0, 0, 0, 0, time.UTC), unknown, "unknown phase"},
+		{"bad time", time.Date(2024, time.March, 13, 0, 0, 0, 0, time.UTC), badTime, "invalid time"},
+		{"skipped phase", time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC), outOfOrder, "followed by"},
+	}
+	for _, test := range tests {
+		_, err := moonphase.GetCurrentPhase(test.day, test.data, moonphase.SnapStrategy)
+		if (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.want)
+		}
+	}
+}
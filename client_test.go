@@ -0,0 +1,207 @@
+package moonphase_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+var retryDay = moonphase.DayKey{Year: 2024, Month: time.March, Day: 20}
+
+// returns a server answering its first failures requests with status and the rest with phase
+// data, counting every request in requests
+func newFlakyServer(t *testing.T, failures int64, status int, retryAfter string, requests *int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (atomic.AddInt64(requests, 1) <= failures) {
+			if (retryAfter != "") {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		phases := moonphasetest.MoonPhases(moonphasetest.ReferenceEvents()[15:19]...)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(moonphase.MoonApiResponse{Apiversion: "4.0.1", Numphases: len(phases), Phasedata: phases})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// returns a client of serverURL with retries of its own and seeded jitter
+func newRetryingClient(serverURL string, retries int) *moonphase.Client {
+	client := moonphase.NewClient()
+	client.BaseURL = serverURL
+	client.Retries = retries
+	client.Rand = moonphase.SeededRand(1)
+	return client
+}
+
+// a 503 is retried after a backoff, and the answer after it is returned
+func TestRetryAfterServiceUnavailable(t *testing.T) {
+	var requests int64
+	server := newFlakyServer(t, 1, http.StatusServiceUnavailable, "", &requests)
+	start := time.Now()
+	phases, err := newRetryingClient(server.URL, 1).GetMoonData(context.Background(), retryDay, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(phases) != 4) {
+		t.Errorf("got %d phases, want 4", len(phases))
+	}
+	if (atomic.LoadInt64(&requests) != 2) {
+		t.Errorf("made %d requests, want 2", atomic.LoadInt64(&requests))
+	}
+	// the first backoff is 500ms with up to half of it taken off
+	if elapsed := time.Since(start); (elapsed < 250 * time.Millisecond) {
+		t.Errorf("retried after %s, before the backoff", elapsed)
+	}
+}
+
+// once the retries run out the last status is the error, after Retries + 1 attempts
+func TestRetriesExhausted(t *testing.T) {
+	var requests int64
+	server := newFlakyServer(t, 10, http.StatusServiceUnavailable, "", &requests)
+	_, err := newRetryingClient(server.URL, 2).GetMoonData(context.Background(), retryDay, 4)
+	var statusErr *moonphase.ErrUpstreamStatus
+	if (!errors.As(err, &statusErr) || statusErr.Status != http.StatusServiceUnavailable) {
+		t.Fatalf("got %v, want a 503 ErrUpstreamStatus", err)
+	}
+	if (atomic.LoadInt64(&requests) != 3) {
+		t.Errorf("made %d requests, want 3", atomic.LoadInt64(&requests))
+	}
+}
+
+// a 4xx other than a 429 won't go differently a second time, and a Retry-After past a minute isn't
+// waited out, both fail on the first attempt
+func TestNotRetried(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+	}{
+		{"not found", http.StatusNotFound, ""},
+		{"long Retry-After", http.StatusServiceUnavailable, "3600"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int64
+			server := newFlakyServer(t, 10, test.status, test.retryAfter, &requests)
+			start := time.Now()
+			_, err := newRetryingClient(server.URL, 2).GetMoonData(context.Background(), retryDay, 4)
+			var statusErr *moonphase.ErrUpstreamStatus
+			if (!errors.As(err, &statusErr) || statusErr.Status != test.status) {
+				t.Fatalf("got %v, want a %d ErrUpstreamStatus", err, test.status)
+			}
+			if (atomic.LoadInt64(&requests) != 1) {
+				t.Errorf("made %d requests, want 1", atomic.LoadInt64(&requests))
+			}
+			if elapsed := time.Since(start); (elapsed > 250 * time.Millisecond) {
+				t.Errorf("gave up after %s, want straight away", elapsed)
+			}
+		})
+	}
+}
+
+// a server that hangs is given up on at the HTTP client's timeout, and the timeout is retried
+func TestTimeout(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	client := newRetryingClient(server.URL, 1)
+	client.HTTPClient = &http.Client{Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	_, err := client.GetMoonData(context.Background(), retryDay, 4)
+	var netErr net.Error
+	if (!errors.As(err, &netErr) || !netErr.Timeout()) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if (atomic.LoadInt64(&requests) != 2) {
+		t.Errorf("made %d requests, want 2", atomic.LoadInt64(&requests))
+	}
+	// two 100ms timeouts and a backoff of at most 500ms
+	if elapsed := time.Since(start); (elapsed > 2 * time.Second) {
+		t.Errorf("gave up after %s", elapsed)
+	}
+}
+
+// cancelling the context stops a lookup promptly, in a request to a server that hangs, in the
+// backoff before a retry, or between the pages of a walk, with context.Canceled
+func TestCancelledLookups(t *testing.T) {
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer hanging.Close()
+	var requests int64
+	unavailable := newFlakyServer(t, 100, http.StatusServiceUnavailable, "", &requests)
+	date := time.Date(2024, time.March, 20, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		lookup func(ctx context.Context) error
+	}{
+		{"in flight", func(ctx context.Context) error {
+			_, err := newRetryingClient(hanging.URL, 0).GetPhaseForDate(ctx, date, moonphase.SnapStrategy)
+			return err
+		}},
+		{"in the backoff", func(ctx context.Context) error {
+			_, err := newRetryingClient(unavailable.URL, 5).GetPhaseForDate(ctx, date, moonphase.SnapStrategy)
+			return err
+		}},
+		{"in a walk", func(ctx context.Context) error {
+			client := &moonphase.Client{Source: moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)}
+			// the whole range is in one page, the first day is emitted before the cancel and no other
+			days := 0
+			err := client.WalkDailyDetails(ctx, date, date.AddDate(0, 9, 0), moonphase.SnapStrategy, func(detail moonphase.DailyDetail) bool {
+				days++
+				<-ctx.Done()
+				return true
+			})
+			if (days != 1) {
+				t.Errorf("walked %d days, want only the one before the cancel", days)
+			}
+			return err
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50 * time.Millisecond, cancel)
+			start := time.Now()
+			err := test.lookup(ctx)
+			if (!errors.Is(err, context.Canceled)) {
+				t.Fatalf("got %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); (elapsed > 250 * time.Millisecond) {
+				t.Errorf("returned %s after the lookup started", elapsed)
+			}
+		})
+	}
+	// a context cancelled before the lookup makes no request at all
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	provider := moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)
+	if _, err := (&moonphase.Client{Source: provider}).GetPhaseForDate(ctx, date, moonphase.SnapStrategy); (!errors.Is(err, context.Canceled)) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if (provider.Requests != 0) {
+		t.Errorf("made %d requests with a cancelled context", provider.Requests)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// one media range of an Accept header, like text/* or application/json;q=0.5
+type acceptRange struct {
+	mediaType string
+	subtype   string
+	quality   float64
+}
+
+// parses an Accept header into its media ranges. a range with a q that isn't a number from 0 to 1
+// is dropped, along with anything that isn't a type/subtype pair
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType, subtype, found := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if (!found || mediaType == "" || subtype == "" || (mediaType == "*" && subtype != "*")) {
+			continue
+		}
+		accepted := acceptRange{mediaType: mediaType, subtype: subtype, quality: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if (strings.ToLower(strings.TrimSpace(name)) != "q") {
+				continue
+			}
+			quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if (err != nil || quality < 0 || quality > 1) {
+				accepted.quality = -1
+			} else {
+				accepted.quality = quality
+			}
+		}
+		if (accepted.quality >= 0) {
+			ranges = append(ranges, accepted)
+		}
+	}
+	return ranges
+}
+
+// returns the quality ranges give contentType, from the most specific range matching it as RFC
+// 9110 has it, so text/html;q=0 refuses HTML even alongside */*. 0 when nothing matches
+func acceptQuality(ranges []acceptRange, contentType string) float64 {
+	mediaType, subtype, _ := strings.Cut(contentType, "/")
+	quality := 0.0
+	specificity := -1
+	for _, accepted := range ranges {
+		matches := -1
+		switch {
+		case (accepted.mediaType == mediaType && accepted.subtype == subtype):
+			matches = 2
+		case (accepted.mediaType == mediaType && accepted.subtype == "*"):
+			matches = 1
+		case (accepted.mediaType == "*"):
+			matches = 0
+		}
+		if (matches > specificity) {
+			specificity = matches
+			quality = accepted.quality
+		}
+	}
+	return quality
+}
+
+// returns the type in offered an Accept header prefers, the first offered for one that's empty.
+// among types the client likes equally the one offered first wins. false when the header
+// accepts none of them
+func negotiateContentType(header string, offered []string) (string, bool) {
+	if (strings.TrimSpace(header) == "") {
+		return offered[0], true
+	}
+	ranges := parseAccept(header)
+	best := ""
+	bestQuality := 0.0
+	for _, contentType := range offered {
+		if quality := acceptQuality(ranges, contentType); (quality > bestQuality) {
+			best = contentType
+			bestQuality = quality
+		}
+	}
+	return best, best != ""
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// an around event as printed by -json, with the phase's symbols alongside
+type aroundJSONEvent struct {
+	moonphase.AroundEvent
+	Emoji          string `json:"emoji"`
+	EmojiShortcode string `json:"emoji_shortcode"`
+	// the phase name in -lang, only when it isn't English
+	Name           string `json:"name,omitempty"`
+}
+
+// parses the anchor for an around query, either a day as parseDateArg takes it or an exact RFC 3339 instant
+func parseAroundAnchor(value string, now time.Time, location *time.Location) time.Time {
+	day, err := parseDateFlag(value, now, location)
+	if (err == nil) {
+		return day.Time(location)
+	}
+	// an instant starts with its year, so words with a T in them like Today still get the date error
+	if (strings.Contains(value, "T") && value[0] >= '0' && value[0] <= '9') {
+		anchor, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			fatal(err)
+		}
+		return anchor.In(location)
+	}
+	fatal(err)
+	return time.Time{}
+}
+
+// the flags of around, read once they have been parsed
+type aroundOptions struct {
+	nFlag             *int
+	jsonFlag          *bool
+	plaintextFlag     *bool
+	emojiStyleFlag    *string
+	nowFileFlag       *string
+	nowFileMaxAgeFlag *time.Duration
+	applyClientFlags  func()
+	checkHorizon      func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation       func() *time.Location
+	getHemisphere     func() moonphase.Hemisphere
+	getLanguage       func() string
+	applyQuiet        func()
+}
+
+// defines the flags of around on flags, for the command to parse and for completion to list
+func defineAroundFlags(flags *flag.FlagSet) *aroundOptions {
+	options := &aroundOptions{}
+	options.nFlag = flags.Int("n", 3, message("Number of phase events to list before and after the date"))
+	options.jsonFlag = flags.Bool("json", false, message("Print the events as JSON"))
+	options.plaintextFlag = flags.Bool("plaintext", false, message("Print phase names without emoji"))
+	options.emojiStyleFlag = flags.String("emoji-style", "auto", message("Emoji presentation: auto, emoji, text, shortcode, nerdfont or ascii"))
+	options.nowFileFlag = flags.String("now-file", "", message("File holding a trusted Unix timestamp to use as the current time"))
+	options.nowFileMaxAgeFlag = flags.Duration("now-file-max-age", defaultNowFileMaxAge, message("Ignore the -now-file if it's older than this"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.getHemisphere = addHemisphereFlag(flags)
+	options.getLanguage = addLanguageFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase around 2024-06-10 -n 3
+func runAround(args []string) {
+	aroundFlags := newFlagSet("around")
+	options := defineAroundFlags(aroundFlags)
+	// the date may come before or after the flags
+	parseWithPositionals(aroundFlags, args)
+	options.applyQuiet()
+	applyConfig(aroundFlags)
+	normalizeStringFlags(aroundFlags)
+	options.applyClientFlags()
+	anchorArg := strings.TrimSpace(aroundFlags.Arg(0))
+
+	emojiStyle, err := parseEmojiStyle(*options.emojiStyleFlag)
+	if err != nil {
+		fatal(err)
+	}
+	hemisphere := options.getHemisphere()
+	lang := options.getLanguage()
+	location := options.getLocation()
+	now := getNow(*options.nowFileFlag, *options.nowFileMaxAgeFlag).In(location)
+	anchor := now
+	if (anchorArg != "") {
+		anchor = parseAroundAnchor(anchorArg, now, location)
+	}
+	options.checkHorizon(moonphase.NewDayKey(anchor), moonphase.NewDayKey(now))
+	checkSourceCovers(moonphase.NewDayKey(anchor))
+	events, err := client.GetPhasesAround(ctx, anchor, *options.nFlag)
+	if err != nil {
+		fatal(err)
+	}
+
+	if (*options.jsonFlag) {
+		// the emoji field always holds the emoji, shortcodes have their own
+		jsonEmojiStyle := emojiFieldStyle(emojiStyle)
+		jsonEvents := make([]aroundJSONEvent, 0, len(events))
+		for _, event := range events {
+			jsonEvents = append(jsonEvents, aroundJSONEvent{
+				AroundEvent:    event,
+				Emoji:          getOutput(event.Phase, false, lang, jsonEmojiStyle, hemisphere),
+				EmojiShortcode: getOutput(event.Phase, false, lang, "shortcode", hemisphere),
+				Name:           localizedName(event.Phase, lang),
+			})
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(jsonEvents); err != nil {
+			fatalOutputError(err)
+		}
+		return
+	}
+	for _, event := range events {
+		label := event.Phase.Name(lang)
+		if (!*options.plaintextFlag) {
+			label = fmt.Sprintf("%s %s", getOutput(event.Phase, false, lang, emojiStyle, hemisphere), label)
+		}
+		fmt.Fprintf(stdout, "%5s  %s  %s\n", event.OffsetHuman, event.Instant.In(location).Format("2006-01-02 15:04 MST"), label)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// dates closer together than this are looked up with a single walk of the phase data, the days
+// in between cost less than the extra requests a separate lookup would make
+const batchMaxGapDays int = 90
+
+// a date given to batch, with where it came from for reporting a bad one
+type batchInput struct {
+	source string
+	text   string
+	day    moonphase.DayKey
+	err    error
+}
+
+// a date as batch -json reports it, with its phase or why it failed
+type batchJSONResult struct {
+	// where the date came from, like "line 3", and as it was written there
+	Source  string          `json:"source"`
+	Input   string          `json:"input"`
+	// the day it was read as, not there for a date that didn't parse
+	Date    string          `json:"date,omitempty"`
+	Phase   moonphase.Phase `json:"phase,omitempty"`
+	PhaseID string          `json:"phase_id,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// what batch -json prints, the results in the input order
+type batchJSONOutput struct {
+	// set when some dates failed and others didn't
+	Partial bool              `json:"partial"`
+	Results []batchJSONResult `json:"results"`
+}
+
+// reads the dates to look up, from args or else one per line from stdin. blank lines are skipped
+func readBatchInputs(args []string) []batchInput {
+	var inputs []batchInput
+	if (len(args) > 0) {
+		for i, arg := range args {
+			inputs = append(inputs, batchInput{source: fmt.Sprintf("argument %d", i + 1), text: strings.TrimSpace(arg)})
+		}
+		return inputs
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if (text != "") {
+			inputs = append(inputs, batchInput{source: fmt.Sprintf("line %d", line), text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fatal(err)
+	}
+	return inputs
+}
+
+// classifies every day in days, sorted and without duplicates, walking the phase data once for
+// each run of days no more than batchMaxGapDays apart. a run that fails has its error recorded
+// against each of its days, as does a day the phase data doesn't reach
+func lookUpBatchDays(days []moonphase.DayKey, location *time.Location, strategy moonphase.ClassifyStrategy, offline bool) (map[moonphase.DayKey]moonphase.Phase, map[moonphase.DayKey]error) {
+	phases := map[moonphase.DayKey]moonphase.Phase{}
+	failures := map[moonphase.DayKey]error{}
+	for first := 0; first < len(days); {
+		last := first
+		for (last + 1 < len(days) && !days[last + 1].After(days[last].AddDays(batchMaxGapDays))) {
+			last++
+		}
+		run := days[first:last + 1]
+		if (offline) {
+			for _, day := range run {
+				date := day.Time(location)
+				phase, err := moonphase.GetCurrentPhase(date, moonphase.MeanPhases(date), strategy)
+				if err != nil {
+					failures[day] = err
+					continue
+				}
+				phases[day] = phase
+			}
+		} else {
+			wanted := map[moonphase.DayKey]bool{}
+			for _, day := range run {
+				wanted[day] = true
+			}
+			err := client.WalkDailyPhases(ctx, run[0].Time(location), run[len(run) - 1].Time(location), strategy, func(date time.Time, phase moonphase.Phase) bool {
+				if (wanted[moonphase.NewDayKey(date)]) {
+					phases[moonphase.NewDayKey(date)] = phase
+				}
+				return true
+			})
+			for _, day := range run {
+				if (err != nil) {
+					failures[day] = err
+					continue
+				}
+				// a walk of a -source-file stops at its end without an error
+				if _, found := phases[day]; (!found) {
+					failures[day] = client.CheckCoverage(day)
+					if (failures[day] == nil) {
+						failures[day] = fmt.Errorf("the phase data doesn't reach %s", day)
+					}
+				}
+			}
+		}
+		first = last + 1
+	}
+	return phases, failures
+}
+
+// the flags of batch, read once they have been parsed
+type batchOptions struct {
+	offlineFlag      *bool
+	primaryOnlyFlag  *bool
+	jsonFlag         *bool
+	okIfPartialFlag  *bool
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey) error
+	getLocation      func() *time.Location
+	applyQuiet       func()
+}
+
+// defines the flags of batch on flags, for the command to parse and for completion to list
+func defineBatchFlags(flags *flag.FlagSet) *batchOptions {
+	options := &batchOptions{}
+	options.offlineFlag = flags.Bool("offline", false, message("Calculate the phases locally instead of asking the USNO API"))
+	options.primaryOnlyFlag = flags.Bool("primary-only", false, message("Only report the four primary phases"))
+	options.jsonFlag = flags.Bool("json", false, message("Print the results as JSON, with the error of each date that failed"))
+	options.okIfPartialFlag = flags.Bool("ok-if-partial", false, message("Exit 0 when some dates failed, as long as one didn't"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonCheckFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase batch 2024-01-01 2024-02-01, or cut -d, -f1 dates.csv | moonphase batch
+func runBatch(args []string) {
+	batchFlags := newFlagSet("batch")
+	options := defineBatchFlags(batchFlags)
+	// relative dates like -3d are dates to look up, not flags
+	parseWithPositionals(batchFlags, args)
+	options.applyQuiet()
+	applyConfig(batchFlags)
+	normalizeStringFlags(batchFlags)
+	options.applyClientFlags()
+
+	location := options.getLocation()
+	strategy := moonphase.SnapStrategy
+	if (*options.primaryOnlyFlag) {
+		strategy = moonphase.PrimaryOnlyStrategy
+	}
+	now := time.Now()
+	today := moonphase.NewDayKey(now.In(location))
+	inputs := readBatchInputs(batchFlags.Args())
+	var days []moonphase.DayKey
+	seen := map[moonphase.DayKey]bool{}
+	for i := range inputs {
+		day, err := parseDateArg(inputs[i].text, now, location)
+		if (err == nil) {
+			err = options.checkHorizon(day, today)
+		}
+		if err != nil {
+			inputs[i].err = err
+			continue
+		}
+		inputs[i].day = day
+		if (!seen[day]) {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	phases, failures := lookUpBatchDays(days, location, strategy, *options.offlineFlag)
+
+	failed := 0
+	output := bufio.NewWriter(stdout)
+	results := make([]batchJSONResult, 0, len(inputs))
+	for _, input := range inputs {
+		if (input.err == nil) {
+			input.err = failures[input.day]
+		}
+		result := batchJSONResult{Source: input.source, Input: input.text}
+		if (input.day != (moonphase.DayKey{})) {
+			result.Date = input.day.String()
+		}
+		if (input.err != nil) {
+			failed++
+			result.Error = input.err.Error()
+			if (!*options.jsonFlag) {
+				fmt.Fprintf(stderr, "%s: %q: %v\n", input.source, input.text, input.err)
+			}
+		} else {
+			result.Phase = phases[input.day]
+			result.PhaseID = moonphase.PhaseID(string(result.Phase))
+			if (!*options.jsonFlag) {
+				fmt.Fprintf(output, "%s\t%s\n", input.day, result.Phase)
+			}
+		}
+		results = append(results, result)
+	}
+	// a failed date doesn't stop the others, so the output may be partial
+	partial := failed > 0 && failed < len(inputs)
+	if (*options.jsonFlag) {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(batchJSONOutput{Partial: partial, Results: results}); err != nil {
+			fatalOutputError(err)
+		}
+	}
+	if err := output.Flush(); err != nil {
+		fatalOutputError(err)
+	}
+	if (failed > 0) {
+		// the failures were reported above, each may be a different kind
+		log.Printf("%d of %d dates failed", failed, len(inputs))
+		if (!partial || !*options.okIfPartialFlag) {
+			os.Exit(exitFailure)
+		}
+	}
+}
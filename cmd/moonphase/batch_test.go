@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// a provider whose requests for phases from the months in fail, and that otherwise serves the
+// recorded phases of 2024
+type failingMonthsProvider struct {
+	moonphase.Provider
+	fail map[time.Month]bool
+}
+
+func (p failingMonthsProvider) GetMoonData(ctx context.Context, date moonphase.DayKey, numPhases int) ([]moonphase.MoonPhase, error) {
+	if (p.fail[date.Month]) {
+		return nil, errors.New("upstream down")
+	}
+	return p.Provider.GetMoonData(ctx, date, numPhases)
+}
+
+// the days of a window whose lookup fails carry its error, and the other windows are still classified
+func TestLookUpBatchDaysIsolatesWindows(t *testing.T) {
+	previous := client
+	defer func() { client = previous }()
+	// the July window's walk starts from phases reaching back into June
+	client = &moonphase.Client{Source: failingMonthsProvider{moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...), map[time.Month]bool{time.June: true}}}
+	days := []moonphase.DayKey{
+		{Year: 2024, Month: time.March, Day: 25},
+		{Year: 2024, Month: time.March, Day: 26},
+		{Year: 2024, Month: time.July, Day: 10},
+		{Year: 2024, Month: time.November, Day: 1},
+	}
+	phases, failures := lookUpBatchDays(days, time.UTC, moonphase.SnapStrategy, false)
+	if (len(failures) != 1 || failures[days[2]] == nil || !strings.Contains(failures[days[2]].Error(), "upstream down")) {
+		t.Errorf("failures %v, want only %s's window", failures, days[2])
+	}
+	for _, day := range []moonphase.DayKey{days[0], days[1], days[3]} {
+		if (phases[day] == "") {
+			t.Errorf("%s wasn't classified", day)
+		}
+	}
+	if (phases[days[0]] != moonphase.FullMoon) {
+		t.Errorf("%s: got %s, want the Full Moon", days[0], phases[days[0]])
+	}
+}
+
+// with one of three windows past the end of the -source-file, the other dates are printed, the
+// failed one is reported, -json marks it and the output partial, and -ok-if-partial exits 0 unless
+// every date failed
+func TestBatchPartialResults(t *testing.T) {
+	dir := t.TempDir()
+	source := []string{"batch", "-source", "file", "-source-file", recordedUSNOFile(t), "-timezone", "UTC"}
+	dates := []string{"2024-03-25", "2030-01-01", "2024-09-01"}
+
+	run := runMoonphase(t, dir, append(append([]string{}, source...), dates...)...)
+	if (run.code != exitFailure || run.stdout != "2024-03-25\tFull Moon\n2024-09-01\tWaning Crescent\n" || !strings.Contains(run.stderr, `argument 2: "2030-01-01": `) || !strings.Contains(run.stderr, "1 of 3 dates failed")) {
+		t.Errorf("exit %d, want %d:\n%s%s", run.code, exitFailure, run.stdout, run.stderr)
+	}
+
+	for flag, want := range map[string]int{"": exitFailure, "-ok-if-partial": 0} {
+		args := append(append([]string{}, source...), "-json")
+		if (flag != "") {
+			args = append(args, flag)
+		}
+		run := runMoonphase(t, dir, append(args, dates...)...)
+		if (run.code != want) {
+			t.Errorf("-json %s: exit %d, want %d:\n%s", flag, run.code, want, run.stderr)
+		}
+		var output batchJSONOutput
+		if err := json.Unmarshal([]byte(run.stdout), &output); err != nil {
+			t.Fatalf("%v:\n%s", err, run.stdout)
+		}
+		if (!output.Partial || len(output.Results) != 3) {
+			t.Fatalf("want a partial output of 3 results, got %+v", output)
+		}
+		for i, result := range output.Results {
+			failed := i == 1
+			if (result.Input != dates[i] || result.Date != dates[i] || (result.Error != "") != failed || (result.Phase == "") != failed) {
+				t.Errorf("result %d: %+v", i, result)
+			}
+		}
+		if (output.Results[0].PhaseID != "full-moon" || !strings.Contains(output.Results[1].Error, "only holds phases from 2023-12-05 to 2025-01-29")) {
+			t.Errorf("results %+v", output.Results)
+		}
+	}
+
+	// every date failing isn't partial, and fails even with -ok-if-partial
+	run = runMoonphase(t, dir, append(append([]string{}, source...), "-json", "-ok-if-partial", "2030-01-01", "not a date")...)
+	var output batchJSONOutput
+	if err := json.Unmarshal([]byte(run.stdout), &output); (err != nil || output.Partial || len(output.Results) != 2 || output.Results[1].Date != "" || run.code != exitFailure) {
+		t.Errorf("exit %d, %+v (%v)", run.code, output, err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the flags of cache, read once they have been parsed
+type cacheOptions struct {
+	olderThanFlag    *time.Duration
+	applyClientFlags func()
+	applyQuiet       func()
+}
+
+// defines the flags of cache on flags, for the command to parse and for completion to list
+func defineCacheFlags(flags *flag.FlagSet) *cacheOptions {
+	options := &cacheOptions{}
+	options.olderThanFlag = flags.Duration("older-than", 7 * 24 * time.Hour, message("With purge, remove responses fetched longer ago than this"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase cache compact, moonphase cache purge -older-than 168h
+func runCache(args []string) {
+	// the action comes before the flags, without one -h still lists them
+	var action string
+	if (len(args) > 0 && !strings.HasPrefix(args[0], "-")) {
+		action = args[0]
+		args = args[1:]
+	}
+	cacheFlags := newFlagSet(strings.TrimSpace("cache " + action))
+	options := defineCacheFlags(cacheFlags)
+	cacheFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(cacheFlags)
+	normalizeStringFlags(cacheFlags)
+	options.applyClientFlags()
+	if (action == "") {
+		fatal("cache needs an action: compact or purge")
+	}
+	if (client.CacheDir == "") {
+		fatal("cache needs a -cache-dir")
+	}
+
+	switch action {
+	case "compact":
+		compaction, err := client.CompactCache()
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(stdout, "removed %d expired, %d redundant and %d unpartitioned responses, kept %d in %s\n",
+			compaction.Expired, compaction.Redundant, compaction.Unpartitioned, compaction.Kept, client.CacheDir)
+	case "purge":
+		removed, err := client.PurgeCache(*options.olderThanFlag)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(stdout, "removed %d responses from %s\n", removed, client.CacheDir)
+	default:
+		fatalf("unknown cache action %q, expected compact or purge", action)
+	}
+}
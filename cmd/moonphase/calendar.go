@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the flags of calendar, read once they have been parsed
+type calendarOptions struct {
+	monthsFlag       *int
+	dateFlag         *string
+	outFlag          *string
+	modeFlag         *string
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation      func() *time.Location
+	applyQuiet       func()
+}
+
+// defines the flags of calendar on flags, for the command to parse and for completion to list
+func defineCalendarFlags(flags *flag.FlagSet) *calendarOptions {
+	options := &calendarOptions{}
+	options.monthsFlag = flags.Int("months", 12, message("Months of upcoming phases to include"))
+	options.dateFlag = flags.String("date", "", message("Date the calendar starts on, defaults to today"))
+	options.outFlag = flags.String("o", "phases.ics", message("iCalendar file to write, - for stdout"))
+	options.modeFlag = flags.String("mode", "0644", message("Permissions for the written file"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase calendar -months 6 -o phases.ics
+func runCalendar(args []string) {
+	calendarFlags := newFlagSet("calendar")
+	options := defineCalendarFlags(calendarFlags)
+	calendarFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(calendarFlags)
+	normalizeStringFlags(calendarFlags)
+	options.applyClientFlags()
+
+	outputMode, err := parseOutputMode(*options.modeFlag)
+	if err != nil {
+		fatal(err)
+	}
+	location := options.getLocation()
+	today := moonphase.NewDayKey(time.Now().In(location))
+	startDay := today
+	if (*options.dateFlag != "") {
+		startDay, err = parseDateFlag(*options.dateFlag, time.Now(), location)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	start := startDay.Time(location)
+	end := start.AddDate(0, *options.monthsFlag, 0)
+	options.checkHorizon(startDay, today)
+	options.checkHorizon(moonphase.NewDayKey(end), today)
+	checkSourceCovers(startDay, moonphase.NewDayKey(end))
+
+	phases, err := client.PhasesBetween(ctx, start, end)
+	if err != nil {
+		fatal(err)
+	}
+	var calendar bytes.Buffer
+	if err := moonphase.WriteICS(&calendar, phases); err != nil {
+		fatal(err)
+	}
+	if err := writeOutput(*options.outFlag, false, outputMode, calendar.Bytes()); err != nil {
+		fatalOutputError(err)
+	}
+	if (*options.outFlag != "-") {
+		fmt.Fprintf(stderr, "wrote %d phases to %s\n", len(phases), *options.outFlag)
+	}
+}
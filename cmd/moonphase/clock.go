@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// default for how old a trusted epoch file may be before it's ignored
+const defaultNowFileMaxAge time.Duration = 24 * time.Hour
+
+// longest -now-file-max-age accepted, a clock file older than a year can't be called trusted
+const maxNowFileMaxAge time.Duration = 365 * 24 * time.Hour
+
+// returns the current time. when nowFile is set it holds a trusted Unix timestamp, kept up to date
+// by something like a GPS daemon, and that is used instead of the system clock. the timestamp is
+// advanced by the time since the file was written, and a file older than maxAge falls back to the
+// system clock with a warning. a file that can't be parsed is an error
+func getNow(nowFile string, maxAge time.Duration) time.Time {
+	systemNow := time.Now()
+	if (nowFile == "") {
+		return systemNow
+	}
+	info, err := os.Stat(nowFile)
+	if err != nil {
+		log.Printf("warning: can't read %s, using the system clock: %v", nowFile, err)
+		return systemNow
+	}
+	age := systemNow.Sub(info.ModTime())
+	if (age > maxAge) {
+		log.Printf("warning: %s is %s old, using the system clock", nowFile, age.Round(time.Second))
+		return systemNow
+	}
+	content, err := os.ReadFile(nowFile)
+	if err != nil {
+		fatal(err)
+	}
+	epoch, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		fatalf("%s doesn't hold a Unix timestamp: %v", nowFile, dataError{err})
+	}
+	trustedNow := time.Unix(epoch, 0).Add(age)
+	if delta := trustedNow.Sub(systemNow); (delta > time.Second || delta < -time.Second) {
+		log.Printf("using time from %s, %s from the system clock", nowFile, delta.Round(time.Second))
+	}
+	return trustedNow
+}
+
+// parses a time of day like 18:30 into the time since midnight
+func parseClockTime(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, argumentError{err}
+	}
+	return time.Duration(clock.Hour()) * time.Hour + time.Duration(clock.Minute()) * time.Minute, nil
+}
+
+// reports whether now falls in the night between start and end, both times since local midnight.
+// a start after the end wraps across midnight, so 18:00 to 06:00 covers the small hours too
+func isNightTime(now time.Time, start time.Duration, end time.Duration) bool {
+	midnight := moonphase.NewDayKey(now).Time(now.Location())
+	sinceMidnight := now.Sub(midnight)
+	if (start > end) {
+		return sinceMidnight >= start || sinceMidnight < end
+	}
+	return sinceMidnight >= start && sinceMidnight < end
+}
+
+// the state file key -night-only keeps the last sunrise and sunset it looked up under
+const sunTimesStateKey string = "night-only-sun"
+
+// formats a sunrise or sunset for the state file, - when it doesn't happen that day
+func formatSunTime(instant time.Time) string {
+	if (instant.IsZero()) {
+		return "-"
+	}
+	return instant.Format(time.RFC3339)
+}
+
+// parses a sunrise or sunset formatSunTime wrote
+func parseSunTime(value string) (time.Time, error) {
+	if (value == "-") {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// returns the sunrise and sunset of now's day at lat and lon, from the state file when they were
+// looked up for the same day and place, so a status bar running -night-only every minute only
+// asks the API once a day
+func sunTimesFor(now time.Time, lat float64, lon float64, stateFilePath string) (moonphase.RiseSetTimes, error) {
+	dayAndPlace := fmt.Sprintf("%s %g,%g", moonphase.NewDayKey(now), lat, lon)
+	if fields := strings.Fields(loadState(stateFilePath)[sunTimesStateKey]); (len(fields) == 4 && fields[0] + " " + fields[1] == dayAndPlace) {
+		sunrise, riseErr := parseSunTime(fields[2])
+		sunset, setErr := parseSunTime(fields[3])
+		if (riseErr == nil && setErr == nil) {
+			return moonphase.RiseSetTimes{Sunrise: sunrise, Sunset: sunset}, nil
+		}
+	}
+	times, err := client.RiseSet(ctx, now, lat, lon)
+	if err != nil {
+		return moonphase.RiseSetTimes{}, err
+	}
+	state := loadState(stateFilePath)
+	state[sunTimesStateKey] = dayAndPlace + " " + formatSunTime(times.Sunrise) + " " + formatSunTime(times.Sunset)
+	saveState(stateFilePath, state)
+	return times, nil
+}
+
+// the sun's declination in degrees on the day of t, to within a degree or so, enough to tell
+// the polar day from the polar night
+func solarDeclination(t time.Time) float64 {
+	return -23.44 * math.Cos(2 * math.Pi / 365 * float64(t.YearDay() + 10))
+}
+
+// reports whether the sun is down at now, given the sunrise and sunset of its day at latitude lat.
+// near the polar circles the sun can set and rise again the same day, or only do one of them,
+// and past them neither: the sun stays up in the summer of lat's hemisphere and down in its winter
+func isSunDown(now time.Time, times moonphase.RiseSetTimes, lat float64) bool {
+	rises := !times.Sunrise.IsZero()
+	sets := !times.Sunset.IsZero()
+	switch {
+	case (rises && sets && times.Sunrise.Before(times.Sunset)):
+		return now.Before(times.Sunrise) || !now.Before(times.Sunset)
+	case (rises && sets):
+		return !now.Before(times.Sunset) && now.Before(times.Sunrise)
+	case (rises):
+		return now.Before(times.Sunrise)
+	case (sets):
+		return !now.Before(times.Sunset)
+	}
+	return solarDeclination(now) * lat < 0
+}
+
+// reports whether it's night at now at lat and lon for -night-only, going by the sun. when the
+// API can't be reached it warns and returns fallback, the -night-start to -night-end window
+func isNightAt(now time.Time, lat float64, lon float64, stateFilePath string, fallback bool) bool {
+	times, err := sunTimesFor(now, lat, lon, stateFilePath)
+	if err != nil {
+		log.Printf("warning: can't look up sunrise and sunset, going by -night-start and -night-end: %v", err)
+		return fallback
+	}
+	return isSunDown(now, times, lat)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// writes epoch to a -now-file in dir, last modified age ago
+func writeNowFile(t *testing.T, dir string, epoch time.Time, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(dir, "trusted-epoch")
+	if err := os.WriteFile(path, []byte(strconv.FormatInt(epoch.Unix(), 10) + "\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// a fresh file's timestamp is advanced by the file's age and the delta to the system clock
+// logged, a stale or missing file falls back to the system clock with a warning
+func TestGetNow(t *testing.T) {
+	trusted := time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		file   string
+		want   time.Time
+		logged string
+	}{
+		{"no file", "", time.Now(), ""},
+		{"fresh", writeNowFile(t, t.TempDir(), trusted, 10 * time.Minute), trusted.Add(10 * time.Minute), "using time from"},
+		{"stale", writeNowFile(t, t.TempDir(), trusted, 25 * time.Hour), time.Now(), "old, using the system clock"},
+		{"missing", filepath.Join(dir, "missing"), time.Now(), "can't read"},
+	}
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	for _, test := range tests {
+		logged.Reset()
+		got := getNow(test.file, defaultNowFileMaxAge)
+		if delta := got.Sub(test.want); (delta > 2 * time.Second || delta < -2 * time.Second) {
+			t.Errorf("%s: got %s, want %s", test.name, got.Format(time.RFC3339), test.want.Format(time.RFC3339))
+		}
+		if ((test.logged == "" && logged.Len() != 0) || !strings.Contains(logged.String(), test.logged)) {
+			t.Errorf("%s: logged %q, want %q", test.name, logged.String(), test.logged)
+		}
+	}
+}
+
+// lookups are timed by the trusted clock, down to the next phase -show-next counts to, a stale
+// file is ignored with a warning and one that doesn't hold a timestamp is a parse error
+func TestNowFile(t *testing.T) {
+	dir := t.TempDir()
+	source := []string{"-plaintext", "-timezone", "UTC", "-source", "file", "-source-file", recordedUSNOFile(t)}
+	fresh := writeNowFile(t, dir, time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC), time.Minute)
+	run := runMoonphase(t, dir, append([]string{"-now-file", fresh, "-show-next"}, source...)...)
+	if want := "Waxing Gibbous — 5 days until Full Moon (Mar 25)\n"; (run.code != 0 || run.stdout != want) {
+		t.Errorf("fresh file: exit %d, printed %q, want %q\n%s", run.code, run.stdout, want, run.stderr)
+	}
+	run = runMoonphase(t, dir, append([]string{"around", "-n", "1", "-now-file", fresh}, source...)...)
+	if want := "  -3d  2024-03-17 04:11 UTC  First Quarter\n  +5d  2024-03-25 07:00 UTC  Full Moon\n"; (run.code != 0 || run.stdout != want) {
+		t.Errorf("around: exit %d, printed %q, want %q\n%s", run.code, run.stdout, want, run.stderr)
+	}
+
+	stale := writeNowFile(t, t.TempDir(), time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC), 48 * time.Hour)
+	run = runMoonphase(t, dir, "-now-file", stale, "-offline", "-plaintext")
+	if (run.code != 0 || !strings.Contains(run.stderr, "using the system clock")) {
+		t.Errorf("stale file: exit %d, want 0 and a warning\n%s", run.code, run.stderr)
+	}
+
+	garbage := filepath.Join(dir, "garbage")
+	if err := os.WriteFile(garbage, []byte("yesterday\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run = runMoonphase(t, dir, "-now-file", garbage, "-offline", "-plaintext")
+	if (run.code != exitParse || run.stdout != "" || !strings.Contains(run.stderr, "doesn't hold a Unix timestamp")) {
+		t.Errorf("garbage file: exit %d, printed %q, want exit %d\n%s", run.code, run.stdout, exitParse, run.stderr)
+	}
+}
+
+func TestIsNightTime(t *testing.T) {
+	tests := []struct {
+		clock      string
+		start, end string
+		want       bool
+	}{
+		{"17:59", "18:00", "06:00", false},
+		{"18:00", "18:00", "06:00", true},
+		{"23:59", "18:00", "06:00", true},
+		{"00:00", "18:00", "06:00", true},
+		{"05:59", "18:00", "06:00", true},
+		{"06:00", "18:00", "06:00", false},
+		{"12:00", "18:00", "06:00", false},
+		{"00:30", "00:00", "05:00", true},
+		{"05:00", "00:00", "05:00", false},
+		{"23:30", "00:00", "05:00", false},
+	}
+	for _, test := range tests {
+		clock, err := parseClockTime(test.clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC).Add(clock)
+		start, _ := parseClockTime(test.start)
+		end, _ := parseClockTime(test.end)
+		if got := isNightTime(now, start, end); (got != test.want) {
+			t.Errorf("%s from %s to %s: got %v, want %v", test.clock, test.start, test.end, got, test.want)
+		}
+	}
+}
+
+// night is when the sun is down, and past the polar circles it stays up all the summer and
+// down all the winter of the latitude's hemisphere
+func TestIsSunDown(t *testing.T) {
+	washington := time.FixedZone("EDT", -4 * 60 * 60)
+	at := func(month time.Month, day int, clock string) time.Time {
+		hours, _ := parseClockTime(clock)
+		return time.Date(2024, month, day, 0, 0, 0, 0, washington).Add(hours)
+	}
+	march25 := moonphase.RiseSetTimes{Sunrise: at(time.March, 25, "07:04"), Sunset: at(time.March, 25, "19:29")}
+	// a sunset in the small hours of the day, then a sunrise
+	setFirst := moonphase.RiseSetTimes{Sunrise: at(time.June, 10, "02:10"), Sunset: at(time.June, 10, "00:40")}
+	tests := []struct {
+		name  string
+		now   time.Time
+		times moonphase.RiseSetTimes
+		lat   float64
+		want  bool
+	}{
+		{"before sunrise", at(time.March, 25, "07:03"), march25, 38.88, true},
+		{"at sunrise", at(time.March, 25, "07:04"), march25, 38.88, false},
+		{"afternoon", at(time.March, 25, "15:00"), march25, 38.88, false},
+		{"at sunset", at(time.March, 25, "19:29"), march25, 38.88, true},
+		{"late evening", at(time.March, 25, "23:30"), march25, 38.88, true},
+		{"before the early sunset", at(time.June, 10, "00:20"), setFirst, 66, false},
+		{"between sunset and sunrise", at(time.June, 10, "01:00"), setFirst, 66, true},
+		{"after the sunrise", at(time.June, 10, "12:00"), setFirst, 66, false},
+		{"only a sunrise, before it", at(time.June, 10, "01:00"), moonphase.RiseSetTimes{Sunrise: at(time.June, 10, "02:10")}, 66, true},
+		{"only a sunset, after it", at(time.June, 10, "23:50"), moonphase.RiseSetTimes{Sunset: at(time.June, 10, "23:40")}, 66, true},
+		{"polar day", at(time.June, 21, "23:00"), moonphase.RiseSetTimes{}, 78.22, false},
+		{"polar night", at(time.December, 21, "12:00"), moonphase.RiseSetTimes{}, 78.22, true},
+		{"southern polar day", at(time.December, 21, "23:00"), moonphase.RiseSetTimes{}, -77.85, false},
+		{"southern polar night", at(time.June, 21, "12:00"), moonphase.RiseSetTimes{}, -77.85, true},
+	}
+	for _, test := range tests {
+		if got := isSunDown(test.now, test.times, test.lat); (got != test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// the sun times of the day are looked up once and then read from the state file, and a lookup
+// that fails falls back to the fixed hours
+func TestIsNightAt(t *testing.T) {
+	saved := client
+	defer func() { client = saved }()
+	client = moonphase.NewClient()
+	client.BaseURL = "http://127.0.0.1:0"
+	client.Retries = 0
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	stateFile := filepath.Join(t.TempDir(), "state")
+	evening := time.Date(2024, time.March, 25, 20, 0, 0, 0, time.FixedZone("EDT", -4 * 60 * 60))
+	if (isNightAt(evening, 38.88, -77, stateFile, false) || !strings.Contains(logged.String(), "warning")) {
+		t.Errorf("a failed lookup didn't fall back with a warning: %q", logged.String())
+	}
+
+	sunrise := time.Date(2024, time.March, 25, 7, 4, 0, 0, evening.Location())
+	sunset := time.Date(2024, time.March, 25, 19, 29, 0, 0, evening.Location())
+	saveState(stateFile, map[string]string{sunTimesStateKey: "2024-03-25 38.88,-77 " + formatSunTime(sunrise) + " " + formatSunTime(sunset)})
+	if (!isNightAt(evening, 38.88, -77, stateFile, false)) {
+		t.Error("20:00 after a 19:29 sunset isn't night")
+	}
+	if (isNightAt(evening.Add(-5 * time.Hour), 38.88, -77, stateFile, true)) {
+		t.Error("15:00 before a 19:29 sunset is night")
+	}
+	// another place isn't answered from the state file
+	logged.Reset()
+	if (!isNightAt(evening, 51.5, -0.13, stateFile, true) || !strings.Contains(logged.String(), "warning")) {
+		t.Errorf("another place was answered from the state file: %q", logged.String())
+	}
+}
+
+// during the day a -night-only -template still prints, with no phase, so a status bar reading
+// JSON gets an empty text rather than nothing
+func TestNightOnlyTemplate(t *testing.T) {
+	dir := t.TempDir()
+	template := `{"text": "{{.Emoji}}"}`
+	noon := writeNowFile(t, dir, time.Date(2024, time.March, 25, 12, 0, 0, 0, time.UTC), 0)
+	run := runMoonphase(t, dir, "-offline", "-timezone", "UTC", "-now-file", noon, "-night-only", "-template", template)
+	if (run.code != 0 || strings.TrimSpace(run.stdout) != `{"text": ""}`) {
+		t.Errorf("at noon: exit %d, %q, %s", run.code, run.stdout, run.stderr)
+	}
+	run = runMoonphase(t, dir, "-offline", "-timezone", "UTC", "-now-file", noon, "-night-only")
+	if (run.code != 0 || run.stdout != "") {
+		t.Errorf("at noon without a template: exit %d, %q", run.code, run.stdout)
+	}
+	midnight := writeNowFile(t, dir, time.Date(2024, time.March, 25, 23, 0, 0, 0, time.UTC), 0)
+	run = runMoonphase(t, dir, "-offline", "-timezone", "UTC", "-now-file", midnight, "-night-only", "-template", template)
+	if (run.code != 0 || strings.TrimSpace(run.stdout) == `{"text": ""}` || !strings.HasPrefix(run.stdout, `{"text": "`)) {
+		t.Errorf("at night: exit %d, %q, %s", run.code, run.stdout, run.stderr)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// a subcommand, run with the arguments after its name, and the line help lists it with.
+// defineFlags defines the flags it parses, nil for a command without any of its own
+type subcommand struct {
+	run         func(args []string)
+	defineFlags func(flags *flag.FlagSet)
+	summary     string
+}
+
+// returns the flag set of a command
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	// the flag package's own usage, with the heading in messageLanguage like the flags' usage
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "%s\n", messagef("Usage of %s:", name))
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// returns the flags command defines, on a flag set of its own that's never parsed
+func commandFlags(name string, command subcommand) (flags []*flag.Flag) {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	if (command.defineFlags != nil) {
+		command.defineFlags(flagSet)
+	}
+	flagSet.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// returns the subcommand names in order
+func subcommandNames() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reports whether arg asks for help the way the flag package takes it
+func isHelpFlag(arg string) bool {
+	return (arg == "-h" || arg == "-help" || arg == "--h" || arg == "--help")
+}
+
+// prints the top level help, listing the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s\n\n", message("Usage: moonphase [command] [flags]"))
+	fmt.Fprintf(w, "%s\n\n%s\n", message("Without a command, or with only flags, moonphase runs phase and prints today's phase."), message("Commands:"))
+	for _, name := range subcommandNames() {
+		fmt.Fprintf(w, "  %-15s %s\n", name, message(subcommands[name].summary))
+	}
+	fmt.Fprintf(w, "\n%s\n", message("Run moonphase <command> -h for the flags of a command."))
+}
+
+// moonphase help
+func runHelp(args []string) {
+	helpFlags := newFlagSet("help")
+	// only for the language of the help, messageLanguage already has it
+	getLanguage := addLanguageFlag(helpFlags)
+	helpFlags.Parse(args)
+	getLanguage()
+	if (helpFlags.NArg() > 0) {
+		// the command's own -h lists its flags
+		command, found := subcommands[helpFlags.Arg(0)]
+		if (!found) {
+			fatalf(message("unknown command %q, see moonphase help for the commands"), helpFlags.Arg(0))
+		}
+		command.run([]string{"-h"})
+		return
+	}
+	printUsage(stdout)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// returns the flag names a command's -h lists
+func usageFlagNames(usage string) []string {
+	var names []string
+	for _, line := range strings.Split(usage, "\n") {
+		if (strings.HasPrefix(line, "  -")) {
+			names = append(names, strings.Fields(line)[0][1:])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// every command is dispatched by its name, and parses exactly the flags completion lists for it
+func TestDispatchParsesTheCompletionFlags(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range subcommandNames() {
+		t.Run(name, func(t *testing.T) {
+			run := runMoonphase(t, dir, name, "-h")
+			if (run.code != 0) {
+				t.Fatalf("exit %d, want 0:\n%s", run.code, run.stderr)
+			}
+			if (!strings.Contains(run.stderr, "Usage of " + name)) {
+				t.Errorf("usage isn't for %s:\n%s", name, run.stderr)
+			}
+			var want []string
+			for _, f := range commandFlags(name, subcommands[name]) {
+				want = append(want, f.Name)
+			}
+			if got := usageFlagNames(run.stderr); (!reflect.DeepEqual(got, want)) {
+				t.Errorf("-h lists %q, completion %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDispatchUnknownCommand(t *testing.T) {
+	run := runMoonphase(t, t.TempDir(), "phaze")
+	if (run.code != exitInvalidArguments) {
+		t.Errorf("exit %d, want %d", run.code, exitInvalidArguments)
+	}
+	if (!strings.Contains(run.stderr, `unknown command "phaze"`) || !strings.Contains(run.stderr, "Commands:")) {
+		t.Errorf("stderr doesn't name the command and list the others:\n%s", run.stderr)
+	}
+}
+
+// without a command, flags go to phase
+func TestDispatchWithoutCommand(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"-offline", "-plaintext", "-date", "2024-03-25", "-timezone", "UTC"},
+		{"phase", "-offline", "-plaintext", "-date", "2024-03-25", "-timezone", "UTC"},
+	} {
+		run := runMoonphase(t, dir, args...)
+		if (run.code != 0 || run.stdout != "Full Moon\n") {
+			t.Errorf("%q: exit %d, printed %q, want Full Moon:\n%s", args, run.code, run.stdout, run.stderr)
+		}
+	}
+	help := runMoonphase(t, dir, "-h")
+	if (help.code != 0 || !strings.Contains(help.stdout, "Commands:")) {
+		t.Errorf("-h: exit %d, printed %q, want the command list", help.code, help.stdout)
+	}
+}
+
+// defining the flags doesn't depend on the environment, completion works without a home directory
+func TestCompletionWithoutHome(t *testing.T) {
+	t.Setenv("HOME", "")
+	for _, name := range subcommandNames() {
+		commandFlags(name, subcommands[name])
+	}
+	if script := bashCompletion(); (!strings.Contains(script, "-savefile")) {
+		t.Error("bash completion doesn't list -savefile")
+	}
+	flags := flag.NewFlagSet("phase", flag.ContinueOnError)
+	definePhaseFlags(flags)
+	if (flags.Lookup("savefile").DefValue != "") {
+		t.Errorf("-savefile defaults to %q, want it resolved only once parsed", flags.Lookup("savefile").DefValue)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// the shells completion writes scripts for
+var completionShells = []string{"bash", "zsh"}
+
+// the words completed for the first argument after some commands, other arguments complete as files
+func completionArgs() map[string][]string {
+	phases := []string{"new", "first", "full", "last"}
+	return map[string][]string{
+		"cache":      {"compact", "purge"},
+		"completion": completionShells,
+		"help":       subcommandNames(),
+		"next":       phases,
+		"prev":       phases,
+	}
+}
+
+// returns the -name of each of flags
+func flagNames(flags []*flag.Flag) []string {
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "-" + f.Name)
+	}
+	return names
+}
+
+// returns the first line of a flag's usage, for the zsh descriptions
+func flagSummary(f *flag.Flag) string {
+	return strings.SplitN(f.Usage, "\n", 2)[0]
+}
+
+// quotes s for a single quoted shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// returns the bash completion script for the commands and their flags
+func bashCompletion() string {
+	var script strings.Builder
+	script.WriteString("# bash completion for moonphase, load with: source <(moonphase completion bash)\n\n")
+	script.WriteString("_moonphase() {\n")
+	script.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" words\n")
+	fmt.Fprintf(&script, "\tif [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then\n\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n\t\treturn\n\tfi\n",
+		shellQuote(strings.Join(subcommandNames(), " ")))
+	script.WriteString("\tif [[ $cur != -* ]]; then\n\t\t[[ $COMP_CWORD -eq 2 ]] || return\n\t\tcase \"${COMP_WORDS[1]}\" in\n")
+	args := completionArgs()
+	for _, name := range subcommandNames() {
+		if words, found := args[name]; (found) {
+			fmt.Fprintf(&script, "\t\t%s) words=%s ;;\n", name, shellQuote(strings.Join(words, " ")))
+		}
+	}
+	script.WriteString("\t\tesac\n\telse\n\t\tcase \"${COMP_WORDS[1]}\" in\n")
+	for _, name := range subcommandNames() {
+		fmt.Fprintf(&script, "\t\t%s) words=%s ;;\n", name, shellQuote(strings.Join(flagNames(commandFlags(name, subcommands[name])), " ")))
+	}
+	// no command, the flags of phase
+	fmt.Fprintf(&script, "\t\t*) words=%s ;;\n", shellQuote(strings.Join(flagNames(commandFlags("phase", subcommands["phase"])), " ")))
+	script.WriteString("\t\tesac\n\tfi\n")
+	script.WriteString("\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n}\n\n")
+	script.WriteString("complete -o default -F _moonphase moonphase\n")
+	return script.String()
+}
+
+// writes the zsh candidates array of flags, with their usage as descriptions
+func writeZshFlags(script *strings.Builder, indent string, flags []*flag.Flag) {
+	fmt.Fprintf(script, "%scandidates=(\n", indent)
+	for _, f := range flags {
+		fmt.Fprintf(script, "%s\t%s\n", indent, shellQuote("-" + f.Name + ":" + flagSummary(f)))
+	}
+	fmt.Fprintf(script, "%s)\n", indent)
+}
+
+// returns the zsh completion script for the commands and their flags
+func zshCompletion() string {
+	var script strings.Builder
+	script.WriteString("#compdef moonphase\n")
+	script.WriteString("# zsh completion for moonphase, load with: source <(moonphase completion zsh)\n\n")
+	script.WriteString("_moonphase() {\n\tlocal -a candidates\n")
+	script.WriteString("\tif (( CURRENT == 2 )) && [[ $words[CURRENT] != -* ]]; then\n\t\tcandidates=(\n")
+	for _, name := range subcommandNames() {
+		fmt.Fprintf(&script, "\t\t\t%s\n", shellQuote(name + ":" + subcommands[name].summary))
+	}
+	script.WriteString("\t\t)\n\t\t_describe 'command' candidates\n\t\treturn\n\tfi\n")
+	script.WriteString("\tif [[ $words[CURRENT] != -* ]]; then\n\t\tif (( CURRENT == 3 )); then\n\t\t\tcase $words[2] in\n")
+	args := completionArgs()
+	for _, name := range subcommandNames() {
+		if words, found := args[name]; (found) {
+			fmt.Fprintf(&script, "\t\t\t%s) candidates=(%s) ;;\n", name, strings.Join(words, " "))
+		}
+	}
+	script.WriteString("\t\t\tesac\n\t\tfi\n")
+	script.WriteString("\t\tif (( ${#candidates} )); then\n\t\t\t_describe 'argument' candidates\n\t\telse\n\t\t\t_files\n\t\tfi\n\t\treturn\n\tfi\n")
+	script.WriteString("\tcase $words[2] in\n")
+	for _, name := range subcommandNames() {
+		fmt.Fprintf(&script, "\t%s)\n", name)
+		writeZshFlags(&script, "\t\t", commandFlags(name, subcommands[name]))
+		script.WriteString("\t\t;;\n")
+	}
+	// no command, the flags of phase
+	script.WriteString("\t*)\n")
+	writeZshFlags(&script, "\t\t", commandFlags("phase", subcommands["phase"]))
+	script.WriteString("\t\t;;\n\tesac\n\t_describe 'flag' candidates\n}\n\n")
+	// sourced rather than autoloaded from $fpath, register the function
+	script.WriteString("if [[ $funcstack[1] == _moonphase ]]; then\n\t_moonphase \"$@\"\nelse\n\tcompdef _moonphase moonphase\nfi\n")
+	return script.String()
+}
+
+// moonphase completion bash, moonphase completion zsh
+func runCompletion(args []string) {
+	completionFlags := newFlagSet("completion")
+	completionFlags.Parse(args)
+	if (completionFlags.NArg() != 1) {
+		fatalf("completion needs a shell: %s", strings.Join(completionShells, " or "))
+	}
+	var script string
+	switch completionFlags.Arg(0) {
+	case "bash":
+		script = bashCompletion()
+	case "zsh":
+		script = zshCompletion()
+	default:
+		fatalf("unknown shell %q, expected %s", completionFlags.Arg(0), strings.Join(completionShells, " or "))
+	}
+	if _, err := fmt.Fprint(stdout, script); err != nil {
+		fatalOutputError(err)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// the flags the config file can give defaults for, the same in every subcommand that has them
+var configurableFlags = map[string]bool{
+	"plaintext":   true,
+	"emoji-style": true,
+	"timezone":    true,
+	"hemisphere":  true,
+	"lang":        true,
+	"cache-dir":   true,
+	"date-layout": true,
+	"timeout":     true,
+	"retries":     true,
+	"deadline":    true,
+	"max-past":    true,
+	"max-future":  true,
+}
+
+// returns where the config file lives, $MOONPHASE_CONFIG or ~/.config/moonphase/config on Linux
+func configPath() (string, error) {
+	if path := os.Getenv("MOONPHASE_CONFIG"); (path != "") {
+		return path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "moonphase", "config"), nil
+}
+
+// loads the name=value lines of the config file at path, a missing file is an empty config.
+// blank lines and lines starting with # are skipped
+func loadConfig(path string) (map[string]string, error) {
+	config := map[string]string{}
+	file, err := os.Open(path)
+	if err != nil {
+		if (os.IsNotExist(err)) {
+			return config, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if (text == "" || strings.HasPrefix(text, "#")) {
+			continue
+		}
+		name, value, found := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		if (!found || !configurableFlags[name]) {
+			return nil, dataError{fmt.Errorf("%s:%d: expected a setting like emoji-style=text, got %q", path, line, text)}
+		}
+		config[name] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// formats config as the config file holds it
+func formatConfig(config map[string]string) string {
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	// sorted so rewriting the file doesn't reorder it
+	sort.Strings(names)
+	var content strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&content, "%s=%s\n", name, config[name])
+	}
+	return content.String()
+}
+
+// sets the flags in flags that weren't given on the command line from the config file, so the
+// command line always wins, then checks every numeric flag is within flagRanges, wherever its
+// value came from. call it straight after parsing, before normalizeStringFlags, so config values
+// are checked the same way as flags
+func applyConfig(flags *flag.FlagSet) {
+	// without a config directory there's only the command line to check
+	config := map[string]string{}
+	path, err := configPath()
+	if err == nil {
+		config, err = loadConfig(path)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range config {
+		if (given[name] || flags.Lookup(name) == nil) {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			fatalf("%s: invalid %s: %v", path, name, dataError{err})
+		}
+	}
+	flags.VisitAll(func(f *flag.Flag) {
+		if err := checkFlagRange(f); err != nil {
+			if (config[f.Name] != "" && !given[f.Name]) {
+				// the config file is wrong, not the command line
+				fatalf("%s: %v", path, dataError{err})
+			}
+			fatal(err)
+		}
+	})
+}
+
+// the flags of config, read once they have been parsed
+type configOptions struct {
+	applyQuiet func()
+}
+
+// defines the flags of config on flags, for the command to parse and for completion to list
+func defineConfigFlags(flags *flag.FlagSet) *configOptions {
+	options := &configOptions{}
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// returns the default of each configurable flag, as the first command defining it has it
+func configurableDefaults() map[string]string {
+	defaults := map[string]string{}
+	for _, name := range subcommandNames() {
+		for _, f := range commandFlags(name, subcommands[name]) {
+			if _, found := defaults[f.Name]; (configurableFlags[f.Name] && !found) {
+				defaults[f.Name] = f.DefValue
+			}
+		}
+	}
+	return defaults
+}
+
+// formats the settings config show lists: each configurable flag, whether the config file or
+// the flag's default gives its value, the values it allows if it's numeric, and the value
+func formatConfigSettings(path string, config map[string]string) string {
+	defaults := configurableDefaults()
+	names := make([]string, 0, len(configurableFlags))
+	for name := range configurableFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var settings strings.Builder
+	fmt.Fprintf(&settings, "# %s\n", path)
+	fmt.Fprintf(&settings, "%-12s %-8s %-14s %s\n", "setting", "from", "allowed", "value")
+	for _, name := range names {
+		from, value := "default", defaults[name]
+		if configured, found := config[name]; (found) {
+			from, value = "config", configured
+		}
+		fmt.Fprintf(&settings, "%s\n", strings.TrimRight(fmt.Sprintf("%-12s %-8s %-14s %s", name, from, formatFlagRange(name), value), " "))
+	}
+	return settings.String()
+}
+
+// moonphase config show
+func runConfig(args []string) {
+	// the action comes before the flags, without one -h still lists them
+	var action string
+	if (len(args) > 0 && !strings.HasPrefix(args[0], "-")) {
+		action = args[0]
+		args = args[1:]
+	}
+	configFlags := newFlagSet(strings.TrimSpace("config " + action))
+	options := defineConfigFlags(configFlags)
+	configFlags.Parse(args)
+	options.applyQuiet()
+	if (action == "") {
+		fatal("config needs an action: show")
+	}
+	if (action != "show") {
+		fatalf("unknown config action %q, expected show", action)
+	}
+
+	path, err := configPath()
+	if err != nil {
+		fatal(err)
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprint(stdout, formatConfigSettings(path, config))
+}
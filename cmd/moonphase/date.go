@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the absolute date forms parseDateArg accepts, tried in order. single digit months and days
+// parse as well as padded ones
+var dateArgLayouts = []string{"2006-1-2", "2006/1/2"}
+
+// the two readings of a date with slashes and the year last, month first as in the US and day
+// first as in most of Europe. a date only one of them parses, or that both read as the same day,
+// isn't ambiguous
+var slashDateLayouts = []string{"1/2/2006", "2/1/2006"}
+
+// the Go layout from -date-layout, tried before the other forms so a date it parses is never ambiguous
+var dateLayout string
+
+// the days each keyword parseDateArg accepts is from today
+var dateArgKeywords = map[string]int{
+	"today":     0,
+	"tomorrow":  1,
+	"yesterday": -1,
+}
+
+// the days in each unit of a relative date like +3d or -2w
+var dateArgUnits = map[byte]int{
+	'd': 1,
+	'w': 7,
+}
+
+// the most days a relative date can reach either side of today, far past any horizon
+const maxDateArgOffsetDays int = 1000000
+
+// what the error for a date that doesn't parse lists
+const dateArgFormats string = "YYYY-MM-DD, YYYY/MM/DD, MM/DD/YYYY, DD/MM/YYYY, today, tomorrow, yesterday, or days or weeks from today like +3d or -2w"
+
+// a date with slashes that reads as one day month first and another day first, like 03/04/2024
+type ambiguousDateError struct {
+	input      string
+	candidates []moonphase.DayKey
+}
+
+func (e *ambiguousDateError) Error() string {
+	return fmt.Sprintf(message("ambiguous date %q, it could be %s or %s, write it as YYYY-MM-DD or set -date-layout, like -date-layout 2/1/2006 for day first"), e.input, e.candidates[0], e.candidates[1])
+}
+
+// parses a date argument like 2024-03-25, 2024/3/25, 03/25/2024, 25/03/2024, tomorrow or -2w.
+// relative dates count from the day it is at now in loc. a date like 03/04/2024 that reads as two
+// days is an ambiguousDateError, unless -date-layout says which
+func parseDateArg(s string, now time.Time, loc *time.Location) (moonphase.DayKey, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	today := moonphase.NewDayKey(now.In(loc))
+	if days, found := dateArgKeywords[value]; (found) {
+		return today.AddDays(days), nil
+	}
+	if (len(value) > 2 && (value[0] == '+' || value[0] == '-')) {
+		if unit, found := dateArgUnits[value[len(value) - 1]]; (found) {
+			digits := value[1:len(value) - 1]
+			count, err := strconv.Atoi(digits)
+			// Atoi takes a sign of its own, as in +-3d
+			if (err == nil && digits[0] >= '0' && digits[0] <= '9' && count <= maxDateArgOffsetDays / unit) {
+				days := count * unit
+				if (value[0] == '-') {
+					days = -days
+				}
+				return today.AddDays(days), nil
+			}
+		}
+	}
+	if (dateLayout != "") {
+		if date, err := time.Parse(dateLayout, value); (err == nil) {
+			return moonphase.NewDayKey(date), nil
+		}
+	}
+	for _, layout := range dateArgLayouts {
+		if date, err := time.Parse(layout, value); (err == nil) {
+			return moonphase.NewDayKey(date), nil
+		}
+	}
+	var candidates []moonphase.DayKey
+	for _, layout := range slashDateLayouts {
+		if date, err := time.Parse(layout, value); (err == nil && (len(candidates) == 0 || candidates[0] != moonphase.NewDayKey(date))) {
+			candidates = append(candidates, moonphase.NewDayKey(date))
+		}
+	}
+	switch len(candidates) {
+	case 1:
+		return candidates[0], nil
+	case 2:
+		return moonphase.DayKey{}, argumentError{&ambiguousDateError{input: strings.TrimSpace(s), candidates: candidates}}
+	}
+	return moonphase.DayKey{}, argumentError{fmt.Errorf(message("invalid date %q, expected %s"), s, message(dateArgFormats))}
+}
+
+// parseDateArg for a date the user typed. an ambiguous one is asked about when there's a terminal
+// to ask on, and is the ambiguousDateError when there isn't, as for a script
+func parseDateFlag(s string, now time.Time, loc *time.Location) (moonphase.DayKey, error) {
+	day, err := parseDateArg(s, now, loc)
+	var ambiguous *ambiguousDateError
+	if (errors.As(err, &ambiguous) && isTerminal(os.Stdin) && isTerminal(os.Stdout)) {
+		return chooseAmbiguousDate(ambiguous, bufio.NewReader(os.Stdin), stderr)
+	}
+	return day, err
+}
+
+// asks on prompts which reading of an ambiguous date was meant, reading its number from answers
+// and asking again for anything else. answers running out gives the ambiguousDateError back
+func chooseAmbiguousDate(ambiguous *ambiguousDateError, answers *bufio.Reader, prompts io.Writer) (moonphase.DayKey, error) {
+	var readings []string
+	for _, candidate := range ambiguous.candidates {
+		readings = append(readings, candidate.Time(time.UTC).Format("January 2"))
+	}
+	fmt.Fprintln(prompts, messagef("Did you mean %s or %s?", readings[0], readings[1]))
+	for i, candidate := range ambiguous.candidates {
+		fmt.Fprintf(prompts, "  %d) %s\n", i + 1, candidate.Time(time.UTC).Format("January 2, 2006"))
+	}
+	for {
+		fmt.Fprintf(prompts, "%s [1-%d]: ", message("Choice"), len(ambiguous.candidates))
+		line, err := answers.ReadString('\n')
+		if choice, convErr := strconv.Atoi(strings.TrimSpace(line)); (convErr == nil && choice >= 1 && choice <= len(ambiguous.candidates)) {
+			return ambiguous.candidates[choice - 1], nil
+		}
+		if (err == io.EOF) {
+			fmt.Fprintln(prompts)
+			return moonphase.DayKey{}, argumentError{ambiguous}
+		}
+		if err != nil {
+			return moonphase.DayKey{}, err
+		}
+	}
+}
+
+// reports whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return (err == nil && info.Mode() & os.ModeCharDevice != 0)
+}
+
+// reports whether arg is a relative date like -3d, which the flag package would take for a flag
+func isRelativeDateArg(arg string) bool {
+	_, err := parseDateArg(arg, time.Time{}, time.UTC)
+	return (err == nil && (arg[0] == '-' || arg[0] == '+'))
+}
+
+// parses args with flags, taking the arguments that aren't flags or their values as positional
+// wherever they are, before the flags, between them or after. a relative date like -3d that
+// isn't the value of a flag is positional too, rather than an unknown flag
+func parseWithPositionals(flags *flag.FlagSet, args []string) {
+	var flagArgs, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if (arg == "--") {
+			positional = append(positional, args[i + 1:]...)
+			break
+		}
+		if (!strings.HasPrefix(arg, "-") || arg == "-" || isRelativeDateArg(arg)) {
+			positional = append(positional, arg)
+			continue
+		}
+		flagArgs = append(flagArgs, arg)
+		// a flag given as -name value takes the next argument with it, unless it's a bool
+		name := strings.TrimLeft(arg, "-")
+		if (strings.Contains(name, "=")) {
+			continue
+		}
+		if defined := flags.Lookup(name); (defined != nil && i + 1 < len(args)) {
+			if boolFlag, ok := defined.Value.(interface{ IsBoolFlag() bool }); (ok && boolFlag.IsBoolFlag()) {
+				continue
+			}
+			i++
+			flagArgs = append(flagArgs, args[i])
+		}
+	}
+	flags.Parse(append(append(flagArgs, "--"), positional...))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+func TestParseDateArg(t *testing.T) {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// already the 29th in UTC, still the 28th in Los Angeles
+	now := time.Date(2024, time.February, 29, 5, 0, 0, 0, time.UTC)
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"2024-03-25", "2024-03-25"},
+		{"2024-3-5", "2024-03-05"},
+		{"2024/03/25", "2024-03-25"},
+		{"2024/3/5", "2024-03-05"},
+		{"03/25/2024", "2024-03-25"},
+		{"3/25/2024", "2024-03-25"},
+		{"25/03/2024", "2024-03-25"},
+		{"25/3/2024", "2024-03-25"},
+		// the same day either way round
+		{"03/03/2024", "2024-03-03"},
+		{" 2024-03-25 ", "2024-03-25"},
+		// leap days
+		{"2024-02-29", "2024-02-29"},
+		{"2/29/2024", "2024-02-29"},
+		{"2000-02-29", "2000-02-29"},
+		{"2023-02-29", ""},
+		{"1900-02-29", ""},
+		// keywords and relative dates count from the day in the location, not UTC
+		{"today", "2024-02-28"},
+		{"Today", "2024-02-28"},
+		{"tomorrow", "2024-02-29"},
+		{"yesterday", "2024-02-27"},
+		{"+1d", "2024-02-29"},
+		{"+2d", "2024-03-01"},
+		{"+0d", "2024-02-28"},
+		{"-0d", "2024-02-28"},
+		{"-3d", "2024-02-25"},
+		{"+1w", "2024-03-06"},
+		{"-2W", "2024-02-14"},
+		{"+365d", "2025-02-27"},
+		{"-52w", "2023-03-01"},
+		{"+1000001d", ""},
+		{"+-3d", ""},
+		{"++3d", ""},
+		{"3d", ""},
+		{"+3", ""},
+		{"+d", ""},
+		{"+3m", ""},
+		{"", ""},
+		{"2024-13-01", ""},
+		{"13/13/2024", ""},
+		{"3/5/2024", ""},
+		{"next tuesday", ""},
+	}
+	for _, test := range tests {
+		day, err := parseDateArg(test.arg, now, location)
+		if (test.want == "") {
+			if (err == nil) {
+				t.Errorf("%q: parsed as %s, want an error", test.arg, day)
+			} else if (exitCodeFor(err) != exitInvalidArguments) {
+				t.Errorf("%q: %v exits %d, want %d", test.arg, err, exitCodeFor(err), exitInvalidArguments)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.arg, err)
+		} else if (day.String() != test.want) {
+			t.Errorf("%q: got %s, want %s", test.arg, day, test.want)
+		}
+	}
+}
+
+// sets -date-layout for the rest of the test
+func setDateLayout(t *testing.T, layout string) {
+	previous := dateLayout
+	dateLayout = layout
+	t.Cleanup(func() {
+		dateLayout = previous
+	})
+}
+
+// a date with slashes that reads as two days is an ambiguousDateError with both, month first
+// then day first, and -date-layout picks one of them
+func TestAmbiguousDates(t *testing.T) {
+	now := time.Date(2024, time.February, 29, 5, 0, 0, 0, time.UTC)
+	for _, arg := range []string{"03/04/2024", "3/4/2024", " 12/1/2024 "} {
+		_, err := parseDateArg(arg, now, time.UTC)
+		var ambiguous *ambiguousDateError
+		if (!errors.As(err, &ambiguous)) {
+			t.Errorf("%q: got %v, want an ambiguousDateError", arg, err)
+			continue
+		}
+		if (exitCodeFor(err) != exitInvalidArguments) {
+			t.Errorf("%q: exits %d, want %d", arg, exitCodeFor(err), exitInvalidArguments)
+		}
+		if (len(ambiguous.candidates) != 2 || ambiguous.input != strings.TrimSpace(arg) || !strings.Contains(err.Error(), "-date-layout")) {
+			t.Errorf("%q: got %q with %v", arg, err, ambiguous.candidates)
+		}
+	}
+	tests := []struct {
+		layout string
+		arg    string
+		want   string
+	}{
+		{"2/1/2006", "03/04/2024", "2024-04-03"},
+		{"1/2/2006", "03/04/2024", "2024-03-04"},
+		{"02.01.2006", "03.04.2024", "2024-04-03"},
+		// the other forms still parse around the layout
+		{"2/1/2006", "2024-03-04", "2024-03-04"},
+		{"02.01.2006", "25/03/2024", "2024-03-25"},
+	}
+	for _, test := range tests {
+		t.Run(test.layout + " " + test.arg, func(t *testing.T) {
+			setDateLayout(t, test.layout)
+			day, err := parseDateArg(test.arg, now, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (day.String() != test.want) {
+				t.Errorf("got %s, want %s", day, test.want)
+			}
+		})
+	}
+	// a layout that doesn't parse the date leaves it ambiguous
+	setDateLayout(t, "02.01.2006")
+	var ambiguous *ambiguousDateError
+	if _, err := parseDateArg("03/04/2024", now, time.UTC); (!errors.As(err, &ambiguous)) {
+		t.Errorf("with a day-first layout for dots, got %v, want an ambiguousDateError", err)
+	}
+}
+
+// the prompt lists both readings and takes the number of one, asking again for anything else
+func TestChooseAmbiguousDate(t *testing.T) {
+	ambiguous := &ambiguousDateError{
+		input:      "03/04/2024",
+		candidates: []moonphase.DayKey{{Year: 2024, Month: time.March, Day: 4}, {Year: 2024, Month: time.April, Day: 3}},
+	}
+	tests := []struct {
+		answers string
+		want    string
+		prompts int
+	}{
+		{"1\n", "2024-03-04", 1},
+		{"2\n", "2024-04-03", 1},
+		{" 2 \n", "2024-04-03", 1},
+		{"April\n3\n0\n2\n", "2024-04-03", 4},
+		// no newline after the last answer
+		{"1", "2024-03-04", 1},
+		{"", "", 1},
+		{"x\n", "", 2},
+	}
+	for _, test := range tests {
+		var prompts strings.Builder
+		day, err := chooseAmbiguousDate(ambiguous, bufio.NewReader(strings.NewReader(test.answers)), &prompts)
+		if (!strings.HasPrefix(prompts.String(), "Did you mean March 4 or April 3?\n  1) March 4, 2024\n  2) April 3, 2024\n")) {
+			t.Errorf("%q: prompted %q", test.answers, prompts.String())
+		}
+		if got := strings.Count(prompts.String(), "Choice [1-2]: "); (got != test.prompts) {
+			t.Errorf("%q: asked %d times, want %d", test.answers, got, test.prompts)
+		}
+		if (test.want == "") {
+			if (!errors.Is(err, ambiguous)) {
+				t.Errorf("%q: got %s, %v, want the ambiguousDateError", test.answers, day, err)
+			}
+			continue
+		}
+		if (err != nil || day.String() != test.want) {
+			t.Errorf("%q: got %s, %v, want %s", test.answers, day, err, test.want)
+		}
+	}
+}
+
+// with no terminal to ask on an ambiguous -date fails naming ISO dates and -date-layout, and
+// -date-layout or the config file's date-layout reads it
+func TestAmbiguousDateFlag(t *testing.T) {
+	dir := t.TempDir()
+	run := runMoonphase(t, dir, "-offline", "-plaintext", "-date", "03/04/2024")
+	if (run.code != exitInvalidArguments || run.stdout != "" || !strings.Contains(run.stderr, `ambiguous date "03/04/2024", it could be 2024-03-04 or 2024-04-03`)) {
+		t.Errorf("exit %d, printed %q:\n%s", run.code, run.stdout, run.stderr)
+	}
+	// the Last Quarter of March 3rd, and the Waning Crescent of April 3rd
+	for layout, want := range map[string]string{"1/2/2006": "Last Quarter\n", "2/1/2006": "Waning Crescent\n"} {
+		run = runMoonphase(t, dir, "-offline", "-plaintext", "-timezone", "UTC", "-date", "03/04/2024", "-date-layout", layout)
+		if (run.code != 0 || run.stdout != want) {
+			t.Errorf("-date-layout %s: exit %d, printed %q, want %q\n%s", layout, run.code, run.stdout, want, run.stderr)
+		}
+	}
+	// runMoonphase points MOONPHASE_CONFIG at no-config in dir
+	if err := ioutil.WriteFile(filepath.Join(dir, "no-config"), []byte("date-layout=2/1/2006\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run = runMoonphase(t, dir, "-offline", "-plaintext", "-timezone", "UTC", "-date", "03/04/2024")
+	if (run.code != 0 || run.stdout != "Waning Crescent\n") {
+		t.Errorf("date-layout in the config: exit %d, printed %q\n%s", run.code, run.stdout, run.stderr)
+	}
+}
+
+func TestParseWithPositionals(t *testing.T) {
+	tests := []struct {
+		args           []string
+		wantPositional []string
+		wantN          int
+		wantJSON       bool
+		wantDate       string
+	}{
+		{args: []string{"-3d"}, wantPositional: []string{"-3d"}, wantN: 3},
+		{args: []string{"-n", "1", "-3d"}, wantPositional: []string{"-3d"}, wantN: 1},
+		{args: []string{"2024-06-10", "-n", "2", "-json"}, wantPositional: []string{"2024-06-10"}, wantN: 2, wantJSON: true},
+		{args: []string{"-json", "+1w", "-n=4"}, wantPositional: []string{"+1w"}, wantN: 4, wantJSON: true},
+		{args: []string{"2024-03-01", "-json", "2024-03-31"}, wantPositional: []string{"2024-03-01", "2024-03-31"}, wantN: 3, wantJSON: true},
+		// the value of a flag stays with it, even when it looks like a relative date
+		{args: []string{"-date", "-3d"}, wantN: 3, wantDate: "-3d"},
+		{args: []string{"--date", "-2w", "+1d"}, wantPositional: []string{"+1d"}, wantN: 3, wantDate: "-2w"},
+		{args: []string{"-json", "--", "-n"}, wantPositional: []string{"-n"}, wantN: 3, wantJSON: true},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		n := flags.Int("n", 3, "")
+		json := flags.Bool("json", false, "")
+		date := flags.String("date", "", "")
+		parseWithPositionals(flags, test.args)
+		positional := flags.Args()
+		if (len(positional) == 0) {
+			positional = nil
+		}
+		if (!reflect.DeepEqual(positional, test.wantPositional) || *n != test.wantN || *json != test.wantJSON || *date != test.wantDate) {
+			t.Errorf("%q: got %q -n %d -json %v -date %q", test.args, positional, *n, *json, *date)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the name a failed lookup is counted under in /debug/vars, after the exit status the CLI would give it
+func errorKind(err error) string {
+	switch exitCodeFor(err) {
+	case exitInvalidArguments:
+		return "argument"
+	case exitNetwork:
+		return "network"
+	case exitParse:
+		return "malformed"
+	case exitInterrupted:
+		return "canceled"
+	}
+	return "other"
+}
+
+// counts a failed lookup by its errorKind
+func (s *phaseServer) countError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if (s.errorCounts == nil) {
+		s.errorCounts = map[string]int{}
+	}
+	s.errorCounts[errorKind(err)]++
+}
+
+// the server's counters under "moonphase" in /debug/vars. the cache and request counts are the
+// client's CacheStats and UpstreamStats, the ones -verbose logs, and current_phase_id is today's
+// phase once it has been looked up
+func (s *phaseServer) debugVars() *expvar.Map {
+	vars := new(expvar.Map).Init()
+	cache := s.client.CacheStats()
+	upstream := s.client.UpstreamStats()
+	vars.Add("cache_hits", int64(cache.Hits))
+	vars.Add("cache_misses", int64(cache.Misses))
+	vars.Add("cache_refreshes", int64(cache.Refreshes))
+	vars.Add("cache_failures", int64(cache.Failures))
+	vars.Add("upstream_requests", int64(upstream.Requests))
+	vars.Add("upstream_errors", int64(upstream.Errors))
+	errorVars := new(expvar.Map).Init()
+	currentPhaseID := new(expvar.String)
+	s.mutex.Lock()
+	for kind, count := range s.errorCounts {
+		errorVars.Add(kind, int64(count))
+	}
+	if entry, found := s.entries[moonphase.NewDayKey(time.Now().In(s.location))]; (found) {
+		currentPhaseID.Set(moonphase.PhaseID(string(entry.result.Phase)))
+	}
+	s.mutex.Unlock()
+	vars.Set("errors", errorVars)
+	vars.Set("current_phase_id", currentPhaseID)
+	return vars
+}
+
+// GET /debug/vars, the variables expvar publishes, like memstats, and the server's own counters.
+// cmdline is left out since it holds the -api-key
+func (s *phaseServer) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	if (!allowGet(w, r)) {
+		return
+	}
+	vars := map[string]json.RawMessage{}
+	expvar.Do(func(kv expvar.KeyValue) {
+		if (kv.Key != "cmdline") {
+			vars[kv.Key] = json.RawMessage(kv.Value.String())
+		}
+	})
+	vars["moonphase"] = json.RawMessage(s.debugVars().String())
+	writeJSON(w, http.StatusOK, vars)
+}
+
+// reports whether a request comes from this machine
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// answers requests for next from this machine only, others get a 403, unless remote allows them
+func localOnly(remote bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (!remote && !isLoopbackRequest(r)) {
+			writeJSONError(w, http.StatusForbidden, fmt.Errorf("%s is only served to localhost, see -debug-remote", r.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adds the debug routes to mux: /debug/vars, and the net/http/pprof profiles with -debug-endpoints.
+// pprof's cmdline isn't served, like cmdline in /debug/vars
+func (s *phaseServer) addDebugRoutes(mux *http.ServeMux) {
+	mux.Handle("/debug/vars", localOnly(s.debugRemote, http.HandlerFunc(s.handleDebugVars)))
+	if (!s.debugPprof) {
+		return
+	}
+	mux.Handle("/debug/pprof/", localOnly(s.debugRemote, http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/profile", localOnly(s.debugRemote, http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", localOnly(s.debugRemote, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", localOnly(s.debugRemote, http.HandlerFunc(pprof.Trace)))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// sends a GET for path to s from remoteAddr
+func serveFrom(s *phaseServer, path string, remoteAddr string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.RemoteAddr = remoteAddr
+	return serveRequest(s, request)
+}
+
+// returns the moonphase counters of /debug/vars, failing t unless it answers with memstats beside them
+func scrapeDebugVars(t *testing.T, s *phaseServer) map[string]json.RawMessage {
+	t.Helper()
+	response := serveFrom(s, "/debug/vars", "127.0.0.1:50000")
+	if (response.Code != http.StatusOK) {
+		t.Fatalf("status %d: %s", response.Code, response.Body)
+	}
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(response.Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := vars["memstats"]; (!found) {
+		t.Errorf("no memstats in %s", response.Body)
+	}
+	if _, found := vars["cmdline"]; (found) {
+		t.Errorf("cmdline, which holds the -api-key, is in /debug/vars")
+	}
+	var counters map[string]json.RawMessage
+	if err := json.Unmarshal(vars["moonphase"], &counters); err != nil {
+		t.Fatal(err)
+	}
+	return counters
+}
+
+// /debug/vars counts the client's cache lookups and requests to the API, the failed lookups by
+// kind, and has today's phase once it has been looked up
+func TestServeDebugVars(t *testing.T) {
+	recorded := moonphasetest.RecordedUSNOServer(t, recordedUSNOFile(t))
+	server := newTestServer("", "")
+	server.client = moonphase.NewClient()
+	server.client.BaseURL = recorded.URL
+	server.client.Retries = 0
+	server.client.CacheDir = t.TempDir()
+	for _, path := range []string{"/phase?date=2024-03-25", "/phase?date=2024-03-25", "/phase?date=2030-06-01"} {
+		serveFrom(server, path, "127.0.0.1:50000")
+	}
+	counters := scrapeDebugVars(t, server)
+	for _, name := range []string{"cache_hits", "cache_misses", "cache_refreshes", "cache_failures", "upstream_requests", "upstream_errors", "errors", "current_phase_id"} {
+		if _, found := counters[name]; (!found) {
+			t.Errorf("no moonphase.%s in %v", name, counters)
+		}
+	}
+	var requests, errorCount int
+	var errorKinds map[string]int
+	json.Unmarshal(counters["upstream_requests"], &requests)
+	json.Unmarshal(counters["upstream_errors"], &errorCount)
+	json.Unmarshal(counters["errors"], &errorKinds)
+	if (requests != recorded.Requests() || requests == 0 || errorCount != 0) {
+		t.Errorf("upstream_requests %d and upstream_errors %d, the API answered %d without an error", requests, errorCount, recorded.Requests())
+	}
+	if (len(errorKinds) != 1 || errorKinds["argument"] != 1) {
+		t.Errorf("errors %v, want the date past the recorded phases as an argument error", errorKinds)
+	}
+	// the same counts CacheStats gives -verbose
+	var misses int
+	json.Unmarshal(counters["cache_misses"], &misses)
+	if stats := server.client.CacheStats(); (misses != stats.Misses || misses == 0) {
+		t.Errorf("cache_misses %d, the client counted %+v", misses, stats)
+	}
+
+	offline := newTestServer("", "")
+	if got := string(scrapeDebugVars(t, offline)["current_phase_id"]); (got != `""`) {
+		t.Errorf("current_phase_id %s before today was looked up", got)
+	}
+	serveFrom(offline, "/phase", "127.0.0.1:50000")
+	var current string
+	json.Unmarshal(scrapeDebugVars(t, offline)["current_phase_id"], &current)
+	known := false
+	for _, phase := range moonphase.Phases {
+		known = known || moonphase.PhaseID(string(phase)) == current
+	}
+	if (!known) {
+		t.Errorf("current_phase_id %q after today was looked up", current)
+	}
+}
+
+// /debug/vars is always there and the pprof profiles only with -debug-endpoints, both for
+// localhost alone unless -debug-remote, and behind the -api-key like the other routes
+func TestServeDebugRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		pprof      bool
+		remote     bool
+		apiKey     string
+		path       string
+		remoteAddr string
+		want       int
+	}{
+		{"vars from localhost", false, false, "", "/debug/vars", "127.0.0.1:50000", http.StatusOK},
+		{"vars from IPv6 localhost", false, false, "", "/debug/vars", "[::1]:50000", http.StatusOK},
+		{"vars from another host", false, false, "", "/debug/vars", "192.0.2.1:50000", http.StatusForbidden},
+		{"vars from another host with -debug-remote", false, true, "", "/debug/vars", "192.0.2.1:50000", http.StatusOK},
+		{"vars without the key", false, false, "s3cret", "/debug/vars", "127.0.0.1:50000", http.StatusUnauthorized},
+		{"pprof off", false, false, "", "/debug/pprof/", "127.0.0.1:50000", http.StatusNotFound},
+		{"pprof symbol off", false, false, "", "/debug/pprof/symbol", "127.0.0.1:50000", http.StatusNotFound},
+		{"pprof from localhost", true, false, "", "/debug/pprof/", "127.0.0.1:50000", http.StatusOK},
+		{"pprof heap from localhost", true, false, "", "/debug/pprof/heap", "127.0.0.1:50000", http.StatusOK},
+		{"pprof symbol from localhost", true, false, "", "/debug/pprof/symbol", "127.0.0.1:50000", http.StatusOK},
+		{"pprof from another host", true, false, "", "/debug/pprof/", "192.0.2.1:50000", http.StatusForbidden},
+		{"pprof symbol from another host", true, false, "", "/debug/pprof/symbol", "192.0.2.1:50000", http.StatusForbidden},
+		{"pprof from another host with -debug-remote", true, true, "", "/debug/pprof/", "192.0.2.1:50000", http.StatusOK},
+		{"pprof cmdline", true, false, "", "/debug/pprof/cmdline", "127.0.0.1:50000", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		server := newTestServer(test.apiKey, "")
+		server.debugPprof = test.pprof
+		server.debugRemote = test.remote
+		if response := serveFrom(server, test.path, test.remoteAddr); (response.Code != test.want) {
+			t.Errorf("%s: status %d, want %d: %s", test.name, response.Code, test.want, response.Body)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a replayed invocation under -deterministic gives the same bytes every time it's run
+func TestDeterministicRunsRepeat(t *testing.T) {
+	replay := []string{"-deterministic", "-source", "file", "-source-file", recordedUSNOFile(t), "-timezone", "America/Los_Angeles"}
+	invocations := []struct {
+		args     []string
+		artifact string
+	}{
+		{append([]string{"export", "-year", "2024", "-granularity", "daily", "-o", "daily.csv"}, replay...), "daily.csv"},
+		{append([]string{"export", "-start", "2024-01-01", "-end", "2024-12-31", "-o", "events.csv"}, replay...), "events.csv"},
+		{append([]string{"around", "-n", "4", "-json", "2024-06-10"}, replay...), ""},
+		{append([]string{"phase", "-date", "2024-08-19", "-show-illumination", "-show-age", "-o", "phase.txt"}, replay...), "phase.txt"},
+		{append([]string{"diff-providers", "-start", "2024-03-01", "-end", "2024-03-31", "-format", "json"}, replay...), ""},
+	}
+	for _, invocation := range invocations {
+		var runs []moonphaseRun
+		var artifacts [][]byte
+		for i := 0; i < 2; i++ {
+			dir := t.TempDir()
+			run := runMoonphase(t, dir, invocation.args...)
+			if (run.code != 0) {
+				t.Fatalf("%q: exit %d:\n%s", invocation.args, run.code, run.stderr)
+			}
+			runs = append(runs, run)
+			if (invocation.artifact != "") {
+				artifact, err := os.ReadFile(filepath.Join(dir, invocation.artifact))
+				if err != nil {
+					t.Fatal(err)
+				}
+				artifacts = append(artifacts, artifact)
+			}
+		}
+		if (runs[0].stdout != runs[1].stdout) {
+			t.Errorf("%q: stdout differs:\n%s\n---\n%s", invocation.args, runs[0].stdout, runs[1].stdout)
+		}
+		if (runs[0].stderr != runs[1].stderr) {
+			t.Errorf("%q: stderr differs:\n%s\n---\n%s", invocation.args, runs[0].stderr, runs[1].stderr)
+		}
+		if (len(artifacts) == 2 && (len(artifacts[0]) == 0 || !bytes.Equal(artifacts[0], artifacts[1]))) {
+			t.Errorf("%q: %s differs or is empty", invocation.args, invocation.artifact)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the -format values for diff-providers
+var diffFormats = []string{"table", "csv", "json"}
+
+var diffEventsHeader = []string{"phase_id", "a_instant", "b_instant", "delta_seconds"}
+
+// a primary phase event as resolved by both providers. an event only one of them has near the
+// ends of the range is left out of the deltas and has a nil instant for the other, null in JSON
+type diffEvent struct {
+	PhaseID      string             `json:"phase_id"`
+	AInstant     *moonphase.Instant `json:"a_instant"`
+	BInstant     *moonphase.Instant `json:"b_instant"`
+	DeltaSeconds *int64             `json:"delta_seconds"`
+}
+
+// formats an instant of a diffEvent for the table and CSV, empty for a provider without the event
+func formatDiffInstant(instant *moonphase.Instant) string {
+	if (instant == nil) {
+		return ""
+	}
+	text, _ := instant.MarshalText()
+	return string(text)
+}
+
+// a day the two providers classify differently
+type diffDisagreement struct {
+	Date moonphase.DayKey `json:"date"`
+	A    string           `json:"a"`
+	B    string           `json:"b"`
+}
+
+// the accuracy of provider b against provider a over the range
+type diffSummary struct {
+	Events             int                `json:"events"`
+	Unmatched          int                `json:"unmatched"`
+	MaxDeltaSeconds    int64              `json:"max_delta_seconds"`
+	MeanDeltaSeconds   float64            `json:"mean_delta_seconds"`
+	Days               int                `json:"days"`
+	DailyDisagreements []diffDisagreement `json:"daily_disagreements"`
+}
+
+// returns the primary phase events provider resolves from start up to but not including end
+func getProviderEvents(provider string, start time.Time, end time.Time) ([]moonphase.MoonPhase, error) {
+	if (provider == offlineProvider) {
+		return moonphase.MeanPhasesBetween(start, end), nil
+	}
+	return client.PhasesBetween(ctx, start, end)
+}
+
+// returns the phase provider classifies each day from start to end as, inclusive
+func getProviderDailyPhases(provider string, start time.Time, end time.Time) ([]moonphase.Phase, error) {
+	var phases []moonphase.Phase
+	if (provider == offlineProvider) {
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			phases = append(phases, moonphase.ComputePhase(day))
+		}
+		return phases, nil
+	}
+	err := client.WalkDailyPhases(ctx, start, end, moonphase.SnapStrategy, func(day time.Time, phase moonphase.Phase) bool {
+		phases = append(phases, phase)
+		return true
+	})
+	return phases, err
+}
+
+// pairs each event of a with the event of the same phase in b nearest to it, within half the mean
+// interval between primary phases so neighbouring cycles are never paired
+func matchEvents(a []moonphase.MoonPhase, b []moonphase.MoonPhase) []diffEvent {
+	maxGap := moonphase.MeanSynodicMonth / 8
+	matched := make([]bool, len(b))
+	var events []diffEvent
+	for _, aPhase := range a {
+		aInstant, _ := moonphase.GetPhaseInstant(aPhase)
+		event := diffEvent{PhaseID: moonphase.PhaseID(aPhase.Phase), AInstant: &moonphase.Instant{Time: aInstant}}
+		nearest := -1
+		var nearestGap time.Duration
+		for i, bPhase := range b {
+			bInstant, _ := moonphase.GetPhaseInstant(bPhase)
+			gap := bInstant.Sub(aInstant)
+			if (matched[i] || bPhase.Phase != aPhase.Phase || absDuration(gap) >= maxGap) {
+				continue
+			}
+			if (nearest < 0 || absDuration(gap) < absDuration(nearestGap)) {
+				nearest = i
+				nearestGap = gap
+			}
+		}
+		if (nearest >= 0) {
+			matched[nearest] = true
+			bInstant, _ := moonphase.GetPhaseInstant(b[nearest])
+			event.BInstant = &moonphase.Instant{Time: bInstant}
+			delta := int64(nearestGap / time.Second)
+			event.DeltaSeconds = &delta
+		}
+		events = append(events, event)
+	}
+	for i, bPhase := range b {
+		if (!matched[i]) {
+			bInstant, _ := moonphase.GetPhaseInstant(bPhase)
+			events = append(events, diffEvent{PhaseID: moonphase.PhaseID(bPhase.Phase), BInstant: &moonphase.Instant{Time: bInstant}})
+		}
+	}
+	return events
+}
+
+// works out the delta statistics over the matched events and lists the days classified differently
+func summarizeDiff(events []diffEvent, startDay moonphase.DayKey, aDaily []moonphase.Phase, bDaily []moonphase.Phase) diffSummary {
+	summary := diffSummary{Days: len(aDaily), DailyDisagreements: []diffDisagreement{}}
+	var total int64
+	for _, event := range events {
+		if (event.DeltaSeconds == nil) {
+			summary.Unmatched++
+			continue
+		}
+		summary.Events++
+		delta := *event.DeltaSeconds
+		if (delta < 0) {
+			delta = -delta
+		}
+		total += delta
+		if (delta > summary.MaxDeltaSeconds) {
+			summary.MaxDeltaSeconds = delta
+		}
+	}
+	if (summary.Events > 0) {
+		summary.MeanDeltaSeconds = float64(total) / float64(summary.Events)
+	}
+	for i := range aDaily {
+		if (i < len(bDaily) && aDaily[i] != bDaily[i]) {
+			summary.DailyDisagreements = append(summary.DailyDisagreements, diffDisagreement{
+				Date: startDay.AddDays(i),
+				A:    string(aDaily[i]),
+				B:    string(bDaily[i]),
+			})
+		}
+	}
+	return summary
+}
+
+// returns the magnitude of d
+func absDuration(d time.Duration) time.Duration {
+	if (d < 0) {
+		return -d
+	}
+	return d
+}
+
+// formats a delta in seconds like +1h23m or -14m, for the table
+func formatDelta(seconds int64) string {
+	sign := "+"
+	if (seconds < 0) {
+		sign = "-"
+	}
+	formatted := absDuration(time.Duration(seconds) * time.Second).String()
+	if (strings.HasSuffix(formatted, "m0s")) {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	return sign + formatted
+}
+
+// the flags of diff-providers, read once they have been parsed
+type diffOptions struct {
+	startFlag        *string
+	endFlag          *string
+	aFlag            *string
+	bFlag            *string
+	formatFlag       *string
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation      func() *time.Location
+	applyQuiet       func()
+}
+
+// defines the flags of diff-providers on flags, for the command to parse and for completion to list
+func defineDiffFlags(flags *flag.FlagSet) *diffOptions {
+	options := &diffOptions{}
+	options.startFlag = flags.String("start", "", message("First date to compare, required"))
+	options.endFlag = flags.String("end", "", message("Last date to compare, required"))
+	options.aFlag = flags.String("a", usnoProvider, messagef("Provider to compare against: %s", strings.Join(providers, " or ")))
+	options.bFlag = flags.String("b", offlineProvider, messagef("Provider to compare: %s", strings.Join(providers, " or ")))
+	options.formatFlag = flags.String("format", "table", messagef("Output format: %s", strings.Join(diffFormats, ", ")))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase diff-providers -start 2024-01-01 -end 2024-12-31 -a usno -b offline
+func runDiffProviders(args []string) {
+	diffFlags := newFlagSet("diff-providers")
+	options := defineDiffFlags(diffFlags)
+	diffFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(diffFlags)
+	normalizeStringFlags(diffFlags)
+	options.applyClientFlags()
+
+	if (*options.startFlag == "" || *options.endFlag == "") {
+		fatal("diff-providers needs both -start and -end")
+	}
+	for _, provider := range []string{*options.aFlag, *options.bFlag} {
+		if (provider != usnoProvider && provider != offlineProvider) {
+			fatalf("unknown provider %q, expected %s", provider, strings.Join(providers, " or "))
+		}
+	}
+	switch *options.formatFlag {
+	case "table", "csv", "json":
+	default:
+		fatalf("unknown format %q, expected %s", *options.formatFlag, strings.Join(diffFormats, ", "))
+	}
+	location := options.getLocation()
+	now := time.Now()
+	startDay, err := parseDateFlag(*options.startFlag, now, location)
+	if err != nil {
+		fatal(err)
+	}
+	endDay, err := parseDateFlag(*options.endFlag, now, location)
+	if err != nil {
+		fatal(err)
+	}
+	today := moonphase.NewDayKey(now.In(location))
+	options.checkHorizon(startDay, today)
+	options.checkHorizon(endDay, today)
+	start := startDay.Time(location)
+	end := endDay.Time(location)
+	if (end.Before(start)) {
+		fatal("diff-providers -end is before -start")
+	}
+
+	// the inclusive local days become the half-open range of instants up to the start of the day after end
+	aEvents, err := getProviderEvents(*options.aFlag, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		fatal(err)
+	}
+	bEvents, err := getProviderEvents(*options.bFlag, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		fatal(err)
+	}
+	aDaily, err := getProviderDailyPhases(*options.aFlag, start, end)
+	if err != nil {
+		fatal(err)
+	}
+	bDaily, err := getProviderDailyPhases(*options.bFlag, start, end)
+	if err != nil {
+		fatal(err)
+	}
+	events := matchEvents(aEvents, bEvents)
+	summary := summarizeDiff(events, startDay, aDaily, bDaily)
+
+	switch *options.formatFlag {
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(struct {
+			A       string      `json:"a"`
+			B       string      `json:"b"`
+			Start   string      `json:"start"`
+			End     string      `json:"end"`
+			Events  []diffEvent `json:"events"`
+			Summary diffSummary `json:"summary"`
+		}{*options.aFlag, *options.bFlag, startDay.String(), endDay.String(), events, summary})
+		if err != nil {
+			fatalOutputError(err)
+		}
+	case "csv":
+		// only the events are rows, the summary goes to stderr like export's progress
+		writer := csv.NewWriter(stdout)
+		writer.Write(diffEventsHeader)
+		for _, event := range events {
+			delta := ""
+			if (event.DeltaSeconds != nil) {
+				delta = strconv.FormatInt(*event.DeltaSeconds, 10)
+			}
+			writer.Write([]string{event.PhaseID, formatDiffInstant(event.AInstant), formatDiffInstant(event.BInstant), delta})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			fatalOutputError(err)
+		}
+		fmt.Fprintf(stderr, "%d events, %d unmatched, max delta %ds, mean delta %.0fs, %d of %d days classified differently\n",
+			summary.Events, summary.Unmatched, summary.MaxDeltaSeconds, summary.MeanDeltaSeconds, len(summary.DailyDisagreements), summary.Days)
+	default:
+		var table strings.Builder
+		fmt.Fprintf(&table, "%-14s  %-20s  %-20s  %s\n", "phase", *options.aFlag, *options.bFlag, "delta")
+		for _, event := range events {
+			delta := "unmatched"
+			if (event.DeltaSeconds != nil) {
+				delta = formatDelta(*event.DeltaSeconds)
+			}
+			fmt.Fprintf(&table, "%-14s  %-20s  %-20s  %s\n", event.PhaseID, formatDiffInstant(event.AInstant), formatDiffInstant(event.BInstant), delta)
+		}
+		fmt.Fprintln(&table)
+		fmt.Fprintf(&table, "%d events matched, %d unmatched\n", summary.Events, summary.Unmatched)
+		// the summary deltas are magnitudes
+		fmt.Fprintf(&table, "max delta %s, mean delta %s\n", strings.TrimPrefix(formatDelta(summary.MaxDeltaSeconds), "+"),
+			strings.TrimPrefix(formatDelta(int64(math.Round(summary.MeanDeltaSeconds))), "+"))
+		fmt.Fprintf(&table, "%d of %d days classified differently\n", len(summary.DailyDisagreements), summary.Days)
+		for _, disagreement := range summary.DailyDisagreements {
+			fmt.Fprintf(&table, "  %s  %s: %s, %s: %s\n", disagreement.Date, *options.aFlag, disagreement.A, *options.bFlag, disagreement.B)
+		}
+		if _, err := stdout.Write([]byte(table.String())); err != nil {
+			fatalOutputError(err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// events pair up by phase within an eighth of a month, and the rest keep a nil instant for the other side
+func TestMatchEvents(t *testing.T) {
+	a := moonphasetest.MoonPhases(
+		moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:00"),
+		moonphasetest.Event(moonphase.FirstQuarter, "2024-03-17 04:11"),
+	)
+	b := moonphasetest.MoonPhases(
+		moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:02"),
+		moonphasetest.Event(moonphase.FullMoon, "2024-03-25 07:00"),
+	)
+	events := matchEvents(a, b)
+	if (len(events) != 3) {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	if (events[0].DeltaSeconds == nil || *events[0].DeltaSeconds != 120) {
+		t.Errorf("new moon delta %v, want 120", events[0].DeltaSeconds)
+	}
+	if (events[1].BInstant != nil || events[1].DeltaSeconds != nil) {
+		t.Errorf("first quarter matched %+v, want unmatched", events[1])
+	}
+	if (events[2].AInstant != nil || formatDiffInstant(events[2].BInstant) != "2024-03-25T07:00:00Z") {
+		t.Errorf("full moon %+v, want only b at 2024-03-25T07:00:00Z", events[2])
+	}
+	encoded, err := json.Marshal(events[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"a_instant":"2024-03-17T04:11:00Z","b_instant":null`; (!strings.Contains(string(encoded), want)) {
+		t.Errorf("encoded %s, want it to contain %s", encoded, want)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the words of a digest, the phase names come from Phase.Name
+type digestWords struct {
+	// a format for the week, like 2024-W34
+	Title        string
+	Nights       string
+	Night        string
+	Phase        string
+	Lit          string
+	Events       string
+	NoEvents     string
+	Darkest      string
+	NextFullMoon string
+	// short day names, Sunday first like time.Weekday
+	Weekdays     [7]string
+}
+
+// returns the digest's words in messageLanguage
+func getDigestWords() digestWords {
+	words := digestWords{
+		Title:        message("Moon digest for week %s"),
+		Nights:       message("Nights"),
+		Night:        message("Night"),
+		Phase:        message("Phase"),
+		Lit:          message("Lit"),
+		Events:       message("Primary phases"),
+		NoEvents:     message("No primary phases this week"),
+		Darkest:      message("Darkest night"),
+		NextFullMoon: message("Next full moon"),
+	}
+	for day := time.Sunday; (day <= time.Saturday); day++ {
+		words.Weekdays[day] = message(day.String()[:3])
+	}
+	return words
+}
+
+// one night of the week
+type digestNight struct {
+	// the local day the night starts on
+	Date         moonphase.DayKey
+	Weekday      string
+	Symbol       string
+	Name         string
+	// whole percent lit at the midnight the night runs through
+	Illumination int
+}
+
+// a primary phase event during the week
+type digestEvent struct {
+	Symbol string
+	Name   string
+	// the local weekday, date and time
+	Time   string
+}
+
+// what the digest templates are given
+type digestReport struct {
+	Title        string
+	Words        digestWords
+	Start        moonphase.DayKey
+	End          moonphase.DayKey
+	Nights       []digestNight
+	Events       []digestEvent
+	Darkest      digestNight
+	NextFullMoon digestEvent
+}
+
+// the output formats of digest
+var digestFormats = []string{"text", "markdown", "html"}
+
+// the template of each digest format. html escapes the names, so a translation can't break the
+// page it's pasted into
+var digestTemplates = map[string]interface{ Execute(io.Writer, interface{}) error }{
+	"text": template.Must(template.New("text").Parse(`{{.Title}} ({{.Start}} – {{.End}})
+
+{{.Words.Nights}}
+{{range .Nights}}  {{.Weekday}} {{.Date}}  {{with .Symbol}}{{.}} {{end}}{{printf "%-20s" .Name}} {{printf "%3d" .Illumination}}%
+{{end}}
+{{.Words.Events}}
+{{range .Events}}  {{with .Symbol}}{{.}} {{end}}{{printf "%-20s" .Name}} {{.Time}}
+{{else}}  {{.Words.NoEvents}}
+{{end}}
+{{.Words.Darkest}}: {{.Darkest.Weekday}} {{.Darkest.Date}}, {{with .Darkest.Symbol}}{{.}} {{end}}{{.Darkest.Name}}, {{.Darkest.Illumination}}%
+{{.Words.NextFullMoon}}: {{.NextFullMoon.Time}}
+`)),
+	"markdown": template.Must(template.New("markdown").Parse(`# {{.Title}}
+
+{{.Start}} – {{.End}}
+
+## {{.Words.Nights}}
+
+| {{.Words.Night}} | {{.Words.Phase}} | {{.Words.Lit}} |
+| --- | --- | ---: |
+{{range .Nights}}| {{.Weekday}} {{.Date}} | {{with .Symbol}}{{.}} {{end}}{{.Name}} | {{.Illumination}}% |
+{{end}}
+## {{.Words.Events}}
+
+{{range .Events}}- {{with .Symbol}}{{.}} {{end}}**{{.Name}}** {{.Time}}
+{{else}}{{.Words.NoEvents}}
+{{end}}
+**{{.Words.Darkest}}:** {{.Darkest.Weekday}} {{.Darkest.Date}}, {{with .Darkest.Symbol}}{{.}} {{end}}{{.Darkest.Name}}, {{.Darkest.Illumination}}%
+
+**{{.Words.NextFullMoon}}:** {{.NextFullMoon.Time}}
+`)),
+	"html": htmltemplate.Must(htmltemplate.New("html").Parse(`<section class="moon-digest">
+<h2>{{.Title}}</h2>
+<p>{{.Start}} – {{.End}}</p>
+<h3>{{.Words.Nights}}</h3>
+<table>
+<tr><th>{{.Words.Night}}</th><th>{{.Words.Phase}}</th><th>{{.Words.Lit}}</th></tr>
+{{range .Nights}}<tr><td>{{.Weekday}} {{.Date}}</td><td>{{with .Symbol}}{{.}} {{end}}{{.Name}}</td><td>{{.Illumination}}%</td></tr>
+{{end}}</table>
+<h3>{{.Words.Events}}</h3>
+{{if .Events}}<ul>
+{{range .Events}}<li>{{with .Symbol}}{{.}} {{end}}<strong>{{.Name}}</strong> {{.Time}}</li>
+{{end}}</ul>{{else}}<p>{{.Words.NoEvents}}</p>{{end}}
+<p><strong>{{.Words.Darkest}}:</strong> {{.Darkest.Weekday}} {{.Darkest.Date}}, {{with .Darkest.Symbol}}{{.}} {{end}}{{.Darkest.Name}}, {{.Darkest.Illumination}}%</p>
+<p><strong>{{.Words.NextFullMoon}}:</strong> {{.NextFullMoon.Time}}</p>
+</section>
+`)),
+}
+
+// parses an ISO 8601 week like 2024-W34 and returns its Monday
+func parseISOWeek(value string) (moonphase.DayKey, error) {
+	yearPart, weekPart, found := strings.Cut(strings.ToUpper(strings.TrimSpace(value)), "-W")
+	year, yearErr := strconv.Atoi(yearPart)
+	week, weekErr := strconv.Atoi(weekPart)
+	if (!found || yearErr != nil || weekErr != nil || year < 1 || year > 9999 || week < 1 || week > 53) {
+		return moonphase.DayKey{}, argumentError{fmt.Errorf(message("invalid -week %q, expected a week like 2024-W34"), value)}
+	}
+	// week 1 is the week with January 4th in it
+	january4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := january4.AddDate(0, 0, (week - 1) * 7 - (int(january4.Weekday()) + 6) % 7)
+	if isoYear, isoWeek := monday.ISOWeek(); (isoYear != year || isoWeek != week) {
+		return moonphase.DayKey{}, argumentError{fmt.Errorf(message("invalid -week %q, %d has no week %d"), value, year, week)}
+	}
+	return moonphase.NewDayKey(monday), nil
+}
+
+// the flags of digest, read once they have been parsed
+type digestOptions struct {
+	weekFlag         *string
+	outputFlag       *string
+	plaintextFlag    *bool
+	emojiStyleFlag   *string
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation      func() *time.Location
+	getHemisphere    func() moonphase.Hemisphere
+	getLanguage      func() string
+	applyQuiet       func()
+}
+
+// defines the flags of digest on flags, for the command to parse and for completion to list
+func defineDigestFlags(flags *flag.FlagSet) *digestOptions {
+	options := &digestOptions{}
+	options.weekFlag = flags.String("week", "", message("ISO week to summarize, like 2024-W34, defaults to this week"))
+	options.outputFlag = flags.String("output", "text", messagef("Format of the digest: %s", strings.Join(digestFormats, ", ")))
+	options.plaintextFlag = flags.Bool("plaintext", false, message("Print phase names without emoji"))
+	options.emojiStyleFlag = flags.String("emoji-style", "auto", message("Emoji presentation: auto, emoji, text, shortcode, nerdfont or ascii"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	options.getHemisphere = addHemisphereFlag(flags)
+	options.getLanguage = addLanguageFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase digest -week 2024-W34 -output markdown
+func runDigest(args []string) {
+	digestFlags := newFlagSet("digest")
+	options := defineDigestFlags(digestFlags)
+	digestFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(digestFlags)
+	normalizeStringFlags(digestFlags)
+	options.applyClientFlags()
+	if (digestFlags.NArg() > 0) {
+		fatal(argumentError{fmt.Errorf("unexpected argument %q, give the week with -week", digestFlags.Arg(0))})
+	}
+
+	digestTemplate, found := digestTemplates[*options.outputFlag]
+	if (!found) {
+		fatal(argumentError{fmt.Errorf(message("unknown -output %q, expected one of %s"), *options.outputFlag, strings.Join(digestFormats, ", "))})
+	}
+	emojiStyle, err := parseEmojiStyle(*options.emojiStyleFlag)
+	if err != nil {
+		fatal(err)
+	}
+	hemisphere := options.getHemisphere()
+	lang := options.getLanguage()
+	location := options.getLocation()
+	now := getNow("", 0).In(location)
+	var monday moonphase.DayKey
+	if (*options.weekFlag == "") {
+		monday = moonphase.NewDayKey(now.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7)))
+	} else if monday, err = parseISOWeek(*options.weekFlag); err != nil {
+		fatal(err)
+	}
+	weekStart := monday.Time(location)
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	options.checkHorizon(monday, moonphase.NewDayKey(now))
+	checkSourceCovers(monday)
+
+	words := getDigestWords()
+	symbol := func(phase moonphase.Phase) string {
+		if (*options.plaintextFlag) {
+			return ""
+		}
+		return getOutput(phase, false, lang, emojiStyle, hemisphere)
+	}
+	localTime := func(instant time.Time) string {
+		local := instant.In(location)
+		return words.Weekdays[local.Weekday()] + " " + local.Format("2006-01-02 15:04 MST")
+	}
+	isoYear, isoWeek := weekStart.ISOWeek()
+	report := digestReport{
+		Title: fmt.Sprintf(words.Title, fmt.Sprintf("%04d-W%02d", isoYear, isoWeek)),
+		Words: words,
+		Start: monday,
+		End:   moonphase.NewDayKey(weekEnd.AddDate(0, 0, -1)),
+	}
+	// each night takes the phase of the day it starts on and the illumination of the midnight
+	// that ends it, the start of the next day, so the days run to the Monday after the week
+	var previous *moonphase.DailyDetail
+	err = client.WalkDailyDetails(ctx, weekStart, weekEnd, moonphase.SnapStrategy, func(detail moonphase.DailyDetail) bool {
+		if (previous != nil) {
+			report.Nights = append(report.Nights, digestNight{
+				Date:         previous.Date,
+				Weekday:      words.Weekdays[previous.Date.Time(location).Weekday()],
+				Symbol:       symbol(previous.Phase),
+				Name:         previous.Phase.Name(lang),
+				Illumination: int(detail.Illumination * 100 + 0.5),
+			})
+		}
+		previous = &detail
+		return true
+	})
+	if err != nil {
+		fatal(err)
+	}
+	for i, night := range report.Nights {
+		if (i == 0 || night.Illumination < report.Darkest.Illumination) {
+			report.Darkest = night
+		}
+	}
+	events, err := client.PhasesBetween(ctx, weekStart, weekEnd)
+	if err != nil {
+		fatal(err)
+	}
+	for _, event := range events {
+		instant, err := moonphase.GetPhaseInstant(event)
+		if err != nil {
+			fatal(dataError{err})
+		}
+		phase := moonphase.Phase(event.Phase)
+		report.Events = append(report.Events, digestEvent{Symbol: symbol(phase), Name: phase.Name(lang), Time: localTime(instant)})
+	}
+	nextFullMoon, err := client.NextPhase(ctx, weekEnd, moonphase.FullMoon)
+	if err != nil {
+		fatal(err)
+	}
+	report.NextFullMoon = digestEvent{Symbol: symbol(moonphase.FullMoon), Name: moonphase.FullMoon.Name(lang), Time: localTime(nextFullMoon)}
+
+	if err := digestTemplate.Execute(stdout, report); err != nil {
+		fatalOutputError(err)
+	}
+}
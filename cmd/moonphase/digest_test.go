@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseISOWeek(t *testing.T) {
+	tests := []struct {
+		week string
+		want string
+	}{
+		{"2024-W34", "2024-08-19"},
+		{"2024-w01", "2024-01-01"},
+		// the week of December 30th 2024 is the first of 2025
+		{"2025-W01", "2024-12-30"},
+		{"2020-W53", "2020-12-28"},
+		{"2021-W53", ""},
+		{"2024-W00", ""},
+		{"2024-34", ""},
+		{"W34", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		monday, err := parseISOWeek(test.week)
+		if (test.want == "") {
+			if (err == nil) {
+				t.Errorf("%q: parsed as %s, want an error", test.week, monday)
+			} else if (exitCodeFor(err) != exitInvalidArguments) {
+				t.Errorf("%q: %v exits %d, want %d", test.week, err, exitCodeFor(err), exitInvalidArguments)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.week, err)
+		} else if (monday.String() != test.want) {
+			t.Errorf("%q: got %s, want %s", test.week, monday, test.want)
+		}
+	}
+}
+
+// the digest of the recorded week of the August 2024 Full Moon in each format, against
+// testdata/digest
+func TestDigestGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"2024-W34.txt", []string{"-output", "text", "-timezone", "UTC"}},
+		{"2024-W34.md", []string{"-output", "markdown", "-timezone", "UTC"}},
+		{"2024-W34.html", []string{"-output", "html", "-timezone", "UTC"}},
+		// local times and translated words
+		{"2024-W34.es.md", []string{"-output", "markdown", "-timezone", "America/Los_Angeles", "-lang", "es"}},
+	}
+	for _, test := range tests {
+		t.Run(test.golden, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", "digest", test.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := append([]string{"digest", "-week", "2024-W34", "-source", "file", "-source-file", recordedUSNOFile(t)}, test.args...)
+			run := runMoonphase(t, t.TempDir(), args...)
+			if (run.code != 0) {
+				t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+			}
+			if (run.stdout != string(want)) {
+				t.Errorf("got:\n%s\nwant:\n%s", run.stdout, want)
+			}
+		})
+	}
+}
+
+func TestDigestArguments(t *testing.T) {
+	for _, args := range [][]string{
+		{"digest", "-week", "2024-W34", "-output", "pdf"},
+		{"digest", "-week", "2021-W53"},
+		{"digest", "2024-W34"},
+	} {
+		if run := runMoonphase(t, t.TempDir(), args...); (run.code != exitInvalidArguments) {
+			t.Errorf("%q: exit %d, want %d:\n%s", args, run.code, exitInvalidArguments, run.stderr)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// exit statuses for failures, documented in the README. exitInvalidArguments is also what the
+// flag package exits with for a flag it can't parse
+const (
+	exitFailure          int = 1
+	exitInvalidArguments int = 2
+	exitNetwork          int = 3
+	exitParse            int = 4
+	// what a shell reports for a process killed by SIGINT
+	exitInterrupted      int = 130
+)
+
+// an error in the arguments the command was given, exits exitInvalidArguments
+type argumentError struct {
+	error
+}
+
+func (e argumentError) Unwrap() error {
+	return e.error
+}
+
+// an error in data read from a file, like the -now-file or the config file, exits exitParse
+type dataError struct {
+	error
+}
+
+func (e dataError) Unwrap() error {
+	return e.error
+}
+
+// a service other than the USNO API, like the -geocoder, answering with an error, exits exitNetwork
+type networkError struct {
+	error
+}
+
+func (e networkError) Unwrap() error {
+	return e.error
+}
+
+// returns the exit status for err. a malformed response and an argument error are checked before
+// network failures so they aren't taken for one, and Ctrl-C before all three since it cancels a
+// request in flight
+func exitCodeFor(err error) int {
+	var malformed *moonphase.ErrMalformedResponse
+	var data dataError
+	var network networkError
+	var rejected *moonphase.ErrUpstreamRejected
+	var status *moonphase.ErrUpstreamStatus
+	var urlErr *url.Error
+	var netErr net.Error
+	var argument argumentError
+	var coverage *moonphase.ErrOutsideCoverage
+	var outsideFile *moonphase.ErrOutsideFile
+	var timeErr *time.ParseError
+	var numErr *strconv.NumError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &malformed), errors.As(err, &data):
+		return exitParse
+	// whatever an argument error wraps, even a file error that looks like a network one
+	case errors.As(err, &argument):
+		return exitInvalidArguments
+	case errors.As(err, &rejected), errors.As(err, &status), errors.As(err, &network), errors.As(err, &urlErr), errors.As(err, &netErr):
+		return exitNetwork
+	// dates and numbers that don't parse come from the arguments, as do dates the API or the
+	// -source-file has no data for and dates past the -max-past or -max-future horizon
+	case errors.As(err, &timeErr), errors.As(err, &numErr), errors.As(err, &coverage), errors.As(err, &outsideFile), errors.Is(err, moonphase.ErrBeyondHorizon):
+		return exitInvalidArguments
+	}
+	return exitFailure
+}
+
+// returns the exit status for the first error in v. messages without one are all about the
+// arguments the command was given
+func exitCodeForValues(v []interface{}) int {
+	for _, value := range v {
+		if err, ok := value.(error); ok {
+			return exitCodeFor(err)
+		}
+	}
+	return exitInvalidArguments
+}
+
+// logs like log.Fatal, exiting with the status for what went wrong
+func fatal(v ...interface{}) {
+	log.Output(2, fmt.Sprint(v...))
+	os.Exit(exitCodeForValues(v))
+}
+
+// logs like log.Fatalf, exiting with the status for what went wrong
+func fatalf(format string, v ...interface{}) {
+	log.Output(2, fmt.Sprintf(format, v...))
+	os.Exit(exitCodeForValues(v))
+}
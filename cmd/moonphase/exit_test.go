@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	_, timeErr := time.Parse("2006-01-02", "2024-13-01")
+	_, numErr := strconv.Atoi("x")
+	horizonErr := moonphase.NewHorizon().Check(moonphase.DayKey{Year: 1700, Month: time.January, Day: 1}, moonphase.DayKey{Year: 2024, Month: time.January, Day: 1})
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"other", errors.New("something broke"), exitFailure},
+		{"argument", argumentError{errors.New("bad flag")}, exitInvalidArguments},
+		{"date", timeErr, exitInvalidArguments},
+		{"number", numErr, exitInvalidArguments},
+		{"coverage", &moonphase.ErrOutsideCoverage{Day: moonphase.DayKey{Year: 1600, Month: time.January, Day: 1}}, exitInvalidArguments},
+		{"horizon", horizonErr, exitInvalidArguments},
+		{"wrapped horizon", fmt.Errorf("%w, raise -max-past to allow it", horizonErr), exitInvalidArguments},
+		{"rejected", &moonphase.ErrUpstreamRejected{Status: 400, Message: "Invalid date"}, exitNetwork},
+		{"status", &moonphase.ErrUpstreamStatus{Status: 502}, exitNetwork},
+		{"url", &url.Error{Op: "Get", URL: "https://aa.usno.navy.mil", Err: errors.New("no route")}, exitNetwork},
+		{"net", &net.OpError{Op: "dial", Err: errors.New("refused")}, exitNetwork},
+		{"malformed", &moonphase.ErrMalformedResponse{Err: errors.New("not JSON")}, exitParse},
+		{"data", dataError{errors.New("bad epoch")}, exitParse},
+		// a cancelled request comes back as a url.Error wrapping the context's error
+		{"interrupted", &url.Error{Op: "Get", URL: "https://aa.usno.navy.mil", Err: context.Canceled}, exitInterrupted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := exitCodeFor(test.err); (got != test.want) {
+				t.Errorf("exitCodeFor(%v) = %d, want %d", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+// the exit status of whole runs, as the README's table documents them
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"phasedata": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	day := []string{"-date", "2024-03-25", "-timezone", "UTC"}
+	tests := []struct {
+		name  string
+		args  []string
+		proxy bool
+		want  int
+	}{
+		{"success", append([]string{"-offline"}, day...), false, 0},
+		{"bad date", []string{"-offline", "-date", "2024-13-45"}, false, exitInvalidArguments},
+		{"unknown flag", []string{"-bogus"}, false, exitInvalidArguments},
+		{"bad flag value", append([]string{"-offline", "-retries", "99"}, day...), false, exitInvalidArguments},
+		{"beyond the horizon", []string{"-offline", "-date", "1500-01-01"}, false, exitInvalidArguments},
+		{"unknown command", []string{"phaze"}, false, exitInvalidArguments},
+		// the proxy refuses the connection, so the API can't be reached
+		{"network", append([]string{"-cache-dir", "", "-retries", "0"}, day...), true, exitNetwork},
+		{"malformed response", append([]string{"-source", "file", "-source-file", malformed}, day...), false, exitParse},
+		{"quiet bad date", []string{"-quiet", "-offline", "-date", "nope"}, false, exitInvalidArguments},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if (test.proxy) {
+				t.Setenv("HTTPS_PROXY", "http://127.0.0.1:1")
+			}
+			run := runMoonphase(t, t.TempDir(), test.args...)
+			if (run.code != test.want) {
+				t.Errorf("%q: exit %d, want %d:\n%s", test.args, run.code, test.want, run.stderr)
+			}
+			if (test.args[0] == "-quiet" && run.stdout + run.stderr != "") {
+				t.Errorf("-quiet printed %q", run.stdout + run.stderr)
+			}
+		})
+	}
+}
+
+// -exit-changed exits 5 when the result differs from the last run's and 0 when it's the same
+func TestExitChanged(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		date string
+		want int
+	}{
+		{"2024-03-25", 5},
+		{"2024-03-25", 0},
+		{"2024-03-29", 5},
+	} {
+		run := runMoonphase(t, dir, "-offline", "-changed-only", "-exit-changed", "-timezone", "UTC", "-date", test.date)
+		if (run.code != test.want) {
+			t.Errorf("%s: exit %d, want %d:\n%s", test.date, run.code, test.want, run.stderr)
+		}
+	}
+}
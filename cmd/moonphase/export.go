@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// name of the USNO API as a provider
+const usnoProvider string = "usno"
+
+// name of the mean cycle calculation used by -offline
+const offlineProvider string = "offline"
+
+// name of a saved USNO API response read by -source file
+const fileProvider string = "file"
+
+// the providers diff-providers can compare
+var providers = []string{usnoProvider, offlineProvider}
+
+// the -source values, a saved response can stand in for the API but isn't compared with it
+var sources = []string{usnoProvider, offlineProvider, fileProvider}
+
+// the -source lookups are answered from, recorded against every exported row
+var source string = usnoProvider
+
+var exportEventsHeader = []string{"utc_instant", "local_date", "phase_id", "provider"}
+// illumination is the whole percent lit and age_days the days since the last New Moon, both at
+// the start of the day, as the server's fields of the same names
+var exportDailyHeader = []string{"local_date", "phase_id", "phase", "illumination", "age_days"}
+
+// reads the first column of the last row of an existing export, or "" if there is none. an export
+// interrupted mid-write can end in a row cut short, which is cut off the file so the resumed rows
+// don't run on from it
+func getLastExportedDate(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", nil
+	}
+	defer file.Close()
+	var lastLine string
+	// the length of the file up to the end of its last whole row
+	var complete int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if (err == io.EOF) {
+			if (line != "") {
+				fmt.Fprintf(stderr, "dropping the unfinished last row of %s, %q\n", path, line)
+				if err := os.Truncate(path, complete); err != nil {
+					return "", err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		complete += int64(len(line))
+		if trimmed := strings.TrimRight(line, "\r\n"); (trimmed != "") {
+			lastLine = trimmed
+		}
+	}
+	fields := strings.Split(lastLine, ",")
+	// only the header has been written
+	if (fields[0] == exportEventsHeader[0] || fields[0] == exportDailyHeader[0]) {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+// returns the state file key for the manifest of a resumable export to path
+func exportManifestKey(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	return "export-manifest:" + absPath
+}
+
+// describes the parameters of an export, a resume only continues a file written with the same ones
+func exportManifest(startDay moonphase.DayKey, endDay moonphase.DayKey, granularity string, primaryOnly bool, refine string) string {
+	strategy := "snap"
+	if (primaryOnly) {
+		strategy = "primary-only"
+	}
+	manifest := fmt.Sprintf("%s %s..%s %s %s", source, startDay, endDay, granularity, strategy)
+	// left off when unset, so exports started before -refine existed still resume
+	if (refine != "") {
+		manifest += " refine=" + refine
+	}
+	return manifest
+}
+
+// the -refine values, see exportEvents
+var refineModes = []string{"none", "api"}
+
+// checks a -refine value, and that the rest of the export can be refined
+func checkRefine(refine string, granularity string) error {
+	if (refine == "") {
+		return nil
+	}
+	if (refine != "none" && refine != "api") {
+		return argumentError{fmt.Errorf("unknown -refine %q, expected %s", refine, strings.Join(refineModes, " or "))}
+	}
+	if (granularity != "events") {
+		return argumentError{fmt.Errorf("-refine lists phase events, it can't be combined with -granularity %s", granularity)}
+	}
+	if (refine == "api" && source == offlineProvider) {
+		return argumentError{fmt.Errorf("-refine api corrects the offline phases against the API, it can't be combined with -source offline")}
+	}
+	return nil
+}
+
+// returns the provider column of a refined event, offline or the -source it was paired against
+func refinedProvider(phase moonphase.RefinedPhase) string {
+	if (phase.Provenance == moonphase.APIProvenance) {
+		return source
+	}
+	return offlineProvider
+}
+
+// writes one row per primary phase event on the local days from start to end, inclusive. with
+// -refine, the events come from the mean lunar cycle, with the API's instant for each it has
+// data for when refine is api, and the provider column says which
+func exportEvents(writer *csv.Writer, start time.Time, end time.Time, location *time.Location, refine string) int {
+	rows := 0
+	writeRow := func(phase moonphase.MoonPhase, provider string) bool {
+		instant, err := moonphase.GetPhaseInstant(phase)
+		if err != nil {
+			fatal(err)
+		}
+		localDay := moonphase.NewDayKey(instant.In(location))
+		row := []string{instant.Format(time.RFC3339), localDay.String(), moonphase.PhaseID(phase.Phase), provider}
+		if err := writer.Write(row); err != nil {
+			fatalOutputError(err)
+		}
+		rows++
+		// flush as we go so long exports never sit in memory
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			fatalOutputError(err)
+		}
+		fmt.Fprintf(stderr, "\rexported %d events through %s", rows, localDay)
+		return true
+	}
+	// the inclusive local days become the half-open range of instants up to the start of the day after end
+	switch refine {
+	case "none":
+		for _, phase := range moonphase.MeanPhasesBetween(start, end.AddDate(0, 0, 1)) {
+			writeRow(phase, offlineProvider)
+		}
+	case "api":
+		phases, err := client.RefinePhasesBetween(ctx, start, end.AddDate(0, 0, 1))
+		if err != nil {
+			fatal(err)
+		}
+		refined := 0
+		for _, phase := range phases {
+			if (phase.Provenance == moonphase.APIProvenance) {
+				refined++
+			}
+			writeRow(phase.MoonPhase, refinedProvider(phase))
+		}
+		fmt.Fprintf(stderr, "\nrefined %d of %d events against %s", refined, len(phases), source)
+	default:
+		err := client.WalkPhasesBetween(ctx, start, end.AddDate(0, 0, 1), func(phase moonphase.MoonPhase) bool {
+			return writeRow(phase, source)
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+	return rows
+}
+
+// writes one row per day between start and end, inclusive, with the classified phase
+func exportDaily(writer *csv.Writer, start time.Time, end time.Time, strategy moonphase.ClassifyStrategy) int {
+	rows := 0
+	err := client.WalkDailyDetails(ctx, start, end, strategy, func(detail moonphase.DailyDetail) bool {
+		row := []string{
+			detail.Date.String(),
+			moonphase.PhaseID(string(detail.Phase)),
+			string(detail.Phase),
+			strconv.Itoa(int(detail.Illumination * 100 + 0.5)),
+			strconv.FormatFloat(detail.Age, 'f', 2, 64),
+		}
+		if err := writer.Write(row); err != nil {
+			fatalOutputError(err)
+		}
+		rows++
+		// flush a week at a time so long exports never sit in memory
+		if (rows % 7 == 0) {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				fatalOutputError(err)
+			}
+			fmt.Fprintf(stderr, "\rexported %d days through %s", rows, detail.Date)
+		}
+		return true
+	})
+	if err != nil {
+		fatal(err)
+	}
+	return rows
+}
+
+// the flags of export, read once they have been parsed
+type exportOptions struct {
+	startFlag        *string
+	endFlag          *string
+	yearFlag         *int
+	outFlag          *string
+	modeFlag         *string
+	granularityFlag  *string
+	refineFlag       *string
+	primaryOnlyFlag  *bool
+	resumeFlag       *bool
+	restartFlag      *bool
+	pipefailExitFlag *bool
+	stateFileFlag    *string
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation      func() *time.Location
+	applyQuiet       func()
+}
+
+// defines the flags of export on flags, for the command to parse and for completion to list
+func defineExportFlags(flags *flag.FlagSet) *exportOptions {
+	options := &exportOptions{}
+	options.startFlag = flags.String("start", "", message("First date to export, required"))
+	options.endFlag = flags.String("end", "", message("Last date to export, required"))
+	options.yearFlag = flags.Int("year", 0, message("Export a whole calendar year, instead of -start and -end"))
+	options.outFlag = flags.String("o", "phases.csv", message("CSV file to write, - for stdout"))
+	options.modeFlag = flags.String("mode", "0644", message("Permissions for the written file"))
+	options.granularityFlag = flags.String("granularity", "events", message("One row per phase event (events) or per day (daily)"))
+	options.refineFlag = flags.String("refine", "", message("List the events from the mean lunar cycle, as they are (none) or with the API's instants where it has them (api)"))
+	options.primaryOnlyFlag = flags.Bool("primary-only", false, message("Daily rows only report the four primary phases"))
+	options.resumeFlag = flags.Bool("resume", false, message("Append to an existing export as rows are written, skipping dates already in it"))
+	options.restartFlag = flags.Bool("restart", false, message("With -resume, start the export over instead of continuing it"))
+	options.pipefailExitFlag = flags.Bool("pipefail-exit", false, message("Exit 141 instead of 0 when stdout is closed early"))
+	options.stateFileFlag = flags.String("state-file", "", message("File recording the parameters of -resume exports, defaults to ~/.moonphase-state"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase export -start 2000-01-01 -end 2030-01-01 -o phases.csv
+func runExport(args []string) {
+	exportFlags := newFlagSet("export")
+	options := defineExportFlags(exportFlags)
+	exportFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(exportFlags)
+	normalizeStringFlags(exportFlags)
+	options.applyClientFlags()
+	if (*options.pipefailExitFlag) {
+		brokenPipeExit = 141
+	}
+
+	if (*options.yearFlag != 0) {
+		// dates are kept in the four digit 2006-01-02 form
+		if err := checkIntRange("year", *options.yearFlag, 1, 9999); err != nil {
+			fatal(err)
+		}
+		if (*options.startFlag != "" || *options.endFlag != "") {
+			fatal("export -year can't be combined with -start or -end")
+		}
+		*options.startFlag = moonphase.DayKey{Year: *options.yearFlag, Month: time.January, Day: 1}.String()
+		*options.endFlag = moonphase.DayKey{Year: *options.yearFlag, Month: time.December, Day: 31}.String()
+	}
+	if (*options.startFlag == "" || *options.endFlag == "") {
+		fatal("export needs both -start and -end, or -year")
+	}
+	if err := checkRefine(*options.refineFlag, *options.granularityFlag); err != nil {
+		fatal(err)
+	}
+	var header []string
+	switch *options.granularityFlag {
+	case "events":
+		header = exportEventsHeader
+	case "daily":
+		header = exportDailyHeader
+	default:
+		fatalf("unknown granularity %q, expected events or daily", *options.granularityFlag)
+	}
+	location := options.getLocation()
+	now := time.Now()
+	startDay, err := parseDateFlag(*options.startFlag, now, location)
+	if err != nil {
+		fatal(err)
+	}
+	endDay, err := parseDateFlag(*options.endFlag, now, location)
+	if err != nil {
+		fatal(err)
+	}
+	today := moonphase.NewDayKey(now.In(location))
+	options.checkHorizon(startDay, today)
+	options.checkHorizon(endDay, today)
+	start := startDay.Time(location)
+	end := endDay.Time(location)
+	if (end.Before(start)) {
+		fatal("export -end is before -start")
+	}
+
+	mode, err := parseOutputMode(*options.modeFlag)
+	if err != nil {
+		fatal(err)
+	}
+	writeHeader := true
+	manifestKey := exportManifestKey(*options.outFlag)
+	if (*options.resumeFlag) {
+		manifest := exportManifest(startDay, endDay, *options.granularityFlag, *options.primaryOnlyFlag, *options.refineFlag)
+		if (*options.stateFileFlag == "") {
+			*options.stateFileFlag = homeFile(".moonphase-state")
+		}
+		state := loadState(*options.stateFileFlag)
+		previous, found := state[manifestKey]
+		restart := *options.restartFlag
+		if (found && previous != manifest && !restart) {
+			fmt.Fprintf(stderr, "%s was started as %s, not %s, starting over\n", *options.outFlag, previous, manifest)
+			restart = true
+		}
+		if (restart && *options.outFlag != "-") {
+			// appending a fresh export onto the old one would mix the two
+			if err := os.Remove(*options.outFlag); (err != nil && !os.IsNotExist(err)) {
+				fatal(err)
+			}
+		}
+		state[manifestKey] = manifest
+		saveState(*options.stateFileFlag, state)
+
+		lastDate, err := getLastExportedDate(*options.outFlag)
+		if err != nil {
+			fatal(err)
+		}
+		if (lastDate != "") {
+			if (*options.granularityFlag == "events") {
+				// events are keyed by their utc instant, resume from the local day after it
+				lastInstant, err := time.Parse(time.RFC3339, lastDate)
+				if err != nil {
+					fatal(dataError{err})
+				}
+				lastDate = moonphase.NewDayKey(lastInstant.In(location)).String()
+			}
+			resumeDay, err := moonphase.ParseDayKey(lastDate)
+			if err != nil {
+				fatal(dataError{err})
+			}
+			start = resumeDay.AddDays(1).Time(location)
+			writeHeader = false
+			fmt.Fprintf(stderr, "resuming at %s\n", moonphase.NewDayKey(start))
+		} else if (*options.outFlag != "-") {
+			// nothing past the header, which would otherwise be appended a second time
+			if err := os.Remove(*options.outFlag); (err != nil && !os.IsNotExist(err)) {
+				fatal(err)
+			}
+		}
+	}
+
+	// a resumable export appends as it goes so an interrupted run leaves something to resume from,
+	// otherwise the file only appears once the export is complete
+	output, err := openOutput(*options.outFlag, *options.resumeFlag, mode)
+	if err != nil {
+		fatal(err)
+	}
+	defer output.Abort()
+	writer := csv.NewWriter(output)
+	if (writeHeader) {
+		if err := writer.Write(header); err != nil {
+			fatalOutputError(err)
+		}
+		writer.Flush()
+	}
+
+	var rows int
+	if (!start.After(end)) {
+		if (*options.granularityFlag == "daily") {
+			strategy := moonphase.SnapStrategy
+			if (*options.primaryOnlyFlag) {
+				strategy = moonphase.PrimaryOnlyStrategy
+			}
+			rows = exportDaily(writer, start, end, strategy)
+		} else {
+			rows = exportEvents(writer, start, end, location, *options.refineFlag)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fatalOutputError(err)
+	}
+	if err := output.Commit(); err != nil {
+		fatal(err)
+	}
+	// the export is complete, nothing is left to resume
+	if (*options.resumeFlag) {
+		state := loadState(*options.stateFileFlag)
+		delete(state, manifestKey)
+		saveState(*options.stateFileFlag, state)
+	}
+	fmt.Fprintf(stderr, "\nwrote %d rows to %s\n", rows, *options.outFlag)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// returns the path of the recorded 2024 USNO response moonphasetest serves
+func recordedUSNOFile(t *testing.T) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("..", "..", "moonphasetest", "testdata", "usno-2024.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// -refine api marks each event with where its instant came from, the tail past the data as offline
+func TestExportRefineProvenance(t *testing.T) {
+	run := runMoonphase(t, t.TempDir(), "export", "-refine", "api", "-source", "file", "-source-file", recordedUSNOFile(t),
+		"-start", "2025-01-01", "-end", "2025-02-28", "-timezone", "UTC", "-o", "-")
+	if (run.code != 0) {
+		t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+	}
+	want := "utc_instant,local_date,phase_id,provider\n" +
+		"2025-01-06T23:56:00Z,2025-01-06,first-quarter,file\n" +
+		"2025-01-13T22:27:00Z,2025-01-13,full-moon,file\n" +
+		"2025-01-21T20:31:00Z,2025-01-21,last-quarter,file\n" +
+		"2025-01-29T12:36:00Z,2025-01-29,new-moon,file\n" +
+		"2025-02-05T15:00:00Z,2025-02-05,first-quarter,offline\n" +
+		"2025-02-13T00:11:00Z,2025-02-13,full-moon,offline\n" +
+		"2025-02-20T09:22:00Z,2025-02-20,last-quarter,offline\n" +
+		"2025-02-27T18:33:00Z,2025-02-27,new-moon,offline\n"
+	if (run.stdout != want) {
+		t.Errorf("got:\n%s\nwant:\n%s", run.stdout, want)
+	}
+	if (!strings.Contains(run.stderr, "refined 4 of 8 events against file")) {
+		t.Errorf("stderr doesn't count the refined events:\n%s", run.stderr)
+	}
+}
+
+func TestExportRefineArguments(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"-refine", "usno"},
+		{"-refine", "api", "-granularity", "daily"},
+		{"-refine", "api", "-source", "offline"},
+	} {
+		run := runMoonphase(t, dir, append([]string{"export", "-year", "2030", "-o", "-"}, args...)...)
+		if (run.code != exitInvalidArguments) {
+			t.Errorf("%q: exit %d, want %d", args, run.code, exitInvalidArguments)
+		}
+	}
+	run := runMoonphase(t, dir, "export", "-refine", "none", "-start", "2030-01-01", "-end", "2030-01-31", "-timezone", "UTC", "-o", "-")
+	if (run.code != 0 || strings.Count(run.stdout, ",offline\n") != 4) {
+		t.Errorf("-refine none: exit %d, printed:\n%s", run.code, run.stdout)
+	}
+}
+
+// -year 2024 classifies December 29th to 31st from the lunation running into 2025, and the New
+// Moon straddling New Year is exported by exactly one of the two years
+func TestExportYearBoundary(t *testing.T) {
+	tests := []struct {
+		zone    string
+		daily   string
+		newMoon string
+	}{
+		{"UTC", "2024-12-29,new-moon,New Moon,4,27.74\n2024-12-30,new-moon,New Moon,1,28.74\n2024-12-31,new-moon,New Moon,0,0.06\n", "2024-12-30T22:27:00Z,2024-12-30,new-moon,file\n"},
+		{"America/Los_Angeles", "2024-12-29,new-moon,New Moon,2,28.07\n2024-12-30,new-moon,New Moon,0,29.07\n2024-12-31,new-moon,New Moon,0,0.40\n", "2024-12-30T22:27:00Z,2024-12-30,new-moon,file\n"},
+		{"Asia/Tokyo", "2024-12-29,waning-crescent,Waning Crescent,5,27.36\n2024-12-30,new-moon,New Moon,2,28.36\n2024-12-31,new-moon,New Moon,0,29.36\n", "2024-12-30T22:27:00Z,2024-12-31,new-moon,file\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.zone, func(t *testing.T) {
+			dir := t.TempDir()
+			source := []string{"-source", "file", "-source-file", recordedUSNOFile(t), "-timezone", test.zone, "-o", "-"}
+			run := runMoonphase(t, dir, append([]string{"export", "-year", "2024", "-granularity", "daily"}, source...)...)
+			if (run.code != 0) {
+				t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+			}
+			if (!strings.HasSuffix(run.stdout, test.daily)) {
+				t.Errorf("the daily export doesn't end with:\n%s\ngot:\n%s", test.daily, run.stdout[len(run.stdout) - 200:])
+			}
+			run = runMoonphase(t, dir, append([]string{"export", "-year", "2024"}, source...)...)
+			if (run.code != 0 || !strings.HasSuffix(run.stdout, test.newMoon)) {
+				t.Errorf("exit %d, the 2024 events don't end with %s", run.code, test.newMoon)
+			}
+			year2024 := run.stdout
+			run = runMoonphase(t, dir, append([]string{"export", "-start", "2025-01-01", "-end", "2025-01-20"}, source...)...)
+			if (run.code != 0) {
+				t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+			}
+			if (strings.Contains(run.stdout, test.newMoon)) {
+				t.Errorf("2025 exports the New Moon 2024 did:\n%s", run.stdout)
+			}
+			// 13 New Moons from January 11th 2024, none in 2025 before the 20th
+			if count := strings.Count(year2024 + run.stdout, ",new-moon,"); (count != 13) {
+				t.Errorf("the two years export %d New Moons, want 13", count)
+			}
+		})
+	}
+}
+
+// the same month through export, calendar and range, whose -start and -end days are all
+// inclusive local days, gives the same phase events and the same days. November 2024 in Los
+// Angeles has a phase on the evening of the 30th that's already December in UT
+func TestRangeCommandsAgree(t *testing.T) {
+	tests := []struct {
+		zone string
+		// the last event of the month there
+		last string
+	}{
+		{"America/Los_Angeles", "2024-12-01T06:21:00Z"},
+		{"Asia/Tokyo", "2024-11-23T01:28:00Z"},
+		{"UTC", "2024-11-23T01:28:00Z"},
+	}
+	for _, test := range tests {
+		t.Run(test.zone, func(t *testing.T) {
+			dir := t.TempDir()
+			source := []string{"-source", "file", "-source-file", recordedUSNOFile(t), "-timezone", test.zone}
+			run := func(args ...string) string {
+				t.Helper()
+				run := runMoonphase(t, dir, append(args, source...)...)
+				if (run.code != 0) {
+					t.Fatalf("%q: exit %d:\n%s", args, run.code, run.stderr)
+				}
+				return run.stdout
+			}
+
+			// the events exported, as their UT instants
+			var exported []string
+			for _, row := range strings.Split(strings.TrimSpace(run("export", "-start", "2024-11-01", "-end", "2024-11-30", "-o", "-")), "\n")[1:] {
+				exported = append(exported, strings.Split(row, ",")[0])
+			}
+			var calendar []string
+			for _, line := range strings.Split(run("calendar", "-date", "2024-11-01", "-months", "1", "-o", "-"), "\r\n") {
+				if value, found := strings.CutPrefix(line, "DTSTART:"); (found) {
+					instant, err := time.Parse("20060102T150405Z", value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					calendar = append(calendar, instant.Format(time.RFC3339))
+				}
+			}
+			if (len(exported) == 0 || exported[len(exported) - 1] != test.last) {
+				t.Errorf("export has the events %q, want the last to be %s", exported, test.last)
+			}
+			if (strings.Join(exported, " ") != strings.Join(calendar, " ")) {
+				t.Errorf("export has the events %q, calendar %q", exported, calendar)
+			}
+
+			// the days, as "2024-11-01 New Moon"
+			var daily []string
+			for _, row := range strings.Split(strings.TrimSpace(run("export", "-granularity", "daily", "-start", "2024-11-01", "-end", "2024-11-30", "-o", "-")), "\n")[1:] {
+				fields := strings.Split(row, ",")
+				daily = append(daily, fields[0] + " " + fields[2])
+			}
+			ranged := strings.Split(strings.TrimSpace(run("range", "-from", "2024-11-01", "-to", "2024-11-30", "-plaintext")), "\n")
+			if (len(daily) != 30 || strings.Join(daily, "\n") != strings.Join(ranged, "\n")) {
+				t.Errorf("export -granularity daily has the days:\n%s\nrange:\n%s", strings.Join(daily, "\n"), strings.Join(ranged, "\n"))
+			}
+		})
+	}
+}
+
+// the last row's first column is where a resume picks up, a header alone or no file at all is
+// nothing to resume from, and a row cut short at the end is cut off the file
+func TestGetLastExportedDate(t *testing.T) {
+	header := "local_date,phase_id,phase,illumination,age_days\n"
+	rows := "2024-03-01,waning-crescent,Waning Crescent,62,20.45\n2024-03-02,waning-crescent,Waning Crescent,52,21.45\n"
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		// the file once read, when different from content
+		kept string
+	}{
+		{"rows", header + rows, "2024-03-02", ""},
+		{"blank lines after the rows", header + rows + "\n\n", "2024-03-02", ""},
+		{"crlf rows", strings.ReplaceAll(header + rows, "\n", "\r\n"), "2024-03-02", ""},
+		{"header only", header, "", ""},
+		{"events header only", "utc_instant,local_date,phase_id,provider\n", "", ""},
+		{"truncated last row", header + rows + "2024-03-03,waning-cres", "2024-03-02", header + rows},
+		{"truncated first row", header + "2024-03-0", "", header},
+		{"truncated header", "local_da", "", "-"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "phases.csv")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := getLastExportedDate(path)
+			if (err != nil || got != test.want) {
+				t.Errorf("got %q (%v), want %q", got, err, test.want)
+			}
+			kept := test.content
+			if (test.kept == "-") {
+				kept = ""
+			} else if (test.kept != "") {
+				kept = test.kept
+			}
+			if content, err := os.ReadFile(path); (err != nil || string(content) != kept) {
+				t.Errorf("left %q (%v), want %q", content, err, kept)
+			}
+		})
+	}
+	if got, err := getLastExportedDate(filepath.Join(t.TempDir(), "missing.csv")); (got != "" || err != nil) {
+		t.Errorf("a missing export: got %q (%v), want nothing", got, err)
+	}
+}
+
+// the manifest of a resumable export is kept in the state file under the export's absolute path
+// until the export completes
+func TestExportManifest(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state")
+	out := filepath.Join(dir, "missing", "phases.csv")
+	// the directory to write to doesn't exist, so the export fails once the manifest is saved
+	run := runMoonphase(t, dir, "export", "-resume", "-granularity", "daily", "-start", "2024-03-01", "-end", "2024-03-31",
+		"-source", "file", "-source-file", recordedUSNOFile(t), "-timezone", "UTC", "-state-file", stateFile, "-o", out)
+	if (run.code == 0) {
+		t.Fatalf("exported to a missing directory:\n%s", run.stderr)
+	}
+	state := loadState(stateFile)
+	if got, want := state["export-manifest:" + out], "file 2024-03-01..2024-03-31 daily snap"; (got != want) {
+		t.Errorf("recorded %q in %v, want %q", got, state, want)
+	}
+
+	for _, test := range []struct {
+		granularity string
+		primaryOnly bool
+		refine      string
+		want        string
+	}{
+		{"daily", true, "", "usno 2024-03-01..2024-03-31 daily primary-only"},
+		{"events", false, "", "usno 2024-03-01..2024-03-31 events snap"},
+		{"events", false, "api", "usno 2024-03-01..2024-03-31 events snap refine=api"},
+	} {
+		got := exportManifest(moonphase.DayKey{Year: 2024, Month: time.March, Day: 1}, moonphase.DayKey{Year: 2024, Month: time.March, Day: 31}, test.granularity, test.primaryOnly, test.refine)
+		if (got != test.want) {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+	if got, want := exportManifestKey("phases.csv"), "export-manifest:" + filepath.Join(mustGetwd(t), "phases.csv"); (got != want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+// an interrupted export resumed with the same parameters, from rows cut short or not, ends up the
+// same as one run straight through. a manifest that doesn't match, or -restart, starts it over
+func TestExportResume(t *testing.T) {
+	for _, granularity := range []string{"daily", "events"} {
+		t.Run(granularity, func(t *testing.T) {
+			dir := t.TempDir()
+			stateFile := filepath.Join(dir, "state")
+			out := filepath.Join(dir, "phases.csv")
+			args := []string{"-granularity", granularity, "-start", "2024-03-01", "-end", "2024-05-31",
+				"-source", "file", "-source-file", recordedUSNOFile(t), "-timezone", "America/Los_Angeles", "-state-file", stateFile, "-o", out}
+			manifest := "file 2024-03-01..2024-05-31 " + granularity + " snap"
+			if run := runMoonphase(t, dir, append([]string{"export"}, args...)...); (run.code != 0) {
+				t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+			}
+			whole, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.SplitAfter(string(whole), "\n")
+			// the header and the first few rows, as an interrupted export leaves them
+			interrupted := strings.Join(lines[:5], "")
+			// the same rows with their other columns changed, to tell a resumed export from a restarted one
+			altered := lines[0] + strings.ReplaceAll(strings.Join(lines[1:5], ""), ",", ", ")
+
+			tests := []struct {
+				name     string
+				existing string
+				recorded string
+				restart  bool
+				want     string
+				stderr   string
+			}{
+				{"resume", altered, manifest, false, altered + strings.Join(lines[5:], ""), "resuming at"},
+				{"truncated last row", interrupted + lines[5][:7], manifest, false, string(whole), "dropping the unfinished last row"},
+				{"header only", lines[0], manifest, false, string(whole), ""},
+				{"manifest mismatch", altered, "file 2024-01-01..2024-05-31 " + granularity + " snap", false, string(whole), "starting over"},
+				{"restart", altered, manifest, true, string(whole), ""},
+			}
+			for _, test := range tests {
+				if err := os.WriteFile(out, []byte(test.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+				saveState(stateFile, map[string]string{exportManifestKey(out): test.recorded})
+				runArgs := append([]string{"export", "-resume"}, args...)
+				if (test.restart) {
+					runArgs = append(runArgs, "-restart")
+				}
+				run := runMoonphase(t, dir, runArgs...)
+				if (run.code != 0) {
+					t.Fatalf("%s: exit %d:\n%s", test.name, run.code, run.stderr)
+				}
+				if got, err := os.ReadFile(out); (err != nil || string(got) != test.want) {
+					t.Errorf("%s: wrote:\n%s\nwant:\n%s", test.name, got, test.want)
+				}
+				if (!strings.Contains(run.stderr, test.stderr)) {
+					t.Errorf("%s: stderr doesn't say %q:\n%s", test.name, test.stderr, run.stderr)
+				}
+				if _, found := loadState(stateFile)[exportManifestKey(out)]; (found) {
+					t.Errorf("%s: the manifest is still recorded once the export has completed", test.name)
+				}
+			}
+		})
+	}
+}
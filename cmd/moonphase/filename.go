@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the fields a -template for the filename subcommand can use
+type filenameFields struct {
+	// 2006-01-02
+	Date string
+	// like waxing-gibbous
+	PhaseID string
+	// like Waxing Gibbous, spaces become dashes once sanitized
+	Phase string
+	// whole percent, 0 to 100
+	Illumination int
+}
+
+// the flags of filename, read once they have been parsed
+type filenameOptions struct {
+	templateFlag     *string
+	dateFlag         *string
+	offlineFlag      *bool
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation      func() *time.Location
+	applyQuiet       func()
+}
+
+// defines the flags of filename on flags, for the command to parse and for completion to list
+func defineFilenameFlags(flags *flag.FlagSet) *filenameOptions {
+	options := &filenameOptions{}
+	options.templateFlag = flags.String("template", "{{.Date}}_{{.PhaseID}}", message("Template for the filename, with .Date, .PhaseID, .Phase and .Illumination"))
+	options.dateFlag = flags.String("date", "", message("Date to name, defaults to today"))
+	options.offlineFlag = flags.Bool("offline", false, message("Calculate the phase locally instead of asking the USNO API"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase filename -template "{{.Date}}_{{.PhaseID}}_{{.Illumination}}.jpg"
+func runFilename(args []string) {
+	filenameFlags := newFlagSet("filename")
+	options := defineFilenameFlags(filenameFlags)
+	filenameFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(filenameFlags)
+	normalizeStringFlags(filenameFlags)
+	options.applyClientFlags()
+
+	nameTemplate, err := template.New("filename").Option("missingkey=error").Parse(*options.templateFlag)
+	if err != nil {
+		fatalf("invalid -template: %v", argumentError{err})
+	}
+	location := options.getLocation()
+	now := time.Now().In(location)
+	// timed like -segment, the current moment today, the start of any other date
+	moment := now
+	if (*options.dateFlag != "") {
+		day, err := parseDateFlag(*options.dateFlag, now, location)
+		if err != nil {
+			fatal(err)
+		}
+		moment = day.Time(location)
+	}
+	options.checkHorizon(moonphase.NewDayKey(moment), moonphase.NewDayKey(now))
+	if (!*options.offlineFlag) {
+		checkSourceCovers(moonphase.NewDayKey(moment))
+	}
+
+	var phase moonphase.Phase
+	var segment moonphase.CycleSegment
+	if (*options.offlineFlag) {
+		phase = moonphase.ComputePhase(moment)
+		segment, err = moonphase.GetCycleSegment(moment, moonphase.MeanPhases(moment))
+	} else {
+		phase, err = client.GetPhaseForDate(ctx, moment, moonphase.SnapStrategy)
+		if (err == nil) {
+			segment, err = client.GetSegmentForDate(ctx, moment)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	fields := filenameFields{
+		Date:         moonphase.NewDayKey(moment).String(),
+		PhaseID:      moonphase.PhaseID(string(phase)),
+		Phase:        string(phase),
+		Illumination: int(moonphase.SegmentIllumination(segment) * 100 + 0.5),
+	}
+	var name strings.Builder
+	if err := nameTemplate.Execute(&name, fields); err != nil {
+		fatalf("invalid -template: %v", argumentError{err})
+	}
+	// whatever the template produced, only a safe filename is printed
+	filename := moonphase.SanitizeFilename(name.String())
+	if (filename == "") {
+		fatal("-template produced an empty filename")
+	}
+	if _, err := fmt.Fprintln(stdout, filename); err != nil {
+		fatalOutputError(err)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// checks a numeric flag is within its allowed range, inclusive, so every flag reports a bad
+// value the same way
+func checkIntRange(name string, value int, min int, max int) error {
+	if (value < min || value > max) {
+		return argumentError{fmt.Errorf(message("-%s must be between %d and %d, got %d"), name, min, max, value)}
+	}
+	return nil
+}
+
+// checkIntRange for duration flags
+func checkDurationRange(name string, value time.Duration, min time.Duration, max time.Duration) error {
+	if (value < min || value > max) {
+		return argumentError{fmt.Errorf(message("-%s must be between %s and %s, got %s"), name, min, max, value)}
+	}
+	return nil
+}
+
+// checkIntRange for float values
+func checkFloatRange(name string, value float64, min float64, max float64) error {
+	if (value < min || value > max) {
+		return argumentError{fmt.Errorf(message("-%s must be between %g and %g, got %g"), name, min, max, value)}
+	}
+	return nil
+}
+
+// the inclusive range of a numeric flag, as values of the flag's own type
+type flagRange struct {
+	min interface{}
+	max interface{}
+}
+
+// the allowed values of the numeric flags by name, the same in every command that has them.
+// applyConfig checks them whether they come from the command line or the config file, and
+// config show lists them
+var flagRanges = map[string]flagRange{
+	"timeout":          {time.Second, 5 * time.Minute},
+	"retries":          {0, 10},
+	"deadline":         {time.Duration(0), 24 * time.Hour},
+	"max-past":         {0, 9999},
+	"max-future":       {0, 9999},
+	"now-file-max-age": {time.Duration(0), maxNowFileMaxAge},
+	"n":                {1, moonphase.MaxAroundEvents()},
+	"months":           {1, 120},
+	"older-than":       {time.Duration(0), 100 * 365 * 24 * time.Hour},
+}
+
+// checks f against its range in flagRanges, nil for a flag without one
+func checkFlagRange(f *flag.Flag) error {
+	bounds, found := flagRanges[f.Name]
+	if (!found) {
+		return nil
+	}
+	switch value := f.Value.(flag.Getter).Get().(type) {
+	case int:
+		return checkIntRange(f.Name, value, bounds.min.(int), bounds.max.(int))
+	case time.Duration:
+		return checkDurationRange(f.Name, value, bounds.min.(time.Duration), bounds.max.(time.Duration))
+	case float64:
+		return checkFloatRange(f.Name, value, bounds.min.(float64), bounds.max.(float64))
+	}
+	return nil
+}
+
+// formats the range of the flag called name for config show, "" for a flag without one
+func formatFlagRange(name string) string {
+	bounds, found := flagRanges[name]
+	if (!found) {
+		return ""
+	}
+	return fmt.Sprintf("%v to %v", bounds.min, bounds.max)
+}
+
+// adds -quiet, which discards everything the command would print, errors included, leaving only
+// the exit status for cron jobs and shell conditionals. files written with -o are still written.
+// call the returned function straight after parsing so nothing is printed before it applies
+func addQuietFlag(flags *flag.FlagSet) func() {
+	quietFlag := flags.Bool("quiet", false, message("Print nothing, not even errors, and report only through the exit status"))
+	return func() {
+		if (*quietFlag) {
+			stdout = io.Discard
+			stderr = io.Discard
+			log.SetOutput(io.Discard)
+		}
+	}
+}
+
+// returns where API responses are cached by default, ~/.cache/moonphase on Linux, or "" if
+// there's no user cache directory
+func defaultCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "moonphase")
+}
+
+// the seed of the randomness -deterministic runs draw from
+const deterministicSeed int64 = 1
+
+// registers -timeout, -retries and -cache-dir on flags. the returned function checks them and
+// applies them to client, call it once flags has been parsed
+func addClientFlags(flags *flag.FlagSet) func() {
+	timeoutFlag := flags.Duration("timeout", moonphase.DefaultTimeout, message("Give up on a USNO API request after this long"))
+	retriesFlag := flags.Int("retries", moonphase.DefaultRetries, message("Retry a failed USNO API request this many times"))
+	cacheDirFlag := flags.String("cache-dir", defaultCacheDir(), message("Directory to cache USNO API responses in, empty to disable"))
+	deadlineFlag := flags.Duration("deadline", 0, message("Give up on the whole command after this long, 0 for no limit"))
+	sourceFlag := flags.String("source", "usno", messagef("Where phase data comes from: %s", strings.Join(sources, ", ")))
+	sourceFileFlag := flags.String("source-file", "", message("With -source file, the saved USNO API response to read phases from"))
+	refreshFlag := flags.Bool("refresh", false, message("Fetch from the USNO API again rather than using cached responses"))
+	deterministicFlag := flags.Bool("deterministic", false, message("Seed the retry jitter with a fixed value, so replayed runs repeat exactly"))
+	return func() {
+		// applyConfig has checked -timeout, -retries and -deadline are within flagRanges
+		if (*deadlineFlag > 0) {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, *deadlineFlag)
+			go func() {
+				<-ctx.Done()
+				cancel()
+			}()
+		}
+		client.HTTPClient.Timeout = *timeoutFlag
+		client.Retries = *retriesFlag
+		client.CacheDir = *cacheDirFlag
+		client.ForceRefresh = *refreshFlag
+		if (*deterministicFlag) {
+			client.Rand = moonphase.SeededRand(deterministicSeed)
+		}
+		client.UserAgent = fmt.Sprintf("moonphase/%s (+https://github.com/mitchthorson/go-moon-phase)", getVersion())
+		switch *sourceFlag {
+		case usnoProvider:
+		case offlineProvider:
+			client.Source = moonphase.OfflineProvider{}
+		case fileProvider:
+			if (*sourceFileFlag == "") {
+				fatal("-source file needs a -source-file")
+			}
+			client.Source = moonphase.FileProvider{Path: *sourceFileFlag}
+		default:
+			fatalf(message("unknown -source %q, expected %s"), *sourceFlag, strings.Join(sources, ", "))
+		}
+		source = *sourceFlag
+	}
+}
+
+// what to change when lookups from the -source can't succeed for a day
+var sourceGuidance = map[string]string{
+	usnoProvider: "use -source offline to calculate phases from the mean cycle instead",
+	fileProvider: "give a -source-file covering it, or use -source usno or -source offline",
+}
+
+// exits naming the options to change unless the -source can serve lookups of each day, before
+// anything is looked up. call it once applyClientFlags has run
+func checkSourceCovers(days ...moonphase.DayKey) {
+	for _, day := range days {
+		err := client.CheckCoverage(day)
+		if (err == nil) {
+			continue
+		}
+		// a -source-file that can't be read is the argument's fault, one that can't be parsed the file's
+		var pathErr *os.PathError
+		if (errors.As(err, &pathErr)) {
+			err = argumentError{err}
+		}
+		fatalf("%v, %s", err, message(sourceGuidance[source]))
+	}
+}
+
+// registers -max-past and -max-future on flags. the returned function checks a day against them
+// once flags has been parsed, and exits naming the flag to raise if it's beyond the horizon
+func addHorizonFlags(flags *flag.FlagSet) func(day moonphase.DayKey, today moonphase.DayKey) {
+	checkHorizon := addHorizonCheckFlags(flags)
+	return func(day moonphase.DayKey, today moonphase.DayKey) {
+		if err := checkHorizon(day, today); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// addHorizonFlags for commands that carry on past a day beyond the horizon, the returned function
+// reports it as an error naming the flag to raise instead of exiting
+func addHorizonCheckFlags(flags *flag.FlagSet) func(day moonphase.DayKey, today moonphase.DayKey) error {
+	maxPastFlag := flags.Int("max-past", moonphase.DefaultHorizonYears, message("Refuse dates more than this many years ago"))
+	maxFutureFlag := flags.Int("max-future", moonphase.DefaultHorizonYears, message("Refuse dates more than this many years ahead"))
+	return func(day moonphase.DayKey, today moonphase.DayKey) error {
+		horizon := moonphase.Horizon{PastYears: *maxPastFlag, FutureYears: *maxFutureFlag}
+		if err := horizon.Check(day, today); err != nil {
+			raise := "-max-future"
+			if (day.Before(today)) {
+				raise = "-max-past"
+			}
+			return fmt.Errorf(message("%w, raise %s to allow it"), err, raise)
+		}
+		return nil
+	}
+}
+
+// string flags where an empty value means something, rather than falling back to the default
+var flagsAllowingEmpty = map[string]bool{
+	// an empty -cache-dir turns the cache off
+	"cache-dir": true,
+}
+
+// trims the whitespace shell variables tend to leave around string flag values, and resets a
+// flag given as empty or only whitespace to its default, as if it hadn't been passed at all.
+// call it straight after parsing so nothing downstream sees the raw values
+func normalizeStringFlags(flags *flag.FlagSet) {
+	flags.Visit(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if (!ok) {
+			return
+		}
+		value, isString := getter.Get().(string)
+		if (!isString) {
+			return
+		}
+		trimmed := strings.TrimSpace(value)
+		if (trimmed == "" && !flagsAllowingEmpty[f.Name]) {
+			trimmed = f.DefValue
+		}
+		f.Value.Set(trimmed)
+	})
+}
+
+// registers -timezone on flags. the returned function resolves it once flags has been parsed,
+// and exits if the zone doesn't exist
+func addTimezoneFlag(flags *flag.FlagSet) func() *time.Location {
+	timezoneFlag := flags.String("timezone", "", message("IANA zone to work out dates in, like America/New_York, defaults to the local zone"))
+	return func() *time.Location {
+		location, err := loadTimezone(*timezoneFlag)
+		if err != nil {
+			fatal(err)
+		}
+		return location
+	}
+}
+
+// registers -date-layout on flags, a Go layout dates are read with before the usual forms, for
+// day-first dates like 03/04/2024 that would otherwise be ambiguous
+func addDateLayoutFlag(flags *flag.FlagSet) {
+	flags.StringVar(&dateLayout, "date-layout", "", message("Go layout to read dates with first, like 2/1/2006 for day-first dates"))
+}
+
+// registers -lang on flags. the returned function checks it once flags has been parsed, and exits
+// if there are no phase names in that language
+func addLanguageFlag(flags *flag.FlagSet) func() string {
+	langFlag := flags.String("lang", "en", messagef("Language for phase names: %s", strings.Join(moonphase.Languages, ", ")))
+	return func() string {
+		if (!moonphase.HasLanguage(*langFlag)) {
+			fatalf(message("unknown -lang %q, expected one of %s"), *langFlag, strings.Join(moonphase.Languages, ", "))
+		}
+		return *langFlag
+	}
+}
+
+// registers -hemisphere on flags. the returned function parses it once flags has been parsed,
+// and exits if it's neither north nor south
+func addHemisphereFlag(flags *flag.FlagSet) func() moonphase.Hemisphere {
+	hemisphereFlag := flags.String("hemisphere", "north", message("Hemisphere the moon is seen from, north or south, south mirrors the emoji"))
+	return func() moonphase.Hemisphere {
+		hemisphere, err := moonphase.ParseHemisphere(*hemisphereFlag)
+		if err != nil {
+			fatalf(message("unknown -hemisphere %q, expected north or south"), *hemisphereFlag)
+		}
+		return hemisphere
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// every string flag of every command, set empty, to whitespace or to a value wrapped in it, reads
+// as its default, as its default, or as the value. -cache-dir keeps an empty value, which turns
+// the cache off
+func TestNormalizeStringFlags(t *testing.T) {
+	checked := 0
+	for _, name := range subcommandNames() {
+		command := subcommands[name]
+		if (command.defineFlags == nil) {
+			continue
+		}
+		for _, f := range commandFlags(name, command) {
+			if _, isString := f.Value.(flag.Getter).Get().(string); (!isString) {
+				continue
+			}
+			wantEmpty := f.DefValue
+			if (flagsAllowingEmpty[f.Name]) {
+				wantEmpty = ""
+			}
+			for _, test := range []struct {
+				value string
+				want  string
+			}{
+				{"", wantEmpty},
+				{"   ", wantEmpty},
+				{"\t\n", wantEmpty},
+				{" value\t", "value"},
+				{"value\n", "value"},
+				{"two words ", "two words"},
+			} {
+				flags := flag.NewFlagSet(name, flag.ContinueOnError)
+				command.defineFlags(flags)
+				if err := flags.Set(f.Name, test.value); err != nil {
+					t.Fatal(err)
+				}
+				normalizeStringFlags(flags)
+				if got := flags.Lookup(f.Name).Value.String(); (got != test.want) {
+					t.Errorf("%s -%s %q: got %q, want %q", name, f.Name, test.value, got, test.want)
+				}
+			}
+			checked++
+		}
+	}
+	if (checked < 50) {
+		t.Errorf("checked %d string flags, want every command's", checked)
+	}
+}
+
+// config values get the same treatment as flags: trimmed, empty meaning the default, and
+// overridden by a flag given on the command line
+func TestNormalizeConfigValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	content := "emoji-style=  ascii  \ntimezone=\t\nhemisphere= south\nlang=   \ncache-dir=  \ndate-layout= 2/1/2006 \n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MOONPHASE_CONFIG", path)
+	flags := flag.NewFlagSet("phase", flag.ContinueOnError)
+	definePhaseFlags(flags)
+	if err := flags.Parse([]string{"-hemisphere", " north "}); err != nil {
+		t.Fatal(err)
+	}
+	applyConfig(flags)
+	normalizeStringFlags(flags)
+	for name, want := range map[string]string{
+		"emoji-style": "ascii",
+		"timezone":    "",
+		"hemisphere":  "north",
+		"lang":        flags.Lookup("lang").DefValue,
+		"cache-dir":   "",
+		"date-layout": "2/1/2006",
+	} {
+		if got := flags.Lookup(name).Value.String(); (got != want) {
+			t.Errorf("-%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+// on a lookup, each option empty or whitespace, from a flag or the config file, behaves as if it
+// weren't given, a valid value wrapped in whitespace is used, and an invalid one is named on
+// stderr, as an argument error unless the option falls back with a warning
+func TestStringOptionMatrix(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		configured bool
+		valid      string
+		// what the lookup prints with the option unset, and with the valid value, and whether an
+		// invalid value is only a warning
+		unset      string
+		set        string
+		invalid    string
+		warns      bool
+	}{
+		{"lang", []string{"-date", "2024-03-25", "-offline", "-plaintext"}, true, " es ", "Full Moon\n", "Luna llena\n", "xx", false},
+		{"emoji-style", []string{"-date", "2024-03-25", "-offline"}, true, "\tascii ", "🌕\n", "[####]\n", "sparkly", false},
+		{"hemisphere", []string{"-date", "2024-03-17", "-offline", "-emoji-style", "ascii"}, true, " SOUTH ", "[  ##]\n", "[##  ]\n", "west", false},
+		// the system zone of the run is UTC, where the New Moon is the day after
+		{"timezone", []string{"-date", "2024-03-10", "-offline", "-plaintext", "-show-age"}, true, " Pacific/Honolulu ", "New Moon (age 29.1 days)\n", "New Moon (age 0.0 days)\n", "Mars/Olympus_Mons", false},
+		{"date-layout", []string{"-date", "10/03/2024", "-offline", "-plaintext"}, true, " 2/1/2006 ", "", "New Moon\n", "", false},
+		{"date", []string{"-offline", "-plaintext"}, false, " 2024-03-25 ", "", "Full Moon\n", "2024-13-45", false},
+		{"cache-dir", []string{"-date", "2024-03-25", "-offline", "-plaintext"}, true, " ", "Full Moon\n", "Full Moon\n", "", false},
+		{"now-file", []string{"-date", "2024-03-25", "-offline", "-plaintext"}, false, "", "Full Moon\n", "", "/no/such/now", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			run := func(config string, extra ...string) moonphaseRun {
+				t.Helper()
+				if err := os.WriteFile(filepath.Join(dir, "no-config"), []byte(config), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return runMoonphase(t, dir, append(append([]string{}, test.args...), extra...)...)
+			}
+			for _, empty := range []string{"", "   ", "\t"} {
+				flagRun := run("", "-" + test.name, empty)
+				if (test.unset == "") {
+					// without it the date given can't be read, or today is looked up
+					if (test.name == "date-layout" && flagRun.code != exitInvalidArguments) {
+						t.Errorf("-%s %q: exit %d, printed %q, want the default layout to refuse the date", test.name, empty, flagRun.code, flagRun.stdout)
+					}
+					if (test.name == "date" && flagRun.code != 0) {
+						t.Errorf("-%s %q: exit %d, want today\n%s", test.name, empty, flagRun.code, flagRun.stderr)
+					}
+					continue
+				}
+				if (flagRun.code != 0 || flagRun.stdout != test.unset) {
+					t.Errorf("-%s %q: exit %d, printed %q, want %q\n%s", test.name, empty, flagRun.code, flagRun.stdout, test.unset, flagRun.stderr)
+				}
+				if (test.configured) {
+					configRun := run(test.name + "=" + empty + "\n")
+					if (configRun.code != 0 || configRun.stdout != test.unset) {
+						t.Errorf("config %s=%q: exit %d, printed %q, want %q\n%s", test.name, empty, configRun.code, configRun.stdout, test.unset, configRun.stderr)
+					}
+				}
+			}
+			if (test.valid != "") {
+				if flagRun := run("", "-" + test.name, test.valid); (flagRun.code != 0 || flagRun.stdout != test.set) {
+					t.Errorf("-%s %q: exit %d, printed %q, want %q\n%s", test.name, test.valid, flagRun.code, flagRun.stdout, test.set, flagRun.stderr)
+				}
+				if (test.configured) {
+					if configRun := run(test.name + "=" + test.valid + "\n"); (configRun.code != 0 || configRun.stdout != test.set) {
+						t.Errorf("config %s=%q: exit %d, printed %q, want %q\n%s", test.name, test.valid, configRun.code, configRun.stdout, test.set, configRun.stderr)
+					}
+				}
+			}
+			if (test.invalid != "") {
+				wantCode := exitInvalidArguments
+				if (test.warns) {
+					wantCode = 0
+				}
+				for _, invalid := range []string{test.invalid, " " + test.invalid + " "} {
+					flagRun := run("", "-" + test.name, invalid)
+					if (flagRun.code != wantCode || !strings.Contains(flagRun.stderr, test.invalid)) {
+						t.Errorf("-%s %q: exit %d, want %d naming it:\n%s", test.name, invalid, flagRun.code, wantCode, flagRun.stderr)
+					}
+				}
+			}
+		})
+	}
+}
+
+// a value of a numeric flag, as given on the command line, and whether its range allows it
+type rangeValue struct {
+	value   string
+	allowed bool
+}
+
+// returns the values just below, at and just above each end of the range of the flag called name
+func rangeValues(t *testing.T, name string) []rangeValue {
+	t.Helper()
+	bounds := flagRanges[name]
+	switch min := bounds.min.(type) {
+	case int:
+		max := bounds.max.(int)
+		return []rangeValue{{strconv.Itoa(min - 1), false}, {strconv.Itoa(min), true}, {strconv.Itoa(max), true}, {strconv.Itoa(max + 1), false}}
+	case time.Duration:
+		max := bounds.max.(time.Duration)
+		return []rangeValue{{(min - time.Nanosecond).String(), false}, {min.String(), true}, {max.String(), true}, {(max + time.Nanosecond).String(), false}}
+	case float64:
+		max := bounds.max.(float64)
+		return []rangeValue{{fmt.Sprint(min - 0.5), false}, {fmt.Sprint(min), true}, {fmt.Sprint(max), true}, {fmt.Sprint(max + 0.5), false}}
+	}
+	t.Fatalf("-%s has a range of %T", name, bounds.min)
+	return nil
+}
+
+// every flag with a range has it in the type of the flag in every command defining it, allows its
+// default, and refuses the values either side of it as an argument error worded the same way
+func TestFlagRanges(t *testing.T) {
+	defined := map[string]bool{}
+	for _, command := range subcommandNames() {
+		for _, f := range commandFlags(command, subcommands[command]) {
+			if _, found := flagRanges[f.Name]; (!found) {
+				continue
+			}
+			defined[f.Name] = true
+			if err := checkFlagRange(f); err != nil {
+				t.Errorf("%s: the default of -%s is out of its range: %v", command, f.Name, err)
+			}
+			for _, value := range rangeValues(t, f.Name) {
+				flags := flag.NewFlagSet(command, flag.ContinueOnError)
+				subcommands[command].defineFlags(flags)
+				if err := flags.Set(f.Name, value.value); err != nil {
+					t.Fatal(err)
+				}
+				err := checkFlagRange(flags.Lookup(f.Name))
+				if (value.allowed) {
+					if err != nil {
+						t.Errorf("%s -%s %s: %v", command, f.Name, value.value, err)
+					}
+					continue
+				}
+				want := fmt.Sprintf("-%s must be between %s, got %s", f.Name, strings.Replace(formatFlagRange(f.Name), " to ", " and ", 1), value.value)
+				if (exitCodeFor(err) != exitInvalidArguments || err.Error() != want) {
+					t.Errorf("%s -%s %s: got %v, want the argument error %q", command, f.Name, value.value, err, want)
+				}
+			}
+		}
+	}
+	for name := range flagRanges {
+		if (!defined[name]) {
+			t.Errorf("-%s has a range but no command defines it", name)
+		}
+	}
+	if err := checkFloatRange("coords latitude", 90.5, -90, 90); (err == nil || err.Error() != "-coords latitude must be between -90 and 90, got 90.5") {
+		t.Errorf("got %v", err)
+	}
+}
+
+// each numeric flag is checked against its range when the command runs, from the command line as
+// an argument error and from the config file as an error in the file, which the command line
+// overrides
+func TestFlagRangeSources(t *testing.T) {
+	dir := t.TempDir()
+	commands := map[string][]string{
+		"timeout":          {"-offline"},
+		"retries":          {"-offline"},
+		"deadline":         {"-offline"},
+		"max-past":         {"-offline"},
+		"max-future":       {"-offline"},
+		"now-file-max-age": {"-offline"},
+		"n":                {"around", "-source", "offline"},
+		"months":           {"calendar", "-source", "offline", "-o", filepath.Join(dir, "phases.ics")},
+		"older-than":       {"cache", "purge", "-cache-dir", filepath.Join(dir, "cache")},
+	}
+	for name := range flagRanges {
+		args, found := commands[name]
+		if (!found) {
+			t.Errorf("-%s has a range but no command to check it with", name)
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			config := filepath.Join(dir, "no-config")
+			writeConfig := func(content string) {
+				t.Helper()
+				if err := os.WriteFile(config, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer writeConfig("")
+			for _, value := range rangeValues(t, name) {
+				writeConfig("")
+				flagRun := runMoonphase(t, dir, append(append([]string{}, args...), "-" + name, value.value)...)
+				outOfRange := strings.Contains(flagRun.stderr, "-" + name + " must be between")
+				if (value.allowed && (flagRun.code != 0 || outOfRange)) {
+					t.Errorf("-%s %s: exit %d:\n%s", name, value.value, flagRun.code, flagRun.stderr)
+				}
+				if (!value.allowed && (flagRun.code != exitInvalidArguments || !outOfRange)) {
+					t.Errorf("-%s %s: exit %d, want %d saying it's out of range:\n%s", name, value.value, flagRun.code, exitInvalidArguments, flagRun.stderr)
+				}
+				if (!configurableFlags[name]) {
+					continue
+				}
+				writeConfig(name + "=" + value.value + "\n")
+				configRun := runMoonphase(t, dir, args...)
+				if (value.allowed && configRun.code != 0) {
+					t.Errorf("config %s=%s: exit %d:\n%s", name, value.value, configRun.code, configRun.stderr)
+				}
+				if (!value.allowed) {
+					if (configRun.code != exitParse || !strings.Contains(configRun.stderr, config + ": -" + name + " must be between")) {
+						t.Errorf("config %s=%s: exit %d, want %d naming the file:\n%s", name, value.value, configRun.code, exitParse, configRun.stderr)
+					}
+					if allowed := runMoonphase(t, dir, append(append([]string{}, args...), "-" + name, rangeValues(t, name)[1].value)...); (allowed.code != 0) {
+						t.Errorf("config %s=%s overridden on the command line: exit %d:\n%s", name, value.value, allowed.code, allowed.stderr)
+					}
+				}
+			}
+		})
+	}
+}
+
+// config show lists every configurable setting once, where its value comes from and the range of
+// the numeric ones
+func TestConfigShow(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "no-config")
+	if err := os.WriteFile(config, []byte("retries=20\nemoji-style=ascii\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run := runMoonphase(t, dir, "config", "show")
+	if (run.code != 0) {
+		t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+	}
+	lines := strings.Split(strings.TrimSuffix(run.stdout, "\n"), "\n")
+	if (len(lines) != len(configurableFlags) + 2 || lines[0] != "# " + config) {
+		t.Fatalf("want the path, a heading and a line per setting, got:\n%s", run.stdout)
+	}
+	settings := map[string][]string{}
+	for _, line := range lines[2:] {
+		fields := strings.Fields(line)
+		settings[fields[0]] = fields[1:]
+	}
+	for name, want := range map[string]string{
+		"retries":     "config 0 to 10 20",
+		"emoji-style": "config ascii",
+		"timeout":     "default 1s to 5m0s 10s",
+		"hemisphere":  "default north",
+		"date-layout": "default",
+	} {
+		if got := strings.Join(settings[name], " "); (got != want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	for name := range configurableFlags {
+		if _, found := settings[name]; (!found) {
+			t.Errorf("%s isn't listed", name)
+		}
+	}
+	for _, args := range [][]string{{"config"}, {"config", "edit"}} {
+		if run := runMoonphase(t, dir, args...); (run.code != exitInvalidArguments) {
+			t.Errorf("%q: exit %d, want %d", args, run.code, exitInvalidArguments)
+		}
+	}
+}
+
+// a lookup the -source can't serve exits before looking anything up, naming the options to change
+func TestSourceGuidance(t *testing.T) {
+	dir := t.TempDir()
+	recorded := recordedUSNOFile(t)
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	usnoGuidance := "USNO data only available between 1700 and 2100, asked for phases from 2150-01-01, use -source offline to calculate phases from the mean cycle instead"
+	fileGuidance := "give a -source-file covering it, or use -source usno or -source offline"
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"past the API's years", []string{"-date", "2150-01-01"}, exitInvalidArguments, usnoGuidance},
+		{"range past the API's years", []string{"range", "2099-12-01", "2150-01-01"}, exitInvalidArguments, usnoGuidance},
+		{"next past the API's years", []string{"next", "full", "-date", "2150-01-01"}, exitInvalidArguments, usnoGuidance},
+		{"around past the API's years", []string{"around", "2150-01-01"}, exitInvalidArguments, usnoGuidance},
+		{"calendar past the API's years", []string{"calendar", "-date", "2150-01-01", "-o", "-"}, exitInvalidArguments, usnoGuidance},
+		{"in Spanish", []string{"-lang", "es", "-date", "2150-01-01"}, exitInvalidArguments, "usa -source offline para calcular las fases con el ciclo medio"},
+		{"past the file", []string{"-source", "file", "-source-file", recorded, "-date", "2030-01-01"}, exitInvalidArguments, "only holds phases from 2023-12-05 to 2025-01-29, asked for 2030-01-01, " + fileGuidance},
+		{"range past the file", []string{"range", "-source", "file", "-source-file", recorded, "2024-03-01", "2026-01-01"}, exitInvalidArguments, "asked for 2026-01-01, " + fileGuidance},
+		{"missing file", []string{"-source", "file", "-source-file", filepath.Join(dir, "missing.json"), "-date", "2024-03-01"}, exitInvalidArguments, "no such file or directory, " + fileGuidance},
+		{"malformed file", []string{"-source", "file", "-source-file", malformed, "-date", "2024-03-01"}, exitParse, "malformed.json: invalid character '<' looking for beginning of value, " + fileGuidance},
+	}
+	for _, test := range tests {
+		run := runMoonphase(t, dir, test.args...)
+		if (run.code != test.code || !strings.Contains(run.stderr, test.want) || run.stdout != "") {
+			t.Errorf("%s: exit %d, want %d with %q:\n%s%s", test.name, run.code, test.code, test.want, run.stdout, run.stderr)
+		}
+	}
+	// sources that do cover the day go on to look it up
+	for _, args := range [][]string{
+		{"-source", "file", "-source-file", recorded, "-date", "2024-03-25", "-plaintext"},
+		{"-source", "offline", "-date", "2150-01-01", "-plaintext"},
+		{"-offline", "-date", "2150-01-01", "-plaintext"},
+	} {
+		if run := runMoonphase(t, dir, args...); (run.code != 0 || run.stdout == "") {
+			t.Errorf("%q: exit %d:\n%s", args, run.code, run.stderr)
+		}
+	}
+}
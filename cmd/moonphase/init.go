@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// a question asked by init. check parses an answer into the config values it stands for, the
+// default is used for an empty answer, and an answer check rejects is asked for again
+type initQuestion struct {
+	prompt       string
+	defaultValue string
+	check        func(answer string) (map[string]string, error)
+}
+
+// the init questions, checked with the same functions that check the flags they set
+var initQuestions = []initQuestion{
+	{
+		prompt:       "Output style: auto, emoji, text, shortcode, nerdfont, ascii or plaintext",
+		defaultValue: "auto",
+		check: func(answer string) (map[string]string, error) {
+			if (answer == "plaintext") {
+				return map[string]string{"plaintext": "true"}, nil
+			}
+			style, err := parseEmojiStyle(answer)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"emoji-style": style}, nil
+		},
+	},
+	{
+		prompt:       "Hemisphere you see the moon from, north or south",
+		defaultValue: "north",
+		check: func(answer string) (map[string]string, error) {
+			hemisphere, err := moonphase.ParseHemisphere(answer)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"hemisphere": hemisphere.String()}, nil
+		},
+	},
+	{
+		prompt:       "Timezone, like America/New_York, or system for the local zone",
+		defaultValue: "system",
+		check: func(answer string) (map[string]string, error) {
+			if (answer == "system") {
+				return nil, nil
+			}
+			if _, err := loadTimezone(answer); err != nil {
+				return nil, err
+			}
+			return map[string]string{"timezone": answer}, nil
+		},
+	},
+	{
+		prompt:       "Directory to cache API responses in, or none to turn the cache off",
+		defaultValue: defaultCacheDir(),
+		check: func(answer string) (map[string]string, error) {
+			if (answer == "none" || answer == "") {
+				return map[string]string{"cache-dir": ""}, nil
+			}
+			if (!filepath.IsAbs(answer)) {
+				return nil, fmt.Errorf("the cache directory has to be an absolute path, got %q", answer)
+			}
+			return map[string]string{"cache-dir": answer}, nil
+		},
+	},
+}
+
+// asks each question on prompts and reads the answers from answers, asking again until an answer
+// checks out. answers running out takes the defaults for the rest
+func askInitQuestions(answers *bufio.Reader, prompts io.Writer) map[string]string {
+	config := map[string]string{}
+	for _, question := range initQuestions {
+		for {
+			fmt.Fprintf(prompts, "%s [%s]: ", question.prompt, question.defaultValue)
+			line, err := answers.ReadString('\n')
+			if (err != nil && err != io.EOF) {
+				fatal(err)
+			}
+			answer := strings.TrimSpace(line)
+			if (answer == "") {
+				answer = question.defaultValue
+			}
+			if (err == io.EOF) {
+				fmt.Fprintln(prompts)
+			}
+			values, checkErr := question.check(answer)
+			if (checkErr != nil) {
+				fmt.Fprintln(prompts, checkErr)
+				if (err == io.EOF) {
+					fatal("init: no more answers to try again with")
+				}
+				continue
+			}
+			for name, value := range values {
+				config[name] = value
+			}
+			break
+		}
+	}
+	return config
+}
+
+// moonphase init
+func runInit(args []string) {
+	// no flags, but -h goes through a flag set like every command
+	initFlags := newFlagSet("init")
+	initFlags.Parse(args)
+	if (initFlags.NArg() > 0) {
+		fatalf("init takes no arguments, got %q", strings.Join(initFlags.Args(), " "))
+	}
+	path, err := configPath()
+	if err != nil {
+		fatal(err)
+	}
+	if _, err := os.Stat(path); (err == nil) {
+		fmt.Fprintf(os.Stderr, "replacing %s\n", path)
+	}
+	config := askInitQuestions(bufio.NewReader(os.Stdin), os.Stderr)
+	content := formatConfig(config)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fatal(err)
+	}
+	if err := writeOutput(path, false, defaultOutputMode, []byte(content)); err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(stdout, "wrote %s:\n%s", path, content)
+}
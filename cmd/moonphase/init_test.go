@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// each answer is checked like the flag it sets, one that doesn't check out is asked for again,
+// empty answers take the defaults and answers running out take the rest
+func TestAskInitQuestions(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers string
+		want    map[string]string
+		// in the prompts, as the errors for the answers asked again
+		prompted []string
+	}{
+		{"every answer", "plaintext\nsouth\nEurope/Berlin\nnone\n", map[string]string{"plaintext": "true", "hemisphere": "south", "timezone": "Europe/Berlin", "cache-dir": ""}, nil},
+		{"defaults", "\n\n\n\n", map[string]string{"emoji-style": "auto", "hemisphere": "north", "cache-dir": defaultCacheDir()}, nil},
+		{"answers run out", "ascii\n", map[string]string{"emoji-style": "ascii", "hemisphere": "north", "cache-dir": defaultCacheDir()}, nil},
+		{"trimmed and cased like flags", "  emoji \nSOUTH\nsystem\n/var/cache/moonphase\n", map[string]string{"emoji-style": "emoji", "hemisphere": "south", "cache-dir": "/var/cache/moonphase"}, nil},
+		{
+			"asked again",
+			"sparkly\nshortcode\nwest\nnorth\nMars/Olympus_Mons\nUTC\nrelative/cache\n/tmp/cache\n",
+			map[string]string{"emoji-style": "shortcode", "hemisphere": "north", "timezone": "UTC", "cache-dir": "/tmp/cache"},
+			[]string{"sparkly", "west", "Mars/Olympus_Mons", "absolute path"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var prompts bytes.Buffer
+			got := askInitQuestions(bufio.NewReader(strings.NewReader(test.answers)), &prompts)
+			if (!reflect.DeepEqual(got, test.want)) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if count := strings.Count(prompts.String(), "Output style"); (count != 1 + boolToInt(len(test.prompted) > 0)) {
+				t.Errorf("asked for the output style %d times:\n%s", count, prompts.String())
+			}
+			for _, prompted := range test.prompted {
+				if (!strings.Contains(prompts.String(), prompted)) {
+					t.Errorf("the prompts don't mention %q:\n%s", prompted, prompts.String())
+				}
+			}
+		})
+	}
+}
+
+func boolToInt(b bool) int {
+	if (b) {
+		return 1
+	}
+	return 0
+}
+
+// init run on scripted stdin writes the config file it prints, replacing one already there, and
+// the commands after it read it
+func TestInitWizard(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "no-config")
+	run := runMoonphaseWithInput(t, dir, "plaintext\nsouth\nUTC\nnone\n", "init")
+	want := "cache-dir=\nhemisphere=south\nplaintext=true\ntimezone=UTC\n"
+	if (run.code != 0 || run.stdout != "wrote " + path + ":\n" + want) {
+		t.Fatalf("exit %d, printed %q:\n%s", run.code, run.stdout, run.stderr)
+	}
+	for _, prompt := range []string{"Output style", "Hemisphere", "Timezone", "Directory to cache"} {
+		if (!strings.Contains(run.stderr, prompt)) {
+			t.Errorf("didn't ask about %q:\n%s", prompt, run.stderr)
+		}
+	}
+	if content, err := os.ReadFile(path); (err != nil || string(content) != want) {
+		t.Errorf("wrote %q (%v), want %q", content, err, want)
+	}
+	run = runMoonphase(t, dir, "-date", "2024-03-25", "-offline")
+	if (run.code != 0 || run.stdout != "Full Moon\n") {
+		t.Errorf("with the config: exit %d, printed %q\n%s", run.code, run.stdout, run.stderr)
+	}
+
+	run = runMoonphaseWithInput(t, dir, "emoji\n", "init")
+	if (run.code != 0 || !strings.Contains(run.stderr, "replacing " + path) || !strings.HasSuffix(run.stdout, ":\ncache-dir=" + filepath.Join(dir, ".cache", "moonphase") + "\nemoji-style=emoji\nhemisphere=north\n")) {
+		t.Errorf("replacing: exit %d, printed %q\n%s", run.code, run.stdout, run.stderr)
+	}
+	if run := runMoonphaseWithInput(t, dir, "", "init", "now"); (run.code != exitInvalidArguments) {
+		t.Errorf("init now: exit %d, want %d", run.code, exitInvalidArguments)
+	}
+}
+
+// without a config, and with answers waiting on stdin, a lookup never starts the wizard
+func TestNoWizardWithoutInit(t *testing.T) {
+	run := runMoonphaseWithInput(t, t.TempDir(), "plaintext\nsouth\nUTC\nnone\n", "-date", "2024-03-25", "-offline", "-plaintext")
+	if (run.code != 0 || run.stdout != "Full Moon\n" || strings.Contains(run.stderr, "Output style")) {
+		t.Errorf("exit %d, printed %q\n%s", run.code, run.stdout, run.stderr)
+	}
+}
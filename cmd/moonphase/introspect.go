@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// what this build supports, built from the same tables the commands use so it can't go stale
+type capabilities struct {
+	Version       string   `json:"version"`
+	Subcommands   []string `json:"subcommands"`
+	// the -source values
+	Providers     []string `json:"providers"`
+	// the providers lookups try in order, from Client.Providers, with the -source given
+	ProviderChain []string `json:"provider_chain"`
+	EmojiStyles   []string `json:"emoji_styles"`
+	Languages     []string `json:"languages"`
+}
+
+// returns the module version this binary was built from, "(devel)" for a local build
+func getVersion() string {
+	info, found := debug.ReadBuildInfo()
+	if (!found) {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
+
+// collects the capabilities of this build, with the provider chain of c
+func getCapabilities(c *moonphase.Client) capabilities {
+	return capabilities{
+		Version:       getVersion(),
+		Subcommands:   subcommandNames(),
+		Providers:     sources,
+		ProviderChain: c.Providers(),
+		EmojiStyles:   emojiStyles,
+		Languages:     moonphase.Languages,
+	}
+}
+
+// the flags of introspect, read once they have been parsed
+type introspectOptions struct {
+	jsonFlag         *bool
+	applyClientFlags func()
+	applyQuiet       func()
+}
+
+// defines the flags of introspect on flags, for the command to parse and for completion to list
+func defineIntrospectFlags(flags *flag.FlagSet) *introspectOptions {
+	options := &introspectOptions{}
+	options.jsonFlag = flags.Bool("json", false, message("Print the capabilities as JSON"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase introspect -json
+func runIntrospect(args []string) {
+	introspectFlags := newFlagSet("introspect")
+	options := defineIntrospectFlags(introspectFlags)
+	introspectFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(introspectFlags)
+	normalizeStringFlags(introspectFlags)
+	options.applyClientFlags()
+
+	caps := getCapabilities(client)
+	if (*options.jsonFlag) {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(caps); err != nil {
+			fatalOutputError(err)
+		}
+		return
+	}
+	_, err := fmt.Fprintf(stdout, "version: %s\nsubcommands: %s\nproviders: %s\nprovider chain: %s\nemoji styles: %s\nlanguages: %s\n",
+		caps.Version, strings.Join(caps.Subcommands, ", "), strings.Join(caps.Providers, ", "), strings.Join(caps.ProviderChain, ", "),
+		strings.Join(caps.EmojiStyles, ", "), strings.Join(caps.Languages, ", "))
+	if err != nil {
+		fatalOutputError(err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// decodes a capabilities payload, failing t unless it has exactly the fields of capabilities,
+// each a list of names bar the version
+func decodeCapabilities(t *testing.T, payload []byte) capabilities {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("%v:\n%s", err, payload)
+	}
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{"emoji_styles", "languages", "provider_chain", "providers", "subcommands", "version"}
+	if (!reflect.DeepEqual(names, want)) {
+		t.Errorf("fields %q, want %q", names, want)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	var caps capabilities
+	if err := decoder.Decode(&caps); err != nil {
+		t.Fatalf("%v:\n%s", err, payload)
+	}
+	if (caps.Version == "") {
+		t.Error("no version")
+	}
+	return caps
+}
+
+// reports whether names lists name
+func containsName(names []string, name string) bool {
+	for _, listed := range names {
+		if (listed == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /capabilities lists every subcommand, -source, emoji style and language there is, from the
+// tables the commands use, and the chain of the server's own client
+func TestServeCapabilities(t *testing.T) {
+	server := newTestServer("", "")
+	server.client.RegisterProvider("fallback", moonphase.OfflineProvider{}, moonphase.USNOPriority - 1, nil)
+	response := serveRequest(server, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	if (response.Code != http.StatusOK || !strings.HasPrefix(response.Header().Get("Content-Type"), "application/json")) {
+		t.Fatalf("status %d, Content-Type %q", response.Code, response.Header().Get("Content-Type"))
+	}
+	caps := decodeCapabilities(t, response.Body.Bytes())
+	for name, lists := range map[string][2][]string{
+		"subcommands":    {caps.Subcommands, subcommandNames()},
+		"providers":      {caps.Providers, sources},
+		"provider_chain": {caps.ProviderChain, {"source", "fallback"}},
+		"emoji_styles":   {caps.EmojiStyles, emojiStyles},
+		"languages":      {caps.Languages, moonphase.Languages},
+	} {
+		if (!reflect.DeepEqual(lists[0], lists[1])) {
+			t.Errorf("%s %q, want %q", name, lists[0], lists[1])
+		}
+	}
+	for _, subcommand := range []string{"config", "introspect", "serve"} {
+		if (!containsName(caps.Subcommands, subcommand)) {
+			t.Errorf("subcommands %q leave out %s", caps.Subcommands, subcommand)
+		}
+	}
+}
+
+// what's added to the tables shows up, nothing is a copy taken earlier
+func TestCapabilitiesFollowTheTables(t *testing.T) {
+	previousStyles := emojiStyles
+	defer func() {
+		emojiStyles = previousStyles
+		delete(subcommands, "test-only")
+	}()
+	emojiStyles = append(append([]string{}, emojiStyles...), "sparkly")
+	subcommands["test-only"] = subcommand{summary: "Only in this test"}
+	c := &moonphase.Client{}
+	c.RegisterProvider("extra", moonphase.OfflineProvider{}, moonphase.USNOPriority + 1, nil)
+	caps := getCapabilities(c)
+	if (!containsName(caps.EmojiStyles, "sparkly") || !containsName(caps.Subcommands, "test-only")) {
+		t.Errorf("emoji styles %q and subcommands %q miss what was added", caps.EmojiStyles, caps.Subcommands)
+	}
+	if (!reflect.DeepEqual(caps.ProviderChain, []string{"extra", "usno"})) {
+		t.Errorf("provider chain %q, want extra ahead of usno", caps.ProviderChain)
+	}
+}
+
+// introspect -json prints the payload the server answers with, and the text form the same lists
+func TestIntrospect(t *testing.T) {
+	dir := t.TempDir()
+	run := runMoonphase(t, dir, "introspect", "-json", "-source", "offline")
+	if (run.code != 0) {
+		t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+	}
+	printed := decodeCapabilities(t, []byte(run.stdout))
+	served := decodeCapabilities(t, serveRequest(newTestServer("", ""), httptest.NewRequest(http.MethodGet, "/capabilities", nil)).Body.Bytes())
+	if (!reflect.DeepEqual(printed, served)) {
+		t.Errorf("introspect -json printed %+v, the server answers %+v", printed, served)
+	}
+	text := runMoonphase(t, dir, "introspect", "-source", "offline")
+	for _, line := range []string{
+		"subcommands: " + strings.Join(printed.Subcommands, ", "),
+		"providers: " + strings.Join(printed.Providers, ", "),
+		"provider chain: " + strings.Join(printed.ProviderChain, ", "),
+		"emoji styles: " + strings.Join(printed.EmojiStyles, ", "),
+		"languages: " + strings.Join(printed.Languages, ", "),
+	} {
+		if (!strings.Contains(text.stdout, line + "\n")) {
+			t.Errorf("introspect doesn't print %q:\n%s", line, text.stdout)
+		}
+	}
+}
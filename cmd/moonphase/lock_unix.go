@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// takes an advisory lock on the sidecar path + ".lock", waiting for any other moonphase holding it,
+// and returns the function releasing it. the sidecar is left in place, removing it would let
+// a process that opened it before the removal lock a different file than one opening it after
+func lockFile(path string) (func(), error) {
+	lock, err := os.OpenFile(path + ".lock", os.O_RDWR|os.O_CREATE, defaultOutputMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
@@ -0,0 +1,826 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+	_ "time/tzdata"
+	"log"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// how far in the future a save file record can claim to be from before it's distrusted
+const maxSaveFileSkew time.Duration = time.Hour
+
+// exit status for -exit-changed when the result differs from the last run. it's 5 rather than 3,
+// which is already exitNetwork, so a script can tell a change from a failed lookup
+const exitChanged int = 5
+
+// the client every lookup goes through
+var client = moonphase.NewClient()
+
+// the context every lookup runs in, cancelled by Ctrl-C and once -deadline passes
+var ctx = context.Background()
+
+// the subcommands by name, each parses its own flags from the arguments after the name.
+// filled in by init since introspect, help and completion list them
+var subcommands map[string]subcommand
+
+func init() {
+	subcommands = map[string]subcommand{
+		"phase":          {runPhase, func(flags *flag.FlagSet) { definePhaseFlags(flags) }, "Print the phase for a day, what moonphase does without a command"},
+		"range":          {runRange, func(flags *flag.FlagSet) { definePhaseFlags(flags) }, "Print a phase per day from one date to another"},
+		"export":         {runExport, func(flags *flag.FlagSet) { defineExportFlags(flags) }, "Write the phases between two dates to a CSV file"},
+		"around":         {runAround, func(flags *flag.FlagSet) { defineAroundFlags(flags) }, "List the phase events before and after a date"},
+		"onthisday":      {runOnThisDay, func(flags *flag.FlagSet) { defineOnThisDayFlags(flags) }, "List the phase on a month and day across a range of years"},
+		"filename":       {runFilename, func(flags *flag.FlagSet) { defineFilenameFlags(flags) }, "Print a filename for a day from its phase"},
+		"introspect":     {runIntrospect, func(flags *flag.FlagSet) { defineIntrospectFlags(flags) }, "List the subcommands, providers, emoji styles and languages"},
+		"next":           {runNext, func(flags *flag.FlagSet) { defineSearchFlags(flags) }, "Print when a primary phase next happens"},
+		"prev":           {runPrevious, func(flags *flag.FlagSet) { defineSearchFlags(flags) }, "Print when a primary phase last happened"},
+		"diff-providers": {runDiffProviders, func(flags *flag.FlagSet) { defineDiffFlags(flags) }, "Compare the phases two providers give between two dates"},
+		"config":         {runConfig, func(flags *flag.FlagSet) { defineConfigFlags(flags) }, "Show the settings the config file gives and the values numeric ones allow"},
+		"cache":          {runCache, func(flags *flag.FlagSet) { defineCacheFlags(flags) }, "Compact or purge the -cache-dir"},
+		"digest":         {runDigest, func(flags *flag.FlagSet) { defineDigestFlags(flags) }, "Summarize the moon over an ISO week in text, markdown or html"},
+		"calendar":       {runCalendar, func(flags *flag.FlagSet) { defineCalendarFlags(flags) }, "Write the upcoming primary phases to an iCalendar file"},
+		"init":           {runInit, nil, "Write a config file from a few questions"},
+		"batch":          {runBatch, func(flags *flag.FlagSet) { defineBatchFlags(flags) }, "Print the phases of dates given as arguments or on stdin"},
+		"serve":          {runServe, func(flags *flag.FlagSet) { defineServeFlags(flags) }, "Serve the phase as JSON over HTTP"},
+		"help":           {runHelp, func(flags *flag.FlagSet) { addLanguageFlag(flags) }, "List the commands"},
+		"completion":     {runCompletion, nil, "Print a bash or zsh completion script"},
+	}
+}
+
+// set by -verbose, enables debugf
+var verbose bool
+
+// logs a diagnostic message to stderr when -verbose is set, stdout only ever holds the result
+func debugf(format string, args ...interface{}) {
+	if (verbose) {
+		log.Printf("debug: " + format, args...)
+	}
+}
+
+// returns the path of name in the user's home directory, where the save and state files default
+// to, and exits if there's no home directory
+func homeFile(name string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		fatal(err)
+	}
+	return fmt.Sprintf("%s/%s", homeDir, name)
+}
+
+// returns the location named by -timezone, or the system's local zone when it's empty. the zone
+// database is built in, so named zones load even where the system has no tzdata
+func loadTimezone(name string) (*time.Location, error) {
+	if (name == "") {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, argumentError{fmt.Errorf(message("invalid -timezone %q, expected an IANA zone like America/New_York"), name)}
+	}
+	return location, nil
+}
+
+// the -emoji-style values, see parseEmojiStyle
+var emojiStyles = []string{"auto", "emoji", "text", "shortcode", "nerdfont", "ascii"}
+
+// checks an -emoji-style value: auto leaves the moon emoji bare for the font to decide, emoji
+// appends the variation selector for terminals that otherwise draw them as monochrome text,
+// and text never appends it, for terminals that print the selector as a second character.
+// shortcode prints :full_moon: style names instead, for logs that mangle raw emoji, nerdfont the
+// Nerd Font moon glyphs and ascii a [  ##] bar for terminals without either
+func parseEmojiStyle(style string) (string, error) {
+	for _, known := range emojiStyles {
+		if (style == known) {
+			return style, nil
+		}
+	}
+	return "", argumentError{fmt.Errorf(message("unknown -emoji-style %q, expected one of %s"), style, strings.Join(emojiStyles, ", "))}
+}
+
+// returns the emoji style for the emoji field of JSON output, which always holds an emoji
+func emojiFieldStyle(emojiStyle string) string {
+	if (emojiStyle == "emoji" || emojiStyle == "text") {
+		return emojiStyle
+	}
+	return "auto"
+}
+
+// Return output as string, either plaintext in lang or the symbol in the given -emoji-style, as seen from hemisphere
+func getOutput(phase moonphase.Phase, plaintext bool, lang string, emojiStyle string, hemisphere moonphase.Hemisphere) string {
+	if (plaintext) {
+		return phase.Name(lang)
+	}
+	options := moonphase.SymbolOptions{Hemisphere: hemisphere, Preset: moonphase.EmojiPreset, EmojiPresentation: emojiStyle == "emoji"}
+	if preset, err := moonphase.ParseSymbolPreset(emojiStyle); (err == nil) {
+		options.Preset = preset
+	}
+	return moonphase.Symbol(phase, options)
+}
+
+// returns the name of phase in lang for JSON output alongside the canonical name, "" when they're
+// the same as they are in English
+func localizedName(phase moonphase.Phase, lang string) string {
+	if name := phase.Name(lang); (name != phase.String()) {
+		return name
+	}
+	return ""
+}
+
+// loads content of save file or returns nil?
+func loadSaveFile(saveFilePath string) string {
+	var output string
+	content, err := ioutil.ReadFile(saveFilePath)
+	if err == nil {
+		output = string(content)
+	}
+	return output
+}
+
+// the first line of a save file, naming its format. a file without it is from before the save file
+// held more than one day, a single day,phase line with an optional fetch time, and is read as that
+const saveFileHeader string = "moonphase-save 2"
+
+// entries fetched longer ago than this are dropped when the save file is next written, so looking
+// up many dates doesn't grow it without bound
+const saveFileMaxAge time.Duration = 30 * 24 * time.Hour
+
+// the phase the save file holds for a day, and when it was fetched. entries from files written
+// before the fetch time was recorded have a zero time
+type saveEntry struct {
+	phase     moonphase.Phase
+	fetchedAt time.Time
+}
+
+// parses a day,phase,fetched line of the save file, the fetch time being optional
+func parseSaveEntry(line string) (moonphase.DayKey, saveEntry, error) {
+	fields := strings.Split(line, ",")
+	if (len(fields) < 2 || len(fields) > 3) {
+		return moonphase.DayKey{}, saveEntry{}, fmt.Errorf("expected a day, a phase and a fetch time, got %q", line)
+	}
+	day, err := moonphase.ParseDayKey(fields[0])
+	if err != nil {
+		return moonphase.DayKey{}, saveEntry{}, fmt.Errorf("day: %w", err)
+	}
+	phase, err := moonphase.ParsePhase(fields[1])
+	if err != nil {
+		return moonphase.DayKey{}, saveEntry{}, fmt.Errorf("phase: %w", err)
+	}
+	entry := saveEntry{phase: phase}
+	if (len(fields) > 2) {
+		fetchedUnix, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return moonphase.DayKey{}, saveEntry{}, fmt.Errorf("fetch time: %w", err)
+		}
+		entry.fetchedAt = time.Unix(fetchedUnix, 0)
+	}
+	return day, entry, nil
+}
+
+// parses the content of a save file, in either format, into its entries by day. blank lines are
+// skipped, so an empty file has no entries
+func parseSaveFile(content string) (map[moonphase.DayKey]saveEntry, error) {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); (line != "") {
+			lines = append(lines, line)
+		}
+	}
+	entries := map[moonphase.DayKey]saveEntry{}
+	if (len(lines) == 0) {
+		return entries, nil
+	}
+	firstEntry := 1
+	if (lines[0] != saveFileHeader) {
+		if (strings.HasPrefix(lines[0], "moonphase-save ")) {
+			return nil, fmt.Errorf("save file is in an unknown format %q", lines[0])
+		}
+		if (len(lines) > 1) {
+			return nil, fmt.Errorf("save file has %d lines but no %q header", len(lines), saveFileHeader)
+		}
+		firstEntry = 0
+	}
+	for i, line := range lines[firstEntry:] {
+		day, entry, err := parseSaveEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("save file entry %d: %w", i + 1, err)
+		}
+		entries[day] = entry
+	}
+	return entries, nil
+}
+
+// formats entries as the save file holds them, sorted by day, leaving out those fetched more than
+// saveFileMaxAge before now
+func formatSaveFile(entries map[moonphase.DayKey]saveEntry, now time.Time) string {
+	days := make([]moonphase.DayKey, 0, len(entries))
+	for day, entry := range entries {
+		if (now.Sub(entry.fetchedAt) <= saveFileMaxAge) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	var content strings.Builder
+	content.WriteString(saveFileHeader + "\n")
+	for _, day := range days {
+		entry := entries[day]
+		fmt.Fprintf(&content, "%s,%s,%d\n", day, entry.phase, entry.fetchedAt.Unix())
+	}
+	return content.String()
+}
+
+// reports whether a save file entry can answer a lookup. an entry fetched more than
+// maxSaveFileSkew in the future means the clock has been changed since, so it's refetched
+// rather than trusted
+func isSaveFresh(entry saveEntry, now time.Time) bool {
+	if (!entry.fetchedAt.IsZero() && entry.fetchedAt.Sub(now) > maxSaveFileSkew) {
+		log.Printf("warning: save file entry was written at %s, after the current time %s, fetching again", entry.fetchedAt.Format(time.RFC3339), now.Format(time.RFC3339))
+		return false
+	}
+	return true
+}
+
+// set once a save file write has hit a read-only filesystem or been refused permission,
+// so the rest of the process doesn't keep retrying
+var saveFileReadOnly bool
+
+// reports whether a write failed because the location can't be written to at all, which is
+// normal on read-only $HOME setups and shouldn't fail the lookup
+func isReadOnlyError(err error) bool {
+	return errors.Is(err, syscall.EROFS) || errors.Is(err, fs.ErrPermission)
+}
+
+// saves current phase to local file, along with when it was fetched
+func savePhaseToFile(day moonphase.DayKey, phase moonphase.Phase, fetchedAt time.Time, saveFilePath string) {
+	if (saveFileReadOnly) {
+		return
+	}
+	// a -savefile in a directory that doesn't exist yet gets one
+	err := os.MkdirAll(filepath.Dir(saveFilePath), 0755)
+	// held from the read to the rename, so two lookups saving at once can't each merge into the
+	// file as it was before the other and lose the other's day
+	var unlock func()
+	if (err == nil) {
+		unlock, err = lockFile(saveFilePath)
+	}
+	if (err == nil) {
+		defer unlock()
+		// read again rather than reusing what the lookup read, so entries another process saved
+		// since aren't lost. a file that doesn't parse is started over
+		entries, parseErr := parseSaveFile(loadSaveFile(saveFilePath))
+		if parseErr != nil {
+			entries = map[moonphase.DayKey]saveEntry{}
+		}
+		entries[day] = saveEntry{phase: phase, fetchedAt: fetchedAt}
+		// replaced in one rename, so a shell prompt rendering at the same moment reads either the old
+		// file or the new one, never half of one
+		err = writeOutput(saveFilePath, false, defaultOutputMode, []byte(formatSaveFile(entries, fetchedAt)))
+	}
+	if (err != nil && isReadOnlyError(err)) {
+		// the phase is still printed, only persistence is lost
+		saveFileReadOnly = true
+		debugf("can't write %s, not saving phases for the rest of the run: %v", saveFilePath, err)
+		return
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func main() {
+	messageLanguage = messageLanguageFor(os.Args[1:])
+	// writes to a closed stdout return errBrokenPipe instead of killing the process
+	signal.Ignore(syscall.SIGPIPE)
+	// Ctrl-C cancels the request in flight so the command exits cleanly, a second one kills it as usual
+	var stopSignals context.CancelFunc
+	ctx, stopSignals = signal.NotifyContext(ctx, os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stopSignals()
+	}()
+	// subcommands have their own flags, hand them everything after the name. with none, or only
+	// flags, it's the phase lookup as it was before there were subcommands
+	if (len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-")) {
+		command, found := subcommands[os.Args[1]]
+		if (!found) {
+			fmt.Fprintf(stderr, "moonphase: %s\n\n", messagef("unknown command %q", os.Args[1]))
+			printUsage(stderr)
+			os.Exit(exitInvalidArguments)
+		}
+		command.run(os.Args[2:])
+		return
+	}
+	if (len(os.Args) > 1 && isHelpFlag(os.Args[1])) {
+		printUsage(stdout)
+		return
+	}
+	runPhase(os.Args[1:])
+}
+
+// moonphase phase -date 2024-03-25, and moonphase without a subcommand
+func runPhase(args []string) {
+	runPhaseLookup("phase", args)
+}
+
+// moonphase range 2024-03-01 2024-03-31, a phase per day like -from and -to
+func runRange(args []string) {
+	runPhaseLookup("range", args)
+}
+
+// the flags of phase and range, read once they have been parsed
+type phaseOptions struct {
+	plaintextFlag        *bool
+	emojiStyleFlag       *string
+	saveFileFlag         *string
+	outFlag              *string
+	appendFlag           *bool
+	modeFlag             *string
+	changedOnlyFlag      *bool
+	exitChangedFlag      *bool
+	stateFileFlag        *string
+	segmentFlag          *bool
+	showIlluminationFlag *bool
+	rawFlag              *bool
+	rawUrlOnlyFlag       *bool
+	offlineFlag          *bool
+	primaryOnlyFlag      *bool
+	dateFlag             string
+	fromFlag             string
+	toFlag               string
+	nowFileFlag          *string
+	nowFileMaxAgeFlag    *time.Duration
+	nightOnlyFlag        *bool
+	nightStartFlag       *string
+	nightEndFlag         *string
+	textfileMetricsFlag  *string
+	showNextFlag         *bool
+	showAgeFlag          *bool
+	templateFlag         *string
+	coordsFlag           *string
+	placeFlag            *string
+	geocoderFlag         *string
+	geocoderURLFlag      *string
+	pipefailExitFlag     *bool
+	applyClientFlags     func()
+	checkHorizon         func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation          func() *time.Location
+	getHemisphere        func() moonphase.Hemisphere
+	getLanguage          func() string
+	applyQuiet           func()
+}
+
+// defines the flags of phase and range on flags, for the command to parse and for completion to list
+func definePhaseFlags(flags *flag.FlagSet) *phaseOptions {
+	options := &phaseOptions{}
+	// prefer plaintext or emoji output? defualts to emoji
+	options.plaintextFlag = flags.Bool("plaintext", false, message("Get result in plain english."))
+	options.emojiStyleFlag = flags.String("emoji-style", "auto", message("Emoji presentation: auto, emoji, text, shortcode, nerdfont or ascii"))
+	// output file to cache daily phase info, empty for $HOME/.moonphase
+	options.saveFileFlag = flags.String("savefile", "", message("File to persist output to, defaults to ~/.moonphase"))
+	// write the result to a file instead of stdout
+	options.outFlag = flags.String("o", "-", message("File to write the result to, - for stdout"))
+	options.appendFlag = flags.Bool("append", false, message("Append the result to the -o file instead of replacing it"))
+	options.modeFlag = flags.String("mode", "0644", message("Permissions for the -o file"))
+	// only print when the output differs from the last run, for append-to-log cron jobs
+	options.changedOnlyFlag = flags.Bool("changed-only", false, message("Print nothing if the result is the same as last run."))
+	options.exitChangedFlag = flags.Bool("exit-changed", false, message("With -changed-only, exit 5 when the result changed."))
+	options.stateFileFlag = flags.String("state-file", "", message("File remembering the last result for -changed-only, defaults to ~/.moonphase-state"))
+	// print which quarter-to-quarter stretch of the cycle the date is in
+	options.segmentFlag = flags.Bool("segment", false, message("Print the segment of the cycle, like first-quarter-to-full, instead of the phase."))
+	options.showIlluminationFlag = flags.Bool("show-illumination", false, message("Append the approximate illuminated percentage, like \"Waxing Gibbous (73%)\"."))
+	// show the API request itself, for debugging or piping into jq
+	options.rawFlag = flags.Bool("raw", false, message("Print the raw USNO API response instead of the phase."))
+	options.rawUrlOnlyFlag = flags.Bool("raw-url-only", false, message("Print the USNO API URL that would be requested, without fetching it."))
+	// compute the phase from the mean lunar cycle, for when the API can't be reached
+	options.offlineFlag = flags.Bool("offline", false, message("Calculate the phase locally instead of asking the USNO API."))
+	// report intermediate days as the most recent primary phase
+	options.primaryOnlyFlag = flags.Bool("primary-only", false, message("Only report the four primary phases."))
+	// store passed date, default to current date in current time one
+	flags.StringVar(&options.dateFlag, "date", "", message("Date to get phase for, defaults to today"))
+	// print a phase per day instead, for month views
+	flags.StringVar(&options.fromFlag, "from", "", message("First date of a range to print a phase per day for"))
+	flags.StringVar(&options.toFlag, "to", "", message("Last date of the -from range, inclusive"))
+	// trusted clock for machines whose system clock can't be relied on
+	options.nowFileFlag = flags.String("now-file", "", message("File holding a trusted Unix timestamp to use as the current time"))
+	options.nowFileMaxAgeFlag = flags.Duration("now-file-max-age", defaultNowFileMaxAge, message("Ignore the -now-file if it's older than this"))
+	// only show the moon in the evening, for status bars
+	options.nightOnlyFlag = flags.Bool("night-only", false, message("Print nothing during local daytime, from sunrise to sunset with -coords or -place."))
+	options.nightStartFlag = flags.String("night-start", "18:00", message("Local time night begins for -night-only without -coords or -place"))
+	options.nightEndFlag = flags.String("night-end", "06:00", message("Local time night ends for -night-only without -coords or -place"))
+	// one-shot metrics for the node_exporter textfile collector
+	options.textfileMetricsFlag = flags.String("textfile-metrics", "", message("Also write the phase as Prometheus metrics to this file"))
+	options.showNextFlag = flags.Bool("show-next", false, message("Also print how many days until the next primary phase"))
+	options.showAgeFlag = flags.Bool("show-age", false, message("Append the moon's age in days since the last New Moon, like \"Waxing Gibbous (age 10.4 days)\"."))
+	options.templateFlag = flags.String("template", "", message("Go template for the output, like \"{{.Emoji}} {{.Illumination}}%\", see the README for its fields"))
+	// moonrise and moonset for a location, asked of the USNO API separately from the phase
+	options.coordsFlag = flags.String("coords", "", message("Also print moonrise, transit and moonset at this latitude,longitude, like 38.88,-77.00"))
+	options.placeFlag = flags.String("place", "", message("Like -coords, for a place named like \"Portland, OR\""))
+	options.geocoderFlag = flags.String("geocoder", "offline", message("What finds the -place: offline, the built-in list of major cities, or nominatim"))
+	options.geocoderURLFlag = flags.String("geocoder-url", defaultNominatimURL, message("Nominatim instance -geocoder nominatim asks"))
+	options.pipefailExitFlag = flags.Bool("pipefail-exit", false, message("Exit 141 instead of 0 when stdout is closed early"))
+	flags.BoolVar(&verbose, "verbose", false, message("Log what the lookup is doing to stderr."))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.getHemisphere = addHemisphereFlag(flags)
+	options.getLanguage = addLanguageFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// the phase lookup behind phase and range, which takes the -from and -to dates as arguments too
+func runPhaseLookup(name string, args []string) {
+	phaseFlags := newFlagSet(name)
+	options := definePhaseFlags(phaseFlags)
+	// range's dates may come before, between or after the flags
+	parseWithPositionals(phaseFlags, args)
+	options.applyQuiet()
+	applyConfig(phaseFlags)
+	normalizeStringFlags(phaseFlags)
+	options.applyClientFlags()
+	// the home directory is only looked up once the flags are parsed, and only if they need it
+	if (*options.saveFileFlag == "") {
+		*options.saveFileFlag = homeFile(".moonphase")
+	}
+	if (*options.stateFileFlag == "") {
+		*options.stateFileFlag = homeFile(".moonphase-state")
+	}
+	var dateArgs []string
+	for _, arg := range phaseFlags.Args() {
+		dateArgs = append(dateArgs, strings.TrimSpace(arg))
+	}
+	if (name == "range") {
+		if (len(dateArgs) > 0 && (options.fromFlag != "" || options.toFlag != "")) {
+			fatal("range takes its dates as arguments or as -from and -to, not both")
+		}
+		if (len(dateArgs) > 0) {
+			if (len(dateArgs) != 2) {
+				fatalf(message("range takes a first and last date, got %q"), strings.Join(dateArgs, " "))
+			}
+			options.fromFlag, options.toFlag = dateArgs[0], dateArgs[1]
+		}
+		if (options.fromFlag == "" || options.toFlag == "") {
+			fatal("range needs a first and last date, like moonphase range 2024-03-01 2024-03-31")
+		}
+	} else if (len(dateArgs) > 0) {
+		fatalf(message("%s takes no arguments, got %q, see moonphase help for the commands"), name, strings.Join(dateArgs, " "))
+	}
+	if (*options.pipefailExitFlag) {
+		brokenPipeExit = 141
+	}
+	outputMode, err := parseOutputMode(*options.modeFlag)
+	if err != nil {
+		fatal(err)
+	}
+	emojiStyle, err := parseEmojiStyle(*options.emojiStyleFlag)
+	if err != nil {
+		fatal(err)
+	}
+	hemisphere := options.getHemisphere()
+	lang := options.getLanguage()
+	var reportTemplate *template.Template
+	if (*options.templateFlag != "") {
+		if (*options.showIlluminationFlag || *options.showNextFlag || *options.showAgeFlag) {
+			fatal("-template has .Illumination, .NextPhase and .Age, it can't be combined with -show-illumination, -show-next or -show-age")
+		}
+		if (*options.segmentFlag || *options.rawFlag || *options.rawUrlOnlyFlag || options.fromFlag != "" || options.toFlag != "") {
+			fatal("-template is for a single day's phase, it can't be combined with -segment, -raw, -raw-url-only, -from or -to")
+		}
+		reportTemplate, err = parseReportTemplate(*options.templateFlag)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	var lat, lon float64
+	if (*options.placeFlag != "") {
+		if (*options.coordsFlag != "") {
+			fatal("-place and -coords both give the location, use one of them")
+		}
+		if (*options.offlineFlag) {
+			fatal("-place needs the USNO API for the rise and set times, it can't be combined with -offline")
+		}
+		lat, lon, err = resolvePlace(ctx, *options.placeFlag, *options.geocoderFlag, *options.geocoderURLFlag, *options.stateFileFlag)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if (*options.coordsFlag != "") {
+		if (*options.offlineFlag) {
+			fatal("-coords needs the USNO API, it can't be combined with -offline")
+		}
+		lat, lon, err = parseCoords(*options.coordsFlag)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	// local timezone
+	currentLocation := options.getLocation()
+	now := getNow(*options.nowFileFlag, *options.nowFileMaxAgeFlag).In(currentLocation)
+	if (*options.nightOnlyFlag) {
+		nightStart, err := parseClockTime(*options.nightStartFlag)
+		if err != nil {
+			fatalf("invalid -night-start: %v", err)
+		}
+		nightEnd, err := parseClockTime(*options.nightEndFlag)
+		if err != nil {
+			fatalf("invalid -night-end: %v", err)
+		}
+		night := isNightTime(now, nightStart, nightEnd)
+		if (*options.coordsFlag != "" || *options.placeFlag != "") {
+			night = isNightAt(now, lat, lon, *options.stateFileFlag, night)
+		}
+		// daytime, nothing to show and no need to look anything up. a template is rendered with
+		// no phase, so one written as JSON for a status bar still prints valid JSON
+		if (!night) {
+			if (reportTemplate != nil) {
+				daytime, err := renderReport(reportTemplate, phaseReport{})
+				if err != nil {
+					fatal(err)
+				}
+				printResult(daytime, *options.outFlag, *options.appendFlag, outputMode)
+			}
+			os.Exit(0)
+		}
+	}
+	// convert date string to real date
+	dayFromFlag := moonphase.NewDayKey(now)
+	if (options.dateFlag != "") {
+		dayFromFlag, err = parseDateFlag(options.dateFlag, now, currentLocation)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	dateFromFlag := dayFromFlag.Time(currentLocation)
+	debugf("looking up %s in %s", dayFromFlag, currentLocation)
+	options.checkHorizon(dayFromFlag, moonphase.NewDayKey(now))
+	// -from and -to are checked once they're parsed below
+	if (!*options.offlineFlag && options.fromFlag == "") {
+		checkSourceCovers(dayFromFlag)
+	}
+	strategy := moonphase.SnapStrategy
+	if (*options.primaryOnlyFlag) {
+		strategy = moonphase.PrimaryOnlyStrategy
+	}
+	if (*options.offlineFlag && (*options.rawFlag || *options.rawUrlOnlyFlag)) {
+		fatal("-raw and -raw-url-only show the API request, they can't be combined with -offline")
+	}
+	if (client.Source != nil && (*options.rawFlag || *options.rawUrlOnlyFlag)) {
+		fatal("-raw and -raw-url-only show the API request, they need -source usno")
+	}
+	if (*options.rawFlag || *options.rawUrlOnlyFlag) {
+		startDay, numPhases := moonphase.FetchWindow(dateFromFlag, moonphase.FetchStrategy{Future: 1})
+		apiUrl := client.GetMoonDataURL(startDay, numPhases)
+		if (*options.rawUrlOnlyFlag) {
+			fmt.Fprintln(stdout, apiUrl)
+			return
+		}
+		_, body, err := client.GetRawMoonData(ctx, apiUrl)
+		if err != nil {
+			fatal(err)
+		}
+		if _, err := stdout.Write(body); err != nil {
+			fatalOutputError(err)
+		}
+		return
+	}
+	if (*options.segmentFlag) {
+		// today is placed by the current moment, any other date by its start
+		segmentTime := dateFromFlag
+		if (options.dateFlag == "") {
+			segmentTime = now
+		}
+		var segment moonphase.CycleSegment
+		if (*options.offlineFlag) {
+			segment, err = moonphase.GetCycleSegment(segmentTime, moonphase.MeanPhases(segmentTime))
+		} else {
+			segment, err = client.GetSegmentForDate(ctx, segmentTime)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		printResult(segment.Label, *options.outFlag, *options.appendFlag, outputMode)
+		return
+	}
+	if (options.fromFlag != "" || options.toFlag != "") {
+		if (options.fromFlag == "" || options.toFlag == "") {
+			fatal("-from and -to need each other")
+		}
+		if (options.dateFlag != "" || *options.segmentFlag) {
+			fatal("-from and -to can't be combined with -date or -segment")
+		}
+		fromDay, err := parseDateFlag(options.fromFlag, now, currentLocation)
+		if err != nil {
+			fatal(err)
+		}
+		toDay, err := parseDateFlag(options.toFlag, now, currentLocation)
+		if err != nil {
+			fatal(err)
+		}
+		options.checkHorizon(fromDay, moonphase.NewDayKey(now))
+		options.checkHorizon(toDay, moonphase.NewDayKey(now))
+		if (!*options.offlineFlag) {
+			checkSourceCovers(fromDay, toDay)
+		}
+		var days []moonphase.DailyPhase
+		if (*options.offlineFlag) {
+			if (toDay.Before(fromDay)) {
+				fatalf(message("range ends on %s, before it starts on %s"), toDay, fromDay)
+			}
+			for day := fromDay; !day.After(toDay); day = day.AddDays(1) {
+				date := day.Time(currentLocation)
+				phase, err := moonphase.GetCurrentPhase(date, moonphase.MeanPhases(date), strategy)
+				if err != nil {
+					fatal(err)
+				}
+				days = append(days, moonphase.DailyPhase{Date: day, Phase: phase})
+			}
+		} else {
+			days, err = client.GetPhasesForRange(ctx, fromDay.Time(currentLocation), toDay.Time(currentLocation), strategy)
+			if err != nil {
+				fatal(err)
+			}
+		}
+		lines := make([]string, 0, len(days))
+		for _, day := range days {
+			lines = append(lines, fmt.Sprintf("%s %s", day.Date, getOutput(day.Phase, *options.plaintextFlag, lang, emojiStyle, hemisphere)))
+		}
+		printResult(strings.Join(lines, "\n"), *options.outFlag, *options.appendFlag, outputMode)
+		return
+	}
+	// read from the save file location and check for cached moon phase
+	// the save file only holds API snap results, so primary-only, offline and -source lookups skip it
+	useSaveFile := strategy == moonphase.SnapStrategy && !*options.offlineFlag && client.Source == nil
+	saveFileContent := ""
+	if (useSaveFile) {
+		saveFileContent = loadSaveFile(*options.saveFileFlag)
+	}
+	var phase moonphase.Phase
+	if (*options.offlineFlag) {
+		offlinePhase, err := moonphase.GetCurrentPhase(dateFromFlag, moonphase.MeanPhases(dateFromFlag), strategy)
+		if err != nil {
+			fatal(err)
+		}
+		phase = offlinePhase
+		debugf("calculated %s offline", phase)
+	}
+	if (saveFileContent != "") {
+		entries, err := parseSaveFile(saveFileContent)
+		// a corrupt save file is only a cache miss, the fetch below rewrites it
+		if err != nil {
+			log.Printf("warning: ignoring %s: %v", *options.saveFileFlag, err)
+		} else if entry, found := entries[dayFromFlag]; (!found) {
+			debugf("%s doesn't hold %s", *options.saveFileFlag, dayFromFlag)
+		} else if (isSaveFresh(entry, now)) {
+			// if the save file contains the phase for the requested date, use it
+			phase = entry.phase
+			debugf("using %s from %s", phase, *options.saveFileFlag)
+		}
+	} else if (useSaveFile) {
+		debugf("no save file at %s", *options.saveFileFlag)
+	}
+	// otherwise fetch a new phase from the API for the given date
+	if (phase == "") {
+		debugf("fetching %s from %s, unless %q has it cached", dayFromFlag, client.BaseURL, client.CacheDir)
+		fetchedPhase, err := client.GetPhaseForDate(ctx, dateFromFlag, strategy)
+		if err != nil {
+			fatal(err)
+		}
+		phase = fetchedPhase
+		// cache result to local save file
+		if (useSaveFile) {
+			savePhaseToFile(dayFromFlag, phase, now, *options.saveFileFlag)
+		}
+	}
+	if (*options.textfileMetricsFlag != "") {
+		// timed like -segment, the current moment today, the start of any other date
+		metricsTime := now
+		if (options.dateFlag != "") {
+			metricsTime = dateFromFlag
+		}
+		if err := writeTextfileMetrics(*options.textfileMetricsFlag, phase, metricsTime, *options.offlineFlag); err != nil {
+			fatal(err)
+		}
+	}
+	// illumination and age are timed like -segment, the current moment today, the start of any other date
+	illuminationTime := now
+	if (options.dateFlag != "") {
+		illuminationTime = dateFromFlag
+	}
+	lookupIllumination := func() float64 {
+		if (*options.offlineFlag) {
+			segment, err := moonphase.GetCycleSegment(illuminationTime, moonphase.MeanPhases(illuminationTime))
+			if err != nil {
+				fatal(err)
+			}
+			return moonphase.SegmentIllumination(segment)
+		}
+		illumination, err := client.GetIllumination(ctx, illuminationTime)
+		if err != nil {
+			fatal(err)
+		}
+		return illumination
+	}
+	lookupAge := func() float64 {
+		var age float64
+		if (*options.offlineFlag) {
+			age, err = moonphase.GetMoonAge(illuminationTime, moonphase.MeanPhases(illuminationTime))
+		} else {
+			age, err = client.GetAge(ctx, illuminationTime)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return age
+	}
+	lookupResult := func() moonphase.PhaseResult {
+		var result moonphase.PhaseResult
+		if (*options.offlineFlag) {
+			result, err = moonphase.GetPhaseResult(dateFromFlag, moonphase.MeanPhases(dateFromFlag), strategy)
+		} else {
+			result, err = client.GetPhaseResultForDate(ctx, dateFromFlag, strategy)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return result
+	}
+	phaseOutput := getOutput(phase, *options.plaintextFlag, lang, emojiStyle, hemisphere)
+	if (*options.showIlluminationFlag) {
+		phaseOutput = fmt.Sprintf("%s (%.0f%%)", phaseOutput, lookupIllumination() * 100)
+	}
+	if (*options.showAgeFlag) {
+		phaseOutput = fmt.Sprintf("%s (age %.1f days)", phaseOutput, lookupAge())
+	}
+	if (*options.showNextFlag) {
+		// only today's next phase is counted down from the current moment
+		var countdownFrom time.Time
+		if (options.dateFlag == "") {
+			countdownFrom = now
+		}
+		phaseOutput = fmt.Sprintf("%s %s", phaseOutput, formatNextPhase(lookupResult().Next, countdownFrom, *options.plaintextFlag, lang, emojiStyle, hemisphere))
+	}
+	if (reportTemplate != nil) {
+		phaseOutput, err = renderReport(reportTemplate, newPhaseReport(lookupResult(), lookupIllumination(), lookupAge(), lang, emojiStyle, hemisphere))
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if (*options.coordsFlag != "" || *options.placeFlag != "") {
+		times, err := client.RiseSet(ctx, dateFromFlag, lat, lon)
+		if err != nil {
+			fatal(err)
+		}
+		phaseOutput = fmt.Sprintf("%s\n%s", phaseOutput, formatRiseSet(times))
+	}
+	if (*options.changedOnlyFlag) {
+		// keyed by output style so switching between emoji and plaintext still prints
+		stateKey := "last-output-emoji"
+		if (reportTemplate != nil) {
+			stateKey = "last-output-template"
+		} else if (*options.plaintextFlag) {
+			stateKey = "last-output-plaintext"
+		}
+		if (!recordChangedOutput(*options.stateFileFlag, stateKey, phaseOutput)) {
+			os.Exit(0)
+		}
+		printResult(phaseOutput, *options.outFlag, *options.appendFlag, outputMode)
+		if (*options.exitChangedFlag) {
+			os.Exit(exitChanged)
+		}
+		return
+	}
+	// print output
+	printResult(phaseOutput, *options.outFlag, *options.appendFlag, outputMode)
+}
+
+// prints the result line to stdout or writes it to the -o file
+func printResult(result string, path string, appendMode bool, mode os.FileMode) {
+	if stats := client.CacheStats(); (stats != moonphase.CacheStats{}) {
+		debugf("the cache answered %d lookups, missed %d, refreshed %d and failed %d with a cached error", stats.Hits, stats.Misses, stats.Refreshes, stats.Failures)
+	}
+	if stats := client.UpstreamStats(); (stats.Requests > 0) {
+		debugf("sent the API %d requests, %d of them failed", stats.Requests, stats.Errors)
+	}
+	err := writeOutput(path, appendMode, mode, []byte(result + "\n"))
+	if err != nil {
+		fatalOutputError(err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// set in the environment of the test binary when runMoonphase runs it as moonphase
+const runAsMoonphaseEnv string = "MOONPHASE_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if (os.Getenv(runAsMoonphaseEnv) != "") {
+		os.Args = append([]string{"moonphase"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// the output and exit status of a moonphase run
+type moonphaseRun struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// runs the test binary as moonphase with args, in dir with no config file and a home of its own
+func runMoonphase(t *testing.T, dir string, args ...string) moonphaseRun {
+	t.Helper()
+	return runMoonphaseWithInput(t, dir, "", args...)
+}
+
+// runs the test binary as moonphase like runMoonphase, reading input on stdin
+func runMoonphaseWithInput(t *testing.T, dir string, input string, args ...string) moonphaseRun {
+	t.Helper()
+	command := exec.Command(os.Args[0], args...)
+	command.Dir = dir
+	command.Stdin = strings.NewReader(input)
+	command.Env = append(os.Environ(), runAsMoonphaseEnv + "=1", "HOME=" + dir, "MOONPHASE_CONFIG=" + filepath.Join(dir, "no-config"))
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	err := command.Run()
+	run := moonphaseRun{stdout: stdout.String(), stderr: stderr.String()}
+	var exitErr *exec.ExitError
+	if (errors.As(err, &exitErr)) {
+		run.code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+// -show-next counts calendar days in -timezone, so the same date is a day closer to the Full Moon
+// in Honolulu, where it's the evening of the 24th
+func TestShowNext(t *testing.T) {
+	for zone, want := range map[string]string{
+		"UTC":              "Waxing Gibbous — 5 days until Full Moon (Mar 25)\n",
+		"Pacific/Honolulu": "Waxing Gibbous — 4 days until Full Moon (Mar 24)\n",
+		"Asia/Tokyo":       "Waxing Gibbous — 5 days until Full Moon (Mar 25)\n",
+	} {
+		run := runMoonphase(t, t.TempDir(), "-date", "2024-03-20", "-plaintext", "-show-next", "-timezone", zone, "-source", "file", "-source-file", recordedUSNOFile(t))
+		if (run.code != 0 || run.stdout != want) {
+			t.Errorf("%s: exit %d, printed %q, want %q\n%s", zone, run.code, run.stdout, want, run.stderr)
+		}
+	}
+}
+
+// the New Moon of 2024-03-10 is at 09:00 UT, so the start of the 10th is nearly a whole lunation
+// old in UTC and Tokyo and just past it in Honolulu, with the API's phases or offline
+func TestShowAgeNearNewMoon(t *testing.T) {
+	for zone, want := range map[string]string{
+		"UTC":              "New Moon (age 29.0 days)\n",
+		"Pacific/Honolulu": "New Moon (age 0.0 days)\n",
+		"Asia/Tokyo":       "New Moon (age 28.7 days)\n",
+	} {
+		run := runMoonphase(t, t.TempDir(), "-date", "2024-03-10", "-plaintext", "-show-age", "-timezone", zone, "-source", "file", "-source-file", recordedUSNOFile(t))
+		if (run.code != 0 || run.stdout != want) {
+			t.Errorf("%s: exit %d, printed %q, want %q\n%s", zone, run.code, run.stdout, want, run.stderr)
+		}
+	}
+	for zone, want := range map[string]string{
+		"UTC":              "New Moon (age 29.1 days)\n",
+		"Pacific/Honolulu": "New Moon (age 0.0 days)\n",
+	} {
+		run := runMoonphase(t, t.TempDir(), "-date", "2024-03-10", "-plaintext", "-show-age", "-timezone", zone, "-offline")
+		if (run.code != 0 || run.stdout != want) {
+			t.Errorf("%s offline: exit %d, printed %q, want %q\n%s", zone, run.code, run.stdout, want, run.stderr)
+		}
+	}
+}
+
+// -segment with -date is timed at the start of the day in -timezone, which in Los Angeles is the
+// instant of the Full Moon of 2024-03-25 07:00 UT and the first moment of the segment after it,
+// and in Honolulu is an hour past the New Moon of 2024-03-10 09:00 UT
+func TestSegmentAtBoundaries(t *testing.T) {
+	tests := []struct {
+		date string
+		zone string
+		want string
+	}{
+		{"2024-03-25", "UTC", "first-quarter-to-full\n"},
+		{"2024-03-25", "Asia/Tokyo", "first-quarter-to-full\n"},
+		{"2024-03-25", "America/Los_Angeles", "full-to-last-quarter\n"},
+		{"2024-03-10", "America/Los_Angeles", "last-quarter-to-new\n"},
+		{"2024-03-10", "Pacific/Honolulu", "new-to-first-quarter\n"},
+	}
+	for _, test := range tests {
+		run := runMoonphase(t, t.TempDir(), "-date", test.date, "-segment", "-timezone", test.zone, "-source", "file", "-source-file", recordedUSNOFile(t))
+		if (run.code != 0 || run.stdout != test.want) {
+			t.Errorf("%s in %s: exit %d, printed %q, want %q\n%s", test.date, test.zone, run.code, run.stdout, test.want, run.stderr)
+		}
+	}
+}
+
+// -raw-url-only prints the request a lookup of -date would make, starting far enough before the
+// day to bracket it wherever it's the start of that day, and -raw refuses anything but the API
+func TestRawURL(t *testing.T) {
+	for _, zone := range []string{"UTC", "Asia/Tokyo", "Pacific/Honolulu"} {
+		run := runMoonphase(t, t.TempDir(), "-date", "2024-03-25", "-timezone", zone, "-raw-url-only")
+		if want := "https://aa.usno.navy.mil/api/moon/phases/date?date=2024-03-15&nump=3\n"; (run.code != 0 || run.stdout != want) {
+			t.Errorf("%s: exit %d, printed %q, want %q\n%s", zone, run.code, run.stdout, want, run.stderr)
+		}
+	}
+	for _, args := range [][]string{
+		{"-raw", "-offline"},
+		{"-raw-url-only", "-offline"},
+		{"-raw", "-source", "file", "-source-file", recordedUSNOFile(t)},
+		{"-raw-url-only", "-source", "offline"},
+	} {
+		run := runMoonphase(t, t.TempDir(), append([]string{"-date", "2024-03-25"}, args...)...)
+		if (run.code != exitInvalidArguments || run.stdout != "") {
+			t.Errorf("%q: exit %d, printed %q, want exit %d and nothing", args, run.code, run.stdout, exitInvalidArguments)
+		}
+	}
+}
+
+// -verbose only adds to stderr, stdout holds the same result as without it, whatever answers the
+// lookup
+func TestVerboseKeepsStdoutToTheResult(t *testing.T) {
+	dir := t.TempDir()
+	saveFile := filepath.Join(dir, "moonphase")
+	content := fmt.Sprintf("%s\n2024-03-25,Full Moon,%d\n", saveFileHeader, time.Now().Unix())
+	if err := os.WriteFile(saveFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args   []string
+		logged string
+	}{
+		{[]string{"-offline", "-date", "2024-03-25", "-plaintext", "-timezone", "UTC"}, "calculated Full Moon offline"},
+		{[]string{"-date", "2024-03-25", "-plaintext", "-timezone", "UTC", "-savefile", saveFile}, "using Full Moon from"},
+		{[]string{"-date", "2024-03-25", "-timezone", "UTC", "-source", "file", "-source-file", recordedUSNOFile(t), "-show-next"}, "fetching 2024-03-25"},
+		{[]string{"range", "-offline", "-plaintext", "-timezone", "UTC", "2024-03-24", "2024-03-26"}, "looking up"},
+	}
+	for _, test := range tests {
+		quiet := runMoonphase(t, dir, test.args...)
+		verboseArgs := append([]string{"-verbose"}, test.args...)
+		if (!strings.HasPrefix(test.args[0], "-")) {
+			verboseArgs = append([]string{test.args[0], "-verbose"}, test.args[1:]...)
+		}
+		verboseRun := runMoonphase(t, dir, verboseArgs...)
+		if (quiet.code != 0 || verboseRun.code != 0 || quiet.stdout == "") {
+			t.Fatalf("%q: exit %d, and %d with -verbose:\n%s", test.args, quiet.code, verboseRun.code, verboseRun.stderr)
+		}
+		if (verboseRun.stdout != quiet.stdout || strings.Contains(verboseRun.stdout, "debug:")) {
+			t.Errorf("%q: -verbose printed %q, want %q", test.args, verboseRun.stdout, quiet.stdout)
+		}
+		if (!strings.Contains(verboseRun.stderr, "debug: " + test.logged) || strings.Contains(quiet.stderr, "debug:")) {
+			t.Errorf("%q: want %q logged only with -verbose, got %q and %q", test.args, test.logged, verboseRun.stderr, quiet.stderr)
+		}
+	}
+}
+
+// -date looks up the day given rather than today, through a lunation of the recorded USNO data,
+// and range prints each day asked for
+func TestDateIsLookedUp(t *testing.T) {
+	dir := t.TempDir()
+	source := []string{"-plaintext", "-timezone", "UTC", "-source", "file", "-source-file", recordedUSNOFile(t)}
+	today := runMoonphase(t, dir, append([]string{"-offline"}, source[:3]...)...)
+	for date, want := range map[string]string{
+		"2024-03-10": "New Moon",
+		"2024-03-13": "Waxing Crescent",
+		"2024-03-17": "First Quarter",
+		"2024-03-20": "Waxing Gibbous",
+		"2024-03-25": "Full Moon",
+		"2024-03-28": "Waning Gibbous",
+		"2024-04-02": "Last Quarter",
+		"2024-04-05": "Waning Crescent",
+	} {
+		run := runMoonphase(t, dir, append([]string{"-date", date}, source...)...)
+		if (run.code != 0 || run.stdout != want + "\n") {
+			t.Errorf("-date %s: exit %d, printed %q, want %s (today is %q)\n%s", date, run.code, run.stdout, want, today.stdout, run.stderr)
+		}
+	}
+	run := runMoonphase(t, dir, append(append([]string{"range"}, source...), "2024-03-13", "2024-03-20")...)
+	lines := strings.Split(strings.TrimSuffix(run.stdout, "\n"), "\n")
+	if (run.code != 0 || len(lines) != 8) {
+		t.Fatalf("range: exit %d, printed %q, want a line per day\n%s", run.code, run.stdout, run.stderr)
+	}
+	for i, line := range lines {
+		if day := fmt.Sprintf("2024-03-%02d ", 13 + i); (!strings.HasPrefix(line, day)) {
+			t.Errorf("range line %d is %q, want it for %s", i + 1, line, day)
+		}
+	}
+	if (lines[0] != "2024-03-13 Waxing Crescent" || lines[4] != "2024-03-17 First Quarter" || lines[7] != "2024-03-20 Waxing Gibbous") {
+		t.Errorf("range printed %q", lines)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the language help and errors are printed in, set from -lang before any flags are defined so
+// their usage is already translated when -h prints it
+var messageLanguage string = "en"
+
+// the user-facing messages in each language other than English, keyed by the English message
+// itself, so an untranslated message falls back to the English one. es is complete, fr and de
+// only have the digest's words
+var catalogs = map[string]map[string]string{
+	"es": {
+		// help
+		"Usage: moonphase [command] [flags]": "Uso: moonphase [comando] [opciones]",
+		"Without a command, or with only flags, moonphase runs phase and prints today's phase.": "Sin comando, o solo con opciones, moonphase ejecuta phase y muestra la fase de hoy.",
+		"Commands:": "Comandos:",
+		"Run moonphase <command> -h for the flags of a command.": "Ejecuta moonphase <comando> -h para ver las opciones de un comando.",
+		"Usage of %s:": "Uso de %s:",
+
+		// command summaries
+		"Print the phase for a day, what moonphase does without a command": "Muestra la fase de un día, lo que hace moonphase sin comando",
+		"Print a phase per day from one date to another": "Muestra una fase por día entre dos fechas",
+		"Write the phases between two dates to a CSV file": "Escribe las fases entre dos fechas en un archivo CSV",
+		"List the phase events before and after a date": "Lista las fases antes y después de una fecha",
+		"List the phase on a month and day across a range of years": "Lista la fase de un mes y día a lo largo de varios años",
+		"Print a filename for a day from its phase": "Muestra un nombre de archivo para un día según su fase",
+		"List the subcommands, providers, emoji styles and languages": "Lista los comandos, proveedores, estilos de emoji e idiomas",
+		"Print when a primary phase next happens": "Muestra cuándo ocurre la próxima fase principal",
+		"Print when a primary phase last happened": "Muestra cuándo ocurrió la última fase principal",
+		"Compare the phases two providers give between two dates": "Compara las fases de dos proveedores entre dos fechas",
+		"Compact or purge the -cache-dir": "Compacta o vacía el -cache-dir",
+		"Show the settings the config file gives and the values numeric ones allow": "Muestra los ajustes del archivo de configuración y los valores que admiten los numéricos",
+		"Summarize the moon over an ISO week in text, markdown or html": "Resume la luna de una semana ISO en texto, markdown o html",
+		"Write the upcoming primary phases to an iCalendar file": "Escribe las próximas fases principales en un archivo iCalendar",
+		"Write a config file from a few questions": "Escribe un archivo de configuración a partir de unas preguntas",
+		"Print the phases of dates given as arguments or on stdin": "Muestra las fases de las fechas dadas como argumentos o por stdin",
+		"Serve the phase as JSON over HTTP": "Sirve la fase como JSON por HTTP",
+		"List the commands": "Lista los comandos",
+		"Print a bash or zsh completion script": "Muestra un script de autocompletado para bash o zsh",
+
+		// flags shared by the commands
+		"Print nothing, not even errors, and report only through the exit status": "No muestra nada, ni siquiera errores, e informa solo con el código de salida",
+		"Give up on a USNO API request after this long": "Abandona una petición a la API del USNO tras este tiempo",
+		"Retry a failed USNO API request this many times": "Reintenta una petición fallida a la API del USNO estas veces",
+		"Directory to cache USNO API responses in, empty to disable": "Directorio donde guardar las respuestas de la API del USNO, vacío para desactivarlo",
+		"Give up on the whole command after this long, 0 for no limit": "Abandona el comando entero tras este tiempo, 0 para no tener límite",
+		"Where phase data comes from: %s": "De dónde vienen los datos de las fases: %s",
+		"With -source file, the saved USNO API response to read phases from": "Con -source file, la respuesta guardada de la API del USNO de la que leer las fases",
+		"now": "ahora",
+		"in %d minutes": "en %d minutos",
+		"%d minutes ago": "hace %d minutos",
+		"Fetch from the USNO API again rather than using cached responses": "Vuelve a pedir a la API del USNO en vez de usar las respuestas guardadas",
+		"Seed the retry jitter with a fixed value, so replayed runs repeat exactly": "Fija la semilla de la variación de los reintentos, para que las ejecuciones se repitan igual",
+		"Refuse dates more than this many years ago": "Rechaza fechas de hace más de estos años",
+		"Refuse dates more than this many years ahead": "Rechaza fechas de dentro de más de estos años",
+		"IANA zone to work out dates in, like America/New_York, defaults to the local zone": "Zona IANA en la que calcular las fechas, como America/New_York, por defecto la zona local",
+		"Go layout to read dates with first, like 2/1/2006 for day-first dates": "Formato de Go con el que leer primero las fechas, como 2/1/2006 para fechas con el día primero",
+		"Language for phase names: %s": "Idioma de los nombres de las fases: %s",
+		"Hemisphere the moon is seen from, north or south, south mirrors the emoji": "Hemisferio desde el que se ve la luna, north o south, south refleja el emoji",
+		"Print phase names without emoji": "Muestra los nombres de las fases sin emoji",
+		"Emoji presentation: auto, emoji, text, shortcode, nerdfont or ascii": "Presentación del emoji: auto, emoji, text, shortcode, nerdfont o ascii",
+		"Emoji presentation in the emoji field: auto, emoji, text or shortcode": "Presentación del emoji en el campo emoji: auto, emoji, text o shortcode",
+		"File holding a trusted Unix timestamp to use as the current time": "Archivo con una marca de tiempo Unix fiable que usar como hora actual",
+		"Ignore the -now-file if it's older than this": "Ignora el -now-file si es más antiguo que esto",
+		"Permissions for the written file": "Permisos del archivo escrito",
+
+		// phase and range
+		"Get result in plain english.": "Muestra el resultado como texto.",
+		"File to persist output to, defaults to ~/.moonphase": "Archivo donde guardar el resultado, por defecto ~/.moonphase",
+		"File to write the result to, - for stdout": "Archivo donde escribir el resultado, - para stdout",
+		"Append the result to the -o file instead of replacing it": "Añade el resultado al archivo -o en vez de reemplazarlo",
+		"Permissions for the -o file": "Permisos del archivo -o",
+		"Print nothing if the result is the same as last run.": "No muestra nada si el resultado es el mismo que la última vez.",
+		"With -changed-only, exit 5 when the result changed.": "Con -changed-only, sale con 5 cuando el resultado ha cambiado.",
+		"File remembering the last result for -changed-only, defaults to ~/.moonphase-state": "Archivo que recuerda el último resultado para -changed-only, por defecto ~/.moonphase-state",
+		"Print the segment of the cycle, like first-quarter-to-full, instead of the phase.": "Muestra el tramo del ciclo, como first-quarter-to-full, en vez de la fase.",
+		"Append the approximate illuminated percentage, like \"Waxing Gibbous (73%)\".": "Añade el porcentaje iluminado aproximado, como \"Gibosa creciente (73%)\".",
+		"Print the raw USNO API response instead of the phase.": "Muestra la respuesta sin procesar de la API del USNO en vez de la fase.",
+		"Print the USNO API URL that would be requested, without fetching it.": "Muestra la URL de la API del USNO que se pediría, sin pedirla.",
+		"Calculate the phase locally instead of asking the USNO API.": "Calcula la fase localmente en vez de preguntar a la API del USNO.",
+		"Only report the four primary phases.": "Informa solo de las cuatro fases principales.",
+		"Date to get phase for, defaults to today": "Fecha de la que obtener la fase, por defecto hoy",
+		"First date of a range to print a phase per day for": "Primera fecha de un intervalo del que mostrar una fase por día",
+		"Last date of the -from range, inclusive": "Última fecha del intervalo -from, incluida",
+		"Print nothing during local daytime, from sunrise to sunset with -coords or -place.": "No muestra nada durante el día local, del amanecer al atardecer con -coords o -place.",
+		"Local time night begins for -night-only without -coords or -place": "Hora local a la que empieza la noche para -night-only sin -coords ni -place",
+		"Local time night ends for -night-only without -coords or -place": "Hora local a la que acaba la noche para -night-only sin -coords ni -place",
+		"Also write the phase as Prometheus metrics to this file": "Escribe también la fase como métricas de Prometheus en este archivo",
+		"Also print how many days until the next primary phase": "Muestra también cuántos días faltan para la próxima fase principal",
+		"Append the moon's age in days since the last New Moon, like \"Waxing Gibbous (age 10.4 days)\".": "Añade la edad de la luna en días desde la última luna nueva, como \"Gibosa creciente (edad 10.4 días)\".",
+		"Go template for the output, like \"{{.Emoji}} {{.Illumination}}%\", see the README for its fields": "Plantilla de Go para el resultado, como \"{{.Emoji}} {{.Illumination}}%\", ver el README para sus campos",
+		"Also print moonrise, transit and moonset at this latitude,longitude, like 38.88,-77.00": "Muestra también la salida, el tránsito y la puesta de la luna en esta latitud,longitud, como 38.88,-77.00",
+		"Like -coords, for a place named like \"Portland, OR\"": "Como -coords, para un lugar con nombre como \"Portland, OR\"",
+		"What finds the -place: offline, the built-in list of major cities, or nominatim": "Qué encuentra el -place: offline, la lista incluida de grandes ciudades, o nominatim",
+		"Nominatim instance -geocoder nominatim asks": "Instancia de Nominatim a la que pregunta -geocoder nominatim",
+		"no place matches -place %q, check the spelling or give -coords instead": "ningún lugar coincide con -place %q, revisa la ortografía o usa -coords",
+		"-place %q could be %s, add the region or country to choose one": "-place %q puede ser %s, añade la región o el país para elegir uno",
+		"Log what the lookup is doing to stderr.": "Registra en stderr lo que hace la consulta.",
+
+		// the other commands
+		"First date to export, required": "Primera fecha que exportar, obligatoria",
+		"Last date to export, required": "Última fecha que exportar, obligatoria",
+		"Export a whole calendar year, instead of -start and -end": "Exporta un año natural entero, en vez de -start y -end",
+		"CSV file to write, - for stdout": "Archivo CSV que escribir, - para stdout",
+		"One row per phase event (events) or per day (daily)": "Una fila por fase (events) o por día (daily)",
+		"List the events from the mean lunar cycle, as they are (none) or with the API's instants where it has them (api)": "Lista las fases del ciclo lunar medio, tal cual (none) o con los instantes de la API donde los tenga (api)",
+		"Daily rows only report the four primary phases": "Las filas diarias solo informan de las cuatro fases principales",
+		"Append to an existing export as rows are written, skipping dates already in it": "Añade a una exportación existente según se escriben las filas, saltando las fechas que ya tiene",
+		"With -resume, start the export over instead of continuing it": "Con -resume, empieza la exportación de nuevo en vez de continuarla",
+		"Exit 141 instead of 0 when stdout is closed early": "Sale con 141 en vez de 0 cuando stdout se cierra antes de tiempo",
+		"File recording the parameters of -resume exports, defaults to ~/.moonphase-state": "Archivo que guarda los parámetros de las exportaciones -resume, por defecto ~/.moonphase-state",
+		"Number of phase events to list before and after the date": "Número de fases que listar antes y después de la fecha",
+		"Print the events as JSON": "Muestra las fases como JSON",
+		"Month and day to look up, like 06-15, defaults to today": "Mes y día que consultar, como 06-15, por defecto hoy",
+		"Years to list, like 1950-2000, required": "Años que listar, como 1950-2000, obligatorio",
+		"Ask the USNO API for each year instead of calculating the phases": "Pregunta a la API del USNO por cada año en vez de calcular las fases",
+		"Print the years and counts as JSON": "Muestra los años y los totales como JSON",
+		"Template for the filename, with .Date, .PhaseID, .Phase and .Illumination": "Plantilla del nombre de archivo, con .Date, .PhaseID, .Phase y .Illumination",
+		"Date to name, defaults to today": "Fecha que nombrar, por defecto hoy",
+		"Calculate the phase locally instead of asking the USNO API": "Calcula la fase localmente en vez de preguntar a la API del USNO",
+		"Calculate the phases locally instead of asking the USNO API": "Calcula las fases localmente en vez de preguntar a la API del USNO",
+		"Print the capabilities as JSON": "Muestra las capacidades como JSON",
+		"Date to search from, defaults to now": "Fecha desde la que buscar, por defecto ahora",
+		"First date to compare, required": "Primera fecha que comparar, obligatoria",
+		"Last date to compare, required": "Última fecha que comparar, obligatoria",
+		"Provider to compare against: %s": "Proveedor con el que comparar: %s",
+		"Provider to compare: %s": "Proveedor que comparar: %s",
+		"Output format: %s": "Formato de salida: %s",
+		"With purge, remove responses fetched longer ago than this": "Con purge, borra las respuestas pedidas hace más de esto",
+		"Only report the four primary phases": "Informa solo de las cuatro fases principales",
+		"Print the results as JSON, with the error of each date that failed": "Escribe los resultados como JSON, con el error de cada fecha que falló",
+		"Exit 0 when some dates failed, as long as one didn't": "Sale con 0 cuando algunas fechas fallaron, siempre que alguna no fallara",
+		"Months of upcoming phases to include": "Meses de próximas fases que incluir",
+		"Date the calendar starts on, defaults to today": "Fecha en la que empieza el calendario, por defecto hoy",
+		"iCalendar file to write, - for stdout": "Archivo iCalendar que escribir, - para stdout",
+		"ISO week to summarize, like 2024-W34, defaults to this week": "Semana ISO que resumir, como 2024-W34, por defecto esta semana",
+		"Format of the digest: %s": "Formato del resumen: %s",
+		"Address to serve HTTP on": "Dirección en la que servir HTTP",
+		"Bearer token requests need, except /healthz and /livez, none when empty": "Token bearer que necesitan las peticiones, salvo /healthz y /livez, ninguno si está vacío",
+		"Origins browsers may call the server from, comma-separated or *, CORS is off when empty": "Orígenes desde los que los navegadores pueden llamar al servidor, separados por comas o *, sin CORS si está vacío",
+		"Also serve the net/http/pprof profiles under /debug/pprof/": "Sirve también los perfiles de net/http/pprof en /debug/pprof/",
+		"Serve /debug/vars and the -debug-endpoints to other hosts than localhost too": "Sirve /debug/vars y los -debug-endpoints también a otros hosts además de localhost",
+		"OpenTelemetry collector to send traces of the requests to over OTLP/HTTP, like http://localhost:4318, none when empty": "Colector de OpenTelemetry al que enviar trazas de las peticiones por OTLP/HTTP, como http://localhost:4318, ninguno si está vacío",
+		"Log each day looked up to stderr": "Registra en stderr cada día consultado",
+
+		// errors
+		"invalid date %q, expected %s": "fecha no válida %q, se esperaba %s",
+		"YYYY-MM-DD, YYYY/MM/DD, MM/DD/YYYY, DD/MM/YYYY, today, tomorrow, yesterday, or days or weeks from today like +3d or -2w": "AAAA-MM-DD, AAAA/MM/DD, MM/DD/AAAA, DD/MM/AAAA, today, tomorrow, yesterday, o días o semanas desde hoy como +3d o -2w",
+		"ambiguous date %q, it could be %s or %s, write it as YYYY-MM-DD or set -date-layout, like -date-layout 2/1/2006 for day first": "fecha ambigua %q, puede ser %s o %s, escríbela como AAAA-MM-DD o usa -date-layout, como -date-layout 2/1/2006 para el día primero",
+		"Did you mean %s or %s?": "¿Querías decir %s o %s?",
+		"Choice": "Opción",
+		"-%s must be between %d and %d, got %d": "-%s debe estar entre %d y %d, se recibió %d",
+		"-%s must be between %s and %s, got %s": "-%s debe estar entre %s y %s, se recibió %s",
+		"-%s must be between %g and %g, got %g": "-%s debe estar entre %g y %g, se recibió %g",
+		"unknown -source %q, expected %s": "-source desconocido %q, se esperaba %s",
+		"use -source offline to calculate phases from the mean cycle instead": "usa -source offline para calcular las fases con el ciclo medio",
+		"give a -source-file covering it, or use -source usno or -source offline": "da un -source-file que lo cubra, o usa -source usno o -source offline",
+		"unknown -lang %q, expected one of %s": "-lang desconocido %q, se esperaba uno de %s",
+		"%w, raise %s to allow it": "%w, aumenta %s para permitirlo",
+		"invalid -timezone %q, expected an IANA zone like America/New_York": "-timezone no válido %q, se esperaba una zona IANA como America/New_York",
+		"unknown -emoji-style %q, expected one of %s": "-emoji-style desconocido %q, se esperaba uno de %s",
+		"unknown -hemisphere %q, expected north or south": "-hemisphere desconocido %q, se esperaba north o south",
+		"unknown command %q": "comando desconocido %q",
+		"unknown command %q, see moonphase help for the commands": "comando desconocido %q, ver moonphase help para los comandos",
+		"%s takes no arguments, got %q, see moonphase help for the commands": "%s no admite argumentos, se recibió %q, ver moonphase help para los comandos",
+		"range takes a first and last date, got %q": "range admite una primera y una última fecha, se recibió %q",
+		"range ends on %s, before it starts on %s": "range acaba el %s, antes de empezar el %s",
+		"invalid -week %q, expected a week like 2024-W34": "-week no válido %q, se esperaba una semana como 2024-W34",
+		"invalid -week %q, %d has no week %d": "-week no válido %q, %d no tiene semana %d",
+		"unknown -output %q, expected one of %s": "-output desconocido %q, se esperaba uno de %s",
+
+		// digest
+		"Moon digest for week %s": "Resumen lunar de la semana %s",
+		"Nights": "Noches",
+		"Night": "Noche",
+		"Phase": "Fase",
+		"Lit": "Iluminada",
+		"Primary phases": "Fases principales",
+		"No primary phases this week": "Ninguna fase principal esta semana",
+		"Darkest night": "Noche más oscura",
+		"Next full moon": "Próxima luna llena",
+		"Sun": "dom",
+		"Mon": "lun",
+		"Tue": "mar",
+		"Wed": "mié",
+		"Thu": "jue",
+		"Fri": "vie",
+		"Sat": "sáb",
+	},
+	"fr": {
+		"Moon digest for week %s": "Résumé lunaire de la semaine %s",
+		"Nights": "Nuits",
+		"Night": "Nuit",
+		"Lit": "Éclairée",
+		"Primary phases": "Phases principales",
+		"No primary phases this week": "Aucune phase principale cette semaine",
+		"Darkest night": "Nuit la plus sombre",
+		"Next full moon": "Prochaine pleine lune",
+		"Sun": "dim",
+		"Mon": "lun",
+		"Tue": "mar",
+		"Wed": "mer",
+		"Thu": "jeu",
+		"Fri": "ven",
+		"Sat": "sam",
+	},
+	"de": {
+		"Moon digest for week %s": "Mondübersicht für Woche %s",
+		"Nights": "Nächte",
+		"Night": "Nacht",
+		"Lit": "Beleuchtet",
+		"Primary phases": "Hauptphasen",
+		"No primary phases this week": "Keine Hauptphase in dieser Woche",
+		"Darkest night": "Dunkelste Nacht",
+		"Next full moon": "Nächster Vollmond",
+		"Sun": "So",
+		"Mon": "Mo",
+		"Tue": "Di",
+		"Wed": "Mi",
+		"Thu": "Do",
+		"Fri": "Fr",
+		"Sat": "Sa",
+	},
+}
+
+// returns the catalog for lang, a code like "es" or "es-MX", nil for English or a language
+// without one
+func catalogFor(lang string) map[string]string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); (i >= 0) {
+		lang = lang[:i]
+	}
+	return catalogs[lang]
+}
+
+// returns text, an English message, in messageLanguage, or as it is when there's no translation
+func message(text string) string {
+	if translated, found := catalogFor(messageLanguage)[text]; (found) {
+		return translated
+	}
+	return text
+}
+
+// formats the translation of format, like fmt.Sprintf
+func messagef(format string, v ...interface{}) string {
+	return fmt.Sprintf(message(format), v...)
+}
+
+// returns the language args ask for with -lang, or else the config file's lang, for the messages
+// printed before the command's own flags are parsed. anything after -- is an argument, not a flag
+func messageLanguageFor(args []string) string {
+	for i, arg := range args {
+		if (arg == "--") {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if (!strings.HasPrefix(arg, "-") || name != "lang") {
+			continue
+		}
+		if (hasValue) {
+			return value
+		}
+		if (i + 1 < len(args)) {
+			return args[i + 1]
+		}
+	}
+	if path, err := configPath(); (err == nil) {
+		if config, err := loadConfig(path); (err == nil && config["lang"] != "") {
+			return config["lang"]
+		}
+	}
+	return "en"
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// sets messageLanguage for the rest of the test
+func setMessageLanguage(t *testing.T, lang string) {
+	previous := messageLanguage
+	messageLanguage = lang
+	t.Cleanup(func() {
+		messageLanguage = previous
+	})
+}
+
+func TestMessageFallback(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+		want string
+	}{
+		{"es", "Print phase names without emoji", "Muestra los nombres de las fases sin emoji"},
+		{"es-MX", "Print phase names without emoji", "Muestra los nombres de las fases sin emoji"},
+		{"ES", "Nights", "Noches"},
+		// fr only has the digest's words, the rest stays English
+		{"fr", "Nights", "Nuits"},
+		{"fr", "Print phase names without emoji", "Print phase names without emoji"},
+		// a message no catalog has
+		{"es", "not a message anywhere", "not a message anywhere"},
+		{"en", "Print phase names without emoji", "Print phase names without emoji"},
+		{"xx", "Nights", "Nights"},
+	}
+	for _, test := range tests {
+		setMessageLanguage(t, test.lang)
+		if got := message(test.text); (got != test.want) {
+			t.Errorf("%s: message(%q) = %q, want %q", test.lang, test.text, got, test.want)
+		}
+	}
+}
+
+// a translation takes the same arguments as the English message, in the same order
+func TestCatalogFormats(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0-9]*[a-zA-Z%]`)
+	for lang, catalog := range catalogs {
+		for text, translated := range catalog {
+			if (strings.Join(verbs.FindAllString(text, -1), " ") != strings.Join(verbs.FindAllString(translated, -1), " ")) {
+				t.Errorf("%s: %q doesn't have the verbs of %q", lang, translated, text)
+			}
+		}
+	}
+}
+
+// every command's summary and flags have a Spanish translation
+func TestSpanishCatalogIsComplete(t *testing.T) {
+	for _, name := range subcommandNames() {
+		if _, found := catalogs["es"][subcommands[name].summary]; (!found) {
+			t.Errorf("%s: no translation of the summary %q", name, subcommands[name].summary)
+		}
+		setMessageLanguage(t, "en")
+		english := commandFlags(name, subcommands[name])
+		setMessageLanguage(t, "es")
+		spanish := commandFlags(name, subcommands[name])
+		for i, f := range english {
+			if (spanish[i].Usage == f.Usage) {
+				t.Errorf("%s -%s: no translation of %q", name, f.Name, f.Usage)
+			}
+		}
+	}
+}
+
+func TestMessageLanguageFor(t *testing.T) {
+	t.Setenv("MOONPHASE_CONFIG", t.TempDir() + "/no-config")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"phase", "-lang", "es"}, "es"},
+		{[]string{"-h", "--lang=es"}, "es"},
+		{[]string{"-date", "today", "-lang"}, "en"},
+		{[]string{"batch", "--", "-lang", "es"}, "en"},
+		{nil, "en"},
+	}
+	for _, test := range tests {
+		if got := messageLanguageFor(test.args); (got != test.want) {
+			t.Errorf("%q: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+// -lang translates -h and the errors of whole runs, wherever it is among the flags
+func TestTranslatedRuns(t *testing.T) {
+	run := runMoonphase(t, t.TempDir(), "phase", "-h", "-lang", "es")
+	if (!strings.Contains(run.stderr, "Uso de phase:") || !strings.Contains(run.stderr, "Muestra el resultado como texto.")) {
+		t.Errorf("phase -h -lang es isn't in Spanish:\n%s", run.stderr)
+	}
+	run = runMoonphase(t, t.TempDir(), "help", "-lang", "es")
+	if (!strings.Contains(run.stdout, "Comandos:") || !strings.Contains(run.stdout, "Lista los comandos")) {
+		t.Errorf("help -lang es isn't in Spanish:\n%s", run.stdout)
+	}
+	run = runMoonphase(t, t.TempDir(), "-lang", "es", "-offline", "-date", "nope")
+	if (run.code != exitInvalidArguments || !strings.Contains(run.stderr, `fecha no válida "nope", se esperaba AAAA-MM-DD`)) {
+		t.Errorf("exit %d, the bad date error isn't in Spanish:\n%s", run.code, run.stderr)
+	}
+	// fr has no translation of the error, so it's in English
+	run = runMoonphase(t, t.TempDir(), "-lang", "fr", "-offline", "-date", "nope")
+	if (!strings.Contains(run.stderr, `invalid date "nope", expected YYYY-MM-DD`)) {
+		t.Errorf("the bad date error doesn't fall back to English:\n%s", run.stderr)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the primary phases the time until the next one is reported for
+var metricsNextPhases = []moonphase.Phase{moonphase.NewMoon, moonphase.FullMoon}
+
+// returns when each primary phase next happens after now, through c or from the mean cycle when offline
+func getNextPhases(ctx context.Context, c *moonphase.Client, now time.Time, offline bool) (map[moonphase.Phase]time.Time, error) {
+	next := map[moonphase.Phase]time.Time{}
+	record := func(phase moonphase.Phase, instant time.Time) {
+		if (instant.After(now) && (next[phase].IsZero() || instant.Before(next[phase]))) {
+			next[phase] = instant
+		}
+	}
+	if (offline) {
+		// the mean phases around now and half a cycle later reach past the next of each phase
+		phases := append(moonphase.MeanPhases(now), moonphase.MeanPhases(now.Add(moonphase.MeanSynodicMonth / 2))...)
+		for _, phase := range phases {
+			instant, err := moonphase.GetPhaseInstant(phase)
+			if err != nil {
+				return nil, err
+			}
+			record(moonphase.Phase(phase.Phase), instant)
+		}
+		return next, nil
+	}
+	// four consecutive primary phases hold one of each
+	events, err := c.GetPhasesAround(ctx, now, 4)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		record(event.Phase, event.Instant.Time)
+	}
+	return next, nil
+}
+
+// formats the phase metrics in the Prometheus text exposition format
+func formatMetrics(phase moonphase.Phase, illumination float64, next map[moonphase.Phase]time.Time, now time.Time) string {
+	var metrics strings.Builder
+	fmt.Fprintln(&metrics, "# HELP moonphase_phase Whether the moon is in the phase, by phase_id.")
+	fmt.Fprintln(&metrics, "# TYPE moonphase_phase gauge")
+	for _, name := range moonphase.Phases {
+		value := 0
+		if (name == phase) {
+			value = 1
+		}
+		fmt.Fprintf(&metrics, "moonphase_phase{phase_id=%q} %d\n", moonphase.PhaseID(string(name)), value)
+	}
+	fmt.Fprintln(&metrics, "# HELP moonphase_illumination_ratio The approximate fraction of the moon lit, from 0 to 1.")
+	fmt.Fprintln(&metrics, "# TYPE moonphase_illumination_ratio gauge")
+	fmt.Fprintf(&metrics, "moonphase_illumination_ratio %.4f\n", illumination)
+	fmt.Fprintln(&metrics, "# HELP moonphase_days_to_next Days until the next occurrence of the primary phase, by phase_id.")
+	fmt.Fprintln(&metrics, "# TYPE moonphase_days_to_next gauge")
+	for _, name := range metricsNextPhases {
+		if (next[name].IsZero()) {
+			continue
+		}
+		fmt.Fprintf(&metrics, "moonphase_days_to_next{phase_id=%q} %.3f\n", moonphase.PhaseID(string(name)), next[name].Sub(now).Hours() / 24)
+	}
+	fmt.Fprintln(&metrics, "# HELP moonphase_last_success_timestamp_seconds When the metrics were last computed successfully.")
+	fmt.Fprintln(&metrics, "# TYPE moonphase_last_success_timestamp_seconds gauge")
+	fmt.Fprintf(&metrics, "moonphase_last_success_timestamp_seconds %d\n", now.Unix())
+	return metrics.String()
+}
+
+// looks up what the metrics report at now through c, or from the mean cycle when offline, and
+// formats them. -textfile-metrics and GET /metrics both come through here, so they name and
+// label everything the same
+func collectMetrics(ctx context.Context, c *moonphase.Client, phase moonphase.Phase, now time.Time, offline bool) (string, error) {
+	next, err := getNextPhases(ctx, c, now, offline)
+	if err != nil {
+		return "", err
+	}
+	var illumination float64
+	if (offline) {
+		segment, err := moonphase.GetCycleSegment(now, moonphase.MeanPhases(now))
+		if err != nil {
+			return "", err
+		}
+		illumination = moonphase.SegmentIllumination(segment)
+	} else {
+		illumination, err = c.GetIllumination(ctx, now)
+		if err != nil {
+			return "", err
+		}
+	}
+	return formatMetrics(phase, illumination, next, now), nil
+}
+
+// writes the phase metrics to path for the node_exporter textfile collector, replacing it atomically
+func writeTextfileMetrics(path string, phase moonphase.Phase, now time.Time, offline bool) error {
+	metrics, err := collectMetrics(ctx, client, phase, now, offline)
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, false, defaultOutputMode, []byte(metrics))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// a metric family as the text exposition format describes it
+type metricFamily struct {
+	help    string
+	kind    string
+	// the value of each series, keyed by its labels as written, like {phase_id="new-moon"}
+	samples map[string]float64
+}
+
+var metricSamplePattern = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\.)*"(?:,[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\.)*")*\})? (\S+)$`)
+var metricCommentPattern = regexp.MustCompile(`^# (HELP|TYPE) ([a-zA-Z_:][a-zA-Z0-9_:]*) (.+)$`)
+
+// parses text in the Prometheus text exposition format, failing t on anything a strict parser
+// refuses: a HELP or TYPE given twice or after the samples, a family's samples apart, an unknown
+// type, a repeated series, a value that isn't a number or no newline at the end
+func parseExposition(t *testing.T, text string) map[string]*metricFamily {
+	t.Helper()
+	if (!strings.HasSuffix(text, "\n")) {
+		t.Fatalf("doesn't end with a newline: %q", text)
+	}
+	families := map[string]*metricFamily{}
+	var current string
+	family := func(name string) *metricFamily {
+		if (families[name] == nil) {
+			families[name] = &metricFamily{samples: map[string]float64{}}
+		} else if (name != current) {
+			t.Fatalf("the %s family continues after another", name)
+		}
+		current = name
+		return families[name]
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if match := metricCommentPattern.FindStringSubmatch(line); (match != nil) {
+			metric := family(match[2])
+			if (len(metric.samples) > 0) {
+				t.Fatalf("line %d: %s of %s after its samples", i + 1, match[1], match[2])
+			}
+			if (match[1] == "HELP") {
+				if (metric.help != "") {
+					t.Fatalf("line %d: a second HELP for %s", i + 1, match[2])
+				}
+				metric.help = match[3]
+				continue
+			}
+			if (metric.kind != "") {
+				t.Fatalf("line %d: a second TYPE for %s", i + 1, match[2])
+			}
+			switch match[3] {
+			case "counter", "gauge", "histogram", "summary", "untyped":
+			default:
+				t.Fatalf("line %d: unknown type %q", i + 1, match[3])
+			}
+			metric.kind = match[3]
+			continue
+		}
+		match := metricSamplePattern.FindStringSubmatch(line)
+		if (match == nil) {
+			t.Fatalf("line %d isn't a sample, HELP or TYPE: %q", i + 1, line)
+		}
+		metric := family(match[1])
+		if _, found := metric.samples[match[2]]; (found) {
+			t.Fatalf("line %d: %s%s again", i + 1, match[1], match[2])
+		}
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			t.Fatalf("line %d: %v", i + 1, err)
+		}
+		metric.samples[match[2]] = value
+	}
+	return families
+}
+
+// the metrics at the same moment from the mean cycle offline and through a client answering from
+// it are the same, one gauge per phase with the day's phase set, and the lit fraction and the
+// days to the next New and Full Moon
+func TestCollectMetrics(t *testing.T) {
+	now := time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC)
+	offline, err := collectMetrics(context.Background(), nil, moonphase.WaxingGibbous, now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	online, err := collectMetrics(context.Background(), &moonphase.Client{Source: moonphase.OfflineProvider{}}, moonphase.WaxingGibbous, now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (offline != online) {
+		t.Errorf("offline:\n%s\nthrough a client:\n%s", offline, online)
+	}
+	families := parseExposition(t, offline)
+	phases := families["moonphase_phase"]
+	if (phases == nil || len(phases.samples) != len(moonphase.Phases)) {
+		t.Fatalf("want a moonphase_phase gauge per phase, got %+v", phases)
+	}
+	for _, phase := range moonphase.Phases {
+		want := 0.0
+		if (phase == moonphase.WaxingGibbous) {
+			want = 1
+		}
+		labels := `{phase_id="` + moonphase.PhaseID(string(phase)) + `"}`
+		if got, found := phases.samples[labels]; (!found || got != want) {
+			t.Errorf("moonphase_phase%s is %v, want %v", labels, got, want)
+		}
+	}
+	illumination := families["moonphase_illumination_ratio"].samples[""]
+	if (illumination < 0.75 || illumination > 1) {
+		t.Errorf("moonphase_illumination_ratio %v five days before the Full Moon", illumination)
+	}
+	next := families["moonphase_days_to_next"].samples
+	if (len(next) != 2 || next[`{phase_id="full-moon"}`] <= 0 || next[`{phase_id="full-moon"}`] > 7 || next[`{phase_id="new-moon"}`] < 15) {
+		t.Errorf("moonphase_days_to_next %v", next)
+	}
+	if got := families["moonphase_last_success_timestamp_seconds"].samples[""]; (got != float64(now.Unix())) {
+		t.Errorf("moonphase_last_success_timestamp_seconds %v, want %d", got, now.Unix())
+	}
+	for name, family := range families {
+		if (family.help == "" || family.kind != "gauge") {
+			t.Errorf("%s has HELP %q and TYPE %q, want both, as a gauge", name, family.help, family.kind)
+		}
+	}
+}
+
+// the file -textfile-metrics writes and what GET /metrics answers, both for now and from the mean
+// cycle, have the same families with the same HELP, TYPE and series, with the same values bar the
+// moments between the two
+func TestTextfileMetricsMatchServer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "moonphase.prom")
+	run := runMoonphase(t, dir, "-offline", "-timezone", "UTC", "-textfile-metrics", path)
+	if (run.code != 0) {
+		t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+	}
+	textfile, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := serveRequest(newTestServer("", ""), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if (response.Code != http.StatusOK) {
+		t.Fatalf("status %d: %s", response.Code, response.Body)
+	}
+	if got := response.Header().Get("Content-Type"); (got != "text/plain; version=0.0.4; charset=utf-8") {
+		t.Errorf("Content-Type %q", got)
+	}
+
+	written := parseExposition(t, string(textfile))
+	served := parseExposition(t, response.Body.String())
+	if (len(written) != len(served)) {
+		t.Errorf("the file has %d families, the server %d", len(written), len(served))
+	}
+	// the values that move, and how far they can in the time between the two
+	tolerances := map[string]float64{
+		"moonphase_illumination_ratio":             0.001,
+		"moonphase_days_to_next":                    0.002,
+		"moonphase_last_success_timestamp_seconds": 60,
+	}
+	for name, family := range written {
+		want := served[name]
+		if (want == nil) {
+			t.Errorf("the server doesn't have %s", name)
+			continue
+		}
+		if (family.help != want.help || family.kind != want.kind) {
+			t.Errorf("%s: the file has HELP %q TYPE %q, the server HELP %q TYPE %q", name, family.help, family.kind, want.help, want.kind)
+		}
+		if (len(family.samples) != len(want.samples)) {
+			t.Errorf("%s: the file has %v, the server %v", name, family.samples, want.samples)
+		}
+		for labels, value := range family.samples {
+			servedValue, found := want.samples[labels]
+			if (!found || math.Abs(value - servedValue) > tolerances[name]) {
+				t.Errorf("%s%s: the file has %v, the server %v", name, labels, value, servedValue)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// short names accepted for the primary phases, alongside their full names
+var primaryPhaseAliases = map[string]moonphase.Phase{
+	"new":   moonphase.NewMoon,
+	"first": moonphase.FirstQuarter,
+	"full":  moonphase.FullMoon,
+	"last":  moonphase.LastQuarter,
+}
+
+// parses a primary phase name like "full", "Full Moon" or "first-quarter", ignoring case
+func parsePrimaryPhaseArg(value string) (moonphase.Phase, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(value, "-", " ")), " "))
+	if alias, found := primaryPhaseAliases[normalized]; (found) {
+		return alias, nil
+	}
+	for _, phase := range primaryPhaseAliases {
+		if (strings.ToLower(phase.String()) == normalized) {
+			return phase, nil
+		}
+	}
+	return "", argumentError{fmt.Errorf("unknown phase %q, expected new, first, full or last", value)}
+}
+
+// an event closer than this either way is happening now
+const countdownNowWithin time.Duration = 2 * time.Minute
+
+// an event closer than this either way is counted down in minutes rather than days
+const countdownMinutesWithin time.Duration = 90 * time.Minute
+
+// phrases how far from now an event d away is in lang, negative for one that's past: "now" within
+// two minutes either side, else "in 47 minutes" or "12 minutes ago" within 90. returns "" further
+// out, where the caller counts days
+func humanizeCountdown(d time.Duration, lang string) string {
+	translate := func(text string) string {
+		if translated, found := catalogFor(lang)[text]; (found) {
+			return translated
+		}
+		return text
+	}
+	// to the nearest minute, the API only gives phase times to the minute
+	distance := absDuration(d).Round(time.Minute)
+	minutes := int(distance / time.Minute)
+	switch {
+	case distance < countdownNowWithin:
+		return translate("now")
+	case distance >= countdownMinutesWithin:
+		return ""
+	case d > 0:
+		return fmt.Sprintf(translate("in %d minutes"), minutes)
+	}
+	return fmt.Sprintf(translate("%d minutes ago"), minutes)
+}
+
+// formats the -show-next part of the output, like "— 3 days until Full Moon (Mar 25)", with the
+// next phase shown the same way as the day's. when now is set and the phase is under 90 minutes
+// away it's counted down instead, like "— Full Moon in 47 minutes (00:23)"
+func formatNextPhase(next moonphase.NextPrimaryPhase, now time.Time, plaintext bool, lang string, emojiStyle string, hemisphere moonphase.Hemisphere) string {
+	if (!now.IsZero()) {
+		if countdown := humanizeCountdown(next.Instant.Sub(now), lang); (countdown != "") {
+			return fmt.Sprintf("— %s %s (%s)", getOutput(next.Phase, plaintext, lang, emojiStyle, hemisphere), countdown, next.Instant.In(now.Location()).Format("15:04"))
+		}
+	}
+	days := "days"
+	if (next.DaysUntil == 1) {
+		days = "day"
+	}
+	return fmt.Sprintf("— %d %s until %s (%s)", next.DaysUntil, days, getOutput(next.Phase, plaintext, lang, emojiStyle, hemisphere), next.LocalDate.Time(time.UTC).Format("Jan 2"))
+}
+
+// the flags of next and prev, read once they have been parsed
+type searchOptions struct {
+	dateFlag         *string
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation      func() *time.Location
+	applyQuiet       func()
+}
+
+// defines the flags of next and prev on flags, for the command to parse and for completion to list
+func defineSearchFlags(flags *flag.FlagSet) *searchOptions {
+	options := &searchOptions{}
+	options.dateFlag = flags.String("date", "", message("Date to search from, defaults to now"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase next full, moonphase prev new
+func runNextOrPrevious(name string, args []string) {
+	searchFlags := newFlagSet(name)
+	options := defineSearchFlags(searchFlags)
+	// the phase may come before, between or after the flags
+	parseWithPositionals(searchFlags, args)
+	options.applyQuiet()
+	applyConfig(searchFlags)
+	normalizeStringFlags(searchFlags)
+	options.applyClientFlags()
+	if (searchFlags.NArg() > 1) {
+		fatalf("%s takes one phase, got %q", name, strings.Join(searchFlags.Args(), " "))
+	}
+	phaseArg := strings.TrimSpace(searchFlags.Arg(0))
+	if (phaseArg == "") {
+		fatalf("%s needs a phase: new, first, full or last", name)
+	}
+	phase, err := parsePrimaryPhaseArg(phaseArg)
+	if err != nil {
+		fatal(err)
+	}
+	location := options.getLocation()
+	now := time.Now().In(location)
+	from := now
+	if (*options.dateFlag != "") {
+		day, err := parseDateFlag(*options.dateFlag, now, location)
+		if err != nil {
+			fatal(err)
+		}
+		from = day.Time(location)
+	}
+	options.checkHorizon(moonphase.NewDayKey(from), moonphase.NewDayKey(now))
+	checkSourceCovers(moonphase.NewDayKey(from))
+
+	var instant time.Time
+	if (name == "next") {
+		instant, err = client.NextPhase(ctx, from, phase)
+	} else {
+		instant, err = client.PreviousPhase(ctx, from, phase)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if _, err := fmt.Fprintln(stdout, instant.In(location).Format("2006-01-02 15:04 MST")); err != nil {
+		fatalOutputError(err)
+	}
+}
+
+func runNext(args []string) {
+	runNextOrPrevious("next", args)
+}
+
+func runPrevious(args []string) {
+	runNextOrPrevious("prev", args)
+}
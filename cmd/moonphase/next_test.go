@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+func TestParsePrimaryPhaseArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want moonphase.Phase
+	}{
+		{"new", moonphase.NewMoon},
+		{"FULL", moonphase.FullMoon},
+		{"Full Moon", moonphase.FullMoon},
+		{" first  quarter ", moonphase.FirstQuarter},
+		{"last-quarter", moonphase.LastQuarter},
+		{"Last", moonphase.LastQuarter},
+		{"waxing gibbous", ""},
+		{"fullmoon", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		got, err := parsePrimaryPhaseArg(test.arg)
+		if (test.want == "") {
+			if (err == nil || exitCodeFor(err) != exitInvalidArguments) {
+				t.Errorf("%q: got %s %v, want an argument error", test.arg, got, err)
+			}
+			continue
+		}
+		if (err != nil || got != test.want) {
+			t.Errorf("%q: got %s %v, want %s", test.arg, got, err, test.want)
+		}
+	}
+}
+
+// next and prev print the exact time of the phase in -timezone, searching from the start of the
+// -date. the New Moon of March 10th is three hours after New York's clocks went forward
+func TestNextAndPrev(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"next", "full", "-timezone", "UTC"}, "2024-03-25 07:00 UTC\n"},
+		{[]string{"next", "-timezone", "UTC", "Full Moon"}, "2024-03-25 07:00 UTC\n"},
+		{[]string{"prev", "new", "-timezone", "America/New_York"}, "2024-03-10 05:00 EDT\n"},
+		{[]string{"prev", "new", "-timezone", "UTC"}, "2024-03-10 09:00 UTC\n"},
+		{[]string{"prev", "LAST", "-timezone", "Asia/Tokyo"}, "2024-03-04 00:23 JST\n"},
+		{[]string{"next", "first", "-timezone", "Asia/Tokyo"}, "2024-04-16 04:13 JST\n"},
+	}
+	for _, test := range tests {
+		args := append(test.args, "-date", "2024-03-20", "-source", "file", "-source-file", recordedUSNOFile(t))
+		run := runMoonphase(t, t.TempDir(), args...)
+		if (run.code != 0 || run.stdout != test.want) {
+			t.Errorf("%q: exit %d, printed %q, want %q\n%s", test.args, run.code, run.stdout, test.want, run.stderr)
+		}
+	}
+	run := runMoonphase(t, t.TempDir(), "next", "gibbous", "-source", "offline")
+	if (run.code != exitInvalidArguments || !strings.Contains(run.stderr, "expected new, first, full or last")) {
+		t.Errorf("an unknown phase: exit %d\n%s", run.code, run.stderr)
+	}
+}
+
+// the countdown either side of each threshold and across the event, rounded to the minute
+func TestHumanizeCountdown(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		lang string
+		want string
+	}{
+		{0, "en", "now"},
+		{89 * time.Second, "en", "now"},
+		{-89 * time.Second, "en", "now"},
+		{91 * time.Second, "en", "in 2 minutes"},
+		{-91 * time.Second, "en", "2 minutes ago"},
+		{47 * time.Minute, "en", "in 47 minutes"},
+		{47 * time.Minute + 31 * time.Second, "en", "in 48 minutes"},
+		{-12 * time.Minute, "en", "12 minutes ago"},
+		{89 * time.Minute, "en", "in 89 minutes"},
+		{89 * time.Minute + 29 * time.Second, "en", "in 89 minutes"},
+		{89 * time.Minute + 30 * time.Second, "en", ""},
+		{-90 * time.Minute, "en", ""},
+		{3 * time.Hour, "en", ""},
+		{0, "es", "ahora"},
+		{47 * time.Minute, "es", "en 47 minutos"},
+		{-12 * time.Minute, "es", "hace 12 minutos"},
+		// languages without a translation fall back to English
+		{47 * time.Minute, "xx", "in 47 minutes"},
+	}
+	for _, test := range tests {
+		if got := humanizeCountdown(test.d, test.lang); (got != test.want) {
+			t.Errorf("%s in %s: got %q, want %q", test.d, test.lang, got, test.want)
+		}
+	}
+}
+
+// -show-next counts down to a phase under 90 minutes away, in minutes and -lang, and in days
+// for a -date or a phase further off. Tokyo's Last Quarter is at 00:23 on March 4th
+func TestShowNextCountdown(t *testing.T) {
+	dir := t.TempDir()
+	tokyo := time.FixedZone("JST", 9 * 60 * 60)
+	source := []string{"-show-next", "-primary-only", "-plaintext", "-timezone", "Asia/Tokyo", "-source", "file", "-source-file", recordedUSNOFile(t)}
+	tests := []struct {
+		now  time.Time
+		args []string
+		want string
+	}{
+		{time.Date(2024, time.March, 3, 23, 40, 0, 0, tokyo), nil, "Full Moon — Last Quarter in 43 minutes (00:23)\n"},
+		{time.Date(2024, time.March, 3, 23, 40, 0, 0, tokyo), []string{"-lang", "es"}, "Luna llena — Cuarto menguante en 43 minutos (00:23)\n"},
+		{time.Date(2024, time.March, 3, 22, 0, 0, 0, tokyo), nil, "Full Moon — 1 day until Last Quarter (Mar 4)\n"},
+		{time.Date(2024, time.March, 3, 23, 40, 0, 0, tokyo), []string{"-date", "2024-03-03"}, "Full Moon — 1 day until Last Quarter (Mar 4)\n"},
+	}
+	for _, test := range tests {
+		nowFile := writeNowFile(t, t.TempDir(), test.now, 0)
+		run := runMoonphase(t, dir, append(append([]string{"-now-file", nowFile}, source...), test.args...)...)
+		if (run.code != 0 || run.stdout != test.want) {
+			t.Errorf("%s %q: exit %d, printed %q, want %q\n%s", test.now, test.args, run.code, run.stdout, test.want, run.stderr)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the phase on one year's occurrence of an onthisday date
+type onThisDayYear struct {
+	Year    int              `json:"year"`
+	Date    moonphase.DayKey `json:"date"`
+	Phase   moonphase.Phase  `json:"phase"`
+	PhaseID string           `json:"phase_id"`
+	// the phase name in -lang, only when it isn't English
+	Name    string           `json:"name,omitempty"`
+}
+
+// parses a -years range like 1950-2000, a single year is a range of one
+func parseYearRange(value string) (int, int, error) {
+	first, last, found := strings.Cut(value, "-")
+	if (!found) {
+		last = first
+	}
+	firstYear, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, argumentError{fmt.Errorf("invalid -years %q, expected a range like 1950-2000", value)}
+	}
+	lastYear, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, 0, argumentError{fmt.Errorf("invalid -years %q, expected a range like 1950-2000", value)}
+	}
+	if (lastYear < firstYear) {
+		return 0, 0, argumentError{fmt.Errorf("invalid -years %q, the range ends before it starts", value)}
+	}
+	return firstYear, lastYear, nil
+}
+
+// the flags of onthisday, read once they have been parsed
+type onThisDayOptions struct {
+	dateFlag         *string
+	yearsFlag        *string
+	apiFlag          *bool
+	jsonFlag         *bool
+	plaintextFlag    *bool
+	emojiStyleFlag   *string
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey)
+	getLocation      func() *time.Location
+	getHemisphere    func() moonphase.Hemisphere
+	getLanguage      func() string
+	applyQuiet       func()
+}
+
+// defines the flags of onthisday on flags, for the command to parse and for completion to list
+func defineOnThisDayFlags(flags *flag.FlagSet) *onThisDayOptions {
+	options := &onThisDayOptions{}
+	options.dateFlag = flags.String("date", "", message("Month and day to look up, like 06-15, defaults to today"))
+	options.yearsFlag = flags.String("years", "", message("Years to list, like 1950-2000, required"))
+	options.apiFlag = flags.Bool("api", false, message("Ask the USNO API for each year instead of calculating the phases"))
+	options.jsonFlag = flags.Bool("json", false, message("Print the years and counts as JSON"))
+	options.plaintextFlag = flags.Bool("plaintext", false, message("Print phase names without emoji"))
+	options.emojiStyleFlag = flags.String("emoji-style", "auto", message("Emoji presentation: auto, emoji, text, shortcode, nerdfont or ascii"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	options.getHemisphere = addHemisphereFlag(flags)
+	options.getLanguage = addLanguageFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase onthisday -date 06-15 -years 1950-2000
+func runOnThisDay(args []string) {
+	onThisDayFlags := newFlagSet("onthisday")
+	options := defineOnThisDayFlags(onThisDayFlags)
+	onThisDayFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(onThisDayFlags)
+	normalizeStringFlags(onThisDayFlags)
+	options.applyClientFlags()
+
+	if (*options.yearsFlag == "") {
+		fatal("onthisday needs -years")
+	}
+	firstYear, lastYear, err := parseYearRange(*options.yearsFlag)
+	if err != nil {
+		fatal(err)
+	}
+	if err := checkIntRange("years", firstYear, 1, 9999); err != nil {
+		fatal(err)
+	}
+	if err := checkIntRange("years", lastYear, 1, 9999); err != nil {
+		fatal(err)
+	}
+	emojiStyle, err := parseEmojiStyle(*options.emojiStyleFlag)
+	if err != nil {
+		fatal(err)
+	}
+	hemisphere := options.getHemisphere()
+	lang := options.getLanguage()
+	location := options.getLocation()
+	monthDay := time.Now().In(location).Format("01-02")
+	if (*options.dateFlag != "") {
+		monthDay = *options.dateFlag
+	}
+	// checked against a leap year so 02-29 is accepted
+	if _, err := moonphase.ParseDayKey("2000-" + monthDay); err != nil {
+		fatalf("invalid -date %q, expected a month and day like 06-15", monthDay)
+	}
+	today := moonphase.NewDayKey(time.Now().In(location))
+	options.checkHorizon(moonphase.DayKey{Year: firstYear, Month: time.January, Day: 1}, today)
+	options.checkHorizon(moonphase.DayKey{Year: lastYear, Month: time.December, Day: 31}, today)
+
+	var years []onThisDayYear
+	counts := map[moonphase.Phase]int{}
+	for year := firstYear; year <= lastYear; year++ {
+		day, err := moonphase.ParseDayKey(fmt.Sprintf("%04d-%s", year, monthDay))
+		// 02-29 only happens in leap years
+		if err != nil {
+			continue
+		}
+		date := day.Time(location)
+		phase := moonphase.ComputePhase(date)
+		if (*options.apiFlag) {
+			phase, err = client.GetPhaseForDate(ctx, date, moonphase.SnapStrategy)
+			if err != nil {
+				fatal(err)
+			}
+		}
+		years = append(years, onThisDayYear{
+			Year:    year,
+			Date:    day,
+			Phase:   phase,
+			PhaseID: moonphase.PhaseID(string(phase)),
+			Name:    localizedName(phase, lang),
+		})
+		counts[phase]++
+	}
+
+	if (*options.jsonFlag) {
+		countsByID := map[string]int{}
+		for phase, count := range counts {
+			countsByID[moonphase.PhaseID(string(phase))] = count
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(struct {
+			Date   string          `json:"date"`
+			Years  []onThisDayYear `json:"years"`
+			Counts map[string]int  `json:"counts"`
+		}{monthDay, years, countsByID})
+		if err != nil {
+			fatalOutputError(err)
+		}
+		return
+	}
+	label := func(phase moonphase.Phase) string {
+		if (*options.plaintextFlag) {
+			return phase.Name(lang)
+		}
+		return fmt.Sprintf("%s %s", getOutput(phase, false, lang, emojiStyle, hemisphere), phase.Name(lang))
+	}
+	for _, year := range years {
+		fmt.Fprintf(stdout, "%d  %s\n", year.Year, label(year.Phase))
+	}
+	fmt.Fprintln(stdout)
+	for _, phase := range moonphase.Phases {
+		if (counts[phase] > 0) {
+			fmt.Fprintf(stdout, "%4d  %s\n", counts[phase], label(phase))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the printed JSON of onthisday
+type onThisDayJSON struct {
+	Date   string          `json:"date"`
+	Years  []onThisDayYear `json:"years"`
+	Counts map[string]int  `json:"counts"`
+}
+
+// half a century of June 15ths, calculated in well under a second, lists every year with the
+// phase ComputePhase gives its start in -timezone and counts each phase as often as it's listed
+func TestOnThisDayCounts(t *testing.T) {
+	started := time.Now()
+	run := runMoonphase(t, t.TempDir(), "onthisday", "-date", "06-15", "-years", "1950-2000", "-timezone", "America/Los_Angeles", "-json")
+	if (run.code != 0) {
+		t.Fatalf("exit %d:\n%s", run.code, run.stderr)
+	}
+	if took := time.Since(started); (took > time.Second) {
+		t.Errorf("took %s", took)
+	}
+	var got onThisDayJSON
+	if err := json.Unmarshal([]byte(run.stdout), &got); err != nil {
+		t.Fatal(err)
+	}
+	if (got.Date != "06-15" || len(got.Years) != 51) {
+		t.Fatalf("got %d years of %s, want 51 of 06-15", len(got.Years), got.Date)
+	}
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tallied := map[string]int{}
+	for i, year := range got.Years {
+		want := moonphase.ComputePhase(year.Date.Time(losAngeles))
+		if (year.Year != 1950 + i || year.Date != (moonphase.DayKey{Year: 1950 + i, Month: time.June, Day: 15}) || year.Phase != want || year.PhaseID != moonphase.PhaseID(string(want))) {
+			t.Errorf("%d: got %+v, want %s", 1950 + i, year, want)
+		}
+		tallied[year.PhaseID]++
+	}
+	total := 0
+	for id, count := range got.Counts {
+		total += count
+		if (tallied[id] != count) {
+			t.Errorf("counts %d %s, listed %d", count, id, tallied[id])
+		}
+	}
+	if (total != 51 || len(got.Counts) != len(tallied)) {
+		t.Errorf("counts %v add up to %d, want the 51 listed %v", got.Counts, total, tallied)
+	}
+}
+
+// February 29th is only listed in leap years, and the table's counts follow the years in the
+// order of the cycle
+func TestOnThisDayLeapDay(t *testing.T) {
+	run := runMoonphase(t, t.TempDir(), "onthisday", "-date", "02-29", "-years", "1999-2008", "-timezone", "UTC", "-plaintext")
+	want := "2000  Waning Crescent\n2004  First Quarter\n2008  Last Quarter\n\n" +
+		"   1  First Quarter\n   1  Last Quarter\n   1  Waning Crescent\n"
+	if (run.code != 0 || run.stdout != want) {
+		t.Errorf("exit %d, printed:\n%s\nwant:\n%s\n%s", run.code, run.stdout, want, run.stderr)
+	}
+}
+
+// -api asks the -source for each year, which snaps the day before a Full Moon to it
+func TestOnThisDayAPI(t *testing.T) {
+	run := runMoonphase(t, t.TempDir(), "onthisday", "-date", "03-24", "-years", "2024", "-api", "-timezone", "UTC", "-plaintext",
+		"-source", "file", "-source-file", recordedUSNOFile(t))
+	if want := "2024  Full Moon\n\n   1  Full Moon\n"; (run.code != 0 || run.stdout != want) {
+		t.Errorf("exit %d, printed %q, want %q\n%s", run.code, run.stdout, want, run.stderr)
+	}
+}
+
+func TestOnThisDayArguments(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"-date", "06-15"},
+		{"-date", "06-15", "-years", "2000-1990"},
+		{"-date", "06-15", "-years", "nineteen"},
+		{"-date", "06-15", "-years", "0-10"},
+		{"-date", "13-45", "-years", "2000"},
+		{"-date", "02-30", "-years", "2000"},
+	} {
+		if run := runMoonphase(t, dir, append([]string{"onthisday"}, args...)...); (run.code != exitInvalidArguments) {
+			t.Errorf("%q: exit %d, want %d\n%s", args, run.code, exitInvalidArguments, run.stderr)
+		}
+	}
+}
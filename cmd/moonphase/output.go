@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// default permissions for files written with -o
+const defaultOutputMode os.FileMode = 0644
+
+// returned by writes to stdout once the reader has gone away, like head -1 closing its input
+var errBrokenPipe = errors.New("stdout closed")
+
+// exit status when stdout is closed early, 0 quietly unless -pipefail-exit asks for
+// the 141 a shell reports for a process killed by SIGPIPE
+var brokenPipeExit int = 0
+
+// stdout, with writes after the reader has gone away failing with errBrokenPipe. SIGPIPE is
+// ignored in main so those writes return an error instead of killing the process
+type pipeWriter struct {
+	file *os.File
+}
+
+func (w pipeWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	if (err != nil && errors.Is(err, syscall.EPIPE)) {
+		return n, errBrokenPipe
+	}
+	return n, err
+}
+
+var stdout io.Writer = pipeWriter{os.Stdout}
+
+// where progress and warnings that aren't logged go, swapped out by -quiet
+var stderr io.Writer = os.Stderr
+
+// reports an error writing output and exits, quietly when it's only that stdout was closed early
+func fatalOutputError(err error) {
+	if (errors.Is(err, errBrokenPipe)) {
+		os.Exit(brokenPipeExit)
+	}
+	fatal(err)
+}
+
+// where an output is being written. unless appending, writes go to a temp file in the same
+// directory as the destination and Commit renames it into place, so a failed or interrupted
+// run never leaves a partial file behind
+type outputFile struct {
+	io.Writer
+	file    *os.File
+	path    string
+	tmpPath string
+}
+
+// opens path for writing, "-" means stdout. appendMode adds to the end of an existing file
+// instead of replacing it, for log-like outputs
+func openOutput(path string, appendMode bool, mode os.FileMode) (*outputFile, error) {
+	if (path == "-") {
+		return &outputFile{Writer: stdout, path: path}, nil
+	}
+	if (appendMode) {
+		file, err := os.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_APPEND, mode)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", path, err)
+		}
+		return &outputFile{Writer: file, file: file, path: path}, nil
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "." + filepath.Base(path) + ".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp file for %s: %w", path, err)
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("setting mode of %s: %w", path, err)
+	}
+	return &outputFile{Writer: file, file: file, path: path, tmpPath: file.Name()}, nil
+}
+
+// flushes the output to disk and, for a replacing write, moves it into place
+func (o *outputFile) Commit() error {
+	if (o.file == nil) {
+		return nil
+	}
+	if err := o.file.Sync(); err != nil {
+		o.Abort()
+		return fmt.Errorf("syncing %s: %w", o.path, err)
+	}
+	if err := o.file.Close(); err != nil {
+		o.Abort()
+		return fmt.Errorf("closing %s: %w", o.path, err)
+	}
+	if (o.tmpPath != "") {
+		if err := os.Rename(o.tmpPath, o.path); err != nil {
+			os.Remove(o.tmpPath)
+			return fmt.Errorf("writing %s: %w", o.path, err)
+		}
+	}
+	o.file = nil
+	return nil
+}
+
+// gives up on the output, removing the temp file of a replacing write. safe to call after Commit
+func (o *outputFile) Abort() {
+	if (o.file == nil) {
+		return
+	}
+	o.file.Close()
+	if (o.tmpPath != "") {
+		os.Remove(o.tmpPath)
+	}
+}
+
+// writes content to path in one go, see openOutput
+func writeOutput(path string, appendMode bool, mode os.FileMode, content []byte) error {
+	output, err := openOutput(path, appendMode, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := output.Write(content); err != nil {
+		output.Abort()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return output.Commit()
+}
+
+// parses a -mode value like 0600
+func parseOutputMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if (err != nil || mode > 0777) {
+		return 0, argumentError{fmt.Errorf("invalid -mode %q, expected octal permissions like 0644", value)}
+	}
+	return os.FileMode(mode), nil
+}
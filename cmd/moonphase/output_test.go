@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// returns the temp files openOutput left in dir
+func outputTempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// fails t unless path holds want
+func checkFileContent(t *testing.T, path string, want string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if (err != nil || string(content) != want) {
+		t.Errorf("%s holds %q (%v), want %q", filepath.Base(path), content, err, want)
+	}
+}
+
+// a replacing write goes to a temp file beside the target, which keeps its old content until
+// Commit renames the new one over it with the mode asked for
+func TestOpenOutputReplaces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "phases.csv")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := openOutput(path, false, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := output.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, path, "old\n")
+	if temps := outputTempFiles(t, dir); (len(temps) != 1 || !strings.HasPrefix(filepath.Base(temps[0]), ".phases.csv.tmp-")) {
+		t.Errorf("writing to %v, want one temp file beside phases.csv", temps)
+	}
+	if err := output.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, path, "new\n")
+	if temps := outputTempFiles(t, dir); (len(temps) != 0) {
+		t.Errorf("left %v behind", temps)
+	}
+	if info, err := os.Stat(path); (err != nil || info.Mode().Perm() != 0600) {
+		t.Errorf("mode %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+	// safe once committed, as the deferred Abort of the commands does
+	output.Abort()
+	checkFileContent(t, path, "new\n")
+}
+
+// giving up on a replacing write leaves the target as it was, or not there at all, and no temp file
+func TestOpenOutputAbort(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.csv")
+	if err := os.WriteFile(existing, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.csv")
+	for _, path := range []string{existing, missing} {
+		output, err := openOutput(path, false, defaultOutputMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := output.Write([]byte("partial")); err != nil {
+			t.Fatal(err)
+		}
+		output.Abort()
+		output.Abort()
+	}
+	checkFileContent(t, existing, "old\n")
+	if _, err := os.Stat(missing); (!os.IsNotExist(err)) {
+		t.Errorf("an aborted write created %s (%v)", filepath.Base(missing), err)
+	}
+	if temps := outputTempFiles(t, dir); (len(temps) != 0) {
+		t.Errorf("left %v behind", temps)
+	}
+}
+
+// appending writes straight to the end of the file, creating it with the mode asked for, and
+// keeps what was written even when given up on
+func TestOpenOutputAppends(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.csv")
+	if err := writeOutput(path, true, 0600, []byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); (err != nil || info.Mode().Perm() != 0600) {
+		t.Errorf("mode %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+	output, err := openOutput(path, true, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := output.Write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, path, "a\nb\n")
+	output.Abort()
+	checkFileContent(t, path, "a\nb\n")
+	if err := writeOutput(path, true, 0600, []byte("c\n")); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, path, "a\nb\nc\n")
+	if temps := outputTempFiles(t, dir); (len(temps) != 0) {
+		t.Errorf("appending left %v behind", temps)
+	}
+}
+
+// writeOutput replaces a file in one go, fails naming a path it can't write, and - is stdout
+func TestWriteOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "moonphase.prom")
+	for _, content := range []string{"first\n", "second\n"} {
+		if err := writeOutput(path, false, defaultOutputMode, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		checkFileContent(t, path, content)
+	}
+
+	unwritable := filepath.Join(dir, "missing", "moonphase.prom")
+	if err := writeOutput(unwritable, false, defaultOutputMode, []byte("x")); (err == nil || !strings.Contains(err.Error(), unwritable)) {
+		t.Errorf("got %v, want an error naming %s", err, unwritable)
+	}
+
+	var buffer bytes.Buffer
+	previous := stdout
+	defer func() { stdout = previous }()
+	stdout = &buffer
+	if err := writeOutput("-", false, defaultOutputMode, []byte("to stdout\n")); (err != nil || buffer.String() != "to stdout\n") {
+		t.Errorf("- wrote %q (%v)", buffer.String(), err)
+	}
+}
+
+func TestParseOutputMode(t *testing.T) {
+	for value, want := range map[string]os.FileMode{"0644": 0644, "600": 0600, "0777": 0777} {
+		if got, err := parseOutputMode(value); (err != nil || got != want) {
+			t.Errorf("%s: got %v (%v), want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "0644x", "0800", "01777", "-1"} {
+		if _, err := parseOutputMode(value); (exitCodeFor(err) != exitInvalidArguments) {
+			t.Errorf("%q: got %v, want an argument error", value, err)
+		}
+	}
+}
+
+// runs the test binary as moonphase like runMoonphase, reading the first line it prints and
+// closing stdout on it like head -1 does. returns that line, its exit status and its stderr
+func runMoonphaseIntoHead(t *testing.T, dir string, args ...string) (string, int, string) {
+	t.Helper()
+	command := exec.Command(os.Args[0], args...)
+	command.Dir = dir
+	command.Env = append(os.Environ(), runAsMoonphaseEnv + "=1", "HOME=" + dir, "MOONPHASE_CONFIG=" + filepath.Join(dir, "no-config"))
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	pipe, err := command.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := command.Start(); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(pipe).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipe.Close()
+	code := 0
+	var exitErr *exec.ExitError
+	if err := command.Wait(); (errors.As(err, &exitErr)) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return line, code, stderr.String()
+}
+
+// output far larger than a pipe holds, to a reader that goes away after the first line, stops
+// with exit 0, or 141 with -pipefail-exit, reporting no error. export's progress still goes to stderr
+func TestClosedStdout(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		args  []string
+		first string
+	}{
+		{"range", []string{"range", "-offline", "-plaintext", "2000-01-01", "2099-12-31"}, "2000-01-01 Waning Crescent\n"},
+		{"export", []string{"export", "-source", "offline", "-start", "1900-01-01", "-end", "2099-12-31", "-o", "-"}, "utc_instant,local_date,phase_id,provider\n"},
+		{"daily export", []string{"export", "-source", "offline", "-granularity", "daily", "-start", "1900-01-01", "-end", "2099-12-31", "-o", "-"}, "local_date,phase_id,phase,illumination,age_days\n"},
+	}
+	for _, test := range tests {
+		for flag, want := range map[string]int{"": 0, "-pipefail-exit": 141} {
+			args := append([]string{}, test.args...)
+			if (flag != "") {
+				args = append(args[:1], append([]string{flag}, args[1:]...)...)
+			}
+			first, code, stderr := runMoonphaseIntoHead(t, dir, args...)
+			reported := strings.Contains(stderr, "pipe") || strings.Contains(stderr, "stdout closed") || strings.Contains(stderr, "panic")
+			if (first != test.first || code != want || reported) {
+				t.Errorf("%s %s: printed %q first, exit %d, want %d and no error:\n%s", test.name, flag, first, code, want, stderr)
+			}
+		}
+	}
+}
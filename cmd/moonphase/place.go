@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the geocoders -geocoder chooses between
+var geocoderNames = []string{"offline", "nominatim"}
+
+// where -geocoder nominatim asks by default, the public OpenStreetMap instance
+const defaultNominatimURL string = "https://nominatim.openstreetmap.org"
+
+// a place a -place query could mean
+type placeMatch struct {
+	Name string
+	Lat  float64
+	Lon  float64
+}
+
+// resolves a -place query to the places it could mean, none when it knows of no such place
+type geocoder interface {
+	Geocode(ctx context.Context, query string) ([]placeMatch, error)
+}
+
+//go:embed places.tsv
+var placesTSV string
+
+// a city of the built-in gazetteer
+type gazetteerPlace struct {
+	name    string
+	region  string
+	country string
+	lat     float64
+	lon     float64
+}
+
+// the name a candidate is listed by, enough to tell it apart from the others, like Portland, OR, US
+func (p gazetteerPlace) displayName() string {
+	parts := []string{p.name}
+	if (p.region != "") {
+		parts = append(parts, p.region)
+	}
+	return strings.Join(append(parts, p.country), ", ")
+}
+
+// the offline geocoder, matching a city name and optionally its region and country codes against
+// the major cities embedded in the binary
+type gazetteer []gazetteerPlace
+
+// parses the embedded places.tsv, a line per city of name, region, country, latitude and longitude
+func loadGazetteer() gazetteer {
+	var places gazetteer
+	scanner := bufio.NewScanner(strings.NewReader(placesTSV))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if (len(fields) != 5) {
+			panic(fmt.Sprintf("places.tsv: %q doesn't have five fields", line))
+		}
+		lat, latErr := strconv.ParseFloat(fields[3], 64)
+		lon, lonErr := strconv.ParseFloat(fields[4], 64)
+		if (latErr != nil || lonErr != nil) {
+			panic(fmt.Sprintf("places.tsv: %q has no coordinates", line))
+		}
+		places = append(places, gazetteerPlace{fields[0], fields[1], fields[2], lat, lon})
+	}
+	return places
+}
+
+// matches "Portland", "Portland, OR" or "portland, or, us": the city's name, then any of its
+// region and country codes, each after a comma and in either case
+func (g gazetteer) Geocode(ctx context.Context, query string) ([]placeMatch, error) {
+	parts := strings.Split(query, ",")
+	var matches []placeMatch
+	for _, place := range g {
+		if (!strings.EqualFold(strings.TrimSpace(parts[0]), place.name)) {
+			continue
+		}
+		qualified := true
+		for _, qualifier := range parts[1:] {
+			qualifier = strings.TrimSpace(qualifier)
+			if (!strings.EqualFold(qualifier, place.region) && !strings.EqualFold(qualifier, place.country)) {
+				qualified = false
+			}
+		}
+		if (qualified) {
+			matches = append(matches, placeMatch{place.displayName(), place.lat, place.lon})
+		}
+	}
+	return matches, nil
+}
+
+// the geocoder of -geocoder nominatim, asking the search API of an OpenStreetMap Nominatim instance.
+// its usage policy asks for a User-Agent naming the application and at most a request a second,
+// which the cache of resolved places in the state file keeps a lookup well under
+type nominatimGeocoder struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+}
+
+// a result of the search API, which gives the coordinates as strings
+type nominatimResult struct {
+	DisplayName string `json:"display_name"`
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+}
+
+// results this close to an earlier one are the same place again, like a city and the county
+// or municipality of the same name around it
+const nominatimSamePlaceDegrees float64 = 0.5
+
+func (g nominatimGeocoder) Geocode(ctx context.Context, query string) ([]placeMatch, error) {
+	values := url.Values{"q": {query}, "format": {"jsonv2"}, "limit": {"5"}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(g.BaseURL, "/") + "/search?" + values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", g.UserAgent)
+	request.Header.Set("Accept", "application/json")
+	httpClient := g.HTTPClient
+	if (httpClient == nil) {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if (response.StatusCode != http.StatusOK) {
+		return nil, networkError{fmt.Errorf("nominatim answered %s for %q", response.Status, query)}
+	}
+	var results []nominatimResult
+	if err := json.NewDecoder(response.Body).Decode(&results); err != nil {
+		return nil, dataError{fmt.Errorf("nominatim's answer for %q doesn't parse: %w", query, err)}
+	}
+	var matches []placeMatch
+	for _, result := range results {
+		lat, latErr := strconv.ParseFloat(result.Lat, 64)
+		lon, lonErr := strconv.ParseFloat(result.Lon, 64)
+		if (latErr != nil || lonErr != nil) {
+			return nil, dataError{fmt.Errorf("nominatim gave %q for %q without coordinates", result.DisplayName, query)}
+		}
+		repeated := false
+		for _, match := range matches {
+			if (math.Abs(match.Lat - lat) < nominatimSamePlaceDegrees && math.Abs(match.Lon - lon) < nominatimSamePlaceDegrees) {
+				repeated = true
+			}
+		}
+		if (!repeated) {
+			matches = append(matches, placeMatch{result.DisplayName, lat, lon})
+		}
+	}
+	return matches, nil
+}
+
+// returns the geocoder -geocoder names
+func newGeocoder(name string, nominatimURL string) (geocoder, error) {
+	switch name {
+	case "offline":
+		return loadGazetteer(), nil
+	case "nominatim":
+		userAgent := client.UserAgent
+		if (userAgent == "") {
+			userAgent = moonphase.DefaultUserAgent
+		}
+		return nominatimGeocoder{nominatimURL, client.HTTPClient, userAgent}, nil
+	}
+	return nil, argumentError{fmt.Errorf("invalid -geocoder %q, expected one of %s", name, strings.Join(geocoderNames, ", "))}
+}
+
+// the state file key a place resolved by a geocoder is kept under
+func placeStateKey(geocoderName string, query string) string {
+	return "place:" + geocoderName + ":" + strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// resolves -place to coordinates, from the state file when the same query was resolved before so
+// only the first lookup of a place goes to an online geocoder. a query no place matches, or that
+// several could mean, is an argument error listing the candidates
+func resolvePlace(ctx context.Context, query string, geocoderName string, nominatimURL string, stateFilePath string) (float64, float64, error) {
+	key := placeStateKey(geocoderName, query)
+	if cached, found := loadState(stateFilePath)[key]; (found) {
+		if lat, lon, err := parseCoords(cached); (err == nil) {
+			debugf("-place %q is %s in %s", query, cached, stateFilePath)
+			return lat, lon, nil
+		}
+	}
+	places, err := newGeocoder(geocoderName, nominatimURL)
+	if err != nil {
+		return 0, 0, err
+	}
+	matches, err := places.Geocode(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	if (len(matches) == 0) {
+		return 0, 0, argumentError{fmt.Errorf(message("no place matches -place %q, check the spelling or give -coords instead"), query)}
+	}
+	if (len(matches) > 1) {
+		names := make([]string, len(matches))
+		for i, match := range matches {
+			names[i] = match.Name
+		}
+		return 0, 0, argumentError{fmt.Errorf(message("-place %q could be %s, add the region or country to choose one"), query, strings.Join(names, "; "))}
+	}
+	state := loadState(stateFilePath)
+	state[key] = fmt.Sprintf("%g,%g", matches[0].Lat, matches[0].Lon)
+	saveState(stateFilePath, state)
+	debugf("-place %q is %s, at %s", query, matches[0].Name, state[key])
+	return matches[0].Lat, matches[0].Lon, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// a city alone, or with its region or country codes in either case, is the one place of the
+// built-in gazetteer it names. a name several cities have matches them all, and one of none nothing
+func TestGazetteerGeocode(t *testing.T) {
+	places := loadGazetteer()
+	tests := []struct {
+		query string
+		want  []placeMatch
+	}{
+		{"Portland, OR", []placeMatch{{"Portland, OR, US", 45.52, -122.68}}},
+		{" portland , me , us ", []placeMatch{{"Portland, ME, US", 43.66, -70.26}}},
+		{"Tokyo", []placeMatch{{"Tokyo, JP", 35.68, 139.69}}},
+		{"London, GB", []placeMatch{{"London, GB", 51.51, -0.13}}},
+		{"Portland", []placeMatch{{"Portland, ME, US", 43.66, -70.26}, {"Portland, OR, US", 45.52, -122.68}}},
+		{"Portland, WA", nil},
+		{"Atlantis", nil},
+	}
+	for _, test := range tests {
+		got, err := places.Geocode(context.Background(), test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(got) != len(test.want)) {
+			t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+			continue
+		}
+		for i := range got {
+			if (got[i] != test.want[i]) {
+				t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+			}
+		}
+	}
+}
+
+// every city of places.tsv is on the globe, and is told apart from the others by its listed name
+func TestGazetteerPlaces(t *testing.T) {
+	places := loadGazetteer()
+	if (len(places) < 200) {
+		t.Errorf("the gazetteer has %d places", len(places))
+	}
+	seen := map[string]bool{}
+	for _, place := range places {
+		if (place.lat < -90 || place.lat > 90 || place.lon < -180 || place.lon > 180) {
+			t.Errorf("%s is at %v,%v", place.displayName(), place.lat, place.lon)
+		}
+		if (seen[place.displayName()]) {
+			t.Errorf("%s is listed twice", place.displayName())
+		}
+		seen[place.displayName()] = true
+	}
+}
+
+// returns a Nominatim search API answering with body, and the requests it was sent
+func newNominatimServer(t *testing.T, body string) (*httptest.Server, func() []*http.Request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+		if (r.URL.Path != "/search") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+// the search is asked for JSON with the User-Agent its usage policy wants, and a city and the
+// county of the same name around it are one place
+func TestNominatimGeocode(t *testing.T) {
+	server, requests := newNominatimServer(t, `[
+		{"display_name": "Portland, Multnomah County, Oregon, United States", "lat": "45.5202471", "lon": "-122.674194"},
+		{"display_name": "Multnomah County, Oregon, United States", "lat": "45.5468", "lon": "-122.4172"},
+		{"display_name": "Portland, Cumberland County, Maine, United States", "lat": "43.6573605", "lon": "-70.2586618"}
+	]`)
+	places := nominatimGeocoder{server.URL, nil, "moonphase-test"}
+	got, err := places.Geocode(context.Background(), "Portland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(got) != 2 || got[0].Name != "Portland, Multnomah County, Oregon, United States" || got[0].Lat != 45.5202471 || got[1].Lon != -70.2586618) {
+		t.Errorf("got %v, want Portland, OR then Portland, ME", got)
+	}
+	asked := requests()[0]
+	if (asked.Header.Get("User-Agent") != "moonphase-test" || asked.URL.Query().Get("q") != "Portland" || asked.URL.Query().Get("format") != "jsonv2") {
+		t.Errorf("asked %s with User-Agent %q", asked.URL, asked.Header.Get("User-Agent"))
+	}
+
+	refusing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer refusing.Close()
+	_, err = nominatimGeocoder{refusing.URL, nil, "moonphase-test"}.Geocode(context.Background(), "Portland")
+	if (exitCodeFor(err) != exitNetwork) {
+		t.Errorf("a 429 gave %v, want a network error", err)
+	}
+}
+
+// a place resolved once is kept in the state file, and looked up from it again without the geocoder
+func TestResolvePlaceCached(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state")
+	server, requests := newNominatimServer(t, `[{"display_name": "Tromsø, Troms, Norge", "lat": "69.6496", "lon": "18.9560"}]`)
+	for _, query := range []string{"Tromsø", " tromsø "} {
+		lat, lon, err := resolvePlace(context.Background(), query, "nominatim", server.URL, stateFile)
+		if (err != nil || lat != 69.6496 || lon != 18.956) {
+			t.Errorf("%q: got %v,%v (%v)", query, lat, lon, err)
+		}
+	}
+	if (len(requests()) != 1) {
+		t.Errorf("sent %d requests, want the first lookup only", len(requests()))
+	}
+	server.Close()
+	if lat, lon, err := resolvePlace(context.Background(), "Tromsø", "nominatim", server.URL, stateFile); (err != nil || lat != 69.6496 || lon != 18.956) {
+		t.Errorf("with the geocoder gone: got %v,%v (%v)", lat, lon, err)
+	}
+	if got := loadState(stateFile)["place:nominatim:tromsø"]; (got != "69.6496,18.956") {
+		t.Errorf("the state file holds %q", got)
+	}
+	// the same query through another geocoder isn't answered from this one's
+	if _, _, err := resolvePlace(context.Background(), "Tromsø", "offline", server.URL, stateFile); (exitCodeFor(err) != exitInvalidArguments) {
+		t.Errorf("offline: got %v, want no match", err)
+	}
+}
+
+// a -place several cities could be, or none is, exits 2 before any lookup, listing the candidates
+func TestPlaceFlag(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-place", "Portland"}, `-place "Portland" could be Portland, ME, US; Portland, OR, US, add the region or country to choose one`},
+		{[]string{"-place", "Atlantis"}, `no place matches -place "Atlantis"`},
+		{[]string{"-place", "Tokyo", "-coords", "35.68,139.69"}, "-place and -coords both give the location"},
+		{[]string{"-place", "Tokyo", "-offline"}, "can't be combined with -offline"},
+		{[]string{"-place", "Tokyo", "-geocoder", "atlas"}, `invalid -geocoder "atlas"`},
+	}
+	for _, test := range tests {
+		run := runMoonphase(t, dir, append([]string{"-date", "2024-03-25"}, test.args...)...)
+		if (run.code != exitInvalidArguments || run.stdout != "" || !strings.Contains(run.stderr, test.want)) {
+			t.Errorf("%v: exit %d, printed %q, want exit 2 and %q:\n%s", test.args, run.code, run.stdout, test.want, run.stderr)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// a day's phase as the server's JSON and -template show it, the JSON fields match around and
+// onthisday -json
+type phaseReport struct {
+	Date           moonphase.DayKey `json:"date"`
+	Phase          moonphase.Phase  `json:"phase"`
+	PhaseID        string           `json:"phase_id"`
+	Emoji          string           `json:"emoji"`
+	EmojiShortcode string           `json:"emoji_shortcode"`
+	// the phase name in -lang, only when it isn't English
+	Name           string           `json:"name,omitempty"`
+	// whole percent lit, 0 to 100, like filename's
+	Illumination   int              `json:"illumination"`
+	// days since the last New Moon
+	Age            float64          `json:"age_days"`
+	// the next primary phase, on a later day than this one
+	Next           moonphase.NextPrimaryPhase `json:"next"`
+}
+
+// the next primary phase, for templates
+func (r phaseReport) NextPhase() moonphase.Phase {
+	return r.Next.Phase
+}
+
+// the local day of the next primary phase, for templates
+func (r phaseReport) NextPhaseDate() moonphase.DayKey {
+	return r.Next.LocalDate
+}
+
+// calendar days until the next primary phase, for templates
+func (r phaseReport) DaysUntilNext() int {
+	return r.Next.DaysUntil
+}
+
+// builds the report of result with the illuminated fraction and age at the time it's for
+func newPhaseReport(result moonphase.PhaseResult, illumination float64, age float64, lang string, emojiStyle string, hemisphere moonphase.Hemisphere) phaseReport {
+	return phaseReport{
+		Date:           result.Date,
+		Phase:          result.Phase,
+		PhaseID:        result.PhaseID,
+		// the emoji field always holds the emoji, shortcodes have their own
+		Emoji:          getOutput(result.Phase, false, lang, emojiFieldStyle(emojiStyle), hemisphere),
+		EmojiShortcode: getOutput(result.Phase, false, lang, "shortcode", hemisphere),
+		Name:           localizedName(result.Phase, lang),
+		Illumination:   int(illumination * 100 + 0.5),
+		Age:            age,
+		Next:           result.Next,
+	}
+}
+
+// parses a -template for phaseReport. it's tried on an empty report straight away, so a field
+// that doesn't exist fails before any lookup rather than after it
+func parseReportTemplate(text string) (*template.Template, error) {
+	reportTemplate, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, argumentError{fmt.Errorf("invalid -template: %w", err)}
+	}
+	if _, err := renderReport(reportTemplate, phaseReport{}); err != nil {
+		return nil, err
+	}
+	return reportTemplate, nil
+}
+
+// renders report with a template from parseReportTemplate
+func renderReport(reportTemplate *template.Template, report phaseReport) (string, error) {
+	var rendered strings.Builder
+	if err := reportTemplate.Execute(&rendered, report); err != nil {
+		return "", argumentError{fmt.Errorf("invalid -template: %w", err)}
+	}
+	return rendered.String(), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the report of 2024-03-20 in UTC, five days before the Full Moon
+func testReport(lang string) phaseReport {
+	result := moonphase.PhaseResult{
+		Date:    moonphase.DayKey{Year: 2024, Month: time.March, Day: 20},
+		Phase:   moonphase.WaxingGibbous,
+		PhaseID: "waxing-gibbous",
+		Age:     9.64,
+		Next: moonphase.NextPrimaryPhase{
+			Phase:     moonphase.FullMoon,
+			PhaseID:   "full-moon",
+			Instant:   moonphase.Instant{Time: time.Date(2024, time.March, 25, 7, 0, 0, 0, time.UTC)},
+			LocalDate: moonphase.DayKey{Year: 2024, Month: time.March, Day: 25},
+			DaysUntil: 5,
+		},
+	}
+	return newPhaseReport(result, 0.76, result.Age, lang, "auto", moonphase.NorthernHemisphere)
+}
+
+func TestRenderReport(t *testing.T) {
+	tests := []struct {
+		template string
+		report   phaseReport
+		want     string
+	}{
+		{"{{.Emoji}} {{.Illumination}}%", testReport("en"), "🌔 76%"},
+		{"{{.Phase}} on {{.Date}}", testReport("en"), "Waxing Gibbous on 2024-03-20"},
+		{"{{.PhaseID}} {{.EmojiShortcode}}", testReport("en"), "waxing-gibbous :waxing_gibbous_moon:"},
+		{"{{printf \"%.1f\" .Age}} days old", testReport("en"), "9.6 days old"},
+		{"{{.NextPhase}} on {{.NextPhaseDate}}, {{.DaysUntilNext}} days", testReport("en"), "Full Moon on 2024-03-25, 5 days"},
+		{"{{.Next.Instant.UTC.Format \"15:04\"}} UT", testReport("en"), "07:00 UT"},
+		// Name is only there in another language
+		{"{{with .Name}}{{.}}{{else}}{{.Phase}}{{end}}", testReport("en"), "Waxing Gibbous"},
+		{"{{with .Name}}{{.}}{{else}}{{.Phase}}{{end}}", testReport("es"), "Gibosa creciente"},
+		{"[{{.Name}}]", testReport("en"), "[]"},
+		// a report without a next phase, the empty one templates are checked against
+		{"{{if .NextPhase}}next {{.NextPhase}}{{else}}no next phase{{end}}", phaseReport{}, "no next phase"},
+		{"{{if .NextPhase}}next {{.NextPhase}}{{else}}no next phase{{end}}", testReport("en"), "next Full Moon"},
+	}
+	for _, test := range tests {
+		reportTemplate, err := parseReportTemplate(test.template)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+			continue
+		}
+		got, err := renderReport(reportTemplate, test.report)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+		} else if (got != test.want) {
+			t.Errorf("%q: got %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+// a template that doesn't parse, or names a field the report hasn't got, fails when it's parsed
+// as an invalid argument with the template's own error
+func TestParseReportTemplateErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{{.Emoji", "unclosed action"},
+		{"{{.Nope}}", "can't evaluate field Nope"},
+		{"{{.Next.Nope}}", "can't evaluate field Nope"},
+		{"{{template \"missing\"}}", "not defined"},
+	}
+	for _, test := range tests {
+		_, err := parseReportTemplate(test.template)
+		var argErr argumentError
+		if (!errors.As(err, &argErr) || !strings.Contains(err.Error(), "invalid -template") || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("%q: got %v, want an argument error with %q", test.template, err, test.want)
+		}
+	}
+}
+
+// -template renders the lookup's report, and a bad one exits 2 before any lookup is made
+func TestTemplateRuns(t *testing.T) {
+	source := []string{"-date", "2024-03-20", "-timezone", "UTC", "-source", "file", "-source-file", recordedUSNOFile(t)}
+	run := runMoonphase(t, t.TempDir(), append([]string{"-template", "{{.Phase}}, {{.DaysUntilNext}} days until {{.NextPhase}} ({{.Illumination}}%)"}, source...)...)
+	if want := "Waxing Gibbous, 5 days until Full Moon (76%)\n"; (run.code != 0 || run.stdout != want) {
+		t.Errorf("exit %d, printed %q, want %q\n%s", run.code, run.stdout, want, run.stderr)
+	}
+	run = runMoonphase(t, t.TempDir(), "-template", "{{.Nope}}", "-date", "2024-03-20", "-source", "file", "-source-file", "/nonexistent")
+	if (run.code != exitInvalidArguments || !strings.Contains(run.stderr, "invalid -template")) {
+		t.Errorf("exit %d, want %d:\n%s", run.code, exitInvalidArguments, run.stderr)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// parses a -coords value like 38.88,-77.00, latitude then longitude in degrees, north and east positive
+func parseCoords(value string) (float64, float64, error) {
+	invalid := argumentError{fmt.Errorf("invalid -coords %q, expected latitude,longitude like 38.88,-77.00", value)}
+	latText, lonText, found := strings.Cut(value, ",")
+	if (!found) {
+		return 0, 0, invalid
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	if err := checkFloatRange("coords latitude", lat, -90, 90); err != nil {
+		return 0, 0, err
+	}
+	if err := checkFloatRange("coords longitude", lon, -180, 180); err != nil {
+		return 0, 0, err
+	}
+	return lat, lon, nil
+}
+
+// formats a time of the rise, transit or set line, none when it doesn't happen that day
+func formatRiseSetTime(instant time.Time) string {
+	if (instant.IsZero()) {
+		return "none"
+	}
+	return instant.Format("15:04")
+}
+
+// formats the line printed after the phase for -coords
+func formatRiseSet(times moonphase.RiseSetTimes) string {
+	return fmt.Sprintf("moonrise %s  transit %s  moonset %s",
+		formatRiseSetTime(times.Rise), formatRiseSetTime(times.Transit), formatRiseSetTime(times.Set))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// an event the API leaves out for the day prints as none
+func TestFormatRiseSet(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		times moonphase.RiseSetTimes
+		want  string
+	}{
+		{
+			moonphase.RiseSetTimes{
+				Rise:    time.Date(2024, time.March, 25, 19, 47, 0, 0, location),
+				Transit: time.Date(2024, time.March, 25, 0, 51, 0, 0, location),
+				Set:     time.Date(2024, time.March, 25, 7, 29, 0, 0, location),
+			},
+			"moonrise 19:47  transit 00:51  moonset 07:29",
+		},
+		{
+			moonphase.RiseSetTimes{
+				Transit: time.Date(2024, time.March, 31, 5, 22, 0, 0, location),
+				Set:     time.Date(2024, time.March, 31, 10, 31, 0, 0, location),
+			},
+			"moonrise none  transit 05:22  moonset 10:31",
+		},
+		{moonphase.RiseSetTimes{}, "moonrise none  transit none  moonset none"},
+	}
+	for _, test := range tests {
+		if got := formatRiseSet(test.times); (got != test.want) {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestParseCoords(t *testing.T) {
+	tests := []struct {
+		value    string
+		lat, lon float64
+		valid    bool
+	}{
+		{"38.88,-77.00", 38.88, -77, true},
+		{" 78.22 , 15.65 ", 78.22, 15.65, true},
+		{"-90,180", -90, 180, true},
+		{"90.1,0", 0, 0, false},
+		{"0,-180.5", 0, 0, false},
+		{"38.88", 0, 0, false},
+		{"north,west", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, test := range tests {
+		lat, lon, err := parseCoords(test.value)
+		if (!test.valid) {
+			if (err == nil || exitCodeFor(err) != exitInvalidArguments) {
+				t.Errorf("%q: got %g,%g %v, want an argument error", test.value, lat, lon, err)
+			}
+			continue
+		}
+		if (err != nil || lat != test.lat || lon != test.lon) {
+			t.Errorf("%q: got %g,%g %v, want %g,%g", test.value, lat, lon, err, test.lat, test.lon)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+func TestParseSaveFile(t *testing.T) {
+	fetched := time.Date(2024, time.March, 25, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]saveEntry
+		wantErr bool
+	}{
+		{name: "empty", content: "", want: map[string]saveEntry{}},
+		{name: "only blank lines", content: "\n\n  \n", want: map[string]saveEntry{}},
+		{
+			name:    "old format",
+			content: "2024-03-25,Full Moon",
+			want:    map[string]saveEntry{"2024-03-25": {phase: moonphase.FullMoon}},
+		},
+		{
+			name:    "old format with a trailing newline",
+			content: "2024-03-25,Full Moon\n",
+			want:    map[string]saveEntry{"2024-03-25": {phase: moonphase.FullMoon}},
+		},
+		{
+			name:    "old format with a fetch time",
+			content: fmt.Sprintf("2024-03-25,Full Moon,%d\r\n", fetched.Unix()),
+			want:    map[string]saveEntry{"2024-03-25": {phase: moonphase.FullMoon, fetchedAt: fetched}},
+		},
+		{
+			name:    "current format",
+			content: fmt.Sprintf("moonphase-save 2\n2024-03-24,Waxing Gibbous,%d\n2024-03-25,Full Moon,%d\n", fetched.Unix(), fetched.Unix()),
+			want: map[string]saveEntry{
+				"2024-03-24": {phase: moonphase.WaxingGibbous, fetchedAt: fetched},
+				"2024-03-25": {phase: moonphase.FullMoon, fetchedAt: fetched},
+			},
+		},
+		{
+			name:    "current format without a trailing newline",
+			content: fmt.Sprintf("moonphase-save 2\n2024-03-25,Full Moon,%d", fetched.Unix()),
+			want:    map[string]saveEntry{"2024-03-25": {phase: moonphase.FullMoon, fetchedAt: fetched}},
+		},
+		{name: "header only", content: "moonphase-save 2\n", want: map[string]saveEntry{}},
+		{name: "unknown format", content: "moonphase-save 3\n2024-03-25,Full Moon,0\n", wantErr: true},
+		{name: "several lines without a header", content: "2024-03-24,Waxing Gibbous\n2024-03-25,Full Moon\n", wantErr: true},
+		{name: "unknown phase", content: "2024-03-25,Blue Moon", wantErr: true},
+		{name: "bad day", content: "moonphase-save 2\n2024-02-30,Full Moon,0\n", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := parseSaveFile(test.content)
+			if (test.wantErr) {
+				if (err == nil) {
+					t.Fatalf("parsed %v, want an error", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(entries) != len(test.want)) {
+				t.Fatalf("got %v, want %v", entries, test.want)
+			}
+			for dayText, want := range test.want {
+				day, _ := moonphase.ParseDayKey(dayText)
+				if got := entries[day]; (got.phase != want.phase || !got.fetchedAt.Equal(want.fetchedAt)) {
+					t.Errorf("%s: got %+v, want %+v", dayText, got, want)
+				}
+			}
+		})
+	}
+}
+
+// a file written by formatSaveFile parses back to what was written
+func TestSaveFileRoundTrip(t *testing.T) {
+	now := time.Unix(time.Date(2024, time.March, 25, 8, 0, 0, 0, time.UTC).Unix(), 0)
+	entries := map[moonphase.DayKey]saveEntry{
+		{Year: 2024, Month: time.March, Day: 25}: {phase: moonphase.FullMoon, fetchedAt: now},
+		{Year: 2024, Month: time.March, Day: 1}:  {phase: moonphase.WaningGibbous, fetchedAt: now.Add(-24 * time.Hour)},
+		// past saveFileMaxAge, dropped
+		{Year: 2024, Month: time.January, Day: 1}: {phase: moonphase.WaxingGibbous, fetchedAt: now.Add(-saveFileMaxAge - time.Hour)},
+	}
+	parsed, err := parseSaveFile(formatSaveFile(entries, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(parsed) != 2) {
+		t.Fatalf("got %v, want the two recent entries", parsed)
+	}
+	for day, entry := range parsed {
+		if want := entries[day]; (entry.phase != want.phase || !entry.fetchedAt.Equal(want.fetchedAt)) {
+			t.Errorf("%s: got %+v, want %+v", day, entry, want)
+		}
+	}
+}
+
+// lookups saving at the same time each keep their day
+func TestSavePhaseToFileConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "moonphase")
+	now := time.Now()
+	start := moonphase.NewDayKey(now)
+	const days = 20
+	var wg sync.WaitGroup
+	for i := 0; i < days; i++ {
+		wg.Add(1)
+		go func(day moonphase.DayKey) {
+			defer wg.Done()
+			savePhaseToFile(day, moonphase.FullMoon, now, path)
+		}(start.AddDays(i))
+	}
+	wg.Wait()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := parseSaveFile(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(entries) != days) {
+		t.Errorf("saved %d of %d days:\n%s", len(entries), days, content)
+	}
+}
+
+// an entry answers for its day whenever it was fetched, unless it was fetched more than
+// maxSaveFileSkew after now, when the clock has been set back since, which is warned about
+func TestIsSaveFresh(t *testing.T) {
+	now := time.Date(2024, time.March, 25, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		fetchedAt time.Time
+		want      bool
+	}{
+		{"no fetch time, from the old format", time.Time{}, true},
+		{"earlier today", now.Add(-2 * time.Hour), true},
+		{"the clock jumped forward a week since", now.Add(-7 * 24 * time.Hour), true},
+		{"the clock set back a little", now.Add(30 * time.Minute), true},
+		{"the clock set back by the skew allowed", now.Add(maxSaveFileSkew), true},
+		{"the clock set back past the skew", now.Add(maxSaveFileSkew + time.Second), false},
+		{"the clock set back a day", now.Add(24 * time.Hour), false},
+	}
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	for _, test := range tests {
+		logged.Reset()
+		if got := isSaveFresh(saveEntry{phase: moonphase.FullMoon, fetchedAt: test.fetchedAt}, now); (got != test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		if warned := strings.Contains(logged.String(), "after the current time"); (warned == test.want) {
+			t.Errorf("%s: logged %q", test.name, logged.String())
+		}
+	}
+}
+
+// fills cacheDir with the API response a lookup of day needs, from the recorded USNO file, so a
+// lookup that goes past the save file is answered without the network
+func cacheRecordedResponse(t *testing.T, cacheDir string, day time.Time) {
+	t.Helper()
+	recorded, err := os.ReadFile(recordedUSNOFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(recorded)
+	}))
+	defer server.Close()
+	cachingClient := moonphase.NewClient()
+	cachingClient.BaseURL = server.URL
+	cachingClient.CacheDir = cacheDir
+	if _, err := cachingClient.GetPhaseForDate(context.Background(), day, moonphase.SnapStrategy); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// with the clock set back, an entry fetched in what is now the future past the skew is looked up
+// again and rewritten with the current time, one fetched within it or before a jump forward answers
+func TestSaveFileAfterClockJump(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	day := time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC)
+	cacheRecordedResponse(t, cacheDir, day)
+	clock := time.Date(2024, time.March, 25, 12, 0, 0, 0, time.UTC)
+	nowFile := writeNowFile(t, dir, clock, 0)
+	saveFile := filepath.Join(dir, "moonphase")
+	tests := []struct {
+		name      string
+		fetchedAt time.Time
+		// the save file holds a New Moon the lookup would never give, to tell which answered
+		fromSave  bool
+	}{
+		{"the clock jumped forward since", clock.Add(-10 * 24 * time.Hour), true},
+		{"the clock set back within the skew", clock.Add(30 * time.Minute), true},
+		{"the clock set back past the skew", clock.Add(3 * time.Hour), false},
+		{"the clock set back a week", clock.Add(7 * 24 * time.Hour), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := fmt.Sprintf("%s\n2024-03-25,New Moon,%d\n", saveFileHeader, test.fetchedAt.Unix())
+			if err := os.WriteFile(saveFile, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			run := runMoonphase(t, dir, "-date", "2024-03-25", "-plaintext", "-timezone", "UTC", "-savefile", saveFile, "-cache-dir", cacheDir, "-now-file", nowFile)
+			want := "Full Moon\n"
+			if (test.fromSave) {
+				want = "New Moon\n"
+			}
+			if (run.code != 0 || run.stdout != want) {
+				t.Fatalf("exit %d, printed %q, want %q:\n%s", run.code, run.stdout, want, run.stderr)
+			}
+			if warned := strings.Contains(run.stderr, "after the current time"); (warned == test.fromSave) {
+				t.Errorf("stderr %q", run.stderr)
+			}
+			saved, err := os.ReadFile(saveFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := parseSaveFile(string(saved))
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := entries[moonphase.NewDayKey(day)]
+			if (test.fromSave && (entry.phase != moonphase.NewMoon || !entry.fetchedAt.Equal(time.Unix(test.fetchedAt.Unix(), 0)))) {
+				t.Errorf("rewrote the entry it answered from: %+v", entry)
+			}
+			if (!test.fromSave && (entry.phase != moonphase.FullMoon || absDuration(entry.fetchedAt.Sub(clock)) > time.Minute)) {
+				t.Errorf("saved %+v, want the Full Moon fetched at %s", entry, clock.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+// a read-only filesystem or a refused permission, however wrapped, is the save file's location
+// being read-only, anything else is a real failure
+func TestIsReadOnlyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&os.PathError{Op: "open", Path: "/home/moonphase", Err: syscall.EROFS}, true},
+		{&os.PathError{Op: "mkdir", Path: "/home/.cache", Err: syscall.EACCES}, true},
+		{&os.PathError{Op: "open", Path: "/home/moonphase", Err: syscall.EPERM}, true},
+		{fmt.Errorf("writing the save file: %w", &os.LinkError{Op: "rename", Old: "a", New: "b", Err: syscall.EROFS}), true},
+		{os.ErrPermission, true},
+		{&os.PathError{Op: "write", Path: "/home/moonphase", Err: syscall.ENOSPC}, false},
+		{&os.PathError{Op: "mkdir", Path: "/home/moonphase", Err: syscall.ENOTDIR}, false},
+		{os.ErrNotExist, false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := isReadOnlyError(test.err); (got != test.want) {
+			t.Errorf("%v: got %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+// returns a directory the test can't write to, skipping the test where permissions aren't
+// enforced, as for root
+func readOnlyDir(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "read-only")
+	if err := os.Mkdir(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+	if probe, err := os.Create(filepath.Join(dir, "probe")); (err == nil) {
+		probe.Close()
+		t.Skip("permissions aren't enforced for this user")
+	}
+	return dir
+}
+
+// a save file in a directory that can't be written to is given up on for the rest of the process
+// after the first write, leaving nothing behind
+func TestSavePhaseToReadOnlyDir(t *testing.T) {
+	dir := readOnlyDir(t)
+	defer func() { saveFileReadOnly = false }()
+	path := filepath.Join(dir, "moonphase")
+	now := time.Now()
+	savePhaseToFile(moonphase.NewDayKey(now), moonphase.FullMoon, now, path)
+	if (!saveFileReadOnly) {
+		t.Fatal("the save file isn't marked read-only")
+	}
+	os.Chmod(dir, 0755)
+	// remembered, so a later lookup doesn't try again even once it could
+	savePhaseToFile(moonphase.NewDayKey(now).AddDays(1), moonphase.FullMoon, now, path)
+	if files, _ := os.ReadDir(dir); (len(files) != 0) {
+		t.Errorf("left %v behind", files)
+	}
+}
+
+// a lookup saving to a read-only location still prints the phase and exits 0, with no more than
+// a notice under -verbose
+func TestLookupWithReadOnlySaveFile(t *testing.T) {
+	readOnly := readOnlyDir(t)
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	cacheRecordedResponse(t, cacheDir, time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC))
+	for _, saveFile := range []string{filepath.Join(readOnly, "moonphase"), filepath.Join(readOnly, "nested", "moonphase")} {
+		args := []string{"-date", "2024-03-25", "-plaintext", "-timezone", "UTC", "-savefile", saveFile, "-cache-dir", cacheDir}
+		run := runMoonphase(t, dir, args...)
+		if (run.code != 0 || run.stdout != "Full Moon\n" || run.stderr != "") {
+			t.Errorf("%s: exit %d, printed %q:\n%s", saveFile, run.code, run.stdout, run.stderr)
+		}
+		verboseRun := runMoonphase(t, dir, append(args, "-verbose")...)
+		if (verboseRun.code != 0 || strings.Count(verboseRun.stderr, "not saving phases") != 1) {
+			t.Errorf("%s -verbose: exit %d, want one notice:\n%s", saveFile, verboseRun.code, verboseRun.stderr)
+		}
+	}
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/otlp"
+)
+
+// how long after local midnight the new day's entry is fetched, so a clock a little behind
+// doesn't fetch the day that just ended
+const serveRefreshDelay time.Duration = 5 * time.Minute
+
+// the most days kept in memory, past this the cache starts over
+const serveCacheMaxDays int = 1000
+
+// how long requests in flight get to finish once the server is stopped
+const serveShutdownTimeout time.Duration = 5 * time.Second
+
+// the longest range GET /phases lists, as long as calendar's longest -months
+const servePhasesMaxYears int = 10
+
+// the types GET /phase answers in, the first when the request doesn't say
+var phaseContentTypes = []string{"application/json", "text/plain", "text/html"}
+
+// the types GET /phases answers in, the first when the request doesn't say
+var phasesContentTypes = []string{"application/json", "text/calendar"}
+
+// the page fragment GET /phase answers text/html with, for pages that include it as it is
+var phaseFragmentTemplate = htmltemplate.Must(htmltemplate.New("phase").Parse(`<div class="moon-phase" data-date="{{.Date}}" data-phase-id="{{.PhaseID}}">
+<span class="moon-phase-emoji">{{.Emoji}}</span> <span class="moon-phase-name">{{with .Name}}{{.}}{{else}}{{.Phase}}{{end}}</span> <span class="moon-phase-illumination">{{.Illumination}}%</span>
+</div>
+`))
+
+// what's cached for a day, the parts of its phaseReport the flags don't decide
+type serveEntry struct {
+	result       moonphase.PhaseResult
+	illumination float64
+}
+
+// the handlers behind serve, looking days up through client and keeping them in memory
+type phaseServer struct {
+	client       *moonphase.Client
+	location     *time.Location
+	lang         string
+	emojiStyle   string
+	hemisphere   moonphase.Hemisphere
+	checkHorizon func(day moonphase.DayKey, today moonphase.DayKey) error
+	// the bearer token every request but the health checks needs, none when empty
+	apiKey       string
+	// the origins browsers may call the server from, CORS is off when empty
+	corsOrigins  []string
+	// serve the net/http/pprof profiles, and the debug routes to other hosts than localhost
+	debugPprof   bool
+	debugRemote  bool
+	mutex        sync.Mutex
+	entries      map[moonphase.DayKey]serveEntry
+	// the failed lookups by errorKind, for /debug/vars
+	errorCounts  map[string]int
+}
+
+// looks day up, bypassing and then replacing any entry in memory
+func (s *phaseServer) refresh(ctx context.Context, day moonphase.DayKey) (serveEntry, error) {
+	start := day.Time(s.location)
+	result, err := s.client.GetPhaseResultForDate(ctx, start, moonphase.SnapStrategy)
+	if err != nil {
+		s.countError(err)
+		return serveEntry{}, err
+	}
+	illumination, err := s.client.GetIllumination(ctx, start)
+	if err != nil {
+		s.countError(err)
+		return serveEntry{}, err
+	}
+	entry := serveEntry{result: result, illumination: illumination}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if (len(s.entries) >= serveCacheMaxDays) {
+		s.entries = map[moonphase.DayKey]serveEntry{}
+	}
+	s.entries[day] = entry
+	return entry, nil
+}
+
+// returns day's entry from memory, looking it up the first time it's asked for
+func (s *phaseServer) lookup(ctx context.Context, day moonphase.DayKey) (serveEntry, error) {
+	s.mutex.Lock()
+	entry, found := s.entries[day]
+	s.mutex.Unlock()
+	if (found) {
+		return entry, nil
+	}
+	debugf("serve: looking up %s", day)
+	return s.refresh(ctx, day)
+}
+
+// refetches the current day's entry shortly after each local midnight until ctx is done, so the
+// first request of the day doesn't wait on the API
+func (s *phaseServer) refreshDaily(ctx context.Context) {
+	for {
+		now := time.Now().In(s.location)
+		midnight := moonphase.NewDayKey(now).AddDays(1).Time(s.location)
+		timer := time.NewTimer(midnight.Add(serveRefreshDelay).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		today := moonphase.NewDayKey(time.Now().In(s.location))
+		if _, err := s.refresh(ctx, today); err != nil {
+			log.Printf("warning: refreshing %s: %v", today, err)
+		}
+	}
+}
+
+// returns the HTTP status for a failed lookup, from the exit status the CLI would give it
+func httpStatusFor(err error) int {
+	switch exitCodeFor(err) {
+	case exitInvalidArguments:
+		return http.StatusBadRequest
+	case exitNetwork, exitParse:
+		return http.StatusBadGateway
+	case exitInterrupted:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writes value as the JSON response with status
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		debugf("serve: writing response: %v", err)
+	}
+}
+
+// writes err as a JSON error response with status
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+// an RFC 7807 problem, the body of requests refused before they reach a handler
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// the content types there are, for a 406
+	Supported []string `json:"supported,omitempty"`
+}
+
+// writes an RFC 7807 problem response with status, detail saying what was wrong
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	writeProblemBody(w, problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail})
+}
+
+// writes body as an RFC 7807 problem response with its status
+func writeProblemBody(w http.ResponseWriter, body problem) {
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(body.Status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		debugf("serve: writing response: %v", err)
+	}
+}
+
+// refuses requests without "Authorization: Bearer apiKey" with a 401. the token is compared in
+// constant time, so how long the check takes doesn't give away how much of a guess was right
+func requireAPIKey(apiKey string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if (!found) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="moonphase"`)
+			writeProblem(w, http.StatusUnauthorized, "missing bearer token, send Authorization: Bearer <api key>")
+			return
+		}
+		if (subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(apiKey)) != 1) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="moonphase", error="invalid_token"`)
+			writeProblem(w, http.StatusUnauthorized, "invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adds the CORS headers for requests from origins, "*" for any, and answers their preflight
+// requests. a preflight from any other origin is refused with a 403, its other requests are
+// served without the headers, so the browser keeps the response from the page
+func allowCORS(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if (origin == "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		allowed := false
+		for _, known := range origins {
+			if (known == "*" || known == origin) {
+				allowed = true
+			}
+		}
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if (!allowed) {
+			if (preflight) {
+				writeProblem(w, http.StatusForbidden, fmt.Sprintf("origin %s isn't allowed", origin))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if (preflight) {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Accept")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// the span traceRequests starts for each request, the parent of the client's spans for its lookup
+const serveRequestSpan string = "moonphase.serve.request"
+
+// how often the spans of -otlp-endpoint are sent to the collector
+const serveTraceExportInterval time.Duration = 5 * time.Second
+
+// a ResponseWriter remembering the status written, for the span of the request
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if (w.status == 0) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(body []byte) (int, error) {
+	if (w.status == 0) {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(body)
+}
+
+// starts a span with tracer for each request, with its method, path and status as attributes.
+// a 5xx fails the span
+func traceRequests(tracer moonphase.Tracer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), serveRequestSpan)
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		if (recorder.status == 0) {
+			recorder.status = http.StatusOK
+		}
+		span.SetAttribute("http.status_code", recorder.status)
+		var err error
+		if (recorder.status >= 500) {
+			err = errors.New(http.StatusText(recorder.status))
+		}
+		span.End(err)
+	})
+}
+
+// returns the type in offered to answer r with, from its Accept header. when r accepts none of
+// them it writes a 406 listing them and returns false
+func negotiate(w http.ResponseWriter, r *http.Request, offered []string) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+	contentType, found := negotiateContentType(r.Header.Get("Accept"), offered)
+	if (!found) {
+		writeProblemBody(w, problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(http.StatusNotAcceptable),
+			Status:    http.StatusNotAcceptable,
+			Detail:    fmt.Sprintf("%s can be answered with %s", r.URL.Path, strings.Join(offered, ", ")),
+			Supported: offered,
+		})
+	}
+	return contentType, found
+}
+
+// refuses r with a 405 unless it's a GET or HEAD
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s isn't allowed, use GET", r.Method))
+		return false
+	}
+	return true
+}
+
+// GET /phase?date=2024-03-25, today in the server's -timezone without a date
+// as JSON, the phase ID alone as text/plain, or an HTML fragment, as the Accept header prefers
+func (s *phaseServer) handlePhase(w http.ResponseWriter, r *http.Request) {
+	if (!allowGet(w, r)) {
+		return
+	}
+	contentType, found := negotiate(w, r, phaseContentTypes)
+	if (!found) {
+		return
+	}
+	now := time.Now()
+	today := moonphase.NewDayKey(now.In(s.location))
+	day := today
+	if dateArg := r.URL.Query().Get("date"); (dateArg != "") {
+		parsed, err := parseDateArg(dateArg, now, s.location)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		day = parsed
+	}
+	if err := s.checkHorizon(day, today); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	entry, err := s.lookup(r.Context(), day)
+	if err != nil {
+		log.Printf("looking up %s: %v", day, err)
+		writeJSONError(w, httpStatusFor(err), err)
+		return
+	}
+	report := newPhaseReport(entry.result, entry.illumination, entry.result.Age, s.lang, s.emojiStyle, s.hemisphere)
+	switch contentType {
+	case "text/plain":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, report.PhaseID)
+	case "text/html":
+		var fragment bytes.Buffer
+		if err := phaseFragmentTemplate.Execute(&fragment, report); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(fragment.Bytes())
+	default:
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// a primary phase in GET /phases, with the fields around -json has for it
+type servePhaseEvent struct {
+	Phase          moonphase.Phase   `json:"phase"`
+	PhaseID        string            `json:"phase_id"`
+	Instant        moonphase.Instant `json:"instant"`
+	LocalDate      moonphase.DayKey  `json:"local_date"`
+	Emoji          string            `json:"emoji"`
+	EmojiShortcode string            `json:"emoji_shortcode"`
+	// the phase name in -lang, only when it isn't English
+	Name           string            `json:"name,omitempty"`
+}
+
+// returns the day query parameter name of r holds, fallback when it's missing
+func queryDay(r *http.Request, name string, fallback moonphase.DayKey, now time.Time, location *time.Location) (moonphase.DayKey, error) {
+	value := r.URL.Query().Get(name)
+	if (value == "") {
+		return fallback, nil
+	}
+	day, err := parseDateArg(value, now, location)
+	if err != nil {
+		return moonphase.DayKey{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return day, nil
+}
+
+// GET /phases?start=2024-03-01&end=2024-03-31, the primary phases from the start of start to the
+// end of end, a month from today without them. as JSON, or as an iCalendar file like calendar
+// writes for text/calendar
+func (s *phaseServer) handlePhases(w http.ResponseWriter, r *http.Request) {
+	if (!allowGet(w, r)) {
+		return
+	}
+	contentType, found := negotiate(w, r, phasesContentTypes)
+	if (!found) {
+		return
+	}
+	now := time.Now()
+	today := moonphase.NewDayKey(now.In(s.location))
+	startDay, err := queryDay(r, "start", today, now, s.location)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	endDay, err := queryDay(r, "end", moonphase.NewDayKey(startDay.Time(s.location).AddDate(0, 1, -1)), now, s.location)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	start := startDay.Time(s.location)
+	end := endDay.AddDays(1).Time(s.location)
+	if (endDay.Before(startDay) || end.After(start.AddDate(servePhasesMaxYears, 0, 0))) {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("end must be on or after start and at most %d years on", servePhasesMaxYears))
+		return
+	}
+	for _, day := range []moonphase.DayKey{startDay, endDay} {
+		if err := s.checkHorizon(day, today); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	phases, err := s.client.PhasesBetween(r.Context(), start, end)
+	if err != nil {
+		log.Printf("looking up %s to %s: %v", startDay, endDay, err)
+		writeJSONError(w, httpStatusFor(err), err)
+		return
+	}
+	if (contentType == "text/calendar") {
+		var calendar bytes.Buffer
+		if err := moonphase.WriteICS(&calendar, phases); err != nil {
+			writeJSONError(w, httpStatusFor(err), err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write(calendar.Bytes())
+		return
+	}
+	events := make([]servePhaseEvent, 0, len(phases))
+	for _, phase := range phases {
+		instant, err := moonphase.GetPhaseInstant(phase)
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, err)
+			return
+		}
+		eventPhase := moonphase.Phase(phase.Phase)
+		events = append(events, servePhaseEvent{
+			Phase:          eventPhase,
+			PhaseID:        moonphase.PhaseID(phase.Phase),
+			Instant:        moonphase.Instant{Time: instant},
+			LocalDate:      moonphase.NewDayKey(instant.In(s.location)),
+			Emoji:          getOutput(eventPhase, false, s.lang, emojiFieldStyle(s.emojiStyle), s.hemisphere),
+			EmojiShortcode: getOutput(eventPhase, false, s.lang, "shortcode", s.hemisphere),
+			Name:           localizedName(eventPhase, s.lang),
+		})
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// GET /healthz and /livez, answer as long as the server is up, without touching the API or
+// needing the -api-key
+func (s *phaseServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// GET /capabilities, what introspect -json prints, for clients checking what the server supports
+func (s *phaseServer) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, getCapabilities(s.client))
+}
+
+// GET /metrics, today's phase metrics in the Prometheus text exposition format, as
+// -textfile-metrics writes them
+func (s *phaseServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if (!allowGet(w, r)) {
+		return
+	}
+	now := time.Now()
+	today := moonphase.NewDayKey(now.In(s.location))
+	entry, err := s.lookup(r.Context(), today)
+	if err != nil {
+		log.Printf("looking up %s: %v", today, err)
+		writeJSONError(w, httpStatusFor(err), err)
+		return
+	}
+	metrics, err := collectMetrics(r.Context(), s.client, entry.result.Phase, now, false)
+	if err != nil {
+		log.Printf("collecting the metrics: %v", err)
+		writeJSONError(w, httpStatusFor(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, metrics)
+}
+
+// returns the routes of the server
+func (s *phaseServer) handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("/phase", s.handlePhase)
+	api.HandleFunc("/phases", s.handlePhases)
+	api.HandleFunc("/capabilities", s.handleCapabilities)
+	api.HandleFunc("/metrics", s.handleMetrics)
+	s.addDebugRoutes(api)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/livez", s.handleHealthz)
+	if (s.apiKey != "") {
+		mux.Handle("/", requireAPIKey(s.apiKey, api))
+	} else {
+		mux.Handle("/", api)
+	}
+	var routes http.Handler = mux
+	if (len(s.corsOrigins) > 0) {
+		routes = allowCORS(s.corsOrigins, routes)
+	}
+	if (s.client.Tracer != nil) {
+		routes = traceRequests(s.client.Tracer, routes)
+	}
+	return routes
+}
+
+// splits a comma-separated -cors-origin, dropping a trailing / from each origin since browsers
+// send them without one
+func parseCORSOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); (origin != "") {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// the flags of serve, read once they have been parsed
+type serveOptions struct {
+	listenFlag       *string
+	emojiStyleFlag   *string
+	apiKeyFlag       *string
+	corsOriginFlag   *string
+	pprofFlag        *bool
+	debugRemoteFlag  *bool
+	otlpEndpointFlag *string
+	applyClientFlags func()
+	checkHorizon     func(day moonphase.DayKey, today moonphase.DayKey) error
+	getLocation      func() *time.Location
+	getHemisphere    func() moonphase.Hemisphere
+	getLanguage      func() string
+	applyQuiet       func()
+}
+
+// defines the flags of serve on flags, for the command to parse and for completion to list
+func defineServeFlags(flags *flag.FlagSet) *serveOptions {
+	options := &serveOptions{}
+	options.listenFlag = flags.String("listen", ":8080", message("Address to serve HTTP on"))
+	options.emojiStyleFlag = flags.String("emoji-style", "auto", message("Emoji presentation in the emoji field: auto, emoji, text or shortcode"))
+	options.apiKeyFlag = flags.String("api-key", "", message("Bearer token requests need, except /healthz and /livez, none when empty"))
+	options.corsOriginFlag = flags.String("cors-origin", "", message("Origins browsers may call the server from, comma-separated or *, CORS is off when empty"))
+	options.pprofFlag = flags.Bool("debug-endpoints", false, message("Also serve the net/http/pprof profiles under /debug/pprof/"))
+	options.debugRemoteFlag = flags.Bool("debug-remote", false, message("Serve /debug/vars and the -debug-endpoints to other hosts than localhost too"))
+	options.otlpEndpointFlag = flags.String("otlp-endpoint", "", message("OpenTelemetry collector to send traces of the requests to over OTLP/HTTP, like http://localhost:4318, none when empty"))
+	flags.BoolVar(&verbose, "verbose", false, message("Log each day looked up to stderr"))
+	options.applyClientFlags = addClientFlags(flags)
+	options.checkHorizon = addHorizonCheckFlags(flags)
+	options.getLocation = addTimezoneFlag(flags)
+	addDateLayoutFlag(flags)
+	options.getHemisphere = addHemisphereFlag(flags)
+	options.getLanguage = addLanguageFlag(flags)
+	options.applyQuiet = addQuietFlag(flags)
+	return options
+}
+
+// moonphase serve -listen :8080
+func runServe(args []string) {
+	serveFlags := newFlagSet("serve")
+	options := defineServeFlags(serveFlags)
+	serveFlags.Parse(args)
+	options.applyQuiet()
+	applyConfig(serveFlags)
+	normalizeStringFlags(serveFlags)
+	options.applyClientFlags()
+
+	emojiStyle, err := parseEmojiStyle(*options.emojiStyleFlag)
+	if err != nil {
+		fatal(err)
+	}
+	var exporter *otlp.Exporter
+	if (*options.otlpEndpointFlag != "") {
+		if _, err := url.ParseRequestURI(*options.otlpEndpointFlag); err != nil {
+			fatal(argumentError{fmt.Errorf("invalid -otlp-endpoint %q, expected a URL like http://localhost:4318", *options.otlpEndpointFlag)})
+		}
+		exporter = otlp.NewExporter(*options.otlpEndpointFlag, "moonphase")
+		client.Tracer = exporter
+		go exporter.Run(ctx, serveTraceExportInterval, func(err error) {
+			log.Printf("warning: %v", err)
+		})
+	}
+	server := &phaseServer{
+		client:       client,
+		location:     options.getLocation(),
+		lang:         options.getLanguage(),
+		emojiStyle:   emojiStyle,
+		hemisphere:   options.getHemisphere(),
+		checkHorizon: options.checkHorizon,
+		apiKey:       *options.apiKeyFlag,
+		corsOrigins:  parseCORSOrigins(*options.corsOriginFlag),
+		debugPprof:   *options.pprofFlag,
+		debugRemote:  *options.debugRemoteFlag,
+		entries:      map[moonphase.DayKey]serveEntry{},
+	}
+	listener, err := net.Listen("tcp", *options.listenFlag)
+	if err != nil {
+		fatal(argumentError{fmt.Errorf("can't listen on -listen %s: %v", *options.listenFlag, err)})
+	}
+	httpServer := &http.Server{
+		Handler: server.handler(),
+		// not ctx, which Ctrl-C cancels, so requests in flight get to finish while Shutdown drains
+		// them. a lookup still stops once its client goes away
+		BaseContext: func(net.Listener) context.Context {
+			return context.Background()
+		},
+	}
+	go server.refreshDaily(ctx)
+	// closed once requests in flight have finished after ctx is done
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+		close(stopped)
+	}()
+	fmt.Fprintf(stderr, "serving on http://%s\n", listener.Addr())
+	if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		fatal(err)
+	}
+	<-stopped
+	if (exporter != nil) {
+		flushCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		if err := exporter.Flush(flushCtx); err != nil {
+			log.Printf("warning: %v", err)
+		}
+		cancel()
+	}
+	log.Printf("stopped serving: %v", ctx.Err())
+	if (errors.Is(ctx.Err(), context.Canceled)) {
+		os.Exit(exitInterrupted)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// returns a server that answers from the mean lunar cycle, without the API
+func newTestServer(apiKey string, corsOrigin string) *phaseServer {
+	return &phaseServer{
+		client:       &moonphase.Client{Source: moonphase.OfflineProvider{}},
+		location:     time.UTC,
+		lang:         "en",
+		emojiStyle:   "auto",
+		checkHorizon: moonphase.NewHorizon().Check,
+		apiKey:       apiKey,
+		corsOrigins:  parseCORSOrigins(corsOrigin),
+		entries:      map[moonphase.DayKey]serveEntry{},
+	}
+}
+
+// serves one request through s's routes and middleware
+func serveRequest(s *phaseServer, r *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.handler().ServeHTTP(recorder, r)
+	return recorder
+}
+
+func TestServeAPIKey(t *testing.T) {
+	server := newTestServer("s3cret", "")
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{"authorized", "/capabilities", "Bearer s3cret", http.StatusOK},
+		{"authorized phase", "/phase?date=2024-03-25", "Bearer s3cret", http.StatusOK},
+		{"missing", "/capabilities", "", http.StatusUnauthorized},
+		{"wrong", "/phase", "Bearer s3cre", http.StatusUnauthorized},
+		{"not bearer", "/phase", "Basic czNjcmV0", http.StatusUnauthorized},
+		{"health check", "/healthz", "", http.StatusOK},
+		{"liveness check", "/livez", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if (test.authorization != "") {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			response := serveRequest(server, request)
+			if (response.Code != test.want) {
+				t.Fatalf("status %d, want %d: %s", response.Code, test.want, response.Body)
+			}
+			if (test.want != http.StatusUnauthorized) {
+				return
+			}
+			if got := response.Header().Get("Content-Type"); (got != "application/problem+json; charset=utf-8") {
+				t.Errorf("Content-Type %q, want application/problem+json; charset=utf-8", got)
+			}
+			if (response.Header().Get("WWW-Authenticate") == "") {
+				t.Errorf("no WWW-Authenticate header")
+			}
+			var body problem
+			if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if (body.Status != http.StatusUnauthorized || body.Title != "Unauthorized" || body.Detail == "") {
+				t.Errorf("problem %+v", body)
+			}
+		})
+	}
+}
+
+func TestServeCORS(t *testing.T) {
+	server := newTestServer("s3cret", "https://myapp.example/")
+
+	// the preflight carries no Authorization, it's answered before the key is checked
+	preflight := httptest.NewRequest(http.MethodOptions, "/phase", nil)
+	preflight.Header.Set("Origin", "https://myapp.example")
+	preflight.Header.Set("Access-Control-Request-Method", "GET")
+	preflight.Header.Set("Access-Control-Request-Headers", "authorization")
+	response := serveRequest(server, preflight)
+	if (response.Code != http.StatusNoContent) {
+		t.Fatalf("preflight status %d, want %d", response.Code, http.StatusNoContent)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://myapp.example",
+		"Access-Control-Allow-Methods": "GET, HEAD, OPTIONS",
+		"Access-Control-Allow-Headers": "Authorization, Accept",
+		"Vary":                         "Origin",
+	} {
+		if got := response.Header().Get(header); (got != want) {
+			t.Errorf("preflight %s %q, want %q", header, got, want)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/capabilities", nil)
+	request.Header.Set("Origin", "https://myapp.example")
+	request.Header.Set("Authorization", "Bearer s3cret")
+	response = serveRequest(server, request)
+	if (response.Code != http.StatusOK || response.Header().Get("Access-Control-Allow-Origin") != "https://myapp.example") {
+		t.Errorf("allowed origin: status %d, Access-Control-Allow-Origin %q", response.Code, response.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	disallowed := httptest.NewRequest(http.MethodOptions, "/phase", nil)
+	disallowed.Header.Set("Origin", "https://evil.example")
+	disallowed.Header.Set("Access-Control-Request-Method", "GET")
+	response = serveRequest(server, disallowed)
+	if (response.Code != http.StatusForbidden || response.Header().Get("Access-Control-Allow-Origin") != "") {
+		t.Errorf("disallowed preflight: status %d, Access-Control-Allow-Origin %q", response.Code, response.Header().Get("Access-Control-Allow-Origin"))
+	}
+	request = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	request.Header.Set("Origin", "https://evil.example")
+	response = serveRequest(server, request)
+	if (response.Code != http.StatusOK || response.Header().Get("Access-Control-Allow-Origin") != "") {
+		t.Errorf("disallowed origin: status %d, Access-Control-Allow-Origin %q", response.Code, response.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+// without -cors-origin there are no CORS headers and OPTIONS isn't a method /phase takes
+func TestServeCORSOff(t *testing.T) {
+	request := httptest.NewRequest(http.MethodOptions, "/phase", nil)
+	request.Header.Set("Origin", "https://myapp.example")
+	request.Header.Set("Access-Control-Request-Method", "GET")
+	response := serveRequest(newTestServer("", ""), request)
+	if (response.Code != http.StatusMethodNotAllowed || response.Header().Get("Access-Control-Allow-Origin") != "") {
+		t.Errorf("status %d, Access-Control-Allow-Origin %q", response.Code, response.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"application/json", "application/json"},
+		{"text/plain", "text/plain"},
+		{"TEXT/HTML", "text/html"},
+		{"*/*", "application/json"},
+		{"text/*", "text/plain"},
+		{"text/html;q=0.5, text/plain;q=0.9", "text/plain"},
+		{"text/html; q=0.9, application/json; q=0.8, */*; q=0.1", "text/html"},
+		// the most specific range decides, so json is refused even though */* takes anything
+		{"application/json;q=0, */*", "text/plain"},
+		{"text/*;q=0.2, text/html", "text/html"},
+		// a q out of range drops the range, leaving the one that's there
+		{"text/html;q=2, text/plain", "text/plain"},
+		{"image/png", ""},
+		{"text/plain;q=0", ""},
+		{"nonsense", ""},
+	}
+	for _, test := range tests {
+		got, found := negotiateContentType(test.accept, phaseContentTypes)
+		if (found != (test.want != "") || got != test.want) {
+			t.Errorf("Accept %q: got %q, %v, want %q", test.accept, got, found, test.want)
+		}
+	}
+}
+
+func TestServeAccept(t *testing.T) {
+	server := newTestServer("", "")
+	tests := []struct {
+		path        string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/phase?date=2024-03-25", "", http.StatusOK, "application/json; charset=utf-8", `"phase_id":"full-moon"`},
+		{"/phase?date=2024-03-25", "application/json", http.StatusOK, "application/json; charset=utf-8", `"phase_id":"full-moon"`},
+		{"/phase?date=2024-03-25", "text/plain", http.StatusOK, "text/plain; charset=utf-8", "full-moon\n"},
+		{"/phase?date=2024-03-25", "text/html", http.StatusOK, "text/html; charset=utf-8", `<div class="moon-phase" data-date="2024-03-25" data-phase-id="full-moon">`},
+		{"/phase?date=2024-03-25", "text/html;q=0.4, text/plain;q=0.8", http.StatusOK, "text/plain; charset=utf-8", "full-moon\n"},
+		{"/phase?date=2024-03-25", "*/*", http.StatusOK, "application/json; charset=utf-8", `"phase_id":"full-moon"`},
+		{"/phase?date=2024-03-25", "image/png", http.StatusNotAcceptable, "application/problem+json; charset=utf-8", `"supported":["application/json","text/plain","text/html"]`},
+		{"/phases?start=2024-03-01&end=2024-03-31", "text/calendar", http.StatusOK, "text/calendar; charset=utf-8", "BEGIN:VCALENDAR"},
+		{"/phases?start=2024-03-01&end=2024-03-31", "", http.StatusOK, "application/json; charset=utf-8", `"phase_id":"full-moon"`},
+		{"/phases?start=2024-03-01&end=2024-03-31", "text/html", http.StatusNotAcceptable, "application/problem+json; charset=utf-8", `"supported":["application/json","text/calendar"]`},
+		{"/phases?start=2024-03-31&end=2024-03-01", "", http.StatusBadRequest, "application/json; charset=utf-8", `"error"`},
+		{"/healthz", "", http.StatusOK, "text/plain; charset=utf-8", "ok\n"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if (test.accept != "") {
+			request.Header.Set("Accept", test.accept)
+		}
+		response := serveRequest(server, request)
+		if (response.Code != test.status || response.Header().Get("Content-Type") != test.contentType || !strings.Contains(response.Body.String(), test.body)) {
+			t.Errorf("%s Accept %q: status %d, Content-Type %q:\n%s", test.path, test.accept, response.Code, response.Header().Get("Content-Type"), response.Body)
+		}
+	}
+}
+
+// GET /phases lists each primary phase of the range once, in order
+func TestServePhasesJSON(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/phases?start=2024-03-01&end=2024-03-31", nil)
+	response := serveRequest(newTestServer("", ""), request)
+	var events []servePhaseEvent
+	if err := json.Unmarshal(response.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, event := range events {
+		got = append(got, event.LocalDate.String() + " " + event.PhaseID)
+	}
+	want := []string{"2024-03-03 last-quarter", "2024-03-10 new-moon", "2024-03-17 first-quarter", "2024-03-25 full-moon"}
+	if (!reflect.DeepEqual(got, want)) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// the JSON has the phase's name in the server's -lang beside the canonical phase, and no name
+// in English, where the phase is its name
+func TestServeLocalizedName(t *testing.T) {
+	for lang, want := range map[string]string{"es": "Luna llena", "de": "Vollmond", "en": ""} {
+		server := newTestServer("", "")
+		server.lang = lang
+		response := serveRequest(server, httptest.NewRequest(http.MethodGet, "/phase?date=2024-03-25", nil))
+		var report struct {
+			Phase moonphase.Phase `json:"phase"`
+			Name  *string         `json:"name"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if (report.Phase != moonphase.FullMoon) {
+			t.Errorf("%s: phase %q, want the canonical %q", lang, report.Phase, moonphase.FullMoon)
+		}
+		if (want == "" && report.Name != nil) {
+			t.Errorf("%s: name %q, want none", lang, *report.Name)
+		} else if (want != "" && (report.Name == nil || *report.Name != want)) {
+			t.Errorf("%s: name %v, want %q:\n%s", lang, report.Name, want, response.Body)
+		}
+	}
+}
+
+// with a Tracer on the client each request gets a span, the parent of the spans of the lookup
+// behind it, and an -otlp-endpoint that isn't a URL exits 2
+func TestServeTracing(t *testing.T) {
+	recorded := moonphasetest.RecordedUSNOServer(t, recordedUSNOFile(t))
+	tracer := moonphasetest.NewTracer()
+	server := newTestServer("", "")
+	server.client = moonphase.NewClient()
+	server.client.BaseURL = recorded.URL
+	server.client.Retries = 0
+	server.client.CacheDir = t.TempDir()
+	server.client.Tracer = tracer
+	if response := serveRequest(server, httptest.NewRequest(http.MethodGet, "/phase?date=2024-03-25", nil)); (response.Code != http.StatusOK) {
+		t.Fatalf("status %d: %s", response.Code, response.Body)
+	}
+	serveRequest(server, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	spans := tracer.Spans()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	// the illumination is looked up after the phase, from the response just cached
+	want := []string{serveRequestSpan, moonphase.SpanCacheLookup, moonphase.SpanUpstreamRequest, moonphase.SpanCacheLookup, serveRequestSpan}
+	if (!reflect.DeepEqual(names, want)) {
+		t.Fatalf("spans %q, want %q", names, want)
+	}
+	if request := spans[0]; (request.Parent != -1 || !request.Ended || request.Attributes["http.method"] != "GET" || request.Attributes["http.target"] != "/phase" || request.Attributes["http.status_code"] != 200) {
+		t.Errorf("the request span: %+v", request)
+	}
+	for _, span := range spans[1:4] {
+		if (span.Parent != 0 || !span.Ended) {
+			t.Errorf("%s: parent %d, want an ended child of the request", span.Name, span.Parent)
+		}
+	}
+	if (spans[3].Attributes["cache.result"] != "hit") {
+		t.Errorf("the illumination's cache lookup: %+v", spans[3])
+	}
+	if notFound := spans[4]; (notFound.Parent != -1 || notFound.Attributes["http.status_code"] != http.StatusNotFound || notFound.Err != nil) {
+		t.Errorf("the 404's span: %+v", notFound)
+	}
+
+	run := runMoonphase(t, t.TempDir(), "serve", "-listen", "127.0.0.1:0", "-otlp-endpoint", "localhost 4318")
+	if (run.code != exitInvalidArguments || !strings.Contains(run.stderr, "invalid -otlp-endpoint")) {
+		t.Errorf("exit %d:\n%s", run.code, run.stderr)
+	}
+}
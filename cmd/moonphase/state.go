@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parses a "key"="value" line of the state file, both quoted with strconv.Quote so an output of
+// several lines or a key holding = keeps to one line. a line from older versions, key=value as it
+// is, is read as it was written
+func parseStateLine(line string) (string, string, bool) {
+	if (!strings.HasPrefix(line, `"`)) {
+		return strings.Cut(line, "=")
+	}
+	quotedKey, err := strconv.QuotedPrefix(line)
+	if err != nil {
+		return "", "", false
+	}
+	quotedValue := strings.TrimPrefix(line[len(quotedKey):], "=")
+	if (len(quotedValue) == len(line) - len(quotedKey)) {
+		return "", "", false
+	}
+	key, err := strconv.Unquote(quotedKey)
+	if err != nil {
+		return "", "", false
+	}
+	value, err := strconv.Unquote(quotedValue)
+	if err != nil {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// loads the lines of the state file, a missing file is an empty state
+func loadState(stateFilePath string) map[string]string {
+	state := map[string]string{}
+	file, err := os.Open(stateFilePath)
+	if err != nil {
+		return state
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := parseStateLine(scanner.Text())
+		if (found) {
+			state[key] = value
+		}
+	}
+	return state
+}
+
+// replaces the state file with the given state
+func saveState(stateFilePath string, state map[string]string) {
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	// sorted so the file doesn't churn between runs
+	sort.Strings(keys)
+	var content strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s=%s\n", strconv.Quote(key), strconv.Quote(state[key]))
+	}
+	err := writeOutput(stateFilePath, false, defaultOutputMode, []byte(content.String()))
+	if (err != nil && !isReadOnlyError(err)) {
+		fatal(err)
+	}
+}
+
+// records output as the latest for key and reports whether it differs from the last one recorded
+func recordChangedOutput(stateFilePath string, key string, output string) bool {
+	state := loadState(stateFilePath)
+	if (state[key] == output) {
+		return false
+	}
+	state[key] = output
+	saveState(stateFilePath, state)
+	return true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	state := map[string]string{
+		"emoji":                         "🌕",
+		"template:{{.Phase}}\n{{.Age}}": "Full Moon\n14.8",
+		"coords":                        "Full Moon\nmoonrise 19:52  transit 00:58  moonset 06:55",
+		"export-manifest:/tmp/a=b.csv":  "2024-01-01,2024-12-31,events,usno",
+		"quotes":                        `say "cheese" \ again`,
+		"empty":                         "",
+	}
+	saveState(path, state)
+	if got := loadState(path); (!reflect.DeepEqual(got, state)) {
+		t.Errorf("got %q, want %q", got, state)
+	}
+}
+
+// a state file from before values were quoted still reads
+func TestStateReadsOldFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(path, []byte("emoji=🌕\nplaintext=Full Moon\nbroken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"emoji": "🌕", "plaintext": "Full Moon"}
+	if got := loadState(path); (!reflect.DeepEqual(got, want)) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// a multi-line output is compared whole, not by its first line
+func TestRecordChangedOutputMultiLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	for _, test := range []struct {
+		output  string
+		changed bool
+	}{
+		{"Full Moon\nmoonrise 19:52", true},
+		{"Full Moon\nmoonrise 19:52", false},
+		{"Full Moon\nmoonrise 20:41", true},
+		{"Full Moon", true},
+	} {
+		if changed := recordChangedOutput(path, "coords", test.output); (changed != test.changed) {
+			t.Errorf("%q: changed %v, want %v", test.output, changed, test.changed)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package moonphase
+
+import (
+	"time"
+)
+
+// A local calendar day, used wherever a day is persisted or compared so that the save file,
+// API requests and exports all agree on one canonical 2006-01-02 string
+type DayKey struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// returns the calendar day t falls on in its own location
+func NewDayKey(t time.Time) DayKey {
+	year, month, day := t.Date()
+	return DayKey{year, month, day}
+}
+
+// parses a day in the canonical 2006-01-02 form
+func ParseDayKey(s string) (DayKey, error) {
+	t, err := time.Parse(dateFormat, s)
+	if err != nil {
+		return DayKey{}, err
+	}
+	return NewDayKey(t), nil
+}
+
+// returns the canonical 2006-01-02 form of the day
+func (k DayKey) String() string {
+	return k.Time(time.UTC).Format(dateFormat)
+}
+
+// returns midnight at the start of the day in the given location
+func (k DayKey) Time(location *time.Location) time.Time {
+	return time.Date(k.Year, k.Month, k.Day, 0, 0, 0, 0, location)
+}
+
+// returns the day n days after this one, n may be negative
+func (k DayKey) AddDays(n int) DayKey {
+	return NewDayKey(k.Time(time.UTC).AddDate(0, 0, n))
+}
+
+// returns how many calendar days other is after the day, negative when it's before
+func (k DayKey) DaysUntil(other DayKey) int {
+	return int(other.Time(time.UTC).Sub(k.Time(time.UTC)).Hours() / 24)
+}
+
+// reports whether the day comes before other
+func (k DayKey) Before(other DayKey) bool {
+	return k.Time(time.UTC).Before(other.Time(time.UTC))
+}
+
+// reports whether the day comes after other
+func (k DayKey) After(other DayKey) bool {
+	return other.Before(k)
+}
+
+// marshals the day as 2006-01-02, so JSON outputs never carry a time or zone for a local date
+func (k DayKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// parses a day in the canonical 2006-01-02 form
+func (k *DayKey) UnmarshalText(text []byte) error {
+	day, err := ParseDayKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = day
+	return nil
+}
+
+// An exact moment, always marshaled as RFC 3339 in UTC with a trailing Z so machine outputs
+// never leak the local zone
+type Instant struct {
+	time.Time
+}
+
+// marshals the instant as RFC 3339 in UTC
+func (i Instant) MarshalText() ([]byte, error) {
+	return []byte(i.UTC().Format(time.RFC3339)), nil
+}
+
+// parses an RFC 3339 instant in any offset
+func (i *Instant) UnmarshalText(text []byte) error {
+	t, err := time.Parse(time.RFC3339, string(text))
+	if err != nil {
+		return err
+	}
+	i.Time = t
+	return nil
+}
@@ -0,0 +1,116 @@
+package moonphase_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// a client answering from the reference year's recorded phases rather than the USNO API, and a
+// clock stopped on the evening of the Full Moon of March 2024, so the examples repeat exactly
+func newExampleClient() (*moonphase.Client, *moonphasetest.FakeClock) {
+	client := &moonphase.Client{Source: moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)}
+	return client, moonphasetest.NewFakeClock(time.Date(2024, time.March, 25, 20, 0, 0, 0, time.UTC))
+}
+
+func ExampleClient_GetPhaseForDate() {
+	client, clock := newExampleClient()
+	for _, days := range []int{0, 3, 8} {
+		date := clock.Now().AddDate(0, 0, days)
+		phase, err := client.GetPhaseForDate(context.Background(), date, moonphase.SnapStrategy)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(date.Format("2006-01-02"), phase)
+	}
+	// Output:
+	// 2024-03-25 Full Moon
+	// 2024-03-28 Waning Gibbous
+	// 2024-04-02 Last Quarter
+}
+
+func ExampleClient_NextPhase() {
+	client, clock := newExampleClient()
+	next, err := client.NextPhase(context.Background(), clock.Now(), moonphase.NewMoon)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(next.Format(time.RFC3339), next.Sub(clock.Now()).Round(time.Hour))
+	// Output: 2024-04-08T18:21:00Z 334h0m0s
+}
+
+func ExampleGetCurrentPhase() {
+	phases := moonphasetest.MoonPhases(moonphasetest.ReferenceEvents()...)
+	day := time.Date(2024, time.March, 24, 0, 0, 0, 0, time.UTC)
+	for _, strategy := range []moonphase.ClassifyStrategy{moonphase.SnapStrategy, moonphase.PrimaryOnlyStrategy} {
+		phase, err := moonphase.GetCurrentPhase(day, phases, strategy)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(phase)
+	}
+	// Output:
+	// Full Moon
+	// First Quarter
+}
+
+func ExampleClient_PhasesBetween() {
+	client, _ := newExampleClient()
+	phases, err := client.PhasesBetween(context.Background(), time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, phase := range phases {
+		fmt.Printf("%d-%02d-%02d %s %s\n", phase.Year, phase.Month, phase.Day, phase.Time, phase.Phase)
+	}
+	// Output:
+	// 2024-03-03 15:23 Last Quarter
+	// 2024-03-10 09:00 New Moon
+	// 2024-03-17 04:11 First Quarter
+	// 2024-03-25 07:00 Full Moon
+}
+
+func ExampleClient_GetIllumination() {
+	client, clock := newExampleClient()
+	for _, at := range []time.Time{clock.Now(), clock.Now().AddDate(0, 0, 7)} {
+		illumination, err := client.GetIllumination(context.Background(), at)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%s %.0f%%\n", at.Format("2006-01-02"), illumination * 100)
+	}
+	// Output:
+	// 2024-03-25 100%
+	// 2024-04-01 53%
+}
+
+func ExampleClient_GetPhasesAround() {
+	client, clock := newExampleClient()
+	events, err := client.GetPhasesAround(context.Background(), clock.Now(), 2)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, event := range events {
+		fmt.Println(event.LocalDate, event.OffsetHuman, event.Phase)
+	}
+	// Output:
+	// 2024-03-17 -8d First Quarter
+	// 2024-03-25 0d Full Moon
+	// 2024-04-02 +8d Last Quarter
+	// 2024-04-08 +14d New Moon
+}
+
+func ExampleComputePhase() {
+	// from the mean cycle, without phase data, for any date, like the first Moon landing
+	fmt.Println(moonphase.ComputePhase(time.Date(1969, time.July, 20, 20, 17, 0, 0, time.UTC)))
+	// Output: Waxing Crescent
+}
@@ -0,0 +1,7 @@
+package moonphase
+
+// unexported functions the external tests need
+var RetryWait = retryWait
+var GetSnapWindow = getSnapWindow
+var EscapeICSText = escapeICSText
+var FoldICSLine = foldICSLine
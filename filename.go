@@ -0,0 +1,46 @@
+package moonphase
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// longest filename SanitizeFilename returns, in bytes, the limit on ext4 and most other filesystems
+const maxFilenameBytes int = 255
+
+// the device names Windows reserves, with or without an extension, in any case
+var reservedFilenames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// returns name made safe to use as a filename on Windows and Linux. runs of spaces become a single
+// "-", characters Windows doesn't allow and control characters are dropped, path separators
+// included, trailing dots are removed since Windows strips them, a name Windows reserves for a
+// device like NUL or con.txt gets a leading "_", and the result is cut to 255 bytes without
+// splitting a character. a name with nothing safe in it, like "" or "..", sanitizes to ""
+func SanitizeFilename(name string) string {
+	var sanitized strings.Builder
+	for _, r := range strings.Join(strings.Fields(name), "-") {
+		if (unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r)) {
+			continue
+		}
+		if (sanitized.Len() + utf8.RuneLen(r) > maxFilenameBytes) {
+			break
+		}
+		sanitized.WriteRune(r)
+	}
+	filename := strings.TrimRight(sanitized.String(), ".")
+	stem, _, _ := strings.Cut(filename, ".")
+	if (reservedFilenames[strings.ToUpper(stem)]) {
+		filename = "_" + filename
+		// the prefix can push a name at the limit over it
+		for (len(filename) > maxFilenameBytes) {
+			_, size := utf8.DecodeLastRuneInString(filename)
+			filename = strings.TrimRight(filename[:len(filename) - size], ".")
+		}
+	}
+	return filename
+}
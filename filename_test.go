@@ -0,0 +1,59 @@
+package moonphase_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"2024-03-25_full-moon.jpg", "2024-03-25_full-moon.jpg"},
+		{"Waxing  Gibbous\t73%.jpg", "Waxing-Gibbous-73%.jpg"},
+		{"  Full Moon  ", "Full-Moon"},
+		// path separators of either kind never make it a path
+		{"../../etc/passwd", "....etcpasswd"},
+		{`C:\Users\moon.jpg`, "CUsersmoon.jpg"},
+		{"a/b\\c", "abc"},
+		{`what<>:"|?*.jpg`, "what.jpg"},
+		{"bell\a\x00.jpg", "bell.jpg"},
+		{"trailing...", "trailing"},
+		// device names Windows reserves, in any case and with an extension
+		{"CON", "_CON"},
+		{"nul", "_nul"},
+		{"con.txt", "_con.txt"},
+		{"Com1.tar.gz", "_Com1.tar.gz"},
+		{"LPT9", "_LPT9"},
+		{"lpt0", "lpt0"},
+		{"CONSOLE", "CONSOLE"},
+		{"com10", "com10"},
+		{"my-con.txt", "my-con.txt"},
+		// nothing safe left
+		{"", ""},
+		{"   ", ""},
+		{".", ""},
+		{"..", ""},
+		{"/", ""},
+		{"???", ""},
+	}
+	for _, test := range tests {
+		if got := moonphase.SanitizeFilename(test.name); (got != test.want) {
+			t.Errorf("%q: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// long names are cut to 255 bytes on a character boundary, a reserved name's prefix included
+func TestSanitizeFilenameLength(t *testing.T) {
+	for _, name := range []string{strings.Repeat("a", 300), strings.Repeat("é", 200), "con." + strings.Repeat("x", 251), "nul." + strings.Repeat("🌕", 80)} {
+		got := moonphase.SanitizeFilename(name)
+		if (len(got) > 255 || len(got) < 250 || !utf8.ValidString(got)) {
+			t.Errorf("%.10q...: got %d bytes, valid UTF-8 %v", name, len(got), utf8.ValidString(got))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package moonphase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// FileProvider is a Provider serving the phases in a file saved from the USNO API, like the output
+// of moonphase -raw or a year query, so lookups can run without the network
+type FileProvider struct {
+	// the JSON file, in the API's response shape
+	Path string
+}
+
+// returns up to numPhases of the file's phases, the first being the first on or after date in UT.
+// a file holding fewer returns what it has, so a lookup past either end of it fails to classify
+// rather than getting a wrong answer
+func (p FileProvider) GetMoonData(ctx context.Context, date DayKey, numPhases int) ([]MoonPhase, error) {
+	phases, err := p.readPhases()
+	if err != nil {
+		return nil, err
+	}
+	start := date.Time(time.UTC)
+	first := sort.Search(len(phases), func(i int) bool {
+		return !phaseInstant(phases[i]).Before(start)
+	})
+	phases = phases[first:]
+	if (len(phases) > numPhases) {
+		phases = phases[:numPhases]
+	}
+	return phases, nil
+}
+
+// returned by FileProvider.CheckCoverage for a day the phases in its file don't reach
+type ErrOutsideFile struct {
+	Path string
+	// the day asked for
+	Day DayKey
+	// the UT days of the file's first and last phases, zero when it has none
+	First DayKey
+	Last  DayKey
+}
+
+func (e *ErrOutsideFile) Error() string {
+	if (e.First == DayKey{}) {
+		return fmt.Sprintf("%s holds no phases, asked for %s", e.Path, e.Day)
+	}
+	return fmt.Sprintf("%s only holds phases from %s to %s, asked for %s", e.Path, e.First, e.Last, e.Day)
+}
+
+// returns an ErrOutsideFile unless the file has a phase either side of day, the error reading it if
+// it can't be read. it allows a day at each end for the lookup being in another zone than UT, so a
+// day it passes can still fail to classify at the very ends of the file
+func (p FileProvider) CheckCoverage(day DayKey) error {
+	phases, err := p.readPhases()
+	if err != nil {
+		return err
+	}
+	if (len(phases) == 0) {
+		return &ErrOutsideFile{Path: p.Path, Day: day}
+	}
+	first := NewDayKey(phaseInstant(phases[0]))
+	last := NewDayKey(phaseInstant(phases[len(phases) - 1]))
+	if (first.After(day.AddDays(1)) || last.Before(day.AddDays(-1))) {
+		return &ErrOutsideFile{Path: p.Path, Day: day, First: first, Last: last}
+	}
+	return nil
+}
+
+// reads and checks the file's phases, in order
+func (p FileProvider) readPhases() ([]MoonPhase, error) {
+	content, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	var response MoonApiResponse
+	if err := json.Unmarshal(content, &response); err != nil {
+		return nil, &ErrMalformedResponse{Err: fmt.Errorf("%s: %w", p.Path, err)}
+	}
+	if err := checkPhases(response.Phasedata); err != nil {
+		return nil, &ErrMalformedResponse{Err: fmt.Errorf("%s: %w", p.Path, err)}
+	}
+	phases := response.Phasedata
+	// files joined from several responses needn't be in order
+	sort.SliceStable(phases, func(i, j int) bool {
+		return phaseInstant(phases[i]).Before(phaseInstant(phases[j]))
+	})
+	return phases, nil
+}
@@ -0,0 +1,38 @@
+package moonphase
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBeyondHorizon is wrapped by the error Horizon.Check returns for a day outside it
+var ErrBeyondHorizon = errors.New("beyond the horizon")
+
+// DefaultHorizonYears is how far either side of today a NewHorizon reaches
+const DefaultHorizonYears int = 200
+
+// how far from today lookups are allowed to reach, in whole years either side. it keeps a
+// script from looping an export out to the year 99999, and results far from the present
+// from looking more precise than they are
+type Horizon struct {
+	PastYears   int
+	FutureYears int
+}
+
+// returns a Horizon of DefaultHorizonYears either side
+func NewHorizon() Horizon {
+	return Horizon{PastYears: DefaultHorizonYears, FutureYears: DefaultHorizonYears}
+}
+
+// returns an error wrapping ErrBeyondHorizon if day is further from today than the horizon allows
+func (h Horizon) Check(day DayKey, today DayKey) error {
+	earliest := DayKey{Year: today.Year - h.PastYears, Month: today.Month, Day: today.Day}
+	latest := DayKey{Year: today.Year + h.FutureYears, Month: today.Month, Day: today.Day}
+	if (day.Before(earliest)) {
+		return fmt.Errorf("%w: %s is more than %d years before %s", ErrBeyondHorizon, day, h.PastYears, today)
+	}
+	if (day.After(latest)) {
+		return fmt.Errorf("%w: %s is more than %d years after %s", ErrBeyondHorizon, day, h.FutureYears, today)
+	}
+	return nil
+}
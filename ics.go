@@ -0,0 +1,75 @@
+package moonphase
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// the longest a content line can be in octets before it's folded, not counting the CRLF
+const icsMaxLineOctets int = 75
+
+// the iCalendar form of a UTC date-time
+const icsTimeFormat string = "20060102T150405Z"
+
+// escapes a TEXT value, the characters with a meaning of their own in a content line
+func escapeICSText(text string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\n", "\\n")
+	return replacer.Replace(text)
+}
+
+// folds a content line so no line is longer than icsMaxLineOctets, continuation lines start with
+// a space. lines are only broken between characters, so emoji are never split
+func foldICSLine(line string) string {
+	var folded strings.Builder
+	octets := 0
+	for _, r := range line {
+		size := len(string(r))
+		if (octets + size > icsMaxLineOctets) {
+			folded.WriteString("\r\n ")
+			// the space counts towards the continuation line
+			octets = 1
+		}
+		folded.WriteRune(r)
+		octets += size
+	}
+	folded.WriteString("\r\n")
+	return folded.String()
+}
+
+// WriteICS writes phases to w as an iCalendar file, one VEVENT per phase at the instant the API
+// gives for it, with the emoji and name as its summary. each event's UID is built from its instant
+// and phase, so importing a later export of the same phases updates events instead of repeating them
+func WriteICS(w io.Writer, phases []MoonPhase) error {
+	var calendar strings.Builder
+	writeLine := func(line string) {
+		calendar.WriteString(foldICSLine(line))
+	}
+	stamp := time.Now().UTC().Format(icsTimeFormat)
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//mitchthorson//go-moon-phase//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	for _, phase := range phases {
+		instant, err := GetPhaseInstant(phase)
+		if err != nil {
+			return err
+		}
+		name, err := ParsePhase(phase.Phase)
+		if err != nil {
+			return err
+		}
+		start := instant.Format(icsTimeFormat)
+		writeLine("BEGIN:VEVENT")
+		writeLine(fmt.Sprintf("UID:%s-%s@go-moon-phase", start, PhaseID(phase.Phase)))
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART:" + start)
+		writeLine("SUMMARY:" + escapeICSText(fmt.Sprintf("%s %s", name.Emoji(), name)))
+		writeLine("TRANSP:TRANSPARENT")
+		writeLine("END:VEVENT")
+	}
+	writeLine("END:VCALENDAR")
+	_, err := io.WriteString(w, calendar.String())
+	return err
+}
@@ -0,0 +1,182 @@
+package moonphase_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// undoes escapeICSText
+var unescapeICSText = strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", "\n", "\\N", "\n")
+
+// checks the content lines of an iCalendar file are CRLF terminated and folded to 75 octets, and
+// returns them unfolded
+func unfoldICS(t *testing.T, content string) []string {
+	t.Helper()
+	if (!strings.HasSuffix(content, "\r\n")) {
+		t.Fatalf("doesn't end with CRLF: %q", content[len(content) - 20:])
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\r\n"), "\r\n") {
+		if (len(line) > 75) {
+			t.Errorf("%d octets in %q", len(line), line)
+		}
+		if (!utf8.ValidString(line)) {
+			t.Errorf("folded inside a character: %q", line)
+		}
+		if (strings.Contains(line, "\n")) {
+			t.Errorf("bare LF in %q", line)
+		}
+		if (strings.HasPrefix(line, " ") && len(lines) > 0) {
+			lines[len(lines) - 1] += line[1:]
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parses the VEVENTs of unfolded lines into their properties
+func parseVEvents(t *testing.T, lines []string) []map[string]string {
+	t.Helper()
+	if (lines[0] != "BEGIN:VCALENDAR" || lines[len(lines) - 1] != "END:VCALENDAR") {
+		t.Fatalf("not a VCALENDAR: starts %q, ends %q", lines[0], lines[len(lines) - 1])
+	}
+	var events []map[string]string
+	var event map[string]string
+	for _, line := range lines[1:len(lines) - 1] {
+		name, value, found := strings.Cut(line, ":")
+		if (!found) {
+			t.Fatalf("no value in %q", line)
+		}
+		switch {
+		case (line == "BEGIN:VEVENT"):
+			if (event != nil) {
+				t.Fatal("VEVENT inside a VEVENT")
+			}
+			event = map[string]string{}
+		case (line == "END:VEVENT"):
+			if (event == nil) {
+				t.Fatal("END:VEVENT without a BEGIN")
+			}
+			events = append(events, event)
+			event = nil
+		case (event != nil):
+			if _, repeated := event[name]; (repeated) {
+				t.Errorf("%s twice in an event", name)
+			}
+			event[name] = value
+		}
+	}
+	if (event != nil) {
+		t.Fatal("VEVENT never ended")
+	}
+	return events
+}
+
+// the written calendar parses back to one event per phase, at the phase's instant, with a UID of
+// its own and the emoji and name as the summary
+func TestWriteICSParsesBack(t *testing.T) {
+	events := moonphasetest.ReferenceEvents()
+	var calendar bytes.Buffer
+	if err := moonphase.WriteICS(&calendar, moonphasetest.MoonPhases(events...)); err != nil {
+		t.Fatal(err)
+	}
+	parsed := parseVEvents(t, unfoldICS(t, calendar.String()))
+	if (len(parsed) != len(events)) {
+		t.Fatalf("%d events, want %d", len(parsed), len(events))
+	}
+	uids := map[string]bool{}
+	for i, event := range parsed {
+		if (uids[event["UID"]] || !strings.HasSuffix(event["UID"], "@go-moon-phase")) {
+			t.Errorf("UID %q is repeated or isn't ours", event["UID"])
+		}
+		uids[event["UID"]] = true
+		start, err := time.Parse("20060102T150405Z", event["DTSTART"])
+		if err != nil {
+			t.Errorf("DTSTART %q: %v", event["DTSTART"], err)
+		} else if (!start.Equal(events[i].Instant)) {
+			t.Errorf("DTSTART %s, want %s", start, events[i].Instant)
+		}
+		if _, err := time.Parse("20060102T150405Z", event["DTSTAMP"]); err != nil {
+			t.Errorf("DTSTAMP %q: %v", event["DTSTAMP"], err)
+		}
+		if want := events[i].Phase.Emoji() + " " + events[i].Phase.String(); (unescapeICSText.Replace(event["SUMMARY"]) != want) {
+			t.Errorf("SUMMARY %q, want %q", event["SUMMARY"], want)
+		}
+	}
+	// the same phases written again keep their UIDs, so a re-import updates instead of repeating
+	var again bytes.Buffer
+	if err := moonphase.WriteICS(&again, moonphasetest.MoonPhases(events[:3]...)); err != nil {
+		t.Fatal(err)
+	}
+	for i, event := range parseVEvents(t, unfoldICS(t, again.String())) {
+		if (event["UID"] != parsed[i]["UID"]) {
+			t.Errorf("UID %q the second time, %q the first", event["UID"], parsed[i]["UID"])
+		}
+	}
+}
+
+func TestEscapeICSText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"🌕 Full Moon", "🌕 Full Moon"},
+		{"Moon, full", "Moon\\, full"},
+		{"a;b", "a\\;b"},
+		{"back\\slash", "back\\\\slash"},
+		{"two\nlines", "two\\nlines"},
+		{"\\,;\n", "\\\\\\,\\;\\n"},
+	}
+	for _, test := range tests {
+		got := moonphase.EscapeICSText(test.text)
+		if (got != test.want) {
+			t.Errorf("escaped %q to %q, want %q", test.text, got, test.want)
+		}
+		if (unescapeICSText.Replace(got) != test.text) {
+			t.Errorf("%q doesn't unescape back to %q", got, test.text)
+		}
+	}
+}
+
+// a long line is folded at 75 octets, never inside an emoji, and unfolds back to itself
+func TestFoldICSLine(t *testing.T) {
+	for _, line := range []string{
+		"SUMMARY:short",
+		"SUMMARY:" + strings.Repeat("x", 67),
+		"SUMMARY:" + strings.Repeat("x", 68),
+		"SUMMARY:" + strings.Repeat("🌕", 40),
+		"DESCRIPTION:" + strings.Repeat("a🌗", 50),
+	} {
+		folded := moonphase.FoldICSLine(line)
+		if unfolded := unfoldICS(t, folded); (len(unfolded) != 1 || unfolded[0] != line) {
+			t.Errorf("%q unfolds to %q", line, unfolded)
+		}
+		if (len(line) <= 75 && strings.Contains(folded, "\r\n ")) {
+			t.Errorf("%d octets folded: %q", len(line), folded)
+		}
+	}
+}
+
+// a phase that doesn't parse fails the whole calendar rather than writing a broken event
+func TestWriteICSRejectsBadPhases(t *testing.T) {
+	badTime := moonphasetest.MoonPhases(moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:00"))
+	badTime[0].Time = "9am"
+	unknown := moonphasetest.MoonPhases(moonphasetest.Event(moonphase.NewMoon, "2024-03-10 09:00"))
+	unknown[0].Phase = "Half Moon"
+	for _, phases := range [][]moonphase.MoonPhase{badTime, unknown} {
+		var calendar bytes.Buffer
+		if err := moonphase.WriteICS(&calendar, phases); (err == nil) {
+			t.Errorf("wrote %+v without an error", phases[0])
+		}
+		if (calendar.Len() != 0) {
+			t.Errorf("wrote %d bytes before the error", calendar.Len())
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package moonphase
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// returns the approximate illuminated fraction of the moon, from 0 to 1, within a segment of the
+// cycle. the phase angle is taken to advance evenly from one primary phase to the next, a quarter
+// turn each, so it's exact at the primary phases and within a few percent between them
+func SegmentIllumination(segment CycleSegment) float64 {
+	angle := (float64(segment.Index - 1) + segment.Progress) * math.Pi / 2
+	return (1 - math.Cos(angle)) / 2
+}
+
+// Get the approximate illuminated fraction of the moon at t, from 0 at a New Moon to 1 at a Full Moon
+func (c *Client) GetIllumination(ctx context.Context, t time.Time) (float64, error) {
+	segment, err := c.GetSegmentForDate(ctx, t)
+	if err != nil {
+		return 0, err
+	}
+	return SegmentIllumination(segment), nil
+}
+
+// Get the approximate illuminated fraction of the moon at t using DefaultClient, see SegmentIllumination
+func Illumination(ctx context.Context, t time.Time) (float64, error) {
+	return DefaultClient.GetIllumination(ctx, t)
+}
@@ -0,0 +1,65 @@
+package moonphase_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// 0 at the New Moon, half at the quarters and all of it at the Full Moon, with the cosine between
+func TestSegmentIllumination(t *testing.T) {
+	tests := []struct {
+		index    int
+		progress float64
+		want     float64
+	}{
+		{1, 0, 0},
+		{1, 0.5, (1 - math.Sqrt(0.5)) / 2},
+		{2, 0, 0.5},
+		{2, 0.5, (1 + math.Sqrt(0.5)) / 2},
+		{3, 0, 1},
+		{3, 0.5, (1 + math.Sqrt(0.5)) / 2},
+		{4, 0, 0.5},
+		{4, 0.5, (1 - math.Sqrt(0.5)) / 2},
+		// the end of the last segment is the next New Moon
+		{4, 1, 0},
+	}
+	for _, test := range tests {
+		got := moonphase.SegmentIllumination(moonphase.CycleSegment{Index: test.index, Progress: test.progress})
+		if (math.Abs(got - test.want) > 1e-9) {
+			t.Errorf("segment %d at %.2f: got %.4f, want %.4f", test.index, test.progress, got, test.want)
+		}
+	}
+}
+
+// at the recorded instants of a lunation's primary phases the illumination is exactly 0, 50 and
+// 100%, and the day of a Full Moon is lit nearly all day
+func TestGetIllumination(t *testing.T) {
+	client := newReferenceClient()
+	tests := []struct {
+		at        time.Time
+		want      float64
+		tolerance float64
+	}{
+		{time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC), 0, 1e-9},
+		{time.Date(2024, time.March, 17, 4, 11, 0, 0, time.UTC), 0.5, 1e-9},
+		{time.Date(2024, time.March, 25, 7, 0, 0, 0, time.UTC), 1, 1e-9},
+		{time.Date(2024, time.April, 2, 3, 15, 0, 0, time.UTC), 0.5, 1e-9},
+		{time.Date(2024, time.April, 8, 18, 21, 0, 0, time.UTC), 0, 1e-9},
+		// the starts of the New and Full Moon days, hours from the instants
+		{time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC), 0, 0.01},
+		{time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), 1, 0.01},
+	}
+	for _, test := range tests {
+		got, err := client.GetIllumination(context.Background(), test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (math.Abs(got - test.want) > test.tolerance) {
+			t.Errorf("%s: got %.4f, want %.4f", test.at.Format(time.RFC3339), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package moonphase_test
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// the module's own import path, the one non-stdlib path a library package may import
+const modulePath string = "github.com/mitchthorson/go-moon-phase"
+
+// reports whether path is in the standard library, whose first element never has a dot in it
+func isStdlib(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+// the moonphase package only imports the standard library, and nothing meant for tests, so
+// vendoring it to embed the phase logic never drags in another module. moonphasetest and otlp add
+// only moonphase itself, and go.mod requires nothing
+func TestLibraryImportsOnlyStdlib(t *testing.T) {
+	packages := []struct {
+		dir string
+		// imports outside the standard library it may have
+		allowed map[string]bool
+		// standard library imports it may not have
+		denied  map[string]bool
+	}{
+		{".", nil, map[string]bool{"testing": true, "net/http/httptest": true}},
+		{"moonphasetest", map[string]bool{modulePath: true}, nil},
+		{"otlp", map[string]bool{modulePath: true}, map[string]bool{"testing": true, "net/http/httptest": true}},
+	}
+	for _, pkg := range packages {
+		paths, err := filepath.Glob(filepath.Join(pkg.dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checked := 0
+		for _, path := range paths {
+			if (strings.HasSuffix(path, "_test.go")) {
+				continue
+			}
+			file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checked++
+			for _, spec := range file.Imports {
+				imported, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ((!isStdlib(imported) && !pkg.allowed[imported]) || pkg.denied[imported]) {
+					t.Errorf("%s imports %s, library packages keep to the standard library", path, imported)
+				}
+			}
+		}
+		if (checked == 0) {
+			t.Errorf("no files checked in %s", pkg.dir)
+		}
+	}
+	goMod, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (strings.Contains(string(goMod), "require")) {
+		t.Errorf("go.mod requires other modules:\n%s", goMod)
+	}
+}
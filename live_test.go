@@ -0,0 +1,115 @@
+//go:build live
+// +build live
+
+package moonphase_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the user agent the contract test identifies itself with
+const liveUserAgent string = "go-moon-phase contract test (+https://github.com/mitchthorson/go-moon-phase)"
+
+// records the requests a client makes, on their way out
+type recordingTransport struct {
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func (r *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	r.mutex.Lock()
+	r.requests = append(r.requests, request)
+	r.mutex.Unlock()
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// returns the JSON object names of payload, sorted, failing t if it isn't an object
+func objectFields(t *testing.T, payload json.RawMessage) []string {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("%v: %s", err, payload)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the live USNO API still answers a fixed past date with the fields and types MoonApiResponse
+// and MoonPhase decode, and the phases recorded in usno-2024.json. run it with go test -tags live
+// before a release. it makes a single request, without retrying, and skips when the API can't
+// be reached
+func TestLiveUSNOContract(t *testing.T) {
+	transport := &recordingTransport{}
+	client := moonphase.NewClient()
+	client.HTTPClient = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	client.Retries = 0
+	client.UserAgent = liveUserAgent
+	day := moonphase.DayKey{Year: 2024, Month: time.March, Day: 1}
+	const numPhases = 8
+	status, body, err := client.GetRawMoonData(context.Background(), client.GetMoonDataURL(day, numPhases))
+	var netErr net.Error
+	if (errors.As(err, &netErr)) {
+		t.Skipf("the USNO API can't be reached: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(transport.requests) != 1) {
+		t.Errorf("made %d requests, want 1", len(transport.requests))
+	}
+	if got := transport.requests[0].Header.Get("User-Agent"); (got != liveUserAgent) {
+		t.Errorf("sent User-Agent %q, want %q", got, liveUserAgent)
+	}
+	if (status != http.StatusOK) {
+		t.Fatalf("status %d: %s", status, body)
+	}
+
+	// every field the API sends is one the structs have, and the other way round
+	if got, want := objectFields(t, body), []string{"apiversion", "day", "month", "numphases", "phasedata", "year"}; (!reflect.DeepEqual(got, want)) {
+		t.Errorf("response fields %q, want %q", got, want)
+	}
+	var raw struct {
+		Phasedata []json.RawMessage `json:"phasedata"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for i, phase := range raw.Phasedata {
+		if got, want := objectFields(t, phase), []string{"day", "month", "phase", "time", "year"}; (!reflect.DeepEqual(got, want)) {
+			t.Errorf("phase %d fields %q, want %q", i, got, want)
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	var response moonphase.MoonApiResponse
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("doesn't decode strictly: %v", err)
+	}
+	if (response.Numphases != numPhases || len(response.Phasedata) != numPhases) {
+		t.Errorf("numphases %d with %d phases, want %d", response.Numphases, len(response.Phasedata), numPhases)
+	}
+
+	recorded, err := moonphase.FileProvider{Path: "moonphasetest/testdata/usno-2024.json"}.GetMoonData(context.Background(), day, numPhases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (!reflect.DeepEqual(response.Phasedata, recorded)) {
+		t.Errorf("the API answers %+v, the fixture holds %+v", response.Phasedata, recorded)
+	}
+}
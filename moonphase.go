@@ -0,0 +1,438 @@
+// Package moonphase gets the phase of the moon for a date using the API from the Astronomical
+// Applications Department of the U.S. navy, https://aa.usno.navy.mil/data/api#phase
+//
+//	phase, err := moonphase.GetPhaseForDate(ctx, time.Now())
+//
+// The moonphase command in cmd/moonphase is a thin wrapper around this package.
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Struct to store an API response from https://aa.usno.navy.mil/data/api#phase
+// https://aa.usno.navy.mil/api/moon/phases/date
+// https://aa.usno.navy.mil/api/moon/phases/year leaves Day and Month unset
+type MoonApiResponse struct {
+	Apiversion string     `json:"apiversion"`
+	Day int	              `json:"day"`
+	Month int             `json:"month"`
+	Year int              `json:"year"`
+	Numphases int         `json:"numphases"`
+	Phasedata []MoonPhase `json:"phasedata"`
+}
+
+// Struct to store a Moon phase from API Data
+type MoonPhase struct {
+	Day int	      `json:"day"`
+	Month int     `json:"month"`
+	Year int      `json:"year"`
+	Phase string  `json:"phase"`
+	Time string   `json:"time"`
+}
+
+const dateFormat string = "2006-01-02"
+
+// share of the interval between two primary phases that snaps to each of them
+const snapFraction float64 = 0.25
+
+// upper bound on the snap window in days, however long the interval is
+const maxSnapWindow float64 = 2
+
+// most phases the API will return for a single request
+const maxNumPhases int = 99
+
+// DefaultBaseURL is the USNO API, every request URL is built from a Client's BaseURL
+const DefaultBaseURL string = "https://aa.usno.navy.mil/api"
+
+// DefaultTimeout bounds each request made by a NewClient, the API regularly hangs
+const DefaultTimeout time.Duration = 10 * time.Second
+
+// DefaultRetries is how many times a NewClient retries a failed request
+const DefaultRetries int = 2
+
+// wait before the first retry, doubled for each one after it, up to half of it is taken off at random
+const retryBackoff time.Duration = 500 * time.Millisecond
+
+// the longest Retry-After a retry waits out, the request gives up straight away on a longer one
+const maxRetryAfter time.Duration = time.Minute
+
+// DefaultUserAgent identifies the requests of a Client without a UserAgent of its own
+const DefaultUserAgent string = "go-moon-phase (+https://github.com/mitchthorson/go-moon-phase)"
+
+// Client fetches phase data from the USNO API
+type Client struct {
+	// HTTPClient makes the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// BaseURL of the API, DefaultBaseURL when empty
+	BaseURL string
+	// CacheDir keeps API responses for single date lookups to answer later ones from, no caching when
+	// empty or when there's a Source
+	CacheDir string
+	// Retries is how many more attempts a request gets after a network error, a 5xx or a 429 response
+	Retries int
+	// UserAgent is sent with every request, DefaultUserAgent when empty
+	UserAgent string
+	// Rand is where the jitter of retry backoffs comes from, crypto/rand when nil. use SeededRand
+	// for waits that repeat exactly. nothing a Client returns depends on it
+	Rand io.Reader
+	// Refresh is when a cached response is fetched again for a lookup that reports exact phase
+	// times, DefaultRefreshPolicy when zero
+	Refresh RefreshPolicy
+	// ForceRefresh fetches every lookup again rather than answering it from CacheDir, and caches
+	// the new response
+	ForceRefresh bool
+	// Tracer gets a span for each cache lookup and API request, none are started when nil
+	Tracer Tracer
+	// Source replaces the USNO API as where phase data comes from when set, like an OfflineProvider
+	// or a FileProvider. providers added with RegisterProvider are still tried around it
+	Source Provider
+	// added by RegisterProvider
+	providers []registeredProvider
+	// what the cache did for c's lookups and the requests they sent so far
+	statsMu       sync.Mutex
+	cacheStats    CacheStats
+	upstreamStats UpstreamStats
+}
+
+// returns a Client for the USNO API with DefaultTimeout and DefaultRetries
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		BaseURL:    DefaultBaseURL,
+		Retries:    DefaultRetries,
+	}
+}
+
+// returns the BaseURL to build requests from, without a trailing / so paths can follow it
+func (c *Client) baseURL() string {
+	if (c.BaseURL == "") {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+// DefaultClient is used by the package level functions
+var DefaultClient = NewClient()
+
+// fetches data from the Astronomical Applications Department of the U.S. navy
+// https://aa.usno.navy.mil/
+// https://aa.usno.navy.mil/data/api#phase
+// Note: the API docs and the API itself asks for dates like 01/02/2006, but really it wants 2006-01-02
+func (c *Client) GetMoonData(ctx context.Context, date DayKey, numPhases int) ([]MoonPhase, error) {
+	// a request the API is bound to reject isn't made, so a fallback provider answers straight away
+	if (!USNOCovers(date)) {
+		return nil, &ErrOutsideCoverage{Day: date}
+	}
+	phases, err := c.fetchMoonData(ctx, c.GetMoonDataURL(date, numPhases))
+	if (err == nil && len(phases) == 0) {
+		return nil, &ErrOutsideCoverage{Day: date}
+	}
+	return phases, err
+}
+
+// returns the URL of the date form of the phases API
+func (c *Client) GetMoonDataURL(date DayKey, numPhases int) string {
+	query := url.Values{}
+	query.Set("date", date.String())
+	query.Set("nump", strconv.Itoa(numPhases))
+	return fmt.Sprintf("%s/moon/phases/date?%s", c.baseURL(), query.Encode())
+}
+
+// fetches every phase in a calendar year in one request
+// https://aa.usno.navy.mil/api/moon/phases/year?year=2025
+// the response has no day or month, otherwise it's the same shape as the date query
+func (c *Client) GetMoonDataForYear(ctx context.Context, year int) ([]MoonPhase, error) {
+	firstDay := DayKey{Year: year, Month: time.January, Day: 1}
+	if (!USNOCovers(firstDay)) {
+		return nil, &ErrOutsideCoverage{Day: firstDay}
+	}
+	phases, err := c.fetchMoonData(ctx, c.GetMoonDataForYearURL(year))
+	if (err == nil && len(phases) == 0) {
+		return nil, &ErrOutsideCoverage{Day: firstDay}
+	}
+	return phases, err
+}
+
+// returns the URL of the year form of the phases API
+func (c *Client) GetMoonDataForYearURL(year int) string {
+	query := url.Values{}
+	query.Set("year", strconv.Itoa(year))
+	return fmt.Sprintf("%s/moon/phases/year?%s", c.baseURL(), query.Encode())
+}
+
+// requests either form of the phases API and returns its phase data
+func (c *Client) fetchMoonData(ctx context.Context, apiUrl string) ([]MoonPhase, error) {
+	status, body, err := c.GetRawMoonData(ctx, apiUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUpstreamError(status, body); err != nil {
+		return nil, err
+	}
+	if (status >= 400) {
+		return nil, &ErrUpstreamStatus{Status: status}
+	}
+	var moonApiResponse = MoonApiResponse{}
+	if err := decodeResponse(body, &moonApiResponse); err != nil {
+		return nil, err
+	}
+	// check up front so the rest of the package can rely on the phases
+	if err := checkPhases(moonApiResponse.Phasedata); err != nil {
+		return nil, &ErrMalformedResponse{Err: err}
+	}
+	return moonApiResponse.Phasedata, nil
+}
+
+// requests a URL from the API and returns the status and body untouched. network errors, 5xx
+// and 429 responses are retried up to c.Retries times with a doubling, jittered backoff, waiting
+// longer when a 429 or 503 asks to with Retry-After, the last attempt's result is returned. a
+// Retry-After past maxRetryAfter isn't waited out. cancelling ctx stops the request in flight and
+// any retries after it
+func (c *Client) GetRawMoonData(ctx context.Context, apiUrl string) (int, []byte, error) {
+	ctx, span := c.startSpan(ctx, SpanUpstreamRequest)
+	span.SetAttribute("http.url", apiUrl)
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		status, body, retryAfter, err := c.getRaw(ctx, apiUrl)
+		retryable := err != nil || status >= 500 || status == http.StatusTooManyRequests
+		if (!retryable || attempt >= c.Retries || retryAfter > maxRetryAfter) {
+			span.SetAttribute("retry.count", attempt)
+			if (err == nil) {
+				span.SetAttribute("http.status_code", status)
+			}
+			span.End(err)
+			return status, body, err
+		}
+		wait := retryWait(backoff, retryAfter, c.random())
+		select {
+		case <-ctx.Done():
+			span.SetAttribute("retry.count", attempt)
+			span.End(ctx.Err())
+			return 0, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// returns how long a Retry-After header asks to wait, given either in seconds or as an HTTP
+// date. a missing or unreadable header, or a date already past, is no wait at all
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if (value == "") {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); (err == nil) {
+		if (seconds < 0) {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if (err != nil || !date.After(now)) {
+		return 0
+	}
+	return date.Sub(now)
+}
+
+// makes a single request for GetRawMoonData, returning the Retry-After of a 429 or 503 as well
+func (c *Client) getRaw(ctx context.Context, apiUrl string) (int, []byte, time.Duration, error) {
+	httpClient := c.HTTPClient
+	if (httpClient == nil) {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	userAgent := c.UserAgent
+	if (userAgent == "") {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	c.statsMu.Lock()
+	c.upstreamStats.Requests++
+	if (err != nil || resp.StatusCode >= 400) {
+		c.upstreamStats.Errors++
+	}
+	c.statsMu.Unlock()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("reading USNO API response: %w", err)
+	}
+	var retryAfter time.Duration
+	if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp.StatusCode, body, retryAfter, nil
+}
+
+// returns the exact instant of a phase, the API gives the date and time in UT
+func GetPhaseInstant(phase MoonPhase) (time.Time, error) {
+	phaseTime, err := time.Parse("15:04", phase.Time)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q for %s: %w", phase.Time, phase.Phase, err)
+	}
+	return time.Date(phase.Year, time.Month(phase.Month), phase.Day, phaseTime.Hour(), phaseTime.Minute(), 0, 0, time.UTC), nil
+}
+
+// GetPhaseInstant for phases already checked when they were fetched
+func phaseInstant(phase MoonPhase) time.Time {
+	instant, _ := GetPhaseInstant(phase)
+	return instant
+}
+
+// returns midnight at the start of the day a phase falls on in location. the day is taken from the
+// phase's instant in that zone, not the API's UT date, so a New Moon at 23:40 local time on
+// December 31 stays in the old year even though it's already January 1 in UT
+func getPhaseDate(phase MoonPhase, location *time.Location) time.Time {
+	phaseDate := NewDayKey(phaseInstant(phase).In(location)).Time(location)
+	return phaseDate
+}
+
+// returns a stable identifier for a phase name, "Full Moon" becomes "full-moon"
+func PhaseID(phase string) string {
+	return strings.ToLower(strings.ReplaceAll(phase, " ", "-"))
+}
+
+// returns how many calendar days either side of a primary phase are reported as that phase.
+// the window is a fraction of the actual interval between the two phases, never past
+// maxSnapWindow, and a day snaps when its distance in whole days is below it. the interval only
+// runs from about 6.5 to 8.2 days, a window of 1.6 to 2, so in practice it's always one day either
+// side: a shorter quarter keeps fewer days of the intermediate phase rather than a smaller window
+func getSnapWindow(previousInstant time.Time, nextInstant time.Time) int {
+	window := nextInstant.Sub(previousInstant).Hours() / 24 * snapFraction
+	if (window > maxSnapWindow) {
+		window = maxSnapWindow
+	}
+	return int(math.Ceil(window)) - 1
+}
+
+// the intermediate phase for the days between each pair of consecutive primary phases
+var intermediatePhases = map[[2]Phase]Phase{
+	{NewMoon, FirstQuarter}:  WaxingCrescent,
+	{FirstQuarter, FullMoon}: WaxingGibbous,
+	{FullMoon, LastQuarter}:  WaningGibbous,
+	{LastQuarter, NewMoon}:   WaningCrescent,
+}
+
+// how GetCurrentPhase reports the days between primary phases
+type ClassifyStrategy int
+
+const (
+	// report a primary phase for days within its snap window, otherwise the intermediate phase
+	SnapStrategy ClassifyStrategy = iota
+	// report the most recent primary phase for every day, the calendar almanac convention
+	PrimaryOnlyStrategy
+)
+
+// give me the moon phase for the calendar day of now, with days attributed in now's location
+// fun to say "a slice of moon phase"
+func GetCurrentPhase(now time.Time, recentData []MoonPhase, strategy ClassifyStrategy) (Phase, error) {
+	for _, phase := range recentData {
+		if _, err := GetPhaseInstant(phase); err != nil {
+			return "", err
+		}
+		if _, err := ParsePhase(phase.Phase); err != nil {
+			return "", err
+		}
+	}
+	location := now.Location()
+	for i, phase := range recentData {
+		phaseDate := getPhaseDate(phase, location)
+		// if phase is in future
+		if (phaseDate.After(now)) {
+			if (i < 1) {
+				return "", fmt.Errorf("date range of recent data doesn't have enough history")
+			}
+			//store reference to previous phase
+			previousPhase := recentData[i - 1]
+			if (strategy == PrimaryOnlyStrategy) {
+				return Phase(previousPhase.Phase), nil
+			}
+			// the window is sized from the phase instants, the API's UT times, while the distances
+			// are counted in calendar days since it's a calendar day being classified. counting
+			// hours instead would stretch or shrink the window across a DST change
+			window := getSnapWindow(phaseInstant(previousPhase), phaseInstant(phase))
+			today := NewDayKey(now)
+			// if date is within the window after the previous phase, return previousPhase
+			if ( NewDayKey(getPhaseDate(previousPhase, location)).DaysUntil(today) <= window ) {
+				return Phase(previousPhase.Phase), nil
+			}
+			// if date is within the window before the next phase, return it
+			if ( today.DaysUntil(NewDayKey(phaseDate)) <= window ) {
+				return Phase(phase.Phase), nil
+			}
+			// otherwise it's the intermediate phase between the two
+			intermediate, found := intermediatePhases[[2]Phase{Phase(previousPhase.Phase), Phase(phase.Phase)}]
+			if (found) {
+				return intermediate, nil
+			}
+			return "", fmt.Errorf("unexpected phase %q followed by %q", previousPhase.Phase, phase.Phase)
+		}
+	}
+	return "", fmt.Errorf("date range of recent data doesn't have a phase after the date")
+}
+
+// describes what a lookup needs from the fetched phase data, anything below one is treated
+// as one since classifying a date always needs the phases either side of it
+type FetchStrategy struct {
+	// primary phases needed before the anchor
+	Past int
+	// primary phases needed after the anchor
+	Future int
+	// the lookup reports the phases' instants rather than only classifying days, so a cached
+	// response is refreshed when the Client's RefreshPolicy says its predictions are too old
+	ExactTimes bool
+}
+
+// returns the date to start an API request from and the number of phases to ask for so the
+// result is guaranteed to hold strategy.Past phases before the anchor and strategy.Future after it.
+// the request reaches back the longest possible gap between primary phases for each phase needed,
+// plus a day because the API works in UT dates while the anchor is local
+func FetchWindow(anchor time.Time, strategy FetchStrategy) (DayKey, int) {
+	past := strategy.Past
+	if (past < 1) {
+		past = 1
+	}
+	future := strategy.Future
+	if (future < 1) {
+		future = 1
+	}
+	lookbackDays := past * maxPhaseIntervalDays + 1
+	// the most phases that can fall in the lookback, each at least minPhaseIntervalDays apart
+	maxBefore := lookbackDays / minPhaseIntervalDays + 1
+	return NewDayKey(anchor).AddDays(-lookbackDays), maxBefore + future
+}
+
+// Get the moon's phase for the calendar day of date, in date's location
+func (c *Client) GetPhaseForDate(ctx context.Context, date time.Time, strategy ClassifyStrategy) (Phase, error) {
+	day := NewDayKey(date).Time(date.Location())
+	recentData, err := c.getMoonDataAround(ctx, day, FetchStrategy{Future: 1})
+	if err != nil {
+		return "", err
+	}
+	return GetCurrentPhase(day, recentData, strategy)
+}
+
+// Get the moon's phase for the calendar day of date, in date's location, using DefaultClient
+func GetPhaseForDate(ctx context.Context, date time.Time) (Phase, error) {
+	return DefaultClient.GetPhaseForDate(ctx, date, SnapStrategy)
+}
@@ -0,0 +1,277 @@
+package moonphase_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// a lunation with every phase late in the UT day, the Full Moon so late it's already the next UT
+// day while it's still evening on the US west coast
+var lateUTFixture = moonphasetest.MoonPhases(
+	moonphasetest.Event(moonphase.LastQuarter, "2024-03-03 23:20"),
+	moonphasetest.Event(moonphase.NewMoon, "2024-03-10 23:50"),
+	moonphasetest.Event(moonphase.FirstQuarter, "2024-03-17 23:10"),
+	moonphasetest.Event(moonphase.FullMoon, "2024-03-26 02:40"),
+	moonphasetest.Event(moonphase.LastQuarter, "2024-04-02 23:30"),
+	moonphasetest.Event(moonphase.NewMoon, "2024-04-09 23:55"),
+)
+
+// the API's date and time are read together as UT, not as midnight of the date
+func TestGetPhaseInstant(t *testing.T) {
+	instant, err := moonphase.GetPhaseInstant(lateUTFixture[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, time.March, 10, 23, 50, 0, 0, time.UTC); (!instant.Equal(want)) {
+		t.Errorf("got %s, want %s", instant, want)
+	}
+	local := instant.In(loadLocation(t, "America/Los_Angeles"))
+	if (local.Format("2006-01-02 15:04") != "2024-03-10 16:50") {
+		t.Errorf("got %s in Los Angeles, want 2024-03-10 16:50", local.Format("2006-01-02 15:04"))
+	}
+}
+
+// each phase is on the day it falls on where it's seen from: late in the UT day is the same day in
+// the Americas and the next one in Tokyo, and a few hours into a UT day is still the day before
+// in the Americas
+func TestLatePhasesByZone(t *testing.T) {
+	tests := []struct {
+		zone string
+		want map[string]moonphase.Phase
+	}{
+		{
+			zone: "UTC",
+			want: map[string]moonphase.Phase{
+				"2024-03-11": moonphase.NewMoon,
+				"2024-03-12": moonphase.WaxingCrescent,
+				"2024-03-17": moonphase.FirstQuarter,
+				"2024-03-18": moonphase.FirstQuarter,
+				"2024-03-19": moonphase.WaxingGibbous,
+				"2024-03-24": moonphase.WaxingGibbous,
+				"2024-03-25": moonphase.FullMoon,
+				"2024-03-27": moonphase.FullMoon,
+				"2024-03-28": moonphase.WaningGibbous,
+				"2024-04-03": moonphase.LastQuarter,
+			},
+		},
+		{
+			zone: "America/Los_Angeles",
+			want: map[string]moonphase.Phase{
+				"2024-03-11": moonphase.NewMoon,
+				"2024-03-12": moonphase.WaxingCrescent,
+				"2024-03-18": moonphase.FirstQuarter,
+				"2024-03-19": moonphase.WaxingGibbous,
+				"2024-03-23": moonphase.WaxingGibbous,
+				// the Full Moon is on the evening of the 25th
+				"2024-03-24": moonphase.FullMoon,
+				"2024-03-26": moonphase.FullMoon,
+				"2024-03-27": moonphase.WaningGibbous,
+				"2024-04-03": moonphase.LastQuarter,
+			},
+		},
+		{
+			zone: "America/New_York",
+			want: map[string]moonphase.Phase{
+				"2024-03-12": moonphase.WaxingCrescent,
+				"2024-03-24": moonphase.FullMoon,
+				"2024-03-26": moonphase.FullMoon,
+				"2024-03-27": moonphase.WaningGibbous,
+			},
+		},
+		{
+			zone: "Asia/Tokyo",
+			want: map[string]moonphase.Phase{
+				// the New Moon is the morning of the 11th
+				"2024-03-09": moonphase.WaningCrescent,
+				"2024-03-12": moonphase.NewMoon,
+				"2024-03-13": moonphase.WaxingCrescent,
+				"2024-03-19": moonphase.FirstQuarter,
+				"2024-03-25": moonphase.FullMoon,
+				"2024-03-27": moonphase.FullMoon,
+				"2024-04-04": moonphase.LastQuarter,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.zone, func(t *testing.T) {
+			checkDays(t, classifyDays(t, lateUTFixture, loadLocation(t, test.zone), "2024-03-05", "2024-04-05"), test.want)
+		})
+	}
+}
+
+// a request from FetchWindow holds the phases it was asked for either side of the anchor, with a
+// day to spare, wherever in the lunation and whatever zone the anchor is in
+func TestFetchWindowBrackets(t *testing.T) {
+	provider := moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)
+	strategies := []moonphase.FetchStrategy{
+		{Future: 1},
+		{Past: 1, Future: 1},
+		{Past: 2, Future: 2},
+		{Past: 1, Future: 4},
+		{Past: 4},
+	}
+	// the lunation from the New Moon of June 6th 2024 to the one of July 5th
+	start := time.Date(2024, time.June, 5, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.July, 7, 0, 0, 0, 0, time.UTC)
+	for _, zone := range []string{"UTC", "America/Los_Angeles", "Asia/Tokyo"} {
+		location := loadLocation(t, zone)
+		for instant := start; instant.Before(end); instant = instant.Add(3 * time.Hour) {
+			anchor := instant.In(location)
+			for _, strategy := range strategies {
+				startDay, numPhases := moonphase.FetchWindow(anchor, strategy)
+				// the most the API returns for one request
+				if (numPhases > 99) {
+					t.Fatalf("%s %+v: asks for %d phases", anchor, strategy, numPhases)
+				}
+				phases, err := provider.GetMoonData(context.Background(), startDay, numPhases)
+				if err != nil {
+					t.Fatal(err)
+				}
+				before, after := 0, 0
+				for _, phase := range phases {
+					phaseInstant, err := moonphase.GetPhaseInstant(phase)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if (phaseInstant.Before(anchor.AddDate(0, 0, -1))) {
+						before++
+					}
+					if (phaseInstant.After(anchor.AddDate(0, 0, 1))) {
+						after++
+					}
+				}
+				if (before < strategy.Past || before < 1 || after < strategy.Future || after < 1) {
+					t.Fatalf("%s %+v: from %s with %d phases has %d before and %d after", anchor.Format(time.RFC3339), strategy, startDay, numPhases, before, after)
+				}
+			}
+		}
+	}
+}
+
+// answers with two phases fewer than asked for, as a provider with less data than the API might,
+// though always with one
+type shortProvider struct {
+	inner *moonphasetest.Provider
+}
+
+func (p shortProvider) GetMoonData(ctx context.Context, date moonphase.DayKey, numPhases int) ([]moonphase.MoonPhase, error) {
+	numPhases -= 2
+	if (numPhases < 1) {
+		numPhases = 1
+	}
+	return p.inner.GetMoonData(ctx, date, numPhases)
+}
+
+// four phases from seven days back, the window lookups used to fetch, has no phase before
+// February 2nd 2024 at UTC+14: the Full Moon before it was on January 26th there, and the API
+// answers from the UT day. the lookup finds it now
+func TestLookupAfterLongInterval(t *testing.T) {
+	provider := moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)
+	location := loadLocation(t, "Pacific/Kiritimati")
+	day := time.Date(2024, time.February, 2, 0, 0, 0, 0, location)
+	oldWindow, err := provider.GetMoonData(context.Background(), moonphase.NewDayKey(day.AddDate(0, 0, -7)), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := moonphase.GetCurrentPhase(day, oldWindow, moonphase.SnapStrategy); (err == nil) {
+		t.Fatalf("the old window classifies %s, the fixture no longer reproduces the failure", day.Format("2006-01-02"))
+	}
+	client := &moonphase.Client{Source: provider}
+	tests := []struct {
+		day      string
+		strategy moonphase.ClassifyStrategy
+		want     moonphase.Phase
+	}{
+		{"2024-02-01", moonphase.SnapStrategy, moonphase.WaningGibbous},
+		{"2024-02-02", moonphase.SnapStrategy, moonphase.LastQuarter},
+		{"2024-02-03", moonphase.SnapStrategy, moonphase.LastQuarter},
+		{"2024-02-02", moonphase.PrimaryOnlyStrategy, moonphase.FullMoon},
+	}
+	for _, test := range tests {
+		key, _ := moonphase.ParseDayKey(test.day)
+		got, err := client.GetPhaseForDate(context.Background(), key.Time(location), test.strategy)
+		if err != nil {
+			t.Errorf("%s: %v", test.day, err)
+		} else if (got != test.want) {
+			t.Errorf("%s: got %s, want %s", test.day, got, test.want)
+		}
+	}
+}
+
+// data that doesn't bracket the lookup is asked for again with a wider window, so a provider
+// answering with less than asked still classifies every day of the year
+func TestLookupRefetchesWiderWindow(t *testing.T) {
+	reference := newReferenceClient()
+	inner := moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)
+	short := &moonphase.Client{Source: shortProvider{inner: inner}}
+	for _, zone := range []string{"UTC", "America/Los_Angeles"} {
+		location := loadLocation(t, zone)
+		days := 0
+		for day := time.Date(2024, time.January, 1, 0, 0, 0, 0, location); day.Year() == 2024; day = day.AddDate(0, 0, 1) {
+			days++
+			want, err := reference.GetPhaseForDate(context.Background(), day, moonphase.SnapStrategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := short.GetPhaseForDate(context.Background(), day, moonphase.SnapStrategy)
+			if err != nil {
+				t.Fatalf("%s %s: %v", zone, day.Format("2006-01-02"), err)
+			}
+			if (got != want) {
+				t.Errorf("%s %s: got %s, want %s", zone, day.Format("2006-01-02"), got, want)
+			}
+		}
+	}
+	// some of those lookups needed the second request
+	if (inner.Requests <= 2 * 366) {
+		t.Errorf("made %d requests for %d lookups, none refetched", inner.Requests, 2 * 366)
+	}
+}
+
+// request URLs are the BaseURL, with or without a trailing /, then the path and the query, with
+// the date zero padded to the form the API takes
+func TestGetMoonDataURL(t *testing.T) {
+	march5 := moonphase.DayKey{Year: 2024, Month: time.March, Day: 5}
+	tests := []struct {
+		baseURL   string
+		day       moonphase.DayKey
+		numPhases int
+		want      string
+	}{
+		{moonphase.DefaultBaseURL, march5, 4, "https://aa.usno.navy.mil/api/moon/phases/date?date=2024-03-05&nump=4"},
+		{"", march5, 4, "https://aa.usno.navy.mil/api/moon/phases/date?date=2024-03-05&nump=4"},
+		{"http://127.0.0.1:8080/", march5, 99, "http://127.0.0.1:8080/moon/phases/date?date=2024-03-05&nump=99"},
+		{"https://proxy.example/usno/api", march5, 4, "https://proxy.example/usno/api/moon/phases/date?date=2024-03-05&nump=4"},
+		{moonphase.DefaultBaseURL, moonphase.DayKey{Year: 999, Month: time.January, Day: 1}, 12, "https://aa.usno.navy.mil/api/moon/phases/date?date=0999-01-01&nump=12"},
+	}
+	for _, test := range tests {
+		client := &moonphase.Client{BaseURL: test.baseURL}
+		if got := client.GetMoonDataURL(test.day, test.numPhases); (got != test.want) {
+			t.Errorf("%q: got %s, want %s", test.baseURL, got, test.want)
+		}
+	}
+	if got, want := (&moonphase.Client{BaseURL: "http://127.0.0.1:8080/"}).GetMoonDataForYearURL(2025), "http://127.0.0.1:8080/moon/phases/year?year=2025"; (got != want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// and it's the URL a lookup requests
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.RequestURI()
+		w.Write([]byte(`{"phasedata": [{"phase": "Full Moon", "year": 2024, "month": 3, "day": 25, "time": "07:00"}]}`))
+	}))
+	defer server.Close()
+	client := &moonphase.Client{BaseURL: server.URL + "/"}
+	if _, err := client.GetMoonData(context.Background(), march5, 4); err != nil {
+		t.Fatal(err)
+	}
+	if (requested != "/moon/phases/date?date=2024-03-05&nump=4") {
+		t.Errorf("requested %s", requested)
+	}
+}
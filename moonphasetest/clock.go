@@ -0,0 +1,39 @@
+package moonphasetest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a clock that only moves when told to, for code that takes a func() time.Time.
+// pass its Now method value in place of time.Now
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// returns a FakeClock stopped at t0
+func NewFakeClock(t0 time.Time) *FakeClock {
+	return &FakeClock{now: t0}
+}
+
+// returns the clock's current time
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// moves the clock on by d, which may be negative
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// stops the clock at t
+func (c *FakeClock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = t
+}
@@ -0,0 +1,63 @@
+package moonphasetest
+
+import (
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// PhaseEvent is a primary phase at an instant, the unit fixtures are written in. the API only
+// gives phase times to the minute, so instants are kept in UTC and rounded down to one
+type PhaseEvent struct {
+	Phase   moonphase.Phase
+	Instant time.Time
+}
+
+// returns the event of phase at instant, an RFC 3339 time like 2024-03-25T07:00:00Z or the
+// shorter 2024-03-25 07:00 in UT. fixtures are constants, so an instant that doesn't parse panics
+func Event(phase moonphase.Phase, instant string) PhaseEvent {
+	t, err := time.Parse(time.RFC3339, instant)
+	if err != nil {
+		t, err = time.Parse("2006-01-02 15:04", instant)
+		if err != nil {
+			// allowed panic: a typo in a fixture literal, caught the first time the test runs
+			panic("moonphasetest: bad instant " + instant + ": " + err.Error())
+		}
+	}
+	return PhaseEvent{Phase: phase, Instant: t.UTC().Truncate(time.Minute)}
+}
+
+// returns the event in the API's shape, with the UT date and time it would answer with
+func (e PhaseEvent) MoonPhase() moonphase.MoonPhase {
+	instant := e.Instant.UTC()
+	return moonphase.MoonPhase{
+		Day:   instant.Day(),
+		Month: int(instant.Month()),
+		Year:  instant.Year(),
+		Phase: string(e.Phase),
+		Time:  instant.Format("15:04"),
+	}
+}
+
+// returns events in the API's shape, as GetCurrentPhase and the other classifiers take them
+func MoonPhases(events ...PhaseEvent) []moonphase.MoonPhase {
+	phases := make([]moonphase.MoonPhase, 0, len(events))
+	for _, event := range events {
+		phases = append(phases, event.MoonPhase())
+	}
+	return phases
+}
+
+// returns the events of phases, which must have been checked with moonphase.GetPhaseInstant
+func Events(phases ...moonphase.MoonPhase) []PhaseEvent {
+	events := make([]PhaseEvent, 0, len(phases))
+	for _, phase := range phases {
+		instant, err := moonphase.GetPhaseInstant(phase)
+		if err != nil {
+			// allowed panic: the caller broke the documented precondition
+			panic("moonphasetest: " + err.Error())
+		}
+		events = append(events, PhaseEvent{Phase: moonphase.Phase(phase.Phase), Instant: instant})
+	}
+	return events
+}
@@ -0,0 +1,53 @@
+package moonphasetest_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+func ExampleNewFixtureProvider() {
+	client := &moonphase.Client{Source: moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)}
+	phase, err := client.GetPhaseForDate(context.Background(), time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(phase)
+	// Output: Full Moon
+}
+
+func ExampleEvent() {
+	event := moonphasetest.Event(moonphase.NewMoon, "2024-12-30 22:27")
+	fmt.Printf("%+v\n", event.MoonPhase())
+	// Output: {Day:30 Month:12 Year:2024 Phase:New Moon Time:22:27}
+}
+
+func ExampleNewFakeClock() {
+	clock := moonphasetest.NewFakeClock(time.Date(2024, time.March, 25, 23, 55, 0, 0, time.UTC))
+	clock.Advance(10 * time.Minute)
+	fmt.Println(moonphase.NewDayKey(clock.Now()))
+	// Output: 2024-03-26
+}
+
+func ExampleNewRecordedUSNOServer() {
+	// tests use RecordedUSNOServer(t, path), which closes the server when the test ends
+	server, err := moonphasetest.NewRecordedUSNOServer("testdata/usno-2024.json")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer server.Close()
+	client := moonphase.NewClient()
+	client.BaseURL = server.URL
+	phase, err := client.GetPhaseForDate(context.Background(), time.Date(2024, time.August, 19, 12, 0, 0, 0, time.UTC), moonphase.SnapStrategy)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(phase, server.Requests())
+	// Output: Full Moon 1
+}
@@ -0,0 +1,67 @@
+// Package moonphasetest has helpers for testing code built on the moonphase package, and doubles
+// as an example of a custom provider:
+//
+//	provider := moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)
+//	client := moonphase.NewClient()
+//	client.RegisterProvider("fixture", provider, moonphase.USNOPriority + 1, nil)
+//	phase, err := client.GetPhaseForDate(ctx, date, moonphase.SnapStrategy)
+//
+// The repo's own tests are built on these helpers, so they're kept working along with it.
+package moonphasetest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// Provider is a moonphase.Provider serving a fixed list of phases, so lookups can run without the
+// network. set Err to make every request fail, as when testing a fallback
+type Provider struct {
+	// the primary phases to serve, consecutive and in order
+	Phases []moonphase.MoonPhase
+	// returned by every request when set
+	Err error
+	// how many requests have been made
+	Requests int
+	// guards Requests, lookups may run concurrently
+	mutex sync.Mutex
+}
+
+// returns a Provider serving events, sorted into order
+func NewFixtureProvider(events ...PhaseEvent) *Provider {
+	sorted := append([]PhaseEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Instant.Before(sorted[j].Instant)
+	})
+	return &Provider{Phases: MoonPhases(sorted...)}
+}
+
+// returns up to numPhases of p.Phases, the first being the first on or after date in UT, or
+// ctx's error once it's cancelled
+func (p *Provider) GetMoonData(ctx context.Context, date moonphase.DayKey, numPhases int) ([]moonphase.MoonPhase, error) {
+	p.mutex.Lock()
+	p.Requests++
+	p.mutex.Unlock()
+	if (p.Err != nil) {
+		return nil, p.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	start := date.Time(time.UTC)
+	var phases []moonphase.MoonPhase
+	for _, phase := range p.Phases {
+		instant, err := moonphase.GetPhaseInstant(phase)
+		if err != nil {
+			return nil, err
+		}
+		if (!instant.Before(start) && len(phases) < numPhases) {
+			phases = append(phases, phase)
+		}
+	}
+	return phases, nil
+}
@@ -0,0 +1,75 @@
+package moonphasetest
+
+import (
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// ReferenceYear is the year ReferenceEvents covers
+const ReferenceYear int = 2024
+
+// returns the primary phases of ReferenceYear as the USNO API gives them, in order, with the last
+// four of the year before and the first four of the year after, so every day of the year can be
+// classified and aged and the lunations straddling either end are whole. a new slice each time,
+// so a test can change it
+func ReferenceEvents() []PhaseEvent {
+	return []PhaseEvent{
+		Event(moonphase.LastQuarter, "2023-12-05 05:49"),
+		Event(moonphase.NewMoon, "2023-12-12 23:32"),
+		Event(moonphase.FirstQuarter, "2023-12-19 18:39"),
+		Event(moonphase.FullMoon, "2023-12-27 00:33"),
+		Event(moonphase.LastQuarter, "2024-01-04 03:30"),
+		Event(moonphase.NewMoon, "2024-01-11 11:57"),
+		Event(moonphase.FirstQuarter, "2024-01-18 03:53"),
+		Event(moonphase.FullMoon, "2024-01-25 17:54"),
+		Event(moonphase.LastQuarter, "2024-02-02 23:18"),
+		Event(moonphase.NewMoon, "2024-02-09 22:59"),
+		Event(moonphase.FirstQuarter, "2024-02-16 15:01"),
+		Event(moonphase.FullMoon, "2024-02-24 12:30"),
+		Event(moonphase.LastQuarter, "2024-03-03 15:23"),
+		Event(moonphase.NewMoon, "2024-03-10 09:00"),
+		Event(moonphase.FirstQuarter, "2024-03-17 04:11"),
+		Event(moonphase.FullMoon, "2024-03-25 07:00"),
+		Event(moonphase.LastQuarter, "2024-04-02 03:15"),
+		Event(moonphase.NewMoon, "2024-04-08 18:21"),
+		Event(moonphase.FirstQuarter, "2024-04-15 19:13"),
+		Event(moonphase.FullMoon, "2024-04-23 23:49"),
+		Event(moonphase.LastQuarter, "2024-05-01 11:27"),
+		Event(moonphase.NewMoon, "2024-05-08 03:22"),
+		Event(moonphase.FirstQuarter, "2024-05-15 11:48"),
+		Event(moonphase.FullMoon, "2024-05-23 13:53"),
+		Event(moonphase.LastQuarter, "2024-05-30 17:13"),
+		Event(moonphase.NewMoon, "2024-06-06 12:38"),
+		Event(moonphase.FirstQuarter, "2024-06-14 05:18"),
+		Event(moonphase.FullMoon, "2024-06-22 01:08"),
+		Event(moonphase.LastQuarter, "2024-06-28 21:53"),
+		Event(moonphase.NewMoon, "2024-07-05 22:57"),
+		Event(moonphase.FirstQuarter, "2024-07-13 22:49"),
+		Event(moonphase.FullMoon, "2024-07-21 10:17"),
+		Event(moonphase.LastQuarter, "2024-07-28 02:51"),
+		Event(moonphase.NewMoon, "2024-08-04 11:13"),
+		Event(moonphase.FirstQuarter, "2024-08-12 15:19"),
+		Event(moonphase.FullMoon, "2024-08-19 18:26"),
+		Event(moonphase.LastQuarter, "2024-08-26 09:26"),
+		Event(moonphase.NewMoon, "2024-09-03 01:55"),
+		Event(moonphase.FirstQuarter, "2024-09-11 06:06"),
+		Event(moonphase.FullMoon, "2024-09-18 02:34"),
+		Event(moonphase.LastQuarter, "2024-09-24 18:50"),
+		Event(moonphase.NewMoon, "2024-10-02 18:49"),
+		Event(moonphase.FirstQuarter, "2024-10-10 18:55"),
+		Event(moonphase.FullMoon, "2024-10-17 11:26"),
+		Event(moonphase.LastQuarter, "2024-10-24 08:03"),
+		Event(moonphase.NewMoon, "2024-11-01 12:47"),
+		Event(moonphase.FirstQuarter, "2024-11-09 05:55"),
+		Event(moonphase.FullMoon, "2024-11-15 21:28"),
+		Event(moonphase.LastQuarter, "2024-11-23 01:28"),
+		Event(moonphase.NewMoon, "2024-12-01 06:21"),
+		Event(moonphase.FirstQuarter, "2024-12-08 15:27"),
+		Event(moonphase.FullMoon, "2024-12-15 09:02"),
+		Event(moonphase.LastQuarter, "2024-12-22 22:18"),
+		Event(moonphase.NewMoon, "2024-12-30 22:27"),
+		Event(moonphase.FirstQuarter, "2025-01-06 23:56"),
+		Event(moonphase.FullMoon, "2025-01-13 22:27"),
+		Event(moonphase.LastQuarter, "2025-01-21 20:31"),
+		Event(moonphase.NewMoon, "2025-01-29 12:36"),
+	}
+}
@@ -0,0 +1,74 @@
+package moonphasetest_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// the recorded response in testdata is the same data as ReferenceEvents
+func TestReferenceEventsMatchRecording(t *testing.T) {
+	recorded, err := moonphase.FileProvider{Path: "testdata/usno-2024.json"}.GetMoonData(context.Background(), moonphase.DayKey{Year: 2023, Month: time.January, Day: 1}, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := moonphasetest.MoonPhases(moonphasetest.ReferenceEvents()...); (!reflect.DeepEqual(recorded, want)) {
+		t.Errorf("testdata/usno-2024.json holds %v, ReferenceEvents %v", recorded, want)
+	}
+}
+
+func TestReferenceEventsAreACycle(t *testing.T) {
+	events := moonphasetest.ReferenceEvents()
+	next := map[moonphase.Phase]moonphase.Phase{
+		moonphase.NewMoon:      moonphase.FirstQuarter,
+		moonphase.FirstQuarter: moonphase.FullMoon,
+		moonphase.FullMoon:     moonphase.LastQuarter,
+		moonphase.LastQuarter:  moonphase.NewMoon,
+	}
+	for i := 1; i < len(events); i++ {
+		previous, event := events[i - 1], events[i]
+		if (next[previous.Phase] != event.Phase) {
+			t.Errorf("%s at %s is followed by %s", previous.Phase, previous.Instant, event.Phase)
+		}
+		days := event.Instant.Sub(previous.Instant).Hours() / 24
+		if (days < 6.5 || days > 8.3) {
+			t.Errorf("%s to %s is %.2f days", previous.Instant, event.Instant, days)
+		}
+	}
+	if first, last := events[0].Instant.Year(), events[len(events) - 1].Instant.Year(); (first != moonphasetest.ReferenceYear - 1 || last != moonphasetest.ReferenceYear + 1) {
+		t.Errorf("ReferenceEvents runs from %d to %d", first, last)
+	}
+}
+
+func TestNewFixtureProviderSorts(t *testing.T) {
+	events := moonphasetest.ReferenceEvents()[:4]
+	provider := moonphasetest.NewFixtureProvider(events[3], events[1], events[0], events[2])
+	if want := moonphasetest.MoonPhases(events...); (!reflect.DeepEqual(provider.Phases, want)) {
+		t.Errorf("got %v, want %v", provider.Phases, want)
+	}
+}
+
+func TestRecordedServerYearQuery(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client := moonphase.NewClient()
+	client.BaseURL = server.URL
+	phases, err := client.GetMoonDataForYear(context.Background(), moonphasetest.ReferenceYear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, phase := range phases {
+		if (phase.Year != moonphasetest.ReferenceYear) {
+			t.Errorf("year query returned %+v", phase)
+		}
+	}
+	if (len(phases) != 50) {
+		t.Errorf("got %d phases in %d, want 50", len(phases), moonphasetest.ReferenceYear)
+	}
+	if (server.Requests() != 1) {
+		t.Errorf("made %d requests, want 1", server.Requests())
+	}
+}
@@ -0,0 +1,130 @@
+package moonphasetest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// RecordedServer is an httptest server answering the USNO phase API from a recorded response,
+// for testing the real request path of a Client without the network. point a Client at it with
+//
+//	client.BaseURL = server.URL
+type RecordedServer struct {
+	*httptest.Server
+	// the recorded phases, served like the API would for any date query
+	provider moonphase.FileProvider
+	requests int64
+}
+
+// returns a RecordedServer answering from fixtureFile, closed when the test ends. the file is a
+// saved API response in the shape moonphase.FileProvider reads, such as one from WriteFixtureFile
+func RecordedUSNOServer(t testing.TB, fixtureFile string) *RecordedServer {
+	t.Helper()
+	server, err := NewRecordedUSNOServer(fixtureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+// returns a RecordedServer answering from fixtureFile, for use outside a test. Close it when done
+func NewRecordedUSNOServer(fixtureFile string) (*RecordedServer, error) {
+	// read up front so a missing or broken fixture fails the test setup, not the first lookup
+	if _, err := (moonphase.FileProvider{Path: fixtureFile}).GetMoonData(context.Background(), moonphase.DayKey{Year: 1, Month: time.January, Day: 1}, 0); err != nil {
+		return nil, err
+	}
+	server := &RecordedServer{provider: moonphase.FileProvider{Path: fixtureFile}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/moon/phases/date", server.handleDate)
+	mux.HandleFunc("/moon/phases/year", server.handleYear)
+	server.Server = httptest.NewServer(mux)
+	return server, nil
+}
+
+// returns how many phase requests the server has answered
+func (s *RecordedServer) Requests() int {
+	return int(atomic.LoadInt64(&s.requests))
+}
+
+// writes body as the API would, a JSON payload with a 200 even for errors
+func writeAPIResponse(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// /moon/phases/date?date=2024-03-25&nump=4
+func (s *RecordedServer) handleDate(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+	day, err := moonphase.ParseDayKey(r.URL.Query().Get("date"))
+	if err != nil {
+		writeAPIResponse(w, map[string]string{"error": "invalid date"})
+		return
+	}
+	numPhases, err := strconv.Atoi(r.URL.Query().Get("nump"))
+	if err != nil {
+		writeAPIResponse(w, map[string]string{"error": "invalid nump"})
+		return
+	}
+	phases, err := s.provider.GetMoonData(r.Context(), day, numPhases)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAPIResponse(w, moonphase.MoonApiResponse{
+		Apiversion: "4.0.1",
+		Day:        day.Day,
+		Month:      int(day.Month),
+		Year:       day.Year,
+		Numphases:  len(phases),
+		Phasedata:  append([]moonphase.MoonPhase{}, phases...),
+	})
+}
+
+// /moon/phases/year?year=2024
+func (s *RecordedServer) handleYear(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+	year, err := strconv.Atoi(r.URL.Query().Get("year"))
+	if err != nil {
+		writeAPIResponse(w, map[string]string{"error": "invalid year"})
+		return
+	}
+	all, err := s.provider.GetMoonData(r.Context(), moonphase.DayKey{Year: year, Month: time.January, Day: 1}, 60)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	phases := []moonphase.MoonPhase{}
+	for _, phase := range all {
+		if (phase.Year == year) {
+			phases = append(phases, phase)
+		}
+	}
+	writeAPIResponse(w, moonphase.MoonApiResponse{Apiversion: "4.0.1", Year: year, Numphases: len(phases), Phasedata: phases})
+}
+
+// writes events as a recorded API response in a file of the test's temp dir and returns its path,
+// for RecordedUSNOServer or a moonphase.FileProvider
+func WriteFixtureFile(t testing.TB, events ...PhaseEvent) string {
+	t.Helper()
+	phases := MoonPhases(events...)
+	content, err := json.Marshal(moonphase.MoonApiResponse{Apiversion: "4.0.1", Numphases: len(phases), Phasedata: phases})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "phases.json")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
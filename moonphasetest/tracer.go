@@ -0,0 +1,86 @@
+package moonphasetest
+
+import (
+	"context"
+	"sync"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// RecordedSpan is a span a Tracer was asked to start
+type RecordedSpan struct {
+	Name       string
+	// the index in Spans of the span this one is a child of, -1 for a span without a parent
+	Parent     int
+	Attributes map[string]interface{}
+	Ended      bool
+	// what End was called with
+	Err        error
+}
+
+// Tracer is a moonphase.Tracer keeping every span it starts, for tests of what a Client or a
+// server traced. set it as a Client's Tracer and read Spans once the calls are done
+type Tracer struct {
+	mutex sync.Mutex
+	spans []RecordedSpan
+}
+
+// the key a Tracer's span is kept under in a context
+type spanKey struct{}
+
+// the span a context carries, and the Tracer that started it
+type spanRef struct {
+	tracer *Tracer
+	index  int
+}
+
+// returns a Tracer that has recorded nothing yet
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// starts a span, a child of the one ctx carries if this Tracer started it
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, moonphase.Span) {
+	parent := -1
+	if ref, found := ctx.Value(spanKey{}).(spanRef); (found && ref.tracer == t) {
+		parent = ref.index
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.spans = append(t.spans, RecordedSpan{Name: name, Parent: parent, Attributes: map[string]interface{}{}})
+	ref := spanRef{t, len(t.spans) - 1}
+	return context.WithValue(ctx, spanKey{}, ref), recordingSpan{ref}
+}
+
+// returns a copy of the spans started so far, in the order they were started
+func (t *Tracer) Spans() []RecordedSpan {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	spans := make([]RecordedSpan, len(t.spans))
+	for i, span := range t.spans {
+		span.Attributes = map[string]interface{}{}
+		for key, value := range t.spans[i].Attributes {
+			span.Attributes[key] = value
+		}
+		spans[i] = span
+	}
+	return spans
+}
+
+// the moonphase.Span of a Tracer, writing to its entry in the Tracer's spans
+type recordingSpan struct {
+	spanRef
+}
+
+func (s recordingSpan) SetAttribute(key string, value interface{}) {
+	s.tracer.mutex.Lock()
+	defer s.tracer.mutex.Unlock()
+	s.tracer.spans[s.index].Attributes[key] = value
+}
+
+func (s recordingSpan) End(err error) {
+	s.tracer.mutex.Lock()
+	defer s.tracer.mutex.Unlock()
+	s.tracer.spans[s.index].Ended = true
+	s.tracer.spans[s.index].Err = err
+}
@@ -0,0 +1,67 @@
+package moonphase
+
+import (
+	"strings"
+)
+
+// Languages lists the languages Name has the phase names in, by their ISO 639-1 code
+var Languages = []string{"en", "es", "fr", "de"}
+
+// the phase names in each language other than English, whose names are the Phase values themselves
+var phaseNames = map[string]map[Phase]string{
+	"es": {
+		NewMoon:        "Luna nueva",
+		WaxingCrescent: "Luna creciente",
+		FirstQuarter:   "Cuarto creciente",
+		WaxingGibbous:  "Gibosa creciente",
+		FullMoon:       "Luna llena",
+		WaningGibbous:  "Gibosa menguante",
+		LastQuarter:    "Cuarto menguante",
+		WaningCrescent: "Luna menguante",
+	},
+	"fr": {
+		NewMoon:        "Nouvelle lune",
+		WaxingCrescent: "Premier croissant",
+		FirstQuarter:   "Premier quartier",
+		WaxingGibbous:  "Gibbeuse croissante",
+		FullMoon:       "Pleine lune",
+		WaningGibbous:  "Gibbeuse décroissante",
+		LastQuarter:    "Dernier quartier",
+		WaningCrescent: "Dernier croissant",
+	},
+	"de": {
+		NewMoon:        "Neumond",
+		WaxingCrescent: "Zunehmende Sichel",
+		FirstQuarter:   "Erstes Viertel",
+		WaxingGibbous:  "Zunehmender Mond",
+		FullMoon:       "Vollmond",
+		WaningGibbous:  "Abnehmender Mond",
+		LastQuarter:    "Letztes Viertel",
+		WaningCrescent: "Abnehmende Sichel",
+	},
+}
+
+// returns the language part of a code like "es" or "fr-CA", lowercased
+func baseLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); (i >= 0) {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// reports whether Name has the phase names in lang, a regional code like "de-AT" counts as its language
+func HasLanguage(lang string) bool {
+	lang = baseLanguage(lang)
+	_, found := phaseNames[lang]
+	return found || lang == "en"
+}
+
+// returns the phase's name in lang, a code like "es" or "fr-CA", falling back to the English name
+// for a language there are no names for
+func (p Phase) Name(lang string) string {
+	if name, found := phaseNames[baseLanguage(lang)][p]; (found) {
+		return name
+	}
+	return p.String()
+}
@@ -0,0 +1,143 @@
+package moonphase_test
+
+import (
+	"testing"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+func TestNameSpanish(t *testing.T) {
+	want := map[moonphase.Phase]string{
+		moonphase.NewMoon:        "Luna nueva",
+		moonphase.WaxingCrescent: "Luna creciente",
+		moonphase.FirstQuarter:   "Cuarto creciente",
+		moonphase.WaxingGibbous:  "Gibosa creciente",
+		moonphase.FullMoon:       "Luna llena",
+		moonphase.WaningGibbous:  "Gibosa menguante",
+		moonphase.LastQuarter:    "Cuarto menguante",
+		moonphase.WaningCrescent: "Luna menguante",
+	}
+	for _, phase := range moonphase.Phases {
+		if got := phase.Name("es"); (got != want[phase]) {
+			t.Errorf("%s: got %q, want %q", phase, got, want[phase])
+		}
+	}
+}
+
+func TestNameGerman(t *testing.T) {
+	want := map[moonphase.Phase]string{
+		moonphase.NewMoon:        "Neumond",
+		moonphase.WaxingCrescent: "Zunehmende Sichel",
+		moonphase.FirstQuarter:   "Erstes Viertel",
+		moonphase.WaxingGibbous:  "Zunehmender Mond",
+		moonphase.FullMoon:       "Vollmond",
+		moonphase.WaningGibbous:  "Abnehmender Mond",
+		moonphase.LastQuarter:    "Letztes Viertel",
+		moonphase.WaningCrescent: "Abnehmende Sichel",
+	}
+	for _, phase := range moonphase.Phases {
+		if got := phase.Name("de"); (got != want[phase]) {
+			t.Errorf("%s: got %q, want %q", phase, got, want[phase])
+		}
+	}
+}
+
+// every language Languages lists names all eight phases, each differently
+func TestLanguagesAreComplete(t *testing.T) {
+	for _, lang := range moonphase.Languages {
+		if (!moonphase.HasLanguage(lang)) {
+			t.Errorf("%s is listed but HasLanguage says no", lang)
+		}
+		seen := map[string]moonphase.Phase{}
+		for _, phase := range moonphase.Phases {
+			name := phase.Name(lang)
+			if (lang != "en" && name == phase.String()) {
+				t.Errorf("%s: %s isn't translated", lang, phase)
+			}
+			if other, found := seen[name]; (found) {
+				t.Errorf("%s: %s and %s are both %q", lang, other, phase, name)
+			}
+			seen[name] = phase
+		}
+	}
+}
+
+// a regional code is its language, and a language there are no names for is English
+func TestNameFallback(t *testing.T) {
+	tests := []struct {
+		lang string
+		want string
+		has  bool
+	}{
+		{"en", "Full Moon", true},
+		{"es", "Luna llena", true},
+		{"es-MX", "Luna llena", true},
+		{"fr_CA", "Pleine lune", true},
+		{"FR", "Pleine lune", true},
+		{" de-AT ", "Vollmond", true},
+		{"en-GB", "Full Moon", true},
+		{"pt-BR", "Full Moon", false},
+		{"xx", "Full Moon", false},
+		{"", "Full Moon", false},
+	}
+	for _, test := range tests {
+		if got := moonphase.FullMoon.Name(test.lang); (got != test.want) {
+			t.Errorf("%q: got %q, want %q", test.lang, got, test.want)
+		}
+		if got := moonphase.HasLanguage(test.lang); (got != test.has) {
+			t.Errorf("HasLanguage(%q) = %v, want %v", test.lang, got, test.has)
+		}
+	}
+}
+
+// from the south the lit side is mirrored, so the waxing and waning emoji swap and the New and
+// Full Moon stay as they are, with the shortcodes following the emoji
+func TestEmojiFor(t *testing.T) {
+	tests := []struct {
+		phase          moonphase.Phase
+		north, south   string
+		southShortcode string
+	}{
+		{moonphase.NewMoon, "🌑", "🌑", ":new_moon:"},
+		{moonphase.WaxingCrescent, "🌒", "🌘", ":waning_crescent_moon:"},
+		{moonphase.FirstQuarter, "🌓", "🌗", ":last_quarter_moon:"},
+		{moonphase.WaxingGibbous, "🌔", "🌖", ":waning_gibbous_moon:"},
+		{moonphase.FullMoon, "🌕", "🌕", ":full_moon:"},
+		{moonphase.WaningGibbous, "🌖", "🌔", ":waxing_gibbous_moon:"},
+		{moonphase.LastQuarter, "🌗", "🌓", ":first_quarter_moon:"},
+		{moonphase.WaningCrescent, "🌘", "🌒", ":waxing_crescent_moon:"},
+	}
+	for _, test := range tests {
+		if got := test.phase.EmojiFor(moonphase.NorthernHemisphere); (got != test.north || got != test.phase.Emoji()) {
+			t.Errorf("%s from the north: got %s, want %s", test.phase, got, test.north)
+		}
+		if got := test.phase.EmojiFor(moonphase.SouthernHemisphere); (got != test.south) {
+			t.Errorf("%s from the south: got %s, want %s", test.phase, got, test.south)
+		}
+		if got := test.phase.ShortcodeFor(moonphase.SouthernHemisphere); (got != test.southShortcode) {
+			t.Errorf("%s from the south: got %s, want %s", test.phase, got, test.southShortcode)
+		}
+		if got := test.phase.ShortcodeFor(moonphase.NorthernHemisphere); (got != test.phase.Shortcode()) {
+			t.Errorf("%s from the north: got %s, want %s", test.phase, got, test.phase.Shortcode())
+		}
+	}
+	if (len(tests) != len(moonphase.Phases)) {
+		t.Errorf("tested %d phases, there are %d", len(tests), len(moonphase.Phases))
+	}
+	if got := moonphase.Phase("Blue Moon").EmojiFor(moonphase.SouthernHemisphere); (got != "") {
+		t.Errorf("an unknown phase from the south is %q", got)
+	}
+}
+
+func TestParseHemisphere(t *testing.T) {
+	for name, want := range map[string]moonphase.Hemisphere{"north": moonphase.NorthernHemisphere, "south": moonphase.SouthernHemisphere, " South ": moonphase.SouthernHemisphere, "NORTH": moonphase.NorthernHemisphere} {
+		if got, err := moonphase.ParseHemisphere(name); (err != nil || got != want) {
+			t.Errorf("%q: got %s (%v), want %s", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "southern", "west"} {
+		if _, err := moonphase.ParseHemisphere(name); (err == nil) {
+			t.Errorf("%q: got no error", name)
+		}
+	}
+}
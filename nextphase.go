@@ -0,0 +1,83 @@
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// the next primary phase after a classified day
+type NextPrimaryPhase struct {
+	Phase     Phase   `json:"phase"`
+	PhaseID   string  `json:"phase_id"`
+	Instant   Instant `json:"instant"`
+	LocalDate DayKey  `json:"local_date"`
+	// calendar days from the classified day to LocalDate in the day's location, not 24 hour
+	// blocks, so a phase early tomorrow morning is 1 day away
+	DaysUntil int     `json:"days_until"`
+}
+
+// a day's phase along with the next primary phase after it, for output like
+// "Waxing Gibbous, 3 days until Full Moon"
+type PhaseResult struct {
+	Date    DayKey           `json:"date"`
+	Phase   Phase            `json:"phase"`
+	PhaseID string           `json:"phase_id"`
+	// the moon's age in days at the moment the day was classified from, see GetMoonAge
+	Age     float64          `json:"age_days"`
+	Next    NextPrimaryPhase `json:"next"`
+}
+
+// classifies the calendar day of now like GetCurrentPhase, takes the moon's age at now, and finds
+// the next primary phase, the first on a later local day that isn't the day's own phase. a day
+// snapped to tomorrow's Full Moon reports the Last Quarter after it, rather than being 1 day from
+// the phase it already shows
+func GetPhaseResult(now time.Time, recentData []MoonPhase, strategy ClassifyStrategy) (PhaseResult, error) {
+	phase, err := GetCurrentPhase(now, recentData, strategy)
+	if err != nil {
+		return PhaseResult{}, err
+	}
+	age, err := GetMoonAge(now, recentData)
+	if err != nil {
+		return PhaseResult{}, err
+	}
+	location := now.Location()
+	day := NewDayKey(now)
+	for _, candidate := range recentData {
+		if (!getPhaseDate(candidate, location).After(now) || Phase(candidate.Phase) == phase) {
+			continue
+		}
+		instant := phaseInstant(candidate)
+		localDate := NewDayKey(instant.In(location))
+		return PhaseResult{
+			Date:    day,
+			Phase:   phase,
+			PhaseID: PhaseID(string(phase)),
+			Age:     age,
+			Next: NextPrimaryPhase{
+				Phase:     Phase(candidate.Phase),
+				PhaseID:   PhaseID(candidate.Phase),
+				Instant:   Instant{instant},
+				LocalDate: localDate,
+				DaysUntil: day.DaysUntil(localDate),
+			},
+		}, nil
+	}
+	return PhaseResult{}, fmt.Errorf("date range of recent data doesn't have a primary phase after %s", phase)
+}
+
+// Get the moon's phase for the calendar day of date, in date's location, with the next primary phase after it
+func (c *Client) GetPhaseResultForDate(ctx context.Context, date time.Time, strategy ClassifyStrategy) (PhaseResult, error) {
+	day := NewDayKey(date).Time(date.Location())
+	// the phase after the bracketing one, for days that snap to it, and the New Moon the age counts from
+	recentData, err := c.getMoonDataAround(ctx, day, FetchStrategy{Past: 4, Future: 2, ExactTimes: true})
+	if err != nil {
+		return PhaseResult{}, err
+	}
+	return GetPhaseResult(day, recentData, strategy)
+}
+
+// Get the moon's phase for the calendar day of date with the next primary phase after it, using DefaultClient
+func GetPhaseResultForDate(ctx context.Context, date time.Time) (PhaseResult, error) {
+	return DefaultClient.GetPhaseResultForDate(ctx, date, SnapStrategy)
+}
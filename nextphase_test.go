@@ -0,0 +1,73 @@
+package moonphase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// days until the next primary phase are calendar days in the day's zone, so the same day can be a
+// different count from the phase in another zone, and it's the same count all day long
+func TestDaysUntilAcrossZones(t *testing.T) {
+	client := newReferenceClient()
+	tests := []struct {
+		zone      string
+		day       string
+		next      moonphase.Phase
+		localDate string
+		daysUntil int
+	}{
+		// the Full Moon of 2024-03-25 07:00 UT, midnight in Los Angeles and the evening before in Honolulu
+		{"UTC", "2024-03-20", moonphase.FullMoon, "2024-03-25", 5},
+		{"America/Los_Angeles", "2024-03-20", moonphase.FullMoon, "2024-03-25", 5},
+		{"Pacific/Honolulu", "2024-03-20", moonphase.FullMoon, "2024-03-24", 4},
+		{"Asia/Tokyo", "2024-03-20", moonphase.FullMoon, "2024-03-25", 5},
+		// the New Moon of 2024-12-30 22:27 UT is already the 31st in Tokyo
+		{"UTC", "2024-12-27", moonphase.NewMoon, "2024-12-30", 3},
+		{"America/New_York", "2024-12-27", moonphase.NewMoon, "2024-12-30", 3},
+		{"Asia/Tokyo", "2024-12-27", moonphase.NewMoon, "2024-12-31", 4},
+		// the New Moon of 2024-03-10 09:00 UT is at 01:00 in Los Angeles, an hour before the clocks
+		// go forward, two calendar days from the 8th though not two 24 hour blocks
+		{"America/Los_Angeles", "2024-03-08", moonphase.NewMoon, "2024-03-10", 2},
+		// a day snapped to the phase counts to the one after it
+		{"Pacific/Honolulu", "2024-03-23", moonphase.LastQuarter, "2024-04-01", 9},
+	}
+	for _, test := range tests {
+		location := loadLocation(t, test.zone)
+		day, err := moonphase.ParseDayKey(test.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := client.GetPhaseResultForDate(context.Background(), day.Time(location), moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (result.Next.Phase != test.next || result.Next.LocalDate.String() != test.localDate || result.Next.DaysUntil != test.daysUntil) {
+			t.Errorf("%s %s: %d days until the %s of %s, want %d until the %s of %s", test.zone, test.day, result.Next.DaysUntil, result.Next.Phase, result.Next.LocalDate, test.daysUntil, test.next, test.localDate)
+		}
+	}
+}
+
+// the count is the same at any time of the day, a minute before midnight isn't rounded down to the
+// 24 hour blocks left
+func TestDaysUntilIgnoresTimeOfDay(t *testing.T) {
+	location := loadLocation(t, "America/Los_Angeles")
+	phases := moonphasetest.MoonPhases(moonphasetest.ReferenceEvents()...)
+	for _, clock := range []string{"00:00", "00:01", "12:00", "23:59"} {
+		now, err := time.ParseInLocation("2006-01-02 15:04", "2024-03-22 " + clock, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := moonphase.GetPhaseResult(now, phases, moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the Full Moon is at midnight starting the 25th
+		if (result.Next.Phase != moonphase.FullMoon || result.Next.DaysUntil != 3) {
+			t.Errorf("%s: %d days until the %s, want 3 until the Full Moon", clock, result.Next.DaysUntil, result.Next.Phase)
+		}
+	}
+}
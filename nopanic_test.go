@@ -0,0 +1,80 @@
+package moonphase_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// marks a panic as one the library means to keep, on the line above it, with the reason
+const allowedPanicMarker string = "allowed panic:"
+
+// library code, every package but the command, reports failures as errors. it never panics, bar
+// the calls marked with allowedPanicMarker, and never exits the process with log.Fatal or os.Exit
+func TestNoPanicsInLibraryCode(t *testing.T) {
+	files := token.NewFileSet()
+	checked := 0
+	allowed := 0
+	err := filepath.WalkDir(".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if (entry.IsDir() && (entry.Name() == ".git" || entry.Name() == "testdata" || path == "cmd")) {
+			return filepath.SkipDir
+		}
+		if (entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go")) {
+			return nil
+		}
+		file, err := parser.ParseFile(files, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		checked++
+		// the lines a marker comment ends on
+		marked := map[int]bool{}
+		for _, group := range file.Comments {
+			if (strings.HasPrefix(group.Text(), allowedPanicMarker)) {
+				marked[files.Position(group.End()).Line] = true
+			}
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, isCall := node.(*ast.CallExpr)
+			if (!isCall) {
+				return true
+			}
+			position := files.Position(call.Pos())
+			switch function := call.Fun.(type) {
+			case *ast.Ident:
+				if (function.Name != "panic") {
+					break
+				}
+				if (marked[position.Line - 1]) {
+					allowed++
+					break
+				}
+				t.Errorf("%s: panic in library code, return an error, or mark it with %q and why above it", position, allowedPanicMarker)
+			case *ast.SelectorExpr:
+				if pkg, isIdent := function.X.(*ast.Ident); (isIdent && ((pkg.Name == "log" && strings.HasPrefix(function.Sel.Name, "Fatal")) || (pkg.Name == "os" && function.Sel.Name == "Exit"))) {
+					t.Errorf("%s: %s.%s exits from library code, return an error", position, pkg.Name, function.Sel.Name)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the package and the test helpers
+	if (checked < 20) {
+		t.Errorf("checked %d files, want every library package", checked)
+	}
+	// the two in moonphasetest that turn a broken fixture into a failing test
+	if (allowed != 2) {
+		t.Errorf("%d allowed panics, want the 2 moonphasetest helpers keep, update this as they change", allowed)
+	}
+}
@@ -0,0 +1,86 @@
+package moonphase
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// the primary phases in cycle order, starting from the New Moon
+var primaryPhases = []Phase{NewMoon, FirstQuarter, FullMoon, LastQuarter}
+
+// the mean interval between consecutive primary phases
+const meanPhaseInterval time.Duration = MeanSynodicMonth / 4
+
+// returns the primary phases around t from the mean lunar cycle, in the same shape as the API's
+// phase data so it can be passed to GetCurrentPhase or GetCycleSegment. the mean phases stay within
+// about 14 hours of the real ones for new and full moons, and within a day for the quarters
+func MeanPhases(t time.Time) []MoonPhase {
+	// index of the last mean primary phase at or before t, counting from ReferenceNewMoon
+	index := int(math.Floor(meanPhaseIndex(t)))
+	var phases []MoonPhase
+	// two either side covers the day attribution done by the classifiers, and the four at or
+	// before t hold the New Moon GetMoonAge counts from
+	for i := index - 3; i <= index + 2; i++ {
+		phases = append(phases, meanPhase(i))
+	}
+	return phases
+}
+
+// returns the mean primary phases with instants from start up to but not including end, in order
+func MeanPhasesBetween(start time.Time, end time.Time) []MoonPhase {
+	var phases []MoonPhase
+	// one before the first whole index, the instants are rounded to the minute
+	for i := int(math.Ceil(meanPhaseIndex(start))) - 1; ; i++ {
+		phase := meanPhase(i)
+		instant := phaseInstant(phase)
+		if (!instant.Before(end)) {
+			return phases
+		}
+		if (!instant.Before(start)) {
+			phases = append(phases, phase)
+		}
+	}
+}
+
+// returns how many mean phase intervals t is from ReferenceNewMoon. counted in seconds, since a
+// time.Duration only reaches 292 years from ReferenceNewMoon
+func meanPhaseIndex(t time.Time) float64 {
+	return float64(t.Unix() - ReferenceNewMoon.Unix()) / meanPhaseInterval.Seconds()
+}
+
+// returns the i-th mean primary phase counting from ReferenceNewMoon, rounded to the minute like the API's times
+func meanPhase(i int) MoonPhase {
+	instant := time.Unix(ReferenceNewMoon.Unix() + int64(float64(i) * meanPhaseInterval.Seconds()), 0).UTC().Round(time.Minute)
+	return MoonPhase{
+		Day:   instant.Day(),
+		Month: int(instant.Month()),
+		Year:  instant.Year(),
+		Phase: string(primaryPhases[((i % 4) + 4) % 4]),
+		Time:  instant.Format("15:04"),
+	}
+}
+
+// Compute the moon's phase for the calendar day of t, in t's location, without the API.
+// days are classified the same way GetPhaseForDate does, against the mean phases from MeanPhases
+func ComputePhase(t time.Time) Phase {
+	day := NewDayKey(t).Time(t.Location())
+	// the mean phases always surround the day, so classifying them can't fail
+	phase, _ := GetCurrentPhase(day, MeanPhases(day), SnapStrategy)
+	return phase
+}
+
+// OfflineProvider is a Provider serving the mean phases, never failing. registered below
+// USNOPriority it answers lookups when the API can't
+type OfflineProvider struct{}
+
+// returns numPhases consecutive mean primary phases from the first on or after date in UT
+func (OfflineProvider) GetMoonData(ctx context.Context, date DayKey, numPhases int) ([]MoonPhase, error) {
+	start := date.Time(time.UTC)
+	// one interval more than needed, so rounding never leaves it a phase short
+	phases := MeanPhasesBetween(start, start.Add(time.Duration(numPhases + 1) * meanPhaseInterval))
+	if (len(phases) > numPhases) {
+		phases = phases[:numPhases]
+	}
+	return phases, nil
+}
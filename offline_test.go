@@ -0,0 +1,57 @@
+package moonphase_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// every New and Full Moon in the recorded USNO response is within a day of the mean one, and
+// ComputePhase gives it on the day it falls on, wherever it's seen from
+func TestComputePhaseAgreesWithUSNO(t *testing.T) {
+	recorded, err := moonphase.FileProvider{Path: "moonphasetest/testdata/usno-2024.json"}.GetMoonData(context.Background(), moonphase.DayKey{Year: 2023, Month: time.January, Day: 1}, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := 0
+	for _, phase := range recorded {
+		if (moonphase.Phase(phase.Phase) != moonphase.NewMoon && moonphase.Phase(phase.Phase) != moonphase.FullMoon) {
+			continue
+		}
+		checked++
+		instant, err := moonphase.GetPhaseInstant(phase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nearest := time.Duration(math.MaxInt64)
+		for _, mean := range moonphase.MeanPhases(instant) {
+			meanInstant, _ := moonphase.GetPhaseInstant(mean)
+			if (mean.Phase == phase.Phase && absDuration(meanInstant.Sub(instant)) < nearest) {
+				nearest = absDuration(meanInstant.Sub(instant))
+			}
+		}
+		if (nearest > 24 * time.Hour) {
+			t.Errorf("the %s of %s is %s from the mean one", phase.Phase, instant.Format(time.RFC3339), nearest)
+		}
+		for _, zone := range []string{"UTC", "America/Los_Angeles", "Asia/Tokyo"} {
+			local := instant.In(loadLocation(t, zone))
+			if got := moonphase.ComputePhase(local); (got != moonphase.Phase(phase.Phase)) {
+				t.Errorf("%s %s: got %s, want the %s of %s", zone, local.Format("2006-01-02"), got, phase.Phase, local.Format(time.RFC3339))
+			}
+		}
+	}
+	// thirteen or fourteen of each from 2023 into 2025
+	if (checked < 26) {
+		t.Errorf("checked %d New and Full Moons, the fixture has too few", checked)
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if (d < 0) {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,240 @@
+// Package otlp exports the spans of a moonphase.Client to an OpenTelemetry collector, posting
+// them as OTLP/HTTP JSON with only the standard library, so tracing a Client needs no other module:
+//
+//	exporter := otlp.NewExporter("http://localhost:4318", "my-service")
+//	client := moonphase.NewClient()
+//	client.Tracer = exporter
+//	go exporter.Run(ctx, 5 * time.Second, nil)
+//	defer exporter.Flush(context.Background())
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the path under the Endpoint OTLP/HTTP takes traces on
+const tracesPath string = "/v1/traces"
+
+// the most ended spans kept for the next Flush, past this the oldest are dropped
+const maxPending int = 2048
+
+// Exporter is a moonphase.Tracer keeping the spans it starts until Flush posts the ended ones to
+// an OTLP collector
+type Exporter struct {
+	// Endpoint is the collector's base URL, like http://localhost:4318
+	Endpoint string
+	// ServiceName is the service.name the spans are reported under
+	ServiceName string
+	// HTTPClient posts the spans, http.DefaultClient when nil
+	HTTPClient *http.Client
+	mutex   sync.Mutex
+	pending []*span
+	dropped int
+}
+
+// returns an Exporter posting to the collector at endpoint
+func NewExporter(endpoint string, serviceName string) *Exporter {
+	return &Exporter{
+		Endpoint:    strings.TrimSuffix(endpoint, "/"),
+		ServiceName: serviceName,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// the key an Exporter's span is kept under in a context
+type spanKey struct{}
+
+// a span an Exporter started
+type span struct {
+	exporter   *Exporter
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	err        error
+}
+
+// returns n random bytes in hex, the form OTLP JSON takes trace and span IDs in
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// starts a span, a child of the span ctx carries if an Exporter started it, otherwise the
+// first of a new trace
+func (e *Exporter) Start(ctx context.Context, name string) (context.Context, moonphase.Span) {
+	s := &span{exporter: e, spanID: randomID(8), name: name, start: time.Now(), attributes: map[string]interface{}{}}
+	if parent, found := ctx.Value(spanKey{}).(*span); (found) {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		s.traceID = randomID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *span) SetAttribute(key string, value interface{}) {
+	s.exporter.mutex.Lock()
+	defer s.exporter.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+// ends the span and queues it for the next Flush
+func (s *span) End(err error) {
+	e := s.exporter
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if (!s.end.IsZero()) {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	if (len(e.pending) >= maxPending) {
+		e.pending = e.pending[1:]
+		e.dropped++
+	}
+	e.pending = append(e.pending, s)
+}
+
+// an attribute of OTLP JSON, whose 64 bit integers are strings
+type keyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// returns an attribute in OTLP JSON
+func attribute(key string, value interface{}) keyValue {
+	switch v := value.(type) {
+	case bool:
+		return keyValue{key, map[string]interface{}{"boolValue": v}}
+	case int:
+		return keyValue{key, map[string]interface{}{"intValue": strconv.Itoa(v)}}
+	case int64:
+		return keyValue{key, map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}}
+	case float64:
+		return keyValue{key, map[string]interface{}{"doubleValue": v}}
+	case string:
+		return keyValue{key, map[string]interface{}{"stringValue": v}}
+	}
+	return keyValue{key, map[string]interface{}{"stringValue": fmt.Sprint(value)}}
+}
+
+// the status codes of OTLP spans
+const (
+	statusUnset int = 0
+	statusError int = 2
+)
+
+// SPAN_KIND_INTERNAL, the spans carry no kind of their own
+const spanKindInternal int = 1
+
+// returns s as a span of OTLP JSON
+func (s *span) encode() map[string]interface{} {
+	attributes := []keyValue{}
+	for key, value := range s.attributes {
+		attributes = append(attributes, attribute(key, value))
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Key < attributes[j].Key })
+	status := map[string]interface{}{"code": statusUnset}
+	if (s.err != nil) {
+		status = map[string]interface{}{"code": statusError, "message": s.err.Error()}
+	}
+	encoded := map[string]interface{}{
+		"traceId":           s.traceID,
+		"spanId":            s.spanID,
+		"name":              s.name,
+		"kind":              spanKindInternal,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        attributes,
+		"status":            status,
+	}
+	if (s.parentID != "") {
+		encoded["parentSpanId"] = s.parentID
+	}
+	return encoded
+}
+
+// posts the spans ended since the last Flush to the collector. spans a failed post couldn't
+// deliver aren't sent again
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.mutex.Lock()
+	pending := e.pending
+	dropped := e.dropped
+	e.pending = nil
+	e.dropped = 0
+	spans := make([]map[string]interface{}, len(pending))
+	for i, s := range pending {
+		spans[i] = s.encode()
+	}
+	e.mutex.Unlock()
+	if (len(spans) == 0) {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": []keyValue{attribute("service.name", e.ServiceName)}},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/mitchthorson/go-moon-phase"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint + tracesPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	httpClient := e.HTTPClient
+	if (httpClient == nil) {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("exporting %d spans: %w", len(spans), err)
+	}
+	response.Body.Close()
+	if (response.StatusCode >= 300) {
+		return fmt.Errorf("exporting %d spans: the collector answered %s", len(spans), response.Status)
+	}
+	if (dropped > 0) {
+		return fmt.Errorf("dropped %d spans ended faster than they were exported", dropped)
+	}
+	return nil
+}
+
+// flushes every interval until ctx is done, passing the errors to report, which may be nil
+func (e *Exporter) Run(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := e.Flush(ctx); (err != nil && report != nil) {
+			report(err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package otlp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+	"github.com/mitchthorson/go-moon-phase/otlp"
+)
+
+// the parts of an OTLP/HTTP JSON export the tests look at
+type exportRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []exportAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []exportSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type exportAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type exportSpan struct {
+	TraceID      string            `json:"traceId"`
+	SpanID       string            `json:"spanId"`
+	ParentSpanID string            `json:"parentSpanId"`
+	Name         string            `json:"name"`
+	Start        string            `json:"startTimeUnixNano"`
+	End          string            `json:"endTimeUnixNano"`
+	Attributes   []exportAttribute `json:"attributes"`
+	Status       struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+// returns the value of key among attributes, as OTLP JSON gives it
+func attributeValue(attributes []exportAttribute, key string) map[string]interface{} {
+	for _, attribute := range attributes {
+		if (attribute.Key == key) {
+			return attribute.Value
+		}
+	}
+	return nil
+}
+
+// returns a collector taking OTLP/HTTP JSON, and the exports it was sent
+func newCollector(t *testing.T) (*httptest.Server, func() []exportRequest) {
+	t.Helper()
+	var mutex sync.Mutex
+	var exports []exportRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json") {
+			http.Error(w, "not an OTLP/HTTP JSON export", http.StatusBadRequest)
+			return
+		}
+		var export exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		exports = append(exports, export)
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []exportRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return exports
+	}
+}
+
+// a lookup's cache and request spans are exported in the trace of the span around them, with
+// its ID as their parent, the service name and the request's attributes
+func TestExportLookup(t *testing.T) {
+	collector, exports := newCollector(t)
+	usno := moonphasetest.RecordedUSNOServer(t, "../moonphasetest/testdata/usno-2024.json")
+	exporter := otlp.NewExporter(collector.URL + "/", "moonphase-test")
+	client := moonphase.NewClient()
+	client.BaseURL = usno.URL
+	client.CacheDir = t.TempDir()
+	client.Tracer = exporter
+	ctx, lookup := exporter.Start(context.Background(), "lookup")
+	if _, err := client.GetPhaseResultForDate(ctx, time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), moonphase.SnapStrategy); err != nil {
+		t.Fatal(err)
+	}
+	lookup.End(nil)
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if (len(exports()) != 1) {
+		t.Fatalf("got %d exports, want one, a Flush with nothing ended sends nothing", len(exports()))
+	}
+	export := exports()[0]
+	if (len(export.ResourceSpans) != 1 || len(export.ResourceSpans[0].ScopeSpans) != 1) {
+		t.Fatalf("got %+v", export)
+	}
+	if name := attributeValue(export.ResourceSpans[0].Resource.Attributes, "service.name"); (name["stringValue"] != "moonphase-test") {
+		t.Errorf("service.name %v", name)
+	}
+	spans := map[string]exportSpan{}
+	for _, span := range export.ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[span.Name] = span
+	}
+	root := spans["lookup"]
+	if (len(root.TraceID) != 32 || len(root.SpanID) != 16 || root.ParentSpanID != "") {
+		t.Errorf("the lookup span: %+v", root)
+	}
+	for _, name := range []string{moonphase.SpanCacheLookup, moonphase.SpanUpstreamRequest} {
+		span, found := spans[name]
+		if (!found || span.TraceID != root.TraceID || span.ParentSpanID != root.SpanID || span.Status.Code != 0 || span.Start > span.End) {
+			t.Errorf("%s: %+v, want a child of %s in its trace", name, span, root.SpanID)
+		}
+	}
+	request := spans[moonphase.SpanUpstreamRequest].Attributes
+	if status := attributeValue(request, "http.status_code"); (status["intValue"] != "200") {
+		t.Errorf("http.status_code %v", status)
+	}
+	if retries := attributeValue(request, "retry.count"); (retries["intValue"] != "0") {
+		t.Errorf("retry.count %v", retries)
+	}
+	if result := attributeValue(spans[moonphase.SpanCacheLookup].Attributes, "cache.result"); (result["stringValue"] != "miss") {
+		t.Errorf("cache.result %v", result)
+	}
+}
+
+// a failed span has the error status with its message, and a collector refusing the export is an error
+func TestExportErrors(t *testing.T) {
+	collector, exports := newCollector(t)
+	exporter := otlp.NewExporter(collector.URL, "moonphase-test")
+	_, span := exporter.Start(context.Background(), "failing")
+	span.End(errors.New("upstream down"))
+	span.End(nil)
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := exports()[0].ResourceSpans[0].ScopeSpans[0].Spans
+	if (len(spans) != 1 || spans[0].Status.Code != 2 || spans[0].Status.Message != "upstream down") {
+		t.Errorf("got %+v, want the one span, failed", spans)
+	}
+
+	refusing := otlp.NewExporter(collector.URL + "/elsewhere", "moonphase-test")
+	_, span = refusing.Start(context.Background(), "refused")
+	span.End(nil)
+	if err := refusing.Flush(context.Background()); (err == nil) {
+		t.Error("got no error from a collector answering 400")
+	}
+}
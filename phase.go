@@ -0,0 +1,119 @@
+package moonphase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The name of a phase of the moon, like "Waxing Gibbous"
+type Phase string
+
+// the eight phases, named as the API names the primary ones
+const (
+	NewMoon        Phase = "New Moon"
+	WaxingCrescent Phase = "Waxing Crescent"
+	FirstQuarter   Phase = "First Quarter"
+	WaxingGibbous  Phase = "Waxing Gibbous"
+	FullMoon       Phase = "Full Moon"
+	WaningGibbous  Phase = "Waning Gibbous"
+	LastQuarter    Phase = "Last Quarter"
+	WaningCrescent Phase = "Waning Crescent"
+)
+
+// Phases holds the eight phases in cycle order, starting from the New Moon
+var Phases = []Phase{
+	NewMoon, WaxingCrescent, FirstQuarter, WaxingGibbous,
+	FullMoon, WaningGibbous, LastQuarter, WaningCrescent,
+}
+
+// the symbols for each phase, kept together so a new phase can't miss one
+var phaseSymbols = map[Phase]struct{ emoji, shortcode string }{
+	NewMoon:        {"🌑", ":new_moon:"},
+	WaxingCrescent: {"🌒", ":waxing_crescent_moon:"},
+	FirstQuarter:   {"🌓", ":first_quarter_moon:"},
+	WaxingGibbous:  {"🌔", ":waxing_gibbous_moon:"},
+	FullMoon:       {"🌕", ":full_moon:"},
+	WaningGibbous:  {"🌖", ":waning_gibbous_moon:"},
+	LastQuarter:    {"🌗", ":last_quarter_moon:"},
+	WaningCrescent: {"🌘", ":waning_crescent_moon:"},
+}
+
+// parses a phase name as the API writes it, like "Full Moon". anything that isn't one of the
+// eight phases is an error, so an unexpected value from the API is caught rather than printed
+func ParsePhase(name string) (Phase, error) {
+	phase := Phase(strings.TrimSpace(name))
+	if _, found := phaseSymbols[phase]; (!found) {
+		return "", fmt.Errorf("unknown phase %q", name)
+	}
+	return phase, nil
+}
+
+func (p Phase) String() string {
+	return string(p)
+}
+
+// returns the moon emoji for the phase as seen from the northern hemisphere, like 🌕 for the Full Moon
+func (p Phase) Emoji() string {
+	return phaseSymbols[p].emoji
+}
+
+// returns the GitHub and Slack shortcode for the phase, like :full_moon:
+func (p Phase) Shortcode() string {
+	return phaseSymbols[p].shortcode
+}
+
+// which hemisphere the moon is seen from. the lit side of the moon, and so the emoji for the
+// waxing and waning phases, is mirrored between the two
+type Hemisphere int
+
+const (
+	NorthernHemisphere Hemisphere = iota
+	SouthernHemisphere
+)
+
+// the phase whose emoji looks right from the southern hemisphere, mirrored left to right
+var southernMirror = map[Phase]Phase{
+	WaxingCrescent: WaningCrescent,
+	FirstQuarter:   LastQuarter,
+	WaxingGibbous:  WaningGibbous,
+	WaningGibbous:  WaxingGibbous,
+	LastQuarter:    FirstQuarter,
+	WaningCrescent: WaxingCrescent,
+}
+
+// parses a hemisphere given as north or south
+func ParseHemisphere(name string) (Hemisphere, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "north":
+		return NorthernHemisphere, nil
+	case "south":
+		return SouthernHemisphere, nil
+	}
+	return NorthernHemisphere, fmt.Errorf("unknown hemisphere %q, expected north or south", name)
+}
+
+func (h Hemisphere) String() string {
+	if (h == SouthernHemisphere) {
+		return "south"
+	}
+	return "north"
+}
+
+// returns the phase whose symbols show p as seen from h, the New and Full Moon look the same from both
+func (h Hemisphere) mirror(p Phase) Phase {
+	if mirrored, found := southernMirror[p]; (h == SouthernHemisphere && found) {
+		return mirrored
+	}
+	return p
+}
+
+// returns the moon emoji for the phase as seen from hemisphere, a First Quarter is 🌓 in the
+// north and 🌗 in the south
+func (p Phase) EmojiFor(hemisphere Hemisphere) string {
+	return hemisphere.mirror(p).Emoji()
+}
+
+// returns the shortcode for the emoji EmojiFor gives
+func (p Phase) ShortcodeFor(hemisphere Hemisphere) string {
+	return hemisphere.mirror(p).Shortcode()
+}
@@ -0,0 +1,161 @@
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Provider is a source of primary phase data, in the shape of the date form of the USNO API.
+// a *Client is one, fetching from the API
+type Provider interface {
+	// returns numPhases consecutive primary phases, the first being the first on or after date in
+	// UT. providers that make requests stop them when ctx is cancelled
+	GetMoonData(ctx context.Context, date DayKey, numPhases int) ([]MoonPhase, error)
+}
+
+// the priority of the USNO API in a Client's provider chain, providers registered above it are tried first
+const USNOPriority int = 0
+
+// the name the USNO API has in Providers
+const usnoProviderName string = "usno"
+
+// the name a Client's Source has in Providers
+const sourceProviderName string = "source"
+
+// a Provider in a Client's chain
+type registeredProvider struct {
+	name     string
+	provider Provider
+	priority int
+	health   func() error
+	// set for the USNO API's own entry, whose answers are the only ones cached
+	usno     bool
+}
+
+// RegisterProvider adds provider to the chain the single date lookups and GetPhasesForRange fetch
+// from. providers are tried in order of priority, highest first, with the USNO API or c.Source at
+// USNOPriority and earlier registrations first among equals. when health is given it's called before
+// each request, and a provider whose health check fails, whose request fails or whose data doesn't check
+// out is passed over for the next one. not safe to call while c is in use
+func (c *Client) RegisterProvider(name string, provider Provider, priority int, health func() error) {
+	c.providers = append(c.providers, registeredProvider{name: name, provider: provider, priority: priority, health: health})
+}
+
+// returns the names of the providers in c's chain in the order they're tried
+func (c *Client) Providers() []string {
+	var names []string
+	for _, entry := range c.providerChain() {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// CoverageChecker is a Provider that can tell without a lookup whether it has data for a day, like
+// FileProvider
+type CoverageChecker interface {
+	Provider
+	// returns why lookups of day can't succeed, or nil if they plausibly can
+	CheckCoverage(day DayKey) error
+}
+
+// CheckCoverage returns nil if some provider in c's chain can plausibly serve a lookup of day, so a
+// lookup bound to fail can be refused before any request. the USNO API covers USNOFirstYear to
+// USNOLastYear, a provider that's a CoverageChecker is asked and any other is taken to cover every
+// day. health checks aren't run. when only the USNO API or c.Source is in the chain its error is
+// returned as it is, otherwise every provider's error is listed
+func (c *Client) CheckCoverage(day DayKey) error {
+	chain := c.providerChain()
+	var failures []string
+	var lastErr error
+	for _, entry := range chain {
+		var err error
+		if (entry.usno) {
+			if (!USNOCovers(day)) {
+				err = &ErrOutsideCoverage{Day: day}
+			}
+		} else if checker, ok := entry.provider.(CoverageChecker); (ok) {
+			err = checker.CheckCoverage(day)
+		}
+		if (err == nil) {
+			return nil
+		}
+		lastErr = err
+		failures = append(failures, fmt.Sprintf("%s: %v", entry.name, err))
+	}
+	if (len(chain) == 1) {
+		return lastErr
+	}
+	return fmt.Errorf("no provider covers %s: %s", day, strings.Join(failures, "; "))
+}
+
+// returns c's Source, or c itself fetching from the USNO API when there isn't one
+func (c *Client) source() Provider {
+	if (c.Source != nil) {
+		return c.Source
+	}
+	return c
+}
+
+// returns the registered providers along with c's source, in the order they're tried
+func (c *Client) providerChain() []registeredProvider {
+	base := registeredProvider{name: usnoProviderName, provider: c, priority: USNOPriority, usno: true}
+	if (c.Source != nil) {
+		base = registeredProvider{name: sourceProviderName, provider: c.Source, priority: USNOPriority}
+	}
+	chain := append([]registeredProvider{base}, c.providers...)
+	// stable, so ties keep registration order with the USNO API ahead of providers registered at its priority
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].priority > chain[j].priority
+	})
+	return chain
+}
+
+// checks the time and phase name of every phase, so the rest of the package can rely on them
+func checkPhases(phases []MoonPhase) error {
+	for _, phase := range phases {
+		if _, err := GetPhaseInstant(phase); err != nil {
+			return err
+		}
+		if _, err := ParsePhase(phase.Phase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetches phase data from the first provider in c's chain that's healthy and answers, and returns the
+// entry of the one that answered. when only the USNO API or c.Source is in the chain its error is returned as
+// it is, otherwise every provider's error is listed
+func (c *Client) getMoonDataFromChain(ctx context.Context, date DayKey, numPhases int) ([]MoonPhase, registeredProvider, error) {
+	chain := c.providerChain()
+	var failures []string
+	var lastErr error
+	for _, entry := range chain {
+		// a cancelled lookup stops here rather than falling through to the next provider
+		if err := ctx.Err(); err != nil {
+			return nil, registeredProvider{}, err
+		}
+		if (entry.health != nil) {
+			if err := entry.health(); err != nil {
+				lastErr = fmt.Errorf("%s is unhealthy: %w", entry.name, err)
+				failures = append(failures, lastErr.Error())
+				continue
+			}
+		}
+		phases, err := entry.provider.GetMoonData(ctx, date, numPhases)
+		if (err == nil) {
+			err = checkPhases(phases)
+		}
+		if (err == nil) {
+			return phases, entry, nil
+		}
+		lastErr = err
+		failures = append(failures, fmt.Sprintf("%s: %v", entry.name, err))
+	}
+	if (len(chain) == 1) {
+		return nil, registeredProvider{}, lastErr
+	}
+	return nil, registeredProvider{}, fmt.Errorf("no provider could answer: %s", strings.Join(failures, "; "))
+}
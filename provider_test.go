@@ -0,0 +1,57 @@
+package moonphase_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the USNO API covers its years, a file the days between its first and last phases give or take
+// one, the mean cycle every day, and a chain any day one of its providers covers
+func TestCheckCoverage(t *testing.T) {
+	recorded := moonphase.FileProvider{Path: "moonphasetest/testdata/usno-2024.json"}
+	missing := moonphase.FileProvider{Path: "moonphasetest/testdata/missing.json"}
+	withOffline := moonphase.NewClient()
+	withOffline.RegisterProvider("offline", moonphase.OfflineProvider{}, moonphase.USNOPriority - 1, nil)
+	withMissing := moonphase.NewClient()
+	withMissing.RegisterProvider("missing", missing, moonphase.USNOPriority - 1, nil)
+	var outsideCoverage *moonphase.ErrOutsideCoverage
+	var outsideFile *moonphase.ErrOutsideFile
+	tests := []struct {
+		name   string
+		client *moonphase.Client
+		day    moonphase.DayKey
+		// what the error has to be, nil for none
+		want   func(err error) bool
+	}{
+		{"usno", moonphase.NewClient(), moonphase.DayKey{Year: 2024, Month: time.March, Day: 25}, nil},
+		{"usno, first year", moonphase.NewClient(), moonphase.DayKey{Year: 1700, Month: time.January, Day: 1}, nil},
+		{"usno, before", moonphase.NewClient(), moonphase.DayKey{Year: 1699, Month: time.December, Day: 31}, func(err error) bool { return errors.As(err, &outsideCoverage) }},
+		{"usno, after", moonphase.NewClient(), moonphase.DayKey{Year: 2101, Month: time.January, Day: 1}, func(err error) bool { return errors.As(err, &outsideCoverage) }},
+		{"file", &moonphase.Client{Source: recorded}, moonphase.DayKey{Year: 2024, Month: time.June, Day: 1}, nil},
+		{"file, a day before its first phase", &moonphase.Client{Source: recorded}, moonphase.DayKey{Year: 2023, Month: time.December, Day: 4}, nil},
+		{"file, before", &moonphase.Client{Source: recorded}, moonphase.DayKey{Year: 2023, Month: time.December, Day: 3}, func(err error) bool {
+			return errors.As(err, &outsideFile) && outsideFile.First == moonphase.DayKey{Year: 2023, Month: time.December, Day: 5} && strings.Contains(err.Error(), "from 2023-12-05 to 2025-01-29")
+		}},
+		{"file, a day after its last phase", &moonphase.Client{Source: recorded}, moonphase.DayKey{Year: 2025, Month: time.January, Day: 30}, nil},
+		{"file, after", &moonphase.Client{Source: recorded}, moonphase.DayKey{Year: 2025, Month: time.January, Day: 31}, func(err error) bool {
+			return errors.As(err, &outsideFile) && outsideFile.Last == moonphase.DayKey{Year: 2025, Month: time.January, Day: 29}
+		}},
+		{"missing file", &moonphase.Client{Source: missing}, moonphase.DayKey{Year: 2024, Month: time.June, Day: 1}, func(err error) bool { return errors.Is(err, fs.ErrNotExist) }},
+		{"offline", &moonphase.Client{Source: moonphase.OfflineProvider{}}, moonphase.DayKey{Year: 1500, Month: time.June, Day: 1}, nil},
+		{"usno with offline below", withOffline, moonphase.DayKey{Year: 2150, Month: time.June, Day: 1}, nil},
+		{"usno with a missing file below", withMissing, moonphase.DayKey{Year: 2150, Month: time.June, Day: 1}, func(err error) bool {
+			return strings.Contains(err.Error(), "usno: USNO data only available") && strings.Contains(err.Error(), "missing: open")
+		}},
+	}
+	for _, test := range tests {
+		err := test.client.CheckCoverage(test.day)
+		if (test.want == nil && err != nil || test.want != nil && (err == nil || !test.want(err))) {
+			t.Errorf("%s, %s: got %v", test.name, test.day, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package moonphase
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+// a math/rand source behind a lock, a Client's Rand may be read from several lookups at once
+type seededRand struct {
+	mu     sync.Mutex
+	source *mathrand.Rand
+}
+
+func (r *seededRand) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.source.Read(p)
+}
+
+// SeededRand returns a Client.Rand that gives the same bytes for the same seed, for tests and
+// runs that have to repeat exactly. it's safe to read from concurrently
+func SeededRand(seed int64) io.Reader {
+	return &seededRand{source: mathrand.New(mathrand.NewSource(seed))}
+}
+
+// returns where c draws randomness from, crypto/rand unless Rand is set
+func (c *Client) random() io.Reader {
+	if (c.Rand == nil) {
+		return rand.Reader
+	}
+	return c.Rand
+}
+
+// returns how long to wait before a retry: backoff with up to half of it taken off at random, so
+// clients that failed together don't all retry together, or retryAfter if the server asked for
+// longer. without randomness to read it waits the whole backoff
+func retryWait(backoff time.Duration, retryAfter time.Duration, random io.Reader) time.Duration {
+	wait := backoff
+	var b [8]byte
+	if _, err := io.ReadFull(random, b[:]); (err == nil && backoff >= 2) {
+		wait -= time.Duration(binary.BigEndian.Uint64(b[:]) % uint64(backoff / 2))
+	}
+	if (retryAfter > wait) {
+		wait = retryAfter
+	}
+	return wait
+}
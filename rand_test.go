@@ -0,0 +1,52 @@
+package moonphase_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+func TestSeededRandRepeats(t *testing.T) {
+	a, b := make([]byte, 64), make([]byte, 64)
+	io.ReadFull(moonphase.SeededRand(7), a)
+	io.ReadFull(moonphase.SeededRand(7), b)
+	if (!bytes.Equal(a, b)) {
+		t.Errorf("seed 7 gave %x, then %x", a, b)
+	}
+	io.ReadFull(moonphase.SeededRand(8), b)
+	if (bytes.Equal(a, b)) {
+		t.Error("seeds 7 and 8 gave the same bytes")
+	}
+}
+
+// the jitter takes at most half the backoff off, the same for the same seed, and never shortens a Retry-After
+func TestRetryWait(t *testing.T) {
+	backoff := 500 * time.Millisecond
+	first, second := moonphase.SeededRand(1), moonphase.SeededRand(1)
+	varied := false
+	for i := 0; i < 100; i++ {
+		wait := moonphase.RetryWait(backoff, 0, first)
+		if (wait <= backoff / 2 || wait > backoff) {
+			t.Fatalf("waited %s for a %s backoff", wait, backoff)
+		}
+		if again := moonphase.RetryWait(backoff, 0, second); (again != wait) {
+			t.Fatalf("the same seed waited %s, then %s", wait, again)
+		}
+		varied = varied || wait != backoff
+	}
+	if (!varied) {
+		t.Error("a hundred waits had no jitter")
+	}
+	if wait := moonphase.RetryWait(backoff, 3 * time.Second, moonphase.SeededRand(1)); (wait != 3 * time.Second) {
+		t.Errorf("waited %s, want the 3s Retry-After", wait)
+	}
+	// without randomness the whole backoff is waited
+	if wait := moonphase.RetryWait(backoff, 0, iotest.ErrReader(errors.New("no entropy"))); (wait != backoff) {
+		t.Errorf("waited %s without randomness, want %s", wait, backoff)
+	}
+}
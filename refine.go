@@ -0,0 +1,104 @@
+package moonphase
+
+import (
+	"context"
+	"time"
+)
+
+// where the instant of a RefinedPhase came from
+type Provenance string
+
+const (
+	// the mean lunar cycle, as MeanPhasesBetween calculates it
+	OfflineProvenance Provenance = "offline"
+	// the API, or the client's Source, paired with the mean phase
+	APIProvenance Provenance = "api"
+)
+
+// RefineMaxGap is how far apart a mean phase and the API's can be and still be paired. the mean
+// phases stay within about 14 hours of the real ones, so the pair is always the same event
+const RefineMaxGap time.Duration = 24 * time.Hour
+
+// RefinedPhase is a phase event from the mean lunar cycle, with the API's instant for it when there
+// was one within RefineMaxGap
+type RefinedPhase struct {
+	MoonPhase
+	Provenance Provenance
+	// how much later the API's instant is than the mean one, zero for an unrefined phase
+	Correction time.Duration
+}
+
+// RefinePhases pairs each of the mean phases offline with the API's phase of the same name within
+// RefineMaxGap of it, in order, and takes the API's date and time for it. a mean phase without a
+// pair keeps its instant and OfflineProvenance, API phases without one are left out
+func RefinePhases(offline []MoonPhase, api []MoonPhase) []RefinedPhase {
+	refined := make([]RefinedPhase, 0, len(offline))
+	paired := make([]bool, len(api))
+	// both lists are in order, so the search only moves forward
+	first := 0
+	for _, meanPhase := range offline {
+		meanInstant := phaseInstant(meanPhase)
+		for (first < len(api) && phaseInstant(api[first]).Before(meanInstant.Add(-RefineMaxGap))) {
+			first++
+		}
+		event := RefinedPhase{MoonPhase: meanPhase, Provenance: OfflineProvenance}
+		nearest := -1
+		var nearestGap time.Duration
+		for i := first; (i < len(api) && !phaseInstant(api[i]).After(meanInstant.Add(RefineMaxGap))); i++ {
+			gap := phaseInstant(api[i]).Sub(meanInstant)
+			if (paired[i] || api[i].Phase != meanPhase.Phase) {
+				continue
+			}
+			if (nearest < 0 || absDuration(gap) < absDuration(nearestGap)) {
+				nearest = i
+				nearestGap = gap
+			}
+		}
+		if (nearest >= 0) {
+			paired[nearest] = true
+			event = RefinedPhase{MoonPhase: api[nearest], Provenance: APIProvenance, Correction: nearestGap}
+		}
+		refined = append(refined, event)
+	}
+	return refined
+}
+
+// returns the length of d whichever way it goes
+func absDuration(d time.Duration) time.Duration {
+	if (d < 0) {
+		return -d
+	}
+	return d
+}
+
+// RefinePhasesBetween returns the mean phases with instants in the half-open range [start, end),
+// calculated without the API, then refined with RefinePhases against what c gives for the years
+// USNO covers. phases past the end of the coverage, or of c.Source's data, are left unrefined,
+// so a range decades long costs no more requests than the years the API has
+func (c *Client) RefinePhasesBetween(ctx context.Context, start time.Time, end time.Time) ([]RefinedPhase, error) {
+	// a day either side, so a phase the refinement moves across start or end is still found
+	offline := MeanPhasesBetween(start.Add(-RefineMaxGap), end.Add(RefineMaxGap))
+	apiStart := start.Add(-2 * RefineMaxGap)
+	apiEnd := end.Add(2 * RefineMaxGap)
+	if first := time.Date(USNOFirstYear, time.January, 1, 0, 0, 0, 0, time.UTC); (apiStart.Before(first)) {
+		apiStart = first
+	}
+	if last := time.Date(USNOLastYear + 1, time.January, 1, 0, 0, 0, 0, time.UTC); (apiEnd.After(last)) {
+		apiEnd = last
+	}
+	var api []MoonPhase
+	if (apiStart.Before(apiEnd)) {
+		var err error
+		api, err = c.PhasesBetween(ctx, apiStart, apiEnd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var phases []RefinedPhase
+	for _, phase := range RefinePhases(offline, api) {
+		if instant := phaseInstant(phase.MoonPhase); (!instant.Before(start) && instant.Before(end)) {
+			phases = append(phases, phase)
+		}
+	}
+	return phases, nil
+}
@@ -0,0 +1,140 @@
+package moonphase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// each mean phase takes the nearest API phase of its name within a day, else keeps its own instant
+func TestRefinePhasesPairing(t *testing.T) {
+	event := moonphasetest.Event
+	offline := moonphasetest.MoonPhases(
+		event(moonphase.NewMoon, "2024-03-10 12:00"),
+		event(moonphase.FirstQuarter, "2024-03-17 12:00"),
+		event(moonphase.FullMoon, "2024-03-25 00:00"),
+		event(moonphase.LastQuarter, "2024-04-01 20:00"),
+	)
+	api := moonphasetest.MoonPhases(
+		// 3 hours early, and a second candidate further off that loses
+		event(moonphase.NewMoon, "2024-03-10 09:00"),
+		event(moonphase.NewMoon, "2024-03-11 10:00"),
+		// a day and a minute late, too far to pair
+		event(moonphase.FirstQuarter, "2024-03-18 12:01"),
+		// within a day, but a different phase
+		event(moonphase.WaxingGibbous, "2024-03-24 12:00"),
+		event(moonphase.FullMoon, "2024-03-25 07:00"),
+		// exactly a day early still pairs
+		event(moonphase.LastQuarter, "2024-03-31 20:00"),
+	)
+	want := []struct {
+		instant    string
+		provenance moonphase.Provenance
+		correction time.Duration
+	}{
+		{"2024-03-10T09:00:00Z", moonphase.APIProvenance, -3 * time.Hour},
+		{"2024-03-17T12:00:00Z", moonphase.OfflineProvenance, 0},
+		{"2024-03-25T07:00:00Z", moonphase.APIProvenance, 7 * time.Hour},
+		{"2024-03-31T20:00:00Z", moonphase.APIProvenance, -24 * time.Hour},
+	}
+	refined := moonphase.RefinePhases(offline, api)
+	if (len(refined) != len(want)) {
+		t.Fatalf("got %d phases, want %d", len(refined), len(want))
+	}
+	for i, phase := range refined {
+		instant, _ := moonphase.GetPhaseInstant(phase.MoonPhase)
+		if (instant.Format(time.RFC3339) != want[i].instant || phase.Provenance != want[i].provenance || phase.Correction != want[i].correction) {
+			t.Errorf("%s: got %s %s %s, want %s %s %s", offline[i].Phase, instant.Format(time.RFC3339), phase.Provenance, phase.Correction,
+				want[i].instant, want[i].provenance, want[i].correction)
+		}
+		if (phase.Phase != offline[i].Phase) {
+			t.Errorf("%s was paired with a %s", offline[i].Phase, phase.Phase)
+		}
+	}
+}
+
+// an API phase is only paired once
+func TestRefinePhasesPairsOnce(t *testing.T) {
+	offline := moonphasetest.MoonPhases(
+		moonphasetest.Event(moonphase.FullMoon, "2024-03-25 00:00"),
+		moonphasetest.Event(moonphase.FullMoon, "2024-03-25 12:00"),
+	)
+	api := moonphasetest.MoonPhases(moonphasetest.Event(moonphase.FullMoon, "2024-03-25 07:00"))
+	refined := moonphase.RefinePhases(offline, api)
+	if (refined[0].Provenance != moonphase.APIProvenance || refined[1].Provenance != moonphase.OfflineProvenance) {
+		t.Errorf("got %s and %s, want only the first paired", refined[0].Provenance, refined[1].Provenance)
+	}
+}
+
+// against the reference year, the phases it has are the real ones and the tail after it stays offline
+func TestRefinePhasesBetweenTail(t *testing.T) {
+	client := newReferenceClient()
+	start := time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	phases, err := client.RefinePhasesBetween(context.Background(), start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := map[string]bool{}
+	for _, event := range moonphasetest.ReferenceEvents() {
+		reference[event.Instant.Format(time.RFC3339) + event.Phase.String()] = true
+	}
+	// the last reference phase, the data ends there
+	lastReference := time.Date(2025, time.January, 29, 12, 36, 0, 0, time.UTC)
+	for i, phase := range phases {
+		instant, _ := moonphase.GetPhaseInstant(phase.MoonPhase)
+		if (instant.Before(start) || !instant.Before(end)) {
+			t.Errorf("%s %s is outside the range", phase.Phase, instant)
+		}
+		if (i > 0 && !instant.After(mustInstant(t, phases[i - 1].MoonPhase))) {
+			t.Errorf("%s %s isn't after the phase before it", phase.Phase, instant)
+		}
+		if (instant.After(lastReference.Add(moonphase.RefineMaxGap))) {
+			if (phase.Provenance != moonphase.OfflineProvenance) {
+				t.Errorf("%s %s past the data is %s, want offline", phase.Phase, instant, phase.Provenance)
+			}
+			continue
+		}
+		if (phase.Provenance != moonphase.APIProvenance || !reference[instant.Format(time.RFC3339) + phase.Phase]) {
+			t.Errorf("%s %s is %s, want the reference phase", phase.Phase, instant, phase.Provenance)
+		}
+	}
+	// October 2024 to May 2025 is eight lunations, 32 primary phases give or take one at either end
+	if (len(phases) < 31 || len(phases) > 33) {
+		t.Errorf("got %d phases", len(phases))
+	}
+}
+
+// years past USNO's coverage are calculated without asking the source at all
+func TestRefinePhasesBetweenPastCoverage(t *testing.T) {
+	provider := moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)
+	client := &moonphase.Client{Source: provider}
+	start := time.Date(moonphase.USNOLastYear + 5, time.January, 1, 0, 0, 0, 0, time.UTC)
+	phases, err := client.RefinePhasesBetween(context.Background(), start, start.AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(phases) == 0) {
+		t.Fatal("no phases")
+	}
+	for _, phase := range phases {
+		if (phase.Provenance != moonphase.OfflineProvenance) {
+			t.Errorf("%s is %s, want offline", phase.Phase, phase.Provenance)
+		}
+	}
+	if (provider.Requests != 0) {
+		t.Errorf("made %d requests past the coverage", provider.Requests)
+	}
+}
+
+func mustInstant(t *testing.T, phase moonphase.MoonPhase) time.Time {
+	t.Helper()
+	instant, err := moonphase.GetPhaseInstant(phase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instant
+}
@@ -0,0 +1,144 @@
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// the moon's rise, upper transit and set on a calendar day at a location. a zero time means it
+// doesn't happen that day, as near the poles where the moon can stay up or down all day, or on the
+// day or so a month when it rises or sets just after midnight and skips a day
+type RiseSetTimes struct {
+	Rise    time.Time
+	Transit time.Time
+	Set     time.Time
+	// the sun's rise and set the same day, zero in the polar day or night when it stays up or down
+	Sunrise time.Time
+	Sunset  time.Time
+}
+
+// the part of the API's rise, set and transit response the moon times come from
+type riseSetApiResponse struct {
+	Properties struct {
+		Data struct {
+			Moondata []riseSetEvent `json:"moondata"`
+			Sundata  []riseSetEvent `json:"sundata"`
+		} `json:"data"`
+	} `json:"properties"`
+}
+
+// an event of the moon or the sun in a rise, set and transit response
+type riseSetEvent struct {
+	Phen string `json:"phen"`
+	Time string `json:"time"`
+}
+
+// checks a latitude and longitude are on the globe
+func checkCoords(lat float64, lon float64) error {
+	if (lat < -90 || lat > 90) {
+		return fmt.Errorf("latitude must be between -90 and 90, got %g", lat)
+	}
+	if (lon < -180 || lon > 180) {
+		return fmt.Errorf("longitude must be between -180 and 180, got %g", lon)
+	}
+	return nil
+}
+
+// returns the URL of the one day rise, set and transit API for day at a location, with times
+// given at offsetSeconds east of UT
+func (c *Client) GetRiseSetURL(day DayKey, lat float64, lon float64, offsetSeconds int) string {
+	query := url.Values{}
+	query.Set("date", day.String())
+	query.Set("coords", fmt.Sprintf("%s,%s", strconv.FormatFloat(lat, 'f', -1, 64), strconv.FormatFloat(lon, 'f', -1, 64)))
+	query.Set("tz", strconv.FormatFloat(float64(offsetSeconds) / 3600, 'f', -1, 64))
+	return fmt.Sprintf("%s/rstt/oneday?%s", c.baseURL(), query.Encode())
+}
+
+// returns the instant of an event of a rise, set and transit response for day, whose times are in zone
+func parseRiseSetEvent(event riseSetEvent, day DayKey, zone *time.Location) (time.Time, error) {
+	clock, err := time.Parse("15:04", event.Time)
+	if err != nil {
+		return time.Time{}, &ErrMalformedResponse{Err: fmt.Errorf("invalid time %q for %s: %w", event.Time, event.Phen, err)}
+	}
+	return time.Date(day.Year, day.Month, day.Day, clock.Hour(), clock.Minute(), 0, 0, zone), nil
+}
+
+// parses the moon and sun times out of a rise, set and transit response for day, whose times are in zone
+func parseRiseSet(body []byte, day DayKey, zone *time.Location) (RiseSetTimes, error) {
+	var response riseSetApiResponse
+	if err := decodeResponse(body, &response); err != nil {
+		return RiseSetTimes{}, err
+	}
+	var times RiseSetTimes
+	for _, event := range response.Properties.Data.Moondata {
+		instant, err := parseRiseSetEvent(event, day, zone)
+		if err != nil {
+			return RiseSetTimes{}, err
+		}
+		switch event.Phen {
+		case "Rise":
+			times.Rise = instant
+		case "Upper Transit":
+			times.Transit = instant
+		case "Set":
+			times.Set = instant
+		}
+	}
+	// the sun's twilights and transit aren't kept
+	for _, event := range response.Properties.Data.Sundata {
+		if (event.Phen != "Rise" && event.Phen != "Set") {
+			continue
+		}
+		instant, err := parseRiseSetEvent(event, day, zone)
+		if err != nil {
+			return RiseSetTimes{}, err
+		}
+		if (event.Phen == "Rise") {
+			times.Sunrise = instant
+		} else {
+			times.Sunset = instant
+		}
+	}
+	return times, nil
+}
+
+// returns the moon's rise, upper transit and set, and the sun's rise and set, on the calendar day
+// of date, in date's location, at lat and lon in degrees, north and east positive. these always
+// come from the USNO API, whatever c's Source
+func (c *Client) RiseSet(ctx context.Context, date time.Time, lat float64, lon float64) (RiseSetTimes, error) {
+	if err := checkCoords(lat, lon); err != nil {
+		return RiseSetTimes{}, err
+	}
+	day := NewDayKey(date)
+	// the API takes a fixed offset, the one in effect at midday is right for every time but those
+	// in the hour a clock change repeats or skips
+	_, offset := time.Date(day.Year, day.Month, day.Day, 12, 0, 0, 0, date.Location()).Zone()
+	status, body, err := c.GetRawMoonData(ctx, c.GetRiseSetURL(day, lat, lon, offset))
+	if err != nil {
+		return RiseSetTimes{}, err
+	}
+	if err := checkUpstreamError(status, body); err != nil {
+		return RiseSetTimes{}, err
+	}
+	if (status >= 400) {
+		return RiseSetTimes{}, &ErrUpstreamStatus{Status: status}
+	}
+	times, err := parseRiseSet(body, day, time.FixedZone("", offset))
+	if err != nil {
+		return RiseSetTimes{}, err
+	}
+	for _, instant := range []*time.Time{&times.Rise, &times.Transit, &times.Set, &times.Sunrise, &times.Sunset} {
+		if (!instant.IsZero()) {
+			*instant = instant.In(date.Location())
+		}
+	}
+	return times, nil
+}
+
+// returns the moon's rise, upper transit and set on the calendar day of date at lat and lon, using DefaultClient
+func RiseSet(ctx context.Context, date time.Time, lat float64, lon float64) (RiseSetTimes, error) {
+	return DefaultClient.RiseSet(ctx, date, lat, lon)
+}
@@ -0,0 +1,135 @@
+package moonphase_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// returns a server answering the one day rise, set and transit API with the recorded response for
+// each coords and date in fixtures, and the queries it was asked
+func newRiseSetServer(t *testing.T, fixtures map[string]string) (*httptest.Server, func() []url.Values) {
+	t.Helper()
+	var mu sync.Mutex
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		mu.Lock()
+		queries = append(queries, query)
+		mu.Unlock()
+		fixture, found := fixtures[query.Get("coords") + " " + query.Get("date")]
+		if (r.URL.Path != "/rstt/oneday" || !found) {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return queries
+	}
+}
+
+// the recorded responses parse into times on the day asked for, in the zone asked in, with the
+// events the API leaves out zero: a waning moon that rises after midnight has no rise on the
+// last day of March, and at Longyearbyen the Full Moon of midsummer never rises or sets, nor
+// does the midnight sun
+func TestRiseSet(t *testing.T) {
+	server, queries := newRiseSetServer(t, map[string]string{
+		"38.88,-77 2024-03-25":  "moonphasetest/testdata/rstt-2024-03-25-washington.json",
+		"38.88,-77 2024-03-31":  "moonphasetest/testdata/rstt-2024-03-31-washington.json",
+		"78.22,15.65 2024-06-21": "moonphasetest/testdata/rstt-2024-06-21-longyearbyen.json",
+	})
+	client := moonphase.NewClient()
+	client.BaseURL = server.URL
+	newYork := loadLocation(t, "America/New_York")
+	oslo := loadLocation(t, "Europe/Oslo")
+	tests := []struct {
+		name     string
+		date     time.Time
+		lat, lon float64
+		want     [3]string
+		sun      [2]string
+		tz       string
+	}{
+		{"rise, transit and set", time.Date(2024, time.March, 25, 12, 0, 0, 0, newYork), 38.88, -77, [3]string{"2024-03-25T19:47:00-04:00", "2024-03-25T00:51:00-04:00", "2024-03-25T07:29:00-04:00"}, [2]string{"2024-03-25T07:04:00-04:00", "2024-03-25T19:29:00-04:00"}, "-4"},
+		{"no moonrise", time.Date(2024, time.March, 31, 0, 0, 0, 0, newYork), 38.88, -77, [3]string{"", "2024-03-31T05:22:00-04:00", "2024-03-31T10:31:00-04:00"}, [2]string{"2024-03-31T06:54:00-04:00", "2024-03-31T19:35:00-04:00"}, "-4"},
+		{"polar day", time.Date(2024, time.June, 21, 0, 0, 0, 0, oslo), 78.22, 15.65, [3]string{"", "2024-06-21T00:25:00+02:00", ""}, [2]string{"", ""}, "2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			times, err := client.RiseSet(context.Background(), test.date, test.lat, test.lon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, got := range []time.Time{times.Rise, times.Transit, times.Set} {
+				formatted := ""
+				if (!got.IsZero()) {
+					formatted = got.Format(time.RFC3339)
+					if (got.Location() != test.date.Location()) {
+						t.Errorf("%s is in %s, want %s", formatted, got.Location(), test.date.Location())
+					}
+				}
+				if (formatted != test.want[i]) {
+					t.Errorf("%s: got %q, want %q", []string{"rise", "transit", "set"}[i], formatted, test.want[i])
+				}
+			}
+			for i, got := range []time.Time{times.Sunrise, times.Sunset} {
+				formatted := ""
+				if (!got.IsZero()) {
+					formatted = got.Format(time.RFC3339)
+				}
+				if (formatted != test.sun[i]) {
+					t.Errorf("%s: got %q, want %q", []string{"sunrise", "sunset"}[i], formatted, test.sun[i])
+				}
+			}
+			asked := queries()[len(queries()) - 1]
+			if (asked.Get("tz") != test.tz) {
+				t.Errorf("asked with tz %q, want %q", asked.Get("tz"), test.tz)
+			}
+		})
+	}
+}
+
+// coordinates off the globe are refused without a request, and a response with a time that
+// doesn't parse is an ErrMalformedResponse
+func TestRiseSetErrors(t *testing.T) {
+	server, queries := newRiseSetServer(t, nil)
+	client := moonphase.NewClient()
+	client.BaseURL = server.URL
+	date := time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC)
+	for _, coords := range [][2]float64{{91, 0}, {-90.5, 0}, {0, 180.1}, {0, -181}} {
+		if _, err := client.RiseSet(context.Background(), date, coords[0], coords[1]); (err == nil) {
+			t.Errorf("%v: got no error", coords)
+		}
+	}
+	if (len(queries()) != 0) {
+		t.Errorf("made %d requests for coordinates off the globe", len(queries()))
+	}
+
+	malformed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"properties": {"data": {"moondata": [{"phen": "Rise", "time": "7pm"}]}}}`))
+	}))
+	defer malformed.Close()
+	client.BaseURL = malformed.URL
+	_, err := client.RiseSet(context.Background(), date, 38.88, -77)
+	var malformedErr *moonphase.ErrMalformedResponse
+	if (!errors.As(err, &malformedErr)) {
+		t.Errorf("got %v, want an ErrMalformedResponse", err)
+	}
+}
@@ -0,0 +1,63 @@
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// the four stretches of the cycle between consecutive primary phases, numbered 1 to 4 from the New Moon
+var cycleSegments = map[Phase]CycleSegment{
+	NewMoon:      {Label: "new-to-first-quarter", Index: 1},
+	FirstQuarter: {Label: "first-quarter-to-full", Index: 2},
+	FullMoon:     {Label: "full-to-last-quarter", Index: 3},
+	LastQuarter:  {Label: "last-quarter-to-new", Index: 4},
+}
+
+// which quarter-to-quarter segment of the cycle a moment falls in
+type CycleSegment struct {
+	Label string
+	Index int
+	// the primary phases either side, a moment exactly at a phase starts the segment after it
+	Start time.Time
+	End   time.Time
+	// how far through the segment the moment is, from 0 to 1
+	Progress float64
+}
+
+// returns the segment of the cycle now falls in, using the primary phases in recentData either side of it
+func GetCycleSegment(now time.Time, recentData []MoonPhase) (CycleSegment, error) {
+	for i, phase := range recentData {
+		end, err := GetPhaseInstant(phase)
+		if err != nil {
+			return CycleSegment{}, err
+		}
+		if (end.After(now)) {
+			if (i < 1) {
+				return CycleSegment{}, fmt.Errorf("date range of recent data doesn't have enough history")
+			}
+			previousPhase := recentData[i - 1]
+			segment, found := cycleSegments[Phase(previousPhase.Phase)]
+			if (!found) {
+				return CycleSegment{}, fmt.Errorf("unknown phase %q", previousPhase.Phase)
+			}
+			segment.Start, err = GetPhaseInstant(previousPhase)
+			if err != nil {
+				return CycleSegment{}, err
+			}
+			segment.End = end
+			segment.Progress = float64(now.Sub(segment.Start)) / float64(end.Sub(segment.Start))
+			return segment, nil
+		}
+	}
+	return CycleSegment{}, fmt.Errorf("date range of recent data doesn't have a phase after the date")
+}
+
+// Get the cycle segment for a given moment
+func (c *Client) GetSegmentForDate(ctx context.Context, date time.Time) (CycleSegment, error) {
+	recentData, err := c.getMoonDataAround(ctx, date, FetchStrategy{Future: 1})
+	if err != nil {
+		return CycleSegment{}, err
+	}
+	return GetCycleSegment(date, recentData)
+}
@@ -0,0 +1,66 @@
+package moonphase_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// a moment exactly at a primary phase starts the segment after it, a minute earlier is the end of
+// the one before, and the progress runs from 0 to 1 between them
+func TestGetCycleSegmentBoundaries(t *testing.T) {
+	lastQuarter := time.Date(2024, time.March, 3, 15, 23, 0, 0, time.UTC)
+	newMoon := time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+	firstQuarter := time.Date(2024, time.March, 17, 4, 11, 0, 0, time.UTC)
+	fullMoon := time.Date(2024, time.March, 25, 7, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at       time.Time
+		label    string
+		index    int
+		start    time.Time
+		progress float64
+	}{
+		{lastQuarter, "last-quarter-to-new", 4, lastQuarter, 0},
+		{newMoon.Add(-time.Minute), "last-quarter-to-new", 4, lastQuarter, 1 - 1 / newMoon.Sub(lastQuarter).Minutes()},
+		{newMoon, "new-to-first-quarter", 1, newMoon, 0},
+		{newMoon.Add(firstQuarter.Sub(newMoon) / 2), "new-to-first-quarter", 1, newMoon, 0.5},
+		{firstQuarter, "first-quarter-to-full", 2, firstQuarter, 0},
+		{fullMoon.Add(-time.Minute), "first-quarter-to-full", 2, firstQuarter, 1 - 1 / fullMoon.Sub(firstQuarter).Minutes()},
+		{fullMoon, "full-to-last-quarter", 3, fullMoon, 0},
+		{fullMoon.Add(time.Minute), "full-to-last-quarter", 3, fullMoon, 1 / time.Date(2024, time.April, 2, 3, 15, 0, 0, time.UTC).Sub(fullMoon).Minutes()},
+	}
+	client := newReferenceClient()
+	for _, test := range tests {
+		fromFixture, err := moonphase.GetCycleSegment(test.at, lunationFixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromClient, err := client.GetSegmentForDate(context.Background(), test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, segment := range []moonphase.CycleSegment{fromFixture, fromClient} {
+			if (segment.Label != test.label || segment.Index != test.index || !segment.Start.Equal(test.start) || math.Abs(segment.Progress - test.progress) > 1e-9) {
+				t.Errorf("%s: got %s (%d) from %s at %.6f, want %s (%d) from %s at %.6f", test.at.Format(time.RFC3339),
+					segment.Label, segment.Index, segment.Start.Format(time.RFC3339), segment.Progress,
+					test.label, test.index, test.start.Format(time.RFC3339), test.progress)
+			}
+		}
+	}
+}
+
+// a moment before the first phase of the data, or at or after the last, has no segment to fall in
+func TestGetCycleSegmentOutsideData(t *testing.T) {
+	for at, want := range map[time.Time]string{
+		time.Date(2024, time.March, 3, 15, 22, 0, 0, time.UTC): "enough history",
+		time.Date(2024, time.April, 15, 19, 13, 0, 0, time.UTC): "doesn't have a phase after",
+	} {
+		if _, err := moonphase.GetCycleSegment(at, lunationFixture); (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("%s: got %v, want an error with %q", at.Format(time.RFC3339), err, want)
+		}
+	}
+}
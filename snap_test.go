@@ -0,0 +1,242 @@
+package moonphase_test
+
+import (
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// returns the named zone, failing the test when it isn't in the zone database
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+// classifies each day from first to last in location, one phase per day
+func classifyDays(t *testing.T, phases []moonphase.MoonPhase, location *time.Location, first string, last string) map[string]moonphase.Phase {
+	t.Helper()
+	start, err := moonphase.ParseDayKey(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := moonphase.ParseDayKey(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	days := map[string]moonphase.Phase{}
+	for day := start; !day.After(end); day = day.AddDays(1) {
+		phase, err := moonphase.GetCurrentPhase(day.Time(location), phases, moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatalf("%s: %v", day, err)
+		}
+		days[day.String()] = phase
+	}
+	return days
+}
+
+// checks the classified days against want, day by day
+func checkDays(t *testing.T, got map[string]moonphase.Phase, want map[string]moonphase.Phase) {
+	t.Helper()
+	for day, phase := range want {
+		if (got[day] != phase) {
+			t.Errorf("%s: got %s, want %s", day, got[day], phase)
+		}
+	}
+}
+
+// the snap window is counted in calendar days, so a DST change inside it doesn't stretch it to a
+// fourth day. both fixtures have eight day intervals, where the window is at its longest
+func TestSnapWindowAcrossDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		zone     string
+		// a Full Moon near the March 10 2024 change, eight days from each neighbour
+		fullMoon string
+		first    string
+		last     string
+		want     map[string]moonphase.Phase
+	}{
+		{
+			// clocks go forward on the second day after the Full Moon
+			name:     "after the phase",
+			zone:     "America/New_York",
+			fullMoon: "2024-03-09 12:00",
+			first:    "2024-03-06",
+			last:     "2024-03-12",
+			want: map[string]moonphase.Phase{
+				"2024-03-07": moonphase.WaxingGibbous,
+				"2024-03-08": moonphase.FullMoon,
+				"2024-03-09": moonphase.FullMoon,
+				"2024-03-10": moonphase.FullMoon,
+				"2024-03-11": moonphase.WaningGibbous,
+			},
+		},
+		{
+			// clocks go forward the day before the Full Moon
+			name:     "before the phase",
+			zone:     "America/Los_Angeles",
+			fullMoon: "2024-03-11 12:00",
+			first:    "2024-03-08",
+			last:     "2024-03-14",
+			want: map[string]moonphase.Phase{
+				"2024-03-09": moonphase.WaxingGibbous,
+				"2024-03-10": moonphase.FullMoon,
+				"2024-03-11": moonphase.FullMoon,
+				"2024-03-12": moonphase.FullMoon,
+				"2024-03-13": moonphase.WaningGibbous,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fullMoon := moonphasetest.Event(moonphase.FullMoon, test.fullMoon)
+			phases := moonphasetest.MoonPhases(
+				moonphasetest.Event(moonphase.NewMoon, fullMoon.Instant.Add(-16 * 24 * time.Hour).Format(time.RFC3339)),
+				moonphasetest.Event(moonphase.FirstQuarter, fullMoon.Instant.Add(-8 * 24 * time.Hour).Format(time.RFC3339)),
+				fullMoon,
+				moonphasetest.Event(moonphase.LastQuarter, fullMoon.Instant.Add(8 * 24 * time.Hour).Format(time.RFC3339)),
+				moonphasetest.Event(moonphase.NewMoon, fullMoon.Instant.Add(16 * 24 * time.Hour).Format(time.RFC3339)),
+			)
+			checkDays(t, classifyDays(t, phases, loadLocation(t, test.zone), test.first, test.last), test.want)
+		})
+	}
+}
+
+// the window is the same whatever the time of day being classified
+func TestSnapWindowIgnoresTimeOfDay(t *testing.T) {
+	location := loadLocation(t, "America/New_York")
+	phases := moonphasetest.MoonPhases(moonphasetest.ReferenceEvents()...)
+	// the New Moon of 2024-03-10 09:00 UT falls on the day the clocks go forward
+	for _, clock := range []string{"00:00", "01:59", "03:00", "12:00", "23:59"} {
+		for day, want := range map[string]moonphase.Phase{
+			"2024-03-08": moonphase.WaningCrescent,
+			"2024-03-09": moonphase.NewMoon,
+			"2024-03-10": moonphase.NewMoon,
+			"2024-03-11": moonphase.NewMoon,
+			"2024-03-12": moonphase.WaxingCrescent,
+		} {
+			now, err := time.ParseInLocation("2006-01-02 15:04", day + " " + clock, location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := moonphase.GetCurrentPhase(now, phases, moonphase.SnapStrategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got != want) {
+				t.Errorf("%s %s: got %s, want %s", day, clock, got, want)
+			}
+		}
+	}
+}
+
+// the snap window is a share of each interval, but even the shortest and longest intervals of 2024
+// get the same one day either side: the shortest keeps days of the intermediate phase between its
+// windows and the longest doesn't snap a second day
+func TestSnapWindowIsOneDayOnExtremeIntervals(t *testing.T) {
+	events := moonphasetest.ReferenceEvents()
+	shortest, longest := 1, 1
+	for i := 1; (i < len(events)); i++ {
+		if (events[i].Instant.Year() != 2024) {
+			continue
+		}
+		interval := events[i].Instant.Sub(events[i - 1].Instant)
+		if (interval < events[shortest].Instant.Sub(events[shortest - 1].Instant)) {
+			shortest = i
+		}
+		if (interval > events[longest].Instant.Sub(events[longest - 1].Instant)) {
+			longest = i
+		}
+	}
+	// 6.6 days from the Full Moon of August 19th to the Last Quarter of August 26th
+	if (events[shortest].Instant.Format("2006-01-02") != "2024-08-26") {
+		t.Fatalf("the shortest interval of the reference year ends %s, want 2024-08-26", events[shortest].Instant)
+	}
+	// 8.2 days from the Full Moon of January 25th to the Last Quarter of February 2nd
+	if (events[longest].Instant.Format("2006-01-02") != "2024-02-02") {
+		t.Fatalf("the longest interval of the reference year ends %s, want 2024-02-02", events[longest].Instant)
+	}
+	for _, i := range []int{shortest, longest} {
+		if window := moonphase.GetSnapWindow(events[i - 1].Instant, events[i].Instant); (window != 1) {
+			t.Errorf("the interval ending %s has a window of %d days, want 1", events[i].Instant, window)
+		}
+	}
+	phases := moonphasetest.MoonPhases(events...)
+	tests := []struct {
+		name  string
+		zone  string
+		first string
+		last  string
+		want  map[string]moonphase.Phase
+	}{
+		{
+			name:  "shortest",
+			zone:  "UTC",
+			first: "2024-08-17",
+			last:  "2024-08-28",
+			want: map[string]moonphase.Phase{
+				"2024-08-17": moonphase.WaxingGibbous,
+				"2024-08-18": moonphase.FullMoon,
+				"2024-08-19": moonphase.FullMoon,
+				"2024-08-20": moonphase.FullMoon,
+				"2024-08-21": moonphase.WaningGibbous,
+				"2024-08-22": moonphase.WaningGibbous,
+				"2024-08-23": moonphase.WaningGibbous,
+				"2024-08-24": moonphase.WaningGibbous,
+				"2024-08-25": moonphase.LastQuarter,
+				"2024-08-26": moonphase.LastQuarter,
+				"2024-08-27": moonphase.LastQuarter,
+				"2024-08-28": moonphase.WaningCrescent,
+			},
+		},
+		{
+			// both phases are on later local days, closer together as calendar days
+			name:  "shortest in Tokyo",
+			zone:  "Asia/Tokyo",
+			first: "2024-08-18",
+			last:  "2024-08-28",
+			want: map[string]moonphase.Phase{
+				"2024-08-18": moonphase.WaxingGibbous,
+				"2024-08-19": moonphase.FullMoon,
+				"2024-08-21": moonphase.FullMoon,
+				"2024-08-22": moonphase.WaningGibbous,
+				"2024-08-24": moonphase.WaningGibbous,
+				"2024-08-25": moonphase.LastQuarter,
+				"2024-08-27": moonphase.LastQuarter,
+				"2024-08-28": moonphase.WaningCrescent,
+			},
+		},
+		{
+			name:  "longest",
+			zone:  "UTC",
+			first: "2024-01-23",
+			last:  "2024-02-04",
+			want: map[string]moonphase.Phase{
+				"2024-01-23": moonphase.WaxingGibbous,
+				"2024-01-24": moonphase.FullMoon,
+				"2024-01-25": moonphase.FullMoon,
+				"2024-01-26": moonphase.FullMoon,
+				"2024-01-27": moonphase.WaningGibbous,
+				"2024-01-28": moonphase.WaningGibbous,
+				"2024-01-29": moonphase.WaningGibbous,
+				"2024-01-30": moonphase.WaningGibbous,
+				"2024-01-31": moonphase.WaningGibbous,
+				"2024-02-01": moonphase.LastQuarter,
+				"2024-02-02": moonphase.LastQuarter,
+				"2024-02-03": moonphase.LastQuarter,
+				"2024-02-04": moonphase.WaningCrescent,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkDays(t, classifyDays(t, phases, loadLocation(t, test.zone), test.first, test.last), test.want)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package moonphase
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// a set of symbols for the eight phases, chosen with SymbolOptions.Preset
+type SymbolPreset string
+
+const (
+	// the moon emoji, like 🌕
+	EmojiPreset SymbolPreset = "emoji"
+	// the GitHub and Slack shortcodes, like :full_moon:
+	ShortcodePreset SymbolPreset = "shortcode"
+	// the moon glyphs of the Weather Icons set patched into Nerd Fonts, in the private use area
+	NerdFontPreset SymbolPreset = "nerdfont"
+	// plain ASCII, how much of the moon is lit drawn as # from the side it's lit on
+	ASCIIPreset SymbolPreset = "ascii"
+)
+
+// SymbolPresets lists the presets Symbol has a full table for
+var SymbolPresets = []SymbolPreset{EmojiPreset, ShortcodePreset, NerdFontPreset, ASCIIPreset}
+
+// the symbol of each phase in each preset, as seen from the northern hemisphere. every preset has
+// all eight, so a phase can't render as "" in one of them
+var symbolTables = map[SymbolPreset]map[Phase]string{
+	EmojiPreset: {
+		NewMoon:        phaseSymbols[NewMoon].emoji,
+		WaxingCrescent: phaseSymbols[WaxingCrescent].emoji,
+		FirstQuarter:   phaseSymbols[FirstQuarter].emoji,
+		WaxingGibbous:  phaseSymbols[WaxingGibbous].emoji,
+		FullMoon:       phaseSymbols[FullMoon].emoji,
+		WaningGibbous:  phaseSymbols[WaningGibbous].emoji,
+		LastQuarter:    phaseSymbols[LastQuarter].emoji,
+		WaningCrescent: phaseSymbols[WaningCrescent].emoji,
+	},
+	ShortcodePreset: {
+		NewMoon:        phaseSymbols[NewMoon].shortcode,
+		WaxingCrescent: phaseSymbols[WaxingCrescent].shortcode,
+		FirstQuarter:   phaseSymbols[FirstQuarter].shortcode,
+		WaxingGibbous:  phaseSymbols[WaxingGibbous].shortcode,
+		FullMoon:       phaseSymbols[FullMoon].shortcode,
+		WaningGibbous:  phaseSymbols[WaningGibbous].shortcode,
+		LastQuarter:    phaseSymbols[LastQuarter].shortcode,
+		WaningCrescent: phaseSymbols[WaningCrescent].shortcode,
+	},
+	// nf-weather-moon_new, _full and the quarters, and the third of the six steps the Weather Icons
+	// have for each crescent and gibbous phase
+	NerdFontPreset: {
+		NewMoon:        "\ue38d",
+		WaxingCrescent: "\ue390",
+		FirstQuarter:   "\ue394",
+		WaxingGibbous:  "\ue397",
+		FullMoon:       "\ue39b",
+		WaningGibbous:  "\ue39e",
+		LastQuarter:    "\ue3a2",
+		WaningCrescent: "\ue3a5",
+	},
+	ASCIIPreset: {
+		NewMoon:        "[    ]",
+		WaxingCrescent: "[   #]",
+		FirstQuarter:   "[  ##]",
+		WaxingGibbous:  "[ ###]",
+		FullMoon:       "[####]",
+		WaningGibbous:  "[### ]",
+		LastQuarter:    "[##  ]",
+		WaningCrescent: "[#   ]",
+	},
+}
+
+// the emoji variation selector, asks for the colour rendering of the emoji before it
+const emojiVariationSelector string = "\uFE0F"
+
+// how Symbol renders a phase. the zero value gives the bare emoji as seen from the northern hemisphere
+type SymbolOptions struct {
+	// mirrors the crescent, quarter and gibbous symbols for the southern hemisphere
+	Hemisphere Hemisphere
+	// the table symbols come from, EmojiPreset when empty
+	Preset SymbolPreset
+	// with EmojiPreset, appends the emoji variation selector for terminals that otherwise draw the
+	// moon emoji as monochrome text
+	EmojiPresentation bool
+	// symbols used instead of the preset's for some phases, as they are, without mirroring
+	Overrides map[Phase]string
+}
+
+// ParseSymbolPreset parses the name of a preset, like "nerdfont"
+func ParseSymbolPreset(name string) (SymbolPreset, error) {
+	preset := SymbolPreset(strings.ToLower(strings.TrimSpace(name)))
+	if _, found := symbolTables[preset]; (!found) {
+		names := make([]string, 0, len(SymbolPresets))
+		for _, known := range SymbolPresets {
+			names = append(names, string(known))
+		}
+		return "", fmt.Errorf("unknown symbol preset %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return preset, nil
+}
+
+// Validate checks the preset is known and each override is for one of the eight phases and is a
+// non-empty symbol on a single line, so a bad config is caught before anything is rendered
+func (o SymbolOptions) Validate() error {
+	if (o.Preset != "") {
+		if _, err := ParseSymbolPreset(string(o.Preset)); err != nil {
+			return err
+		}
+	}
+	for phase, symbol := range o.Overrides {
+		if _, found := phaseSymbols[phase]; (!found) {
+			return fmt.Errorf("symbol override for unknown phase %q", phase)
+		}
+		if (strings.TrimSpace(symbol) == "") {
+			return fmt.Errorf("symbol override for %s is empty", phase)
+		}
+		if (strings.IndexFunc(symbol, unicode.IsControl) >= 0) {
+			return fmt.Errorf("symbol override for %s %q has a control character", phase, symbol)
+		}
+	}
+	return nil
+}
+
+// Symbol returns the symbol for p as opts describe it, without looking anything up. it doesn't
+// check opts, call Validate for options from users; an unknown preset renders as EmojiPreset
+// and a phase that isn't one of the eight as ""
+func Symbol(p Phase, opts SymbolOptions) string {
+	if symbol, found := opts.Overrides[p]; (found) {
+		return symbol
+	}
+	table, found := symbolTables[opts.Preset]
+	if (!found) {
+		table = symbolTables[EmojiPreset]
+	}
+	symbol := table[opts.Hemisphere.mirror(p)]
+	if (symbol != "" && opts.EmojiPresentation && (opts.Preset == EmojiPreset || !found)) {
+		symbol += emojiVariationSelector
+	}
+	return symbol
+}
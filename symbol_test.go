@@ -0,0 +1,151 @@
+package moonphase_test
+
+import (
+	"fmt"
+	"testing"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// the exact symbol of every phase from the north and from the south, in each preset. written out
+// as escapes so a rendering bug can be pinned to the code points
+var wantSymbols = map[moonphase.SymbolPreset]map[moonphase.Phase][2]string{
+	moonphase.EmojiPreset: {
+		moonphase.NewMoon:        {"\U0001F311", "\U0001F311"},
+		moonphase.WaxingCrescent: {"\U0001F312", "\U0001F318"},
+		moonphase.FirstQuarter:   {"\U0001F313", "\U0001F317"},
+		moonphase.WaxingGibbous:  {"\U0001F314", "\U0001F316"},
+		moonphase.FullMoon:       {"\U0001F315", "\U0001F315"},
+		moonphase.WaningGibbous:  {"\U0001F316", "\U0001F314"},
+		moonphase.LastQuarter:    {"\U0001F317", "\U0001F313"},
+		moonphase.WaningCrescent: {"\U0001F318", "\U0001F312"},
+	},
+	moonphase.ShortcodePreset: {
+		moonphase.NewMoon:        {":new_moon:", ":new_moon:"},
+		moonphase.WaxingCrescent: {":waxing_crescent_moon:", ":waning_crescent_moon:"},
+		moonphase.FirstQuarter:   {":first_quarter_moon:", ":last_quarter_moon:"},
+		moonphase.WaxingGibbous:  {":waxing_gibbous_moon:", ":waning_gibbous_moon:"},
+		moonphase.FullMoon:       {":full_moon:", ":full_moon:"},
+		moonphase.WaningGibbous:  {":waning_gibbous_moon:", ":waxing_gibbous_moon:"},
+		moonphase.LastQuarter:    {":last_quarter_moon:", ":first_quarter_moon:"},
+		moonphase.WaningCrescent: {":waning_crescent_moon:", ":waxing_crescent_moon:"},
+	},
+	moonphase.NerdFontPreset: {
+		moonphase.NewMoon:        {"\ue38d", "\ue38d"},
+		moonphase.WaxingCrescent: {"\ue390", "\ue3a5"},
+		moonphase.FirstQuarter:   {"\ue394", "\ue3a2"},
+		moonphase.WaxingGibbous:  {"\ue397", "\ue39e"},
+		moonphase.FullMoon:       {"\ue39b", "\ue39b"},
+		moonphase.WaningGibbous:  {"\ue39e", "\ue397"},
+		moonphase.LastQuarter:    {"\ue3a2", "\ue394"},
+		moonphase.WaningCrescent: {"\ue3a5", "\ue390"},
+	},
+	moonphase.ASCIIPreset: {
+		moonphase.NewMoon:        {"[    ]", "[    ]"},
+		moonphase.WaxingCrescent: {"[   #]", "[#   ]"},
+		moonphase.FirstQuarter:   {"[  ##]", "[##  ]"},
+		moonphase.WaxingGibbous:  {"[ ###]", "[### ]"},
+		moonphase.FullMoon:       {"[####]", "[####]"},
+		moonphase.WaningGibbous:  {"[### ]", "[ ###]"},
+		moonphase.LastQuarter:    {"[##  ]", "[  ##]"},
+		moonphase.WaningCrescent: {"[#   ]", "[   #]"},
+	},
+}
+
+func TestSymbol(t *testing.T) {
+	hemispheres := []moonphase.Hemisphere{moonphase.NorthernHemisphere, moonphase.SouthernHemisphere}
+	for _, preset := range moonphase.SymbolPresets {
+		for _, phase := range moonphase.Phases {
+			for i, hemisphere := range hemispheres {
+				want := wantSymbols[preset][phase][i]
+				got := moonphase.Symbol(phase, moonphase.SymbolOptions{Hemisphere: hemisphere, Preset: preset})
+				if (got != want) {
+					t.Errorf("%s %s from the %s: got %+q, want %+q", preset, phase, hemisphere, got, want)
+				}
+				presented := moonphase.Symbol(phase, moonphase.SymbolOptions{Hemisphere: hemisphere, Preset: preset, EmojiPresentation: true})
+				if (preset == moonphase.EmojiPreset) {
+					want += "\uFE0F"
+				}
+				if (presented != want) {
+					t.Errorf("%s %s from the %s with EmojiPresentation: got %+q, want %+q", preset, phase, hemisphere, presented, want)
+				}
+			}
+		}
+	}
+	if (len(wantSymbols) != len(moonphase.SymbolPresets)) {
+		t.Errorf("tested %d presets, there are %d", len(wantSymbols), len(moonphase.SymbolPresets))
+	}
+}
+
+// the zero options are the bare northern emoji, as Phase.Emoji gives them
+func TestSymbolDefaults(t *testing.T) {
+	for _, phase := range moonphase.Phases {
+		if got := moonphase.Symbol(phase, moonphase.SymbolOptions{}); (got != phase.Emoji()) {
+			t.Errorf("%s: got %+q, want %+q", phase, got, phase.Emoji())
+		}
+		if got := moonphase.Symbol(phase, moonphase.SymbolOptions{Hemisphere: moonphase.SouthernHemisphere, Preset: moonphase.ShortcodePreset}); (got != phase.ShortcodeFor(moonphase.SouthernHemisphere)) {
+			t.Errorf("%s: got %q, want %q", phase, got, phase.ShortcodeFor(moonphase.SouthernHemisphere))
+		}
+	}
+	if got := moonphase.Symbol("Blue Moon", moonphase.SymbolOptions{}); (got != "") {
+		t.Errorf("unknown phase rendered as %q", got)
+	}
+}
+
+// an override replaces the preset's symbol as it is, from either hemisphere
+func TestSymbolOverrides(t *testing.T) {
+	options := moonphase.SymbolOptions{
+		Hemisphere: moonphase.SouthernHemisphere,
+		Preset:     moonphase.ASCIIPreset,
+		Overrides:  map[moonphase.Phase]string{moonphase.FirstQuarter: "D", moonphase.FullMoon: "O"},
+	}
+	if err := options.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for phase, want := range map[moonphase.Phase]string{moonphase.FirstQuarter: "D", moonphase.FullMoon: "O", moonphase.LastQuarter: "[  ##]"} {
+		if got := moonphase.Symbol(phase, options); (got != want) {
+			t.Errorf("%s: got %q, want %q", phase, got, want)
+		}
+	}
+}
+
+func TestSymbolOptionsValidate(t *testing.T) {
+	tests := []struct {
+		options moonphase.SymbolOptions
+		valid   bool
+	}{
+		{moonphase.SymbolOptions{}, true},
+		{moonphase.SymbolOptions{Preset: moonphase.NerdFontPreset}, true},
+		{moonphase.SymbolOptions{Preset: "braille"}, false},
+		{moonphase.SymbolOptions{Overrides: map[moonphase.Phase]string{moonphase.NewMoon: "●"}}, true},
+		{moonphase.SymbolOptions{Overrides: map[moonphase.Phase]string{"Blue Moon": "B"}}, false},
+		{moonphase.SymbolOptions{Overrides: map[moonphase.Phase]string{moonphase.NewMoon: ""}}, false},
+		{moonphase.SymbolOptions{Overrides: map[moonphase.Phase]string{moonphase.NewMoon: "  "}}, false},
+		{moonphase.SymbolOptions{Overrides: map[moonphase.Phase]string{moonphase.NewMoon: "N\n"}}, false},
+		{moonphase.SymbolOptions{Overrides: map[moonphase.Phase]string{moonphase.NewMoon: "\x1b[1mN"}}, false},
+	}
+	for _, test := range tests {
+		if err := test.options.Validate(); ((err == nil) != test.valid) {
+			t.Errorf("%+v: got %v, want valid %v", test.options, err, test.valid)
+		}
+	}
+}
+
+func TestParseSymbolPreset(t *testing.T) {
+	for _, preset := range moonphase.SymbolPresets {
+		if got, err := moonphase.ParseSymbolPreset(" " + string(preset) + " "); (err != nil || got != preset) {
+			t.Errorf("%s: got %q, %v", preset, got, err)
+		}
+	}
+	if _, err := moonphase.ParseSymbolPreset("auto"); (err == nil) {
+		t.Error("auto parsed as a preset")
+	}
+}
+
+func ExampleSymbol() {
+	fmt.Println(moonphase.Symbol(moonphase.FirstQuarter, moonphase.SymbolOptions{Preset: moonphase.ASCIIPreset}))
+	fmt.Println(moonphase.Symbol(moonphase.FirstQuarter, moonphase.SymbolOptions{Preset: moonphase.ASCIIPreset, Hemisphere: moonphase.SouthernHemisphere}))
+	// Output:
+	// [  ##]
+	// [##  ]
+}
@@ -0,0 +1,47 @@
+package moonphase
+
+import (
+	"context"
+)
+
+// Tracer starts the spans of what a Client does, for handing them to a tracing system such as
+// OpenTelemetry without this package depending on one. the otlp package has one exporting
+// them over OTLP
+type Tracer interface {
+	// Start begins a span named name, a child of the span ctx carries if any, and returns ctx
+	// carrying the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation a Tracer has started
+type Span interface {
+	// SetAttribute records key on the span, with a string, bool, int or float64 value
+	SetAttribute(key string, value interface{})
+	// End finishes the span, as failed when err isn't nil
+	End(err error)
+}
+
+// the names of the spans a Client starts
+const (
+	// a lookup in the CacheDir, with the cache.result attribute: hit, miss, refresh or failure,
+	// for a cached ErrCachedFailure
+	SpanCacheLookup string = "moonphase.cache.lookup"
+	// a request to the USNO API, with http.url, http.status_code and retry.count, the retries it
+	// took. the span fails when no attempt got a response
+	SpanUpstreamRequest string = "moonphase.upstream.request"
+)
+
+// a Span that records nothing, for a Client without a Tracer
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+
+func (noopSpan) End(err error) {}
+
+// starts a span with c.Tracer, a span recording nothing without one
+func (c *Client) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if (c.Tracer == nil) {
+		return ctx, noopSpan{}
+	}
+	return c.Tracer.Start(ctx, name)
+}
@@ -0,0 +1,78 @@
+package moonphase_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// a lookup the cache misses has a cache lookup and then a request span, both children of the
+// caller's span, and one the cache answers only the cache lookup
+func TestTraceLookups(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, "moonphasetest/testdata/usno-2024.json")
+	client := newCachingClient(t, server.URL)
+	tracer := moonphasetest.NewTracer()
+	client.Tracer = tracer
+	ctx, lookup := tracer.Start(context.Background(), "lookup")
+	date := time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPhaseResultForDate(ctx, date, moonphase.SnapStrategy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lookup.End(nil)
+
+	spans := tracer.Spans()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	want := []string{"lookup", moonphase.SpanCacheLookup, moonphase.SpanUpstreamRequest, moonphase.SpanCacheLookup}
+	if (strings.Join(names, " ") != strings.Join(want, " ")) {
+		t.Fatalf("spans %q, want %q", names, want)
+	}
+	for i, span := range spans[1:] {
+		if (span.Parent != 0 || !span.Ended || span.Err != nil) {
+			t.Errorf("%s: parent %d, ended %v with %v, want an ended child of the lookup", span.Name, span.Parent, span.Ended, span.Err)
+		}
+		if result, want := span.Attributes["cache.result"], []interface{}{"miss", nil, "hit"}[i]; (result != want) {
+			t.Errorf("%s: cache.result %v, want %v", span.Name, result, want)
+		}
+	}
+	request := spans[2].Attributes
+	if url, _ := request["http.url"].(string); (!strings.HasPrefix(url, server.URL + "/moon/phases/date?") || request["http.status_code"] != 200 || request["retry.count"] != 0) {
+		t.Errorf("request attributes %v", request)
+	}
+}
+
+// a request span counts the retries it took, and fails when the last attempt got no response
+func TestTraceRetries(t *testing.T) {
+	var requests int64
+	flaky := newFlakyServer(t, 1, http.StatusServiceUnavailable, "", &requests)
+	client := newRetryingClient(flaky.URL, 1)
+	tracer := moonphasetest.NewTracer()
+	client.Tracer = tracer
+	if _, err := client.GetMoonData(context.Background(), retryDay, 4); err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = "http://127.0.0.1:0"
+	client.Retries = 0
+	if _, err := client.GetMoonData(context.Background(), retryDay, 4); (err == nil) {
+		t.Fatal("got no error from a server that isn't there")
+	}
+	spans := tracer.Spans()
+	if (len(spans) != 2) {
+		t.Fatalf("got %d spans, want a request span per lookup: %+v", len(spans), spans)
+	}
+	if retried := spans[0].Attributes; (retried["retry.count"] != 1 || retried["http.status_code"] != 200 || spans[0].Err != nil) {
+		t.Errorf("the retried request: %v, %v", retried, spans[0].Err)
+	}
+	if failed := spans[1].Attributes; (spans[1].Err == nil || failed["retry.count"] != 0 || failed["http.status_code"] != nil || spans[1].Parent != -1) {
+		t.Errorf("the failed request: %v, %v", failed, spans[1].Err)
+	}
+}
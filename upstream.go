@@ -0,0 +1,143 @@
+package moonphase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// the years the USNO phase data covers, the API rejects dates outside them
+const (
+	USNOFirstYear int = 1700
+	USNOLastYear  int = 2100
+)
+
+// reports whether day is in the years the USNO phase data covers
+func USNOCovers(day DayKey) bool {
+	return day.Year >= USNOFirstYear && day.Year <= USNOLastYear
+}
+
+// returned for a USNO lookup outside the years its phase data covers, either before making the
+// request or when the API answers with no phases at all
+type ErrOutsideCoverage struct {
+	// the first day the lookup asked for phases from
+	Day DayKey
+}
+
+func (e *ErrOutsideCoverage) Error() string {
+	return fmt.Sprintf("USNO data only available between %d and %d, asked for phases from %s", USNOFirstYear, USNOLastYear, e.Day)
+}
+
+// returned when the API answers with its error payload instead of phase data, which it
+// sometimes does with a 200 status
+type ErrUpstreamRejected struct {
+	// HTTP status of the response
+	Status int
+	// the API's own message, verbatim
+	Message string
+}
+
+func (e *ErrUpstreamRejected) Error() string {
+	message := fmt.Sprintf("USNO API rejected the request (status %d): %s", e.Status, e.Message)
+	if hint := e.Hint(); hint != "" {
+		message = fmt.Sprintf("%s\n%s", message, hint)
+	}
+	return message
+}
+
+// returns a friendlier explanation for the common rejections, or "" when there isn't one
+func (e *ErrUpstreamRejected) Hint() string {
+	message := strings.ToLower(e.Message)
+	switch {
+	case strings.Contains(message, "nump") || strings.Contains(message, "number of phases"):
+		return fmt.Sprintf("hint: the API returns between 1 and %d phases per request", maxNumPhases)
+	case strings.Contains(message, "date") || strings.Contains(message, "year"):
+		return fmt.Sprintf("hint: USNO phase data is only available between %d and %d", USNOFirstYear, USNOLastYear)
+	}
+	return ""
+}
+
+// the API's error shape, {"error": "..."}
+type upstreamErrorBody struct {
+	Error *string `json:"error"`
+}
+
+// returns an ErrUpstreamRejected if body is the API's error payload, checked before decoding
+// phase data so a rejection isn't mistaken for an empty result
+func checkUpstreamError(status int, body []byte) error {
+	var probe upstreamErrorBody
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil
+	}
+	if (probe.Error == nil) {
+		return nil
+	}
+	return &ErrUpstreamRejected{Status: status, Message: *probe.Error}
+}
+
+// returned when the API answers with an error status and no error payload, as when it's down
+// behind a proxy's own error page
+type ErrUpstreamStatus struct {
+	// HTTP status of the response
+	Status int
+}
+
+func (e *ErrUpstreamStatus) Error() string {
+	return fmt.Sprintf("USNO API answered with status %d %s", e.Status, http.StatusText(e.Status))
+}
+
+// the most characters of an HTML page's title an ErrMalformedResponse quotes
+const maxQuotedTitle int = 80
+
+// decodes a response body into v, returning an ErrMalformedResponse when it isn't JSON. an HTML
+// page, as proxies and maintenance notices serve, is reported as one with its title rather than
+// as a stray '<'
+func decodeResponse(body []byte, v interface{}) error {
+	err := json.Unmarshal(body, v)
+	if (err == nil) {
+		return nil
+	}
+	trimmed := bytes.TrimSpace(body)
+	if (len(trimmed) > 0 && trimmed[0] == '<') {
+		return &ErrMalformedResponse{Err: fmt.Errorf("got an HTML page instead of JSON%s", htmlTitle(trimmed))}
+	}
+	return &ErrMalformedResponse{Err: err}
+}
+
+// returns ": " and the text of an HTML page's title element, or "" when it hasn't one
+func htmlTitle(page []byte) string {
+	lower := bytes.ToLower(page)
+	start := bytes.Index(lower, []byte("<title>"))
+	if (start < 0) {
+		return ""
+	}
+	start += len("<title>")
+	end := bytes.Index(lower[start:], []byte("</title>"))
+	if (end < 0) {
+		return ""
+	}
+	title := strings.Join(strings.Fields(string(page[start:start + end])), " ")
+	if (title == "") {
+		return ""
+	}
+	if runes := []rune(title); (len(runes) > maxQuotedTitle) {
+		title = string(runes[:maxQuotedTitle]) + "..."
+	}
+	return ": " + title
+}
+
+// returned when the API's answer, or a FileProvider's saved copy of one, isn't phase data the
+// package can use, either not decoding at all or holding a time or phase name that doesn't parse
+type ErrMalformedResponse struct {
+	Err error
+}
+
+func (e *ErrMalformedResponse) Error() string {
+	return fmt.Sprintf("malformed USNO API response: %v", e.Err)
+}
+
+func (e *ErrMalformedResponse) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,155 @@
+package moonphase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+)
+
+// returns a server answering every request with status and body, as contentType
+func newAnsweringServer(t *testing.T, status int, contentType string, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// a proxy's or maintenance page's HTML is a malformed response naming the page, not a JSON error
+func TestHTMLResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   string
+	}{
+		{"200", http.StatusOK, "got an HTML page instead of JSON: Scheduled Maintenance"},
+		{"502", http.StatusBadGateway, "status 502 Bad Gateway"},
+	}
+	page := "<!DOCTYPE html>\n<html><head><title>\n  Scheduled   Maintenance\n</title></head><body>back soon</body></html>"
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newAnsweringServer(t, test.status, "text/html", page)
+			_, err := newRetryingClient(server.URL, 0).GetMoonData(context.Background(), retryDay, 4)
+			if (err == nil || !strings.Contains(err.Error(), test.want)) {
+				t.Fatalf("got %v, want an error with %q", err, test.want)
+			}
+			var malformed *moonphase.ErrMalformedResponse
+			var status *moonphase.ErrUpstreamStatus
+			if (test.status == http.StatusOK && !errors.As(err, &malformed)) {
+				t.Errorf("got %T, want an ErrMalformedResponse", err)
+			}
+			if (test.status != http.StatusOK && (!errors.As(err, &status) || status.Status != test.status)) {
+				t.Errorf("got %T, want an ErrUpstreamStatus", err)
+			}
+		})
+	}
+	// a long title is cut short
+	server := newAnsweringServer(t, http.StatusOK, "text/html", "<html><title>" + strings.Repeat("x", 200) + "</title></html>")
+	_, err := newRetryingClient(server.URL, 0).GetMoonData(context.Background(), retryDay, 4)
+	if (err == nil || !strings.Contains(err.Error(), strings.Repeat("x", 80) + "...") || strings.Contains(err.Error(), strings.Repeat("x", 81))) {
+		t.Errorf("got %v, want the title cut at 80 characters", err)
+	}
+}
+
+// the API's {"error": ...} payload is an ErrUpstreamRejected with its message verbatim, whatever
+// the status it came with, and a hint for the rejections there's one for
+func TestErrorJSON(t *testing.T) {
+	tests := []struct {
+		status  int
+		message string
+		hint    string
+	}{
+		{http.StatusOK, "Invalid date: the date must be between 1700 and 2100", "1700 and 2100"},
+		{http.StatusBadRequest, "nump must be between 1 and 99", "between 1 and 99 phases"},
+		{http.StatusBadRequest, "something else went wrong", ""},
+	}
+	for _, test := range tests {
+		server := newAnsweringServer(t, test.status, "application/json", fmt.Sprintf(`{"error": %q}`, test.message))
+		_, err := newRetryingClient(server.URL, 0).GetMoonData(context.Background(), retryDay, 4)
+		var rejected *moonphase.ErrUpstreamRejected
+		if (!errors.As(err, &rejected)) {
+			t.Fatalf("%d %q: got %v, want an ErrUpstreamRejected", test.status, test.message, err)
+		}
+		if (rejected.Status != test.status || rejected.Message != test.message) {
+			t.Errorf("got status %d message %q, want %d %q", rejected.Status, rejected.Message, test.status, test.message)
+		}
+		if ((test.hint == "" && rejected.Hint() != "") || (test.hint != "" && !strings.Contains(rejected.Hint(), test.hint))) {
+			t.Errorf("%q: hint %q, want one with %q", test.message, rejected.Hint(), test.hint)
+		}
+	}
+	// JSON that's neither phase data nor the error shape is malformed
+	server := newAnsweringServer(t, http.StatusOK, "application/json", `{"phasedata": "soon"}`)
+	_, err := newRetryingClient(server.URL, 0).GetMoonData(context.Background(), retryDay, 4)
+	var malformed *moonphase.ErrMalformedResponse
+	if (!errors.As(err, &malformed)) {
+		t.Errorf("got %v, want an ErrMalformedResponse", err)
+	}
+}
+
+// a 429 is retried once its Retry-After has passed, in seconds or as a date, and every request
+// says who's making it
+func TestRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter func() string
+	}{
+		{"seconds", func() string { return "1" }},
+		{"date", func() string { return time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int64
+			server := newFlakyServer(t, 1, http.StatusTooManyRequests, test.retryAfter(), &requests)
+			start := time.Now()
+			phases, err := newRetryingClient(server.URL, 1).GetMoonData(context.Background(), retryDay, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(phases) != 4 || atomic.LoadInt64(&requests) != 2) {
+				t.Errorf("got %d phases after %d requests, want 4 after 2", len(phases), atomic.LoadInt64(&requests))
+			}
+			// an HTTP date only has whole seconds, so it can be up to a second short
+			if elapsed := time.Since(start); (elapsed < 900 * time.Millisecond) {
+				t.Errorf("retried after %s, before the Retry-After", elapsed)
+			}
+		})
+	}
+	// without retries left the 429 is the error
+	var requests int64
+	server := newFlakyServer(t, 1, http.StatusTooManyRequests, "1", &requests)
+	_, err := newRetryingClient(server.URL, 0).GetMoonData(context.Background(), retryDay, 4)
+	var status *moonphase.ErrUpstreamStatus
+	if (!errors.As(err, &status) || status.Status != http.StatusTooManyRequests) {
+		t.Errorf("got %v, want a 429 ErrUpstreamStatus", err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var got atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("User-Agent"))
+		http.Error(w, "no", http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := newRetryingClient(server.URL, 0)
+	client.GetMoonData(context.Background(), retryDay, 4)
+	if (got.Load() != moonphase.DefaultUserAgent) {
+		t.Errorf("User-Agent %q, want %q", got.Load(), moonphase.DefaultUserAgent)
+	}
+	client.UserAgent = "my-status-bar/1.0"
+	client.GetMoonData(context.Background(), retryDay, 4)
+	if (got.Load() != "my-status-bar/1.0") {
+		t.Errorf("User-Agent %q, want the client's own", got.Load())
+	}
+}
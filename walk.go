@@ -0,0 +1,233 @@
+package moonphase
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// a year query is used once a range covers more than this many days of the year
+const yearQueryMinDays int = 182
+
+// pages through the API, or c.Source when set, from start, calling emit for every phase event until emit returns false,
+// the API runs out of data, or the pages pass end. years that the range covers most of are
+// fetched with a single year query, anything else is paged with date queries. only one page is
+// held at a time, so long ranges don't build up in memory
+func (c *Client) WalkMoonData(ctx context.Context, start time.Time, end time.Time, emit func(MoonPhase) bool) error {
+	pageStart := NewDayKey(start)
+	endDay := NewDayKey(end)
+	for (!pageStart.After(endDay)) {
+		// checked between pages too, for sources that never block on a request
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var phases []MoonPhase
+		var nextStart DayKey
+		var err error
+		overlap := yearQueryOverlap(pageStart, endDay)
+		// only the API has the year form
+		if (c.Source == nil && overlap > yearQueryMinDays) {
+			phases, err = c.GetMoonDataForYear(ctx, pageStart.Year)
+			nextStart = DayKey{pageStart.Year + 1, time.January, 1}
+		} else {
+			// only ask for the phases that can fall in what's left of this year
+			numPhases := overlap / minPhaseIntervalDays + 1
+			if (numPhases > maxNumPhases) {
+				numPhases = maxNumPhases
+			}
+			phases, err = c.source().GetMoonData(ctx, pageStart, numPhases)
+			if (len(phases) > 0) {
+				// no two primary phases share a day, so the next page can start the day after the last event
+				nextStart = NewDayKey(phaseInstant(phases[len(phases) - 1])).AddDays(1)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if (len(phases) == 0) {
+			return nil
+		}
+		for _, phase := range phases {
+			// a year query can reach back before the page start
+			if (NewDayKey(phaseInstant(phase)).Before(pageStart)) {
+				continue
+			}
+			if (!emit(phase)) {
+				return nil
+			}
+		}
+		pageStart = nextStart
+	}
+	return nil
+}
+
+// calls emit for every phase event with an instant in the half-open range [start, end), until emit
+// returns false. ranges of instants are half-open throughout this package, callers working in
+// inclusive calendar days pass the start of the day after their last one as end
+func (c *Client) WalkPhasesBetween(ctx context.Context, start time.Time, end time.Time, emit func(MoonPhase) bool) error {
+	// the API pages by UT date, so walk the UT days the instants fall on
+	return c.WalkMoonData(ctx, start.UTC(), end.UTC(), func(phase MoonPhase) bool {
+		instant := phaseInstant(phase)
+		if (instant.Before(start)) {
+			return true
+		}
+		if (!instant.Before(end)) {
+			return false
+		}
+		return emit(phase)
+	})
+}
+
+// returns every phase event with an instant in the half-open range [start, end)
+func (c *Client) PhasesBetween(ctx context.Context, start time.Time, end time.Time) ([]MoonPhase, error) {
+	var phases []MoonPhase
+	err := c.WalkPhasesBetween(ctx, start, end, func(phase MoonPhase) bool {
+		phases = append(phases, phase)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return phases, nil
+}
+
+// returns how many days of pageStart's calendar year fall between pageStart and end
+func yearQueryOverlap(pageStart DayKey, end DayKey) int {
+	yearEnd := DayKey{pageStart.Year, time.December, 31}
+	if (end.Before(yearEnd)) {
+		yearEnd = end
+	}
+	return int(yearEnd.Time(time.UTC).Sub(pageStart.Time(time.UTC)).Hours() / 24) + 1
+}
+
+// calls emit with the classified phase of every calendar day from start to end inclusive, in
+// start's location, until emit returns false. the phase data is walked once, holding only the
+// phases either side of the current day
+func (c *Client) WalkDailyPhases(ctx context.Context, start time.Time, end time.Time, strategy ClassifyStrategy, emit func(time.Time, Phase) bool) error {
+	return c.walkDays(ctx, start, end, strategy, 1, func(day time.Time, phase Phase, recentData []MoonPhase) bool {
+		return emit(day, phase)
+	})
+}
+
+// calls emit with the classified phase of every calendar day from start to end inclusive, in
+// start's location, along with the moon's illumination and age at the start of the day, until
+// emit returns false. like WalkDailyPhases the phase data is walked once
+func (c *Client) WalkDailyDetails(ctx context.Context, start time.Time, end time.Time, strategy ClassifyStrategy, emit func(DailyDetail) bool) error {
+	var detailErr error
+	// four phases back always holds the New Moon the age counts from
+	err := c.walkDays(ctx, start, end, strategy, 4, func(day time.Time, phase Phase, recentData []MoonPhase) bool {
+		segment, err := GetCycleSegment(day, recentData)
+		if err != nil {
+			detailErr = err
+			return false
+		}
+		age, err := GetMoonAge(day, recentData)
+		if err != nil {
+			detailErr = err
+			return false
+		}
+		return emit(DailyDetail{
+			DailyPhase:   DailyPhase{Date: NewDayKey(day), Phase: phase},
+			Illumination: SegmentIllumination(segment),
+			Age:          age,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return detailErr
+}
+
+// walks the phase data from past phases before start, calling emit for every day from start to
+// end with its phase and at least the past phases before it and one after
+func (c *Client) walkDays(ctx context.Context, start time.Time, end time.Time, strategy ClassifyStrategy, past int, emit func(time.Time, Phase, []MoonPhase) bool) error {
+	location := start.Location()
+	day := NewDayKey(start).Time(location)
+	end = NewDayKey(end).Time(location)
+	var recentData []MoonPhase
+	var classifyErr error
+	// back up far enough that the first day always has past phases before it
+	startDay, _ := FetchWindow(day, FetchStrategy{Past: past})
+	// and far enough past end that the last day has a phase after it
+	walkEnd := end.AddDate(0, 0, maxPhaseIntervalDays + 1)
+	err := c.WalkMoonData(ctx, startDay.Time(location), walkEnd, func(phase MoonPhase) bool {
+		recentData = append(recentData, phase)
+		// classify every day that is now bracketed by the data seen so far
+		for (!day.After(end) && getPhaseDate(phase, location).After(day)) {
+			// a page can hold months of days, so a cancel is noticed between days as well as pages
+			if err := ctx.Err(); err != nil {
+				classifyErr = err
+				return false
+			}
+			dayPhase, err := GetCurrentPhase(day, recentData, strategy)
+			if err != nil {
+				classifyErr = err
+				return false
+			}
+			if (!emit(day, dayPhase, recentData)) {
+				return false
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+		// only the last past phases and the one after them are needed for the days that follow
+		if (len(recentData) > past + 1) {
+			recentData = recentData[len(recentData) - (past + 1):]
+		}
+		return !day.After(end)
+	})
+	if err != nil {
+		return err
+	}
+	return classifyErr
+}
+
+// the classified phase of one calendar day
+type DailyPhase struct {
+	Date  DayKey
+	Phase Phase
+}
+
+// the classified phase of one calendar day with the moon at the start of the day
+type DailyDetail struct {
+	DailyPhase
+	// the approximate illuminated fraction from 0 to 1, see SegmentIllumination
+	Illumination float64
+	// days since the last New Moon, see GetMoonAge
+	Age float64
+}
+
+// returns the classified phase of every calendar day from from to to inclusive, in from's
+// location whatever to's is, using the phase data from a single request
+func (c *Client) GetPhasesForRange(ctx context.Context, from time.Time, to time.Time, strategy ClassifyStrategy) ([]DailyPhase, error) {
+	location := from.Location()
+	firstDay := NewDayKey(from)
+	lastDay := NewDayKey(to.In(location))
+	if (lastDay.Before(firstDay)) {
+		return nil, fmt.Errorf("range ends on %s, before it starts on %s", lastDay, firstDay)
+	}
+	startDay, _ := FetchWindow(firstDay.Time(location), FetchStrategy{})
+	// enough phases to reach from startDay past the last day, which needs a phase after it too
+	spanDays := int(lastDay.Time(time.UTC).Sub(startDay.Time(time.UTC)).Hours() / 24) + maxPhaseIntervalDays + 1
+	numPhases := spanDays / minPhaseIntervalDays + 1
+	if (numPhases > maxNumPhases) {
+		return nil, fmt.Errorf("%s to %s needs more phases than the API returns in one request, export -granularity daily handles long ranges", firstDay, lastDay)
+	}
+	recentData, _, err := c.getMoonDataFromChain(ctx, startDay, numPhases)
+	if err != nil {
+		return nil, err
+	}
+	var days []DailyPhase
+	for day := firstDay; !day.After(lastDay); day = day.AddDays(1) {
+		phase, err := GetCurrentPhase(day.Time(location), recentData, strategy)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, DailyPhase{Date: day, Phase: phase})
+	}
+	return days, nil
+}
+
+// returns the phase of every calendar day from from to to inclusive, in from's location, using DefaultClient
+func GetPhasesForRange(ctx context.Context, from time.Time, to time.Time) ([]DailyPhase, error) {
+	return DefaultClient.GetPhasesForRange(ctx, from, to, SnapStrategy)
+}
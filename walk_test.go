@@ -0,0 +1,117 @@
+package moonphase_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// returns a client answering from the reference year alone
+func newReferenceClient() *moonphase.Client {
+	return &moonphase.Client{Source: moonphasetest.NewFixtureProvider(moonphasetest.ReferenceEvents()...)}
+}
+
+// each day of the walk has what the single day lookups give for its start
+func TestWalkDailyDetailsMatchesLookups(t *testing.T) {
+	client := newReferenceClient()
+	ctx := context.Background()
+	location := loadLocation(t, "America/Los_Angeles")
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, location)
+	end := time.Date(2024, time.March, 31, 0, 0, 0, 0, location)
+	days := 0
+	err := client.WalkDailyDetails(ctx, start, end, moonphase.SnapStrategy, func(detail moonphase.DailyDetail) bool {
+		dayStart := detail.Date.Time(location)
+		if want := moonphase.NewDayKey(start).AddDays(days); (detail.Date != want) {
+			t.Fatalf("day %d is %s, want %s", days, detail.Date, want)
+		}
+		days++
+		phase, err := client.GetPhaseForDate(ctx, dayStart, moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		illumination, err := client.GetIllumination(ctx, dayStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		age, err := client.GetAge(ctx, dayStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (detail.Phase != phase || math.Abs(detail.Illumination - illumination) > 1e-9 || math.Abs(detail.Age - age) > 1e-9) {
+			t.Errorf("%s: walked %s %.3f %.3f, looked up %s %.3f %.3f", detail.Date, detail.Phase, detail.Illumination, detail.Age, phase, illumination, age)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (days != 91) {
+		t.Errorf("walked %d days, want 91", days)
+	}
+}
+
+// the range runs from the day of from to the day to falls on in from's location, both included
+// whatever the time of day, and each day has the phase a lookup of it alone gives
+func TestGetPhasesForRangeBounds(t *testing.T) {
+	client := newReferenceClient()
+	ctx := context.Background()
+	losAngeles := loadLocation(t, "America/Los_Angeles")
+	tests := []struct {
+		name  string
+		from  time.Time
+		to    time.Time
+		first string
+		last  string
+		days  int
+	}{
+		{"one day", time.Date(2024, time.March, 25, 0, 0, 0, 0, losAngeles), time.Date(2024, time.March, 25, 0, 0, 0, 0, losAngeles), "2024-03-25", "2024-03-25", 1},
+		{"later the same day", time.Date(2024, time.March, 25, 18, 0, 0, 0, losAngeles), time.Date(2024, time.March, 25, 6, 0, 0, 0, losAngeles), "2024-03-25", "2024-03-25", 1},
+		{"a month", time.Date(2024, time.March, 1, 0, 0, 0, 0, losAngeles), time.Date(2024, time.March, 31, 23, 59, 0, 0, losAngeles), "2024-03-01", "2024-03-31", 31},
+		// the evening of March 31st in Los Angeles is already April 1st in UT
+		{"to in another zone", time.Date(2024, time.March, 1, 0, 0, 0, 0, losAngeles), time.Date(2024, time.April, 1, 3, 0, 0, 0, time.UTC), "2024-03-01", "2024-03-31", 31},
+		{"a year", time.Date(2024, time.January, 1, 0, 0, 0, 0, losAngeles), time.Date(2024, time.December, 31, 0, 0, 0, 0, losAngeles), "2024-01-01", "2024-12-31", 366},
+	}
+	for _, test := range tests {
+		days, err := client.GetPhasesForRange(ctx, test.from, test.to, moonphase.SnapStrategy)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if (len(days) != test.days || days[0].Date.String() != test.first || days[len(days) - 1].Date.String() != test.last) {
+			t.Errorf("%s: got %d days from %s to %s, want %d from %s to %s", test.name, len(days), days[0].Date, days[len(days) - 1].Date, test.days, test.first, test.last)
+			continue
+		}
+		for _, day := range []moonphase.DailyPhase{days[0], days[len(days) - 1]} {
+			want, err := client.GetPhaseForDate(ctx, day.Date.Time(losAngeles), moonphase.SnapStrategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (day.Phase != want) {
+				t.Errorf("%s: %s is %s in the range, %s alone", test.name, day.Date, day.Phase, want)
+			}
+		}
+	}
+}
+
+// a range ending the day before it starts, or longer than one request can cover, is an error
+func TestGetPhasesForRangeOutOfBounds(t *testing.T) {
+	client := newReferenceClient()
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want string
+	}{
+		{"backwards", time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 24, 23, 59, 0, 0, time.UTC), "before it starts"},
+		{"two years", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC), "export -granularity daily"},
+	}
+	for _, test := range tests {
+		if _, err := client.GetPhasesForRange(context.Background(), test.from, test.to, moonphase.SnapStrategy); (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package moonphase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	moonphase "github.com/mitchthorson/go-moon-phase"
+	"github.com/mitchthorson/go-moon-phase/moonphasetest"
+)
+
+// a New Moon at 23:40 on December 31st in New York is already January 1st in UT, it still belongs
+// to the old year's last days
+func TestNewMoonLateOnNewYearsEve(t *testing.T) {
+	phases := moonphasetest.MoonPhases(
+		moonphasetest.Event(moonphase.FirstQuarter, "2024-12-08 15:27"),
+		moonphasetest.Event(moonphase.FullMoon, "2024-12-16 09:02"),
+		moonphasetest.Event(moonphase.LastQuarter, "2024-12-23 22:18"),
+		moonphasetest.Event(moonphase.NewMoon, "2025-01-01 04:40"),
+		moonphasetest.Event(moonphase.FirstQuarter, "2025-01-07 23:56"),
+	)
+	location := loadLocation(t, "America/New_York")
+	checkDays(t, classifyDays(t, phases, location, "2024-12-28", "2025-01-03"), map[string]moonphase.Phase{
+		"2024-12-29": moonphase.WaningCrescent,
+		"2024-12-30": moonphase.NewMoon,
+		"2024-12-31": moonphase.NewMoon,
+		"2025-01-01": moonphase.NewMoon,
+		"2025-01-02": moonphase.WaxingCrescent,
+	})
+	// the UT day is the next year, which is where it is in UTC
+	checkDays(t, classifyDays(t, phases, time.UTC, "2024-12-28", "2025-01-03"), map[string]moonphase.Phase{
+		"2024-12-30": moonphase.WaningCrescent,
+		"2024-12-31": moonphase.NewMoon,
+		"2025-01-02": moonphase.NewMoon,
+		"2025-01-03": moonphase.WaxingCrescent,
+	})
+}
+
+// the recorded New Moon of 2024-12-30 22:27 UT is on December 30th in the Americas and on the
+// 31st in Tokyo, each year's phases count it once between them, and the days either side of
+// New Year are classified from data reaching into 2025
+func TestYearBoundaryByZone(t *testing.T) {
+	tests := []struct {
+		zone    string
+		newMoon string
+		want    map[string]moonphase.Phase
+	}{
+		{
+			zone:    "UTC",
+			newMoon: "2024-12-30",
+			want: map[string]moonphase.Phase{
+				"2024-12-28": moonphase.WaningCrescent,
+				"2024-12-29": moonphase.NewMoon,
+				"2024-12-31": moonphase.NewMoon,
+				"2025-01-01": moonphase.WaxingCrescent,
+			},
+		},
+		{
+			zone:    "America/Los_Angeles",
+			newMoon: "2024-12-30",
+			want: map[string]moonphase.Phase{
+				"2024-12-28": moonphase.WaningCrescent,
+				"2024-12-29": moonphase.NewMoon,
+				"2024-12-31": moonphase.NewMoon,
+				"2025-01-01": moonphase.WaxingCrescent,
+			},
+		},
+		{
+			zone:    "Asia/Tokyo",
+			newMoon: "2024-12-31",
+			want: map[string]moonphase.Phase{
+				"2024-12-29": moonphase.WaningCrescent,
+				"2024-12-30": moonphase.NewMoon,
+				"2024-12-31": moonphase.NewMoon,
+				"2025-01-01": moonphase.NewMoon,
+				"2025-01-02": moonphase.WaxingCrescent,
+			},
+		},
+	}
+	client := newReferenceClient()
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.zone, func(t *testing.T) {
+			location := loadLocation(t, test.zone)
+			for day, want := range test.want {
+				date, err := moonphase.ParseDayKey(day)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := client.GetPhaseForDate(ctx, date.Time(location), moonphase.SnapStrategy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (got != want) {
+					t.Errorf("%s: got %s, want %s", day, got, want)
+				}
+			}
+
+			newYear := time.Date(2025, time.January, 1, 0, 0, 0, 0, location)
+			year2024, err := client.PhasesBetween(ctx, time.Date(2024, time.January, 1, 0, 0, 0, 0, location), newYear)
+			if err != nil {
+				t.Fatal(err)
+			}
+			year2025, err := client.PhasesBetween(ctx, newYear, time.Date(2025, time.January, 20, 0, 0, 0, 0, location))
+			if err != nil {
+				t.Fatal(err)
+			}
+			last, err := moonphase.GetPhaseInstant(year2024[len(year2024) - 1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (moonphase.Phase(year2024[len(year2024) - 1].Phase) != moonphase.NewMoon || moonphase.NewDayKey(last.In(location)).String() != test.newMoon) {
+				t.Errorf("2024 ends with the %s of %s, want the New Moon of %s", year2024[len(year2024) - 1].Phase, last.In(location), test.newMoon)
+			}
+			if (moonphase.Phase(year2025[0].Phase) != moonphase.FirstQuarter) {
+				t.Errorf("2025 starts with the %s of %d-%02d-%02d, want the First Quarter", year2025[0].Phase, year2025[0].Year, year2025[0].Month, year2025[0].Day)
+			}
+			newMoons := 0
+			for _, phase := range append(year2024, year2025...) {
+				if (moonphase.Phase(phase.Phase) == moonphase.NewMoon) {
+					newMoons++
+				}
+			}
+			// January 11th to December 30th, and none in 2025 before the 20th
+			if (newMoons != 13) {
+				t.Errorf("counted %d New Moons, want 13", newMoons)
+			}
+		})
+	}
+}
+
+// a cached response is found from either side of New Year, though January's lookups read another
+// year's partition
+func TestCacheAcrossNewYear(t *testing.T) {
+	server := moonphasetest.RecordedUSNOServer(t, moonphasetest.WriteFixtureFile(t, moonphasetest.ReferenceEvents()...))
+	client := newCachingClient(t, server.URL)
+	location := loadLocation(t, "America/Los_Angeles")
+	for _, day := range []time.Time{
+		time.Date(2024, time.December, 31, 0, 0, 0, 0, location),
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, location),
+		time.Date(2025, time.January, 2, 0, 0, 0, 0, location),
+	} {
+		if _, err := client.GetPhaseForDate(context.Background(), day, moonphase.SnapStrategy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if (server.Requests() != 1) {
+		t.Errorf("made %d requests, want 1", server.Requests())
+	}
+}